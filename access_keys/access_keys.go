@@ -0,0 +1,124 @@
+package access_keys
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Config holds the key lifecycle settings
+type Config struct {
+	// MaxAgeDays is the age after which a key is due for rotation
+	MaxAgeDays int `json:"max_age_days"`
+	// ValidityDays sets the expiry date of newly recorded keys, 0 for none
+	ValidityDays int `json:"validity_days"`
+}
+
+var config = Config{MaxAgeDays: 180, ValidityDays: 365}
+
+// LoadConfig reads the key lifecycle settings from a JSON config file
+func LoadConfig(configPath string) error {
+	file, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read access key config: %v", err)
+	}
+
+	cfg := config
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return fmt.Errorf("failed to parse access key config: %v", err)
+	}
+	if cfg.MaxAgeDays <= 0 {
+		return fmt.Errorf("max_age_days must be positive")
+	}
+	if cfg.ValidityDays < 0 {
+		return fmt.Errorf("validity_days cannot be negative")
+	}
+
+	config = cfg
+	return nil
+}
+
+// MaxAgeDays returns the configured rotation age
+func MaxAgeDays() int {
+	return config.MaxAgeDays
+}
+
+// ValidityDays returns the configured key validity
+func ValidityDays() int {
+	return config.ValidityDays
+}
+
+var (
+	accessKeyField   = regexp.MustCompile(`"access_key"\s*:\s*"([^"]+)"`)
+	accessKeyPattern = regexp.MustCompile(`^[A-Z0-9]{16,128}$`)
+)
+
+// ExtractAccessKeys returns the access key IDs found in radosgw-admin output
+// or in a plain list of key IDs. Secret keys are never returned: only the
+// access_key fields are read from command output, and plain values that do
+// not look like access key IDs are rejected.
+func ExtractAccessKeys(text string) ([]string, error) {
+	var keys []string
+	seen := map[string]bool{}
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	if matches := accessKeyField.FindAllStringSubmatch(text, -1); len(matches) > 0 {
+		for _, match := range matches {
+			add(match[1])
+		}
+		return keys, nil
+	}
+
+	for _, key := range strings.Fields(text) {
+		if !accessKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("'%s' is not an access key ID", mask(key))
+		}
+		add(key)
+	}
+	return keys, nil
+}
+
+// mask hides most of a value that may be a secret
+func mask(value string) string {
+	if len(value) <= 4 {
+		return "****"
+	}
+	return value[:4] + strings.Repeat("*", 4)
+}
+
+// SplitIssuedKeys tells the key created by "key create" apart from the keys
+// the user had before. The command lists every key of the user, so the new
+// key is the one of the output not among previous; more than one such key
+// cannot be told apart. existing returns previous without duplicates.
+func SplitIssuedKeys(output, previous []string) (issued string, existing []string, err error) {
+	known := map[string]bool{}
+	for _, key := range previous {
+		if !known[key] {
+			known[key] = true
+			existing = append(existing, key)
+		}
+	}
+
+	var unknown []string
+	for _, key := range output {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	switch len(unknown) {
+	case 0:
+		return "", nil, fmt.Errorf("no new access key in the command output, all %d keys were issued before", len(output))
+	case 1:
+		return unknown[0], existing, nil
+	default:
+		return "", nil, fmt.Errorf("the command output lists %d keys that are not recorded for the user: "+
+			"list the keys the user had before the new one was created", len(unknown))
+	}
+}
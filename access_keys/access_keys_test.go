@@ -0,0 +1,68 @@
+package access_keys
+
+import (
+	"reflect"
+	"testing"
+)
+
+const keyCreateOutput = `{
+    "user_id": "ten-acme$ten-acme-app",
+    "display_name": "SRT-1234",
+    "keys": [
+        {
+            "user": "ten-acme$ten-acme-app",
+            "access_key": "OLDKEY0000000001",
+            "secret_key": "old-secret"
+        },
+        {
+            "user": "ten-acme$ten-acme-app",
+            "access_key": "NEWKEY0000000002",
+            "secret_key": "new-secret"
+        }
+    ]
+}`
+
+func TestExtractAccessKeys(t *testing.T) {
+	keys, err := ExtractAccessKeys(keyCreateOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"OLDKEY0000000001", "NEWKEY0000000002"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+
+	if _, err := ExtractAccessKeys("OLDKEY0000000001 new-secret"); err == nil {
+		t.Error("a secret key was accepted as a key ID")
+	}
+}
+
+func TestSplitIssuedKeys(t *testing.T) {
+	output, _ := ExtractAccessKeys(keyCreateOutput)
+
+	issued, existing, err := SplitIssuedKeys(output, []string{"OLDKEY0000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued != "NEWKEY0000000002" || !reflect.DeepEqual(existing, []string{"OLDKEY0000000001"}) {
+		t.Errorf("got %s, %v", issued, existing)
+	}
+
+	// A user created before keys were tracked: the old key is only known
+	// from the list of keys before
+	if _, _, err := SplitIssuedKeys(output, nil); err == nil {
+		t.Error("two unknown keys were accepted")
+	}
+
+	// A recorded key that is no longer on the cluster is still pre-existing
+	issued, existing, err = SplitIssuedKeys([]string{"NEWKEY0000000002"}, []string{"GONEKEY000000003"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued != "NEWKEY0000000002" || !reflect.DeepEqual(existing, []string{"GONEKEY000000003"}) {
+		t.Errorf("got %s, %v", issued, existing)
+	}
+
+	if _, _, err := SplitIssuedKeys(output, output); err == nil {
+		t.Error("output without a new key was accepted")
+	}
+}
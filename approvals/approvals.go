@@ -0,0 +1,197 @@
+package approvals
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// Operations that can require an approval
+const (
+	OpCreate        = "create"
+	OpDeactivate    = "deactivate"
+	OpQuotaDecrease = "quota_decrease"
+	OpQuotaToggle   = "quota_toggle"
+	OpBatchCreate   = "batch_create"
+)
+
+// Operations lists every operation that can require an approval
+var Operations = []string{OpCreate, OpDeactivate, OpQuotaDecrease, OpQuotaToggle, OpBatchCreate}
+
+// Anonymous is the actor of requests without a user from a trusted proxy
+const Anonymous = "anonymous"
+
+// Approval statuses
+const (
+	Pending  = "pending"
+	Approved = "approved"
+	Rejected = "rejected"
+	Executed = "executed"
+)
+
+// Labels are the operation and status names shown in the UI
+var Labels = map[string]string{
+	OpCreate:        "Создание ресурсов",
+	OpDeactivate:    "Удаление ресурсов",
+	OpQuotaDecrease: "Уменьшение квот",
+	OpQuotaToggle:   "Включение и выключение квот",
+	OpBatchCreate:   "Пакетное создание тенантов",
+	Pending:         "Ожидает согласования",
+	Approved:        "Согласована",
+	Rejected:        "Отклонена",
+	Executed:        "Выполнена",
+}
+
+// Config holds the approval rules
+type Config struct {
+	// Enabled turns on approvals, otherwise every change runs immediately
+	Enabled bool `json:"enabled"`
+	// Approvers are the users allowed to approve, as passed by the reverse
+	// proxy in X-Remote-User
+	Approvers []string `json:"approvers"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse proxies
+	// whose X-Remote-User header is trusted. It ships empty: list only the
+	// proxy, as any process that can reach a listed address can claim to be
+	// any user.
+	TrustedProxies []string `json:"trusted_proxies"`
+	// Environments where creating tenants, buckets or users needs an approval
+	Environments []string `json:"environments"`
+	// Deactivation, QuotaDecrease and QuotaToggle require approvals in every
+	// environment
+	Deactivation  bool `json:"deactivation"`
+	QuotaDecrease bool `json:"quota_decrease"`
+	QuotaToggle   bool `json:"quota_toggle"`
+}
+
+var config Config
+
+var trustedProxies []netip.Prefix
+
+// LoadConfig reads the approval rules from a JSON config file
+func LoadConfig(configPath string) error {
+	file, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read approvals config: %v", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return fmt.Errorf("failed to parse approvals config: %v", err)
+	}
+	if cfg.Enabled && len(cfg.Approvers) == 0 {
+		return fmt.Errorf("approvers are required when approvals are enabled")
+	}
+	// The app listens on every interface, so no address is trusted until
+	// the operator lists the proxy. Until then every user is anonymous and
+	// changes needing an approval wait for it.
+	if cfg.Enabled && len(cfg.TrustedProxies) == 0 {
+		slog.Warn("approvals are enabled but trusted_proxies is empty, nobody can review changes until the reverse proxy is listed")
+	}
+
+	var proxies []netip.Prefix
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, err := parseProxy(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %v", proxy, err)
+		}
+		proxies = append(proxies, prefix)
+	}
+
+	config = cfg
+	trustedProxies = proxies
+	return nil
+}
+
+// parseProxy accepts an address or a CIDR range
+func parseProxy(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		return netip.ParsePrefix(proxy)
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Actor returns the user authenticated by the reverse proxy, or Anonymous
+// when the request did not come from a trusted proxy
+func Actor(r *http.Request) string {
+	user := strings.TrimSpace(r.Header.Get("X-Remote-User"))
+	if user == "" {
+		return Anonymous
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return Anonymous
+	}
+	addr = addr.Unmap()
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(addr) {
+			return user
+		}
+	}
+	return Anonymous
+}
+
+// Required reports whether an operation in env needs an approval
+func Required(operation, env string) bool {
+	if !config.Enabled {
+		return false
+	}
+	switch operation {
+	case OpCreate, OpBatchCreate:
+		for _, e := range config.Environments {
+			if strings.EqualFold(e, env) {
+				return true
+			}
+		}
+	case OpDeactivate:
+		return config.Deactivation
+	case OpQuotaDecrease:
+		return config.QuotaDecrease
+	case OpQuotaToggle:
+		return config.QuotaToggle
+	}
+	return false
+}
+
+// CheckReviewer returns an error when user cannot review a request made by
+// requester. Nobody approves their own request.
+func CheckReviewer(user, requester string) error {
+	if err := CheckIdentified(user); err != nil {
+		return err
+	}
+	approver := false
+	for _, a := range config.Approvers {
+		if strings.EqualFold(a, user) {
+			approver = true
+			break
+		}
+	}
+	if !approver {
+		return fmt.Errorf("user %s is not an approver", user)
+	}
+	if strings.EqualFold(user, requester) {
+		return fmt.Errorf("requests cannot be approved by their author")
+	}
+	return nil
+}
+
+// CheckIdentified returns an error for users not authenticated by a trusted
+// proxy
+func CheckIdentified(user string) error {
+	if user == "" || user == Anonymous {
+		return fmt.Errorf("approvals need a user authenticated by the proxy")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package approvals
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loadConfig(t *testing.T, cfg string) error {
+	t.Helper()
+	saved, savedProxies := config, trustedProxies
+	t.Cleanup(func() { config, trustedProxies = saved, savedProxies })
+
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	if err := os.WriteFile(path, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(path)
+}
+
+func TestShippedConfig(t *testing.T) {
+	saved, savedProxies := config, trustedProxies
+	t.Cleanup(func() { config, trustedProxies = saved, savedProxies })
+	if err := LoadConfig("../approvals.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, operation := range Operations {
+		if !Required(operation, "PROD") {
+			t.Errorf("%s in PROD runs without an approval", operation)
+		}
+	}
+	if Required(OpCreate, "IFT") {
+		t.Error("creation in IFT needs an approval")
+	}
+
+	// No address is trusted until the operator lists the proxy
+	if len(trustedProxies) != 0 {
+		t.Errorf("shipped config trusts %v", trustedProxies)
+	}
+	request := httptest.NewRequest("POST", "/zayavki/approvals/review", nil)
+	request.RemoteAddr = "127.0.0.1:51000"
+	request.Header.Set("X-Remote-User", "s3-lead-1")
+	if actor := Actor(request); actor != Anonymous {
+		t.Errorf("shipped config trusts X-Remote-User from localhost: actor %q", actor)
+	}
+}
+
+func TestLoadConfigRejects(t *testing.T) {
+	for name, cfg := range map[string]string{
+		"no approvers":      `{"enabled": true, "trusted_proxies": ["127.0.0.1"]}`,
+		"invalid proxy":     `{"enabled": true, "approvers": ["lead"], "trusted_proxies": ["proxy.local"]}`,
+		"invalid proxy net": `{"trusted_proxies": ["10.0.0.0/33"]}`,
+	} {
+		if err := loadConfig(t, cfg); err == nil {
+			t.Errorf("%s: config accepted: %s", name, cfg)
+		}
+	}
+	if err := loadConfig(t, `{"enabled": false}`); err != nil {
+		t.Errorf("disabled approvals need no approvers: %v", err)
+	}
+}
+
+func TestActor(t *testing.T) {
+	if err := loadConfig(t, `{"enabled": true, "approvers": ["lead"], "trusted_proxies": ["10.1.0.0/16", "::1"]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr, user, want string
+	}{
+		{"10.1.2.3:51000", "ivanov", "ivanov"},
+		{"[::1]:51000", " ivanov ", "ivanov"},
+		{"[::ffff:10.1.2.3]:51000", "ivanov", "ivanov"},
+		{"10.2.0.1:51000", "ivanov", Anonymous},
+		{"10.1.2.3:51000", "", Anonymous},
+		{"unix", "ivanov", Anonymous},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/zayavki/approvals", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.user != "" {
+			r.Header.Set("X-Remote-User", tt.user)
+		}
+		if got := Actor(r); got != tt.want {
+			t.Errorf("Actor(%s, %q) = %q, want %q", tt.remoteAddr, tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestCheckReviewer(t *testing.T) {
+	if err := loadConfig(t, `{"enabled": true, "approvers": ["Lead", "anonymous"], "trusted_proxies": ["127.0.0.1"]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckReviewer("lead", "ivanov"); err != nil {
+		t.Errorf("approver rejected: %v", err)
+	}
+	for _, tt := range []struct{ user, requester string }{
+		{"lead", "LEAD"},
+		{"ivanov", "petrov"},
+		{Anonymous, "ivanov"},
+		{"", "ivanov"},
+	} {
+		if err := CheckReviewer(tt.user, tt.requester); err == nil {
+			t.Errorf("%q reviewed a request of %q", tt.user, tt.requester)
+		}
+	}
+}
+
+func TestRequired(t *testing.T) {
+	if err := loadConfig(t, `{"enabled": true, "approvers": ["lead"], "trusted_proxies": ["127.0.0.1"],
+		"environments": ["prod"], "quota_toggle": true}`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		operation, env string
+		want           bool
+	}{
+		{OpCreate, "PROD", true},
+		{OpBatchCreate, "PROD", true},
+		{OpCreate, "PREPROD", false},
+		{OpQuotaToggle, "", true},
+		{OpQuotaDecrease, "PROD", false},
+		{OpDeactivate, "PROD", false},
+		{"unknown", "PROD", false},
+	}
+	for _, tt := range tests {
+		if got := Required(tt.operation, tt.env); got != tt.want {
+			t.Errorf("Required(%s, %s) = %v, want %v", tt.operation, tt.env, got, tt.want)
+		}
+	}
+
+	config.Enabled = false
+	if Required(OpCreate, "PROD") {
+		t.Error("approval required with approvals disabled")
+	}
+}
@@ -0,0 +1,248 @@
+package batch_import
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Columns are the columns of a batch file, named like the fields of the new
+// tenant form. A row describes one tenant.
+var Columns = []string{
+	"request_id_sd", "request_id_srt", "segment", "env", "ris_number", "ris_name",
+	"resp_group", "owner", "zam_owner", "requester", "email_for_credentials",
+	"tenant_override", "users", "buckets", "user_quotas", "send_email", "email_lang", "cluster",
+}
+
+// RequiredColumns must be filled in every row
+var RequiredColumns = []string{
+	"request_id_sd", "request_id_srt", "segment", "env", "ris_number", "ris_name",
+	"resp_group", "owner", "requester", "email_for_credentials",
+}
+
+// Row is a tenant of a batch. Values are the raw form values accepted by
+// variables_parser.ParseAndProcessVariables, Cluster the cluster to use when
+// several clusters serve the segment and environment.
+type Row struct {
+	Line    int
+	Values  map[string][]string
+	Cluster string
+}
+
+// Parse reads the rows of a CSV or XLSX batch file. The first row holds the
+// column names, empty rows are skipped. Users are separated by spaces, commas
+// or semicolons, bucket and quota lines by new lines or semicolons.
+func Parse(fileName string, data []byte) ([]Row, error) {
+	var records [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		records, err = readCSV(data)
+	case ".xlsx":
+		records, err = readXLSX(data)
+	default:
+		return nil, fmt.Errorf("unsupported batch file type: %s (expected .csv or .xlsx)", fileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("batch file is empty")
+	}
+
+	known := map[string]bool{}
+	for _, column := range Columns {
+		known[column] = true
+	}
+	header := make([]string, len(records[0]))
+	seen := map[string]bool{}
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column: %s", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column: %s", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	for _, column := range RequiredColumns {
+		if !seen[column] {
+			return nil, fmt.Errorf("missing column: %s", column)
+		}
+	}
+
+	var rows []Row
+	for i, record := range records[1:] {
+		row := Row{Line: i + 2, Values: map[string][]string{"create_tenant": {"true"}}}
+		empty := true
+		for j, value := range record {
+			value = strings.TrimSpace(value)
+			if j >= len(header) || header[j] == "" || value == "" {
+				continue
+			}
+			empty = false
+			switch header[j] {
+			case "users":
+				value = strings.Join(strings.FieldsFunc(value, func(r rune) bool {
+					return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+				}), "\n")
+			case "buckets", "user_quotas":
+				value = strings.Join(strings.FieldsFunc(value, func(r rune) bool {
+					return r == ';' || r == '\n' || r == '\r'
+				}), "\n")
+			case "cluster":
+				row.Cluster = value
+				continue
+			}
+			row.Values[header[j]] = []string{value}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("batch file has no rows")
+	}
+	return rows, nil
+}
+
+// readCSV reads comma or semicolon separated values, whichever the header
+// line uses. Spreadsheets in the Russian locale save with semicolons.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	headerLine, _, _ := bytes.Cut(data, []byte("\n"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	if bytes.Count(headerLine, []byte(";")) > bytes.Count(headerLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV: %v", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// readXLSX reads the first sheet of a workbook
+func readXLSX(data []byte) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error opening Excel file: %v", err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		return nil, fmt.Errorf("error reading rows: %v", err)
+	}
+	return rows, nil
+}
+
+var (
+	emailPattern  = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+	userPattern   = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+	bucketPattern = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+)
+
+// CheckRow returns the problems of a row processed by
+// variables_parser.ParseAndProcessVariables. These are the checks the form
+// does in the browser.
+func CheckRow(vars map[string][]string) []string {
+	getFirst := func(key string) string {
+		if len(vars[key]) > 0 {
+			return vars[key][0]
+		}
+		return ""
+	}
+
+	var problems []string
+	for _, column := range RequiredColumns {
+		key := column
+		if key == "email_for_credentials" {
+			key = "email"
+		}
+		if getFirst(key) == "" {
+			problems = append(problems, fmt.Sprintf("Не заполнено поле %s", column))
+		}
+	}
+	if sd := getFirst("request_id_sd"); sd != "" && !strings.HasPrefix(sd, "SD-") {
+		problems = append(problems, fmt.Sprintf("Номер обращения %s должен начинаться с SD-", sd))
+	}
+	if srt := getFirst("request_id_srt"); srt != "" && !strings.HasPrefix(srt, "SRT-") {
+		problems = append(problems, fmt.Sprintf("Номер задания %s должен начинаться с SRT-", srt))
+	}
+	for _, key := range []string{"owner", "zam_owner", "email"} {
+		if email := getFirst(key); email != "" && !emailPattern.MatchString(email) {
+			problems = append(problems, fmt.Sprintf("Неверный email: %s", email))
+		}
+	}
+
+	envCode, risName := getFirst("env_code"), strings.ToLower(getFirst("ris_name"))
+	userPrefix := fmt.Sprintf("%s_%s_", envCode, risName)
+	bucketPrefix := fmt.Sprintf("%s-%s-", envCode, strings.ReplaceAll(risName, "_", "-"))
+
+	seen := map[string]bool{}
+	for _, user := range vars["users"] {
+		lower := strings.ToLower(user)
+		switch {
+		case !userPattern.MatchString(user):
+			problems = append(problems, fmt.Sprintf("Имя пользователя %s содержит недопустимые символы", user))
+		case !strings.HasPrefix(lower, userPrefix) || len(lower) <= len(userPrefix):
+			problems = append(problems, fmt.Sprintf("Имя пользователя %s должно начинаться с %s", user, userPrefix))
+		case seen[lower]:
+			problems = append(problems, fmt.Sprintf("Пользователь %s указан дважды", user))
+		}
+		seen[lower] = true
+	}
+
+	seen = map[string]bool{}
+	for _, bucket := range vars["bucketnames"] {
+		lower := strings.ToLower(bucket)
+		switch {
+		case !bucketPattern.MatchString(bucket):
+			problems = append(problems, fmt.Sprintf("Имя бакета %s содержит недопустимые символы", bucket))
+		case !strings.HasPrefix(lower, bucketPrefix) || len(lower) <= len(bucketPrefix):
+			problems = append(problems, fmt.Sprintf("Имя бакета %s должно начинаться с %s", bucket, bucketPrefix))
+		case seen[lower]:
+			problems = append(problems, fmt.Sprintf("Бакет %s указан дважды", bucket))
+		}
+		seen[lower] = true
+	}
+	return problems
+}
+
+// Template returns a CSV batch file with the column names and an example row
+func Template() []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = ';'
+	w.Write(Columns)
+	w.Write([]string{
+		"SD-0000001", "SRT-0000001", "INET-DEVTEST-SYNT", "IFT", "1763", "cosd",
+		"Ответственная РГ", "owner@vtb.ru", "zam.owner@vtb.ru", "Иванов Иван Иванович", "owner@vtb.ru",
+		"", "if_cosd_user1 if_cosd_user2", "if-cosd-bucket1 | 100G; if-cosd-bucket2 | 1.5T | versioning",
+		"if_cosd_user1 | 500G", "false", "ru", "",
+	})
+	w.Flush()
+	return append([]byte("\ufeff"), buf.Bytes()...)
+}
@@ -0,0 +1,106 @@
+package batch_import
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixtureRows are the rows of every batch file in testdata: mixed-case
+// header names, an unnamed column, blank rows and multi-line cells
+var fixtureRows = []Row{
+	{
+		Line: 2,
+		Values: map[string][]string{
+			"create_tenant":         {"true"},
+			"request_id_sd":         {"SD-0000001"},
+			"request_id_srt":        {"SRT-0000001"},
+			"segment":               {"INET-DEVTEST-SYNT"},
+			"env":                   {"IFT"},
+			"ris_number":            {"1763"},
+			"ris_name":              {"cosd"},
+			"resp_group":            {"Ответственная РГ"},
+			"owner":                 {"owner@vtb.ru"},
+			"requester":             {"Иванов Иван Иванович"},
+			"email_for_credentials": {"owner@vtb.ru"},
+			"users":                 {"if_cosd_user1\nif_cosd_user2\nif_cosd_user3"},
+			"buckets":               {"if-cosd-bucket1 | 100G\n if-cosd-bucket2 | 1.5T | versioning"},
+			"user_quotas":           {"if_cosd_user1 | 500G"},
+		},
+		Cluster: "IFT-K12",
+	},
+	{
+		Line: 5,
+		Values: map[string][]string{
+			"create_tenant":         {"true"},
+			"request_id_sd":         {"SD-0000002"},
+			"request_id_srt":        {"SRT-0000002"},
+			"segment":               {"INET-DEVTEST-SYNT"},
+			"env":                   {"IFT"},
+			"ris_number":            {"1763"},
+			"ris_name":              {"cosd"},
+			"resp_group":            {"Ответственная РГ"},
+			"owner":                 {"owner@vtb.ru"},
+			"requester":             {"Иванов Иван Иванович"},
+			"email_for_credentials": {"owner@vtb.ru"},
+			"users":                 {"if_cosd_user4"},
+			"buckets":               {"if-cosd-bucket3 | 1T\nif-cosd-bucket4 | 2T"},
+		},
+	},
+}
+
+func TestParseFixtures(t *testing.T) {
+	for _, name := range []string{"batch_comma.csv", "batch_semicolon.csv", "batch.xlsx"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := Parse(name, data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(rows, fixtureRows) {
+				t.Errorf("Parse() = %+v\nwant %+v", rows, fixtureRows)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	header := strings.Join(RequiredColumns, ",")
+	row := strings.Repeat("x,", len(RequiredColumns)-1) + "x"
+
+	tests := []struct {
+		name, file, data, wantErr string
+	}{
+		{"unsupported type", "batch.txt", header + "\n" + row, "unsupported batch file type"},
+		{"empty file", "batch.csv", "", "batch file is empty"},
+		{"header only", "batch.csv", header + "\n,,\n", "batch file has no rows"},
+		{"unknown column", "batch.csv", header + ",colour\n" + row, "unknown column: colour"},
+		{"duplicate column", "batch.csv", header + ",ENV\n" + row, "duplicate column: env"},
+		{"missing column", "batch.csv", strings.TrimPrefix(header, "request_id_sd,") + "\n" + row, "missing column: request_id_sd"},
+		{"broken quoting", "batch.csv", header + "\n\"x" + row, "error reading CSV"},
+		{"not a workbook", "batch.xlsx", header, "error opening Excel file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.file, []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateParses(t *testing.T) {
+	rows, err := Parse("template.csv", Template())
+	if err != nil {
+		t.Fatalf("Parse(Template()): %v", err)
+	}
+	if len(rows) != 1 || rows[0].Values["users"][0] != "if_cosd_user1\nif_cosd_user2" || rows[0].Values["send_email"][0] != "false" {
+		t.Errorf("template row = %+v", rows)
+	}
+}
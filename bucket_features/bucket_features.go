@@ -0,0 +1,167 @@
+package bucket_features
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Object lock retention modes
+const (
+	Governance = "GOVERNANCE"
+	Compliance = "COMPLIANCE"
+)
+
+// Features holds the optional bucket settings requested with a bucket
+type Features struct {
+	Versioning bool
+	LockMode   string
+	LockDays   int
+	ExpireDays int
+}
+
+// Rules limits the bucket features allowed in an environment
+type Rules struct {
+	AllowCompliance bool
+	MaxLockDays     int
+	MaxExpireDays   int
+}
+
+// EnvRules are the feature limits per environment. Compliance locks cannot be
+// lifted before they expire, so they are only allowed where data must be kept.
+var EnvRules = map[string]Rules{
+	"PROD":    {AllowCompliance: true, MaxLockDays: 3650, MaxExpireDays: 3650},
+	"PREPROD": {AllowCompliance: false, MaxLockDays: 365, MaxExpireDays: 3650},
+	"IFT":     {AllowCompliance: false, MaxLockDays: 30, MaxExpireDays: 365},
+	"HOTFIX":  {AllowCompliance: false, MaxLockDays: 30, MaxExpireDays: 365},
+	"LT":      {AllowCompliance: false, MaxLockDays: 30, MaxExpireDays: 365},
+}
+
+// ParseOption applies a bucket line option (versioning, lock=MODE:days or
+// expire=days) and reports whether the option is a bucket feature.
+func (f *Features) ParseOption(option string) (bool, error) {
+	key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "versioning":
+		f.Versioning = true
+	case "lock":
+		mode, days, ok := strings.Cut(value, ":")
+		mode = strings.ToUpper(strings.TrimSpace(mode))
+		if !ok || (mode != Governance && mode != Compliance) {
+			return true, fmt.Errorf("invalid object lock %q (expected lock=GOVERNANCE:days or lock=COMPLIANCE:days)", value)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(days))
+		if err != nil || n <= 0 {
+			return true, fmt.Errorf("invalid object lock retention %q", days)
+		}
+		// Object lock requires versioning
+		f.Versioning = true
+		f.LockMode, f.LockDays = mode, n
+	case "expire":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return true, fmt.Errorf("invalid expiration %q (expected expire=days)", value)
+		}
+		f.ExpireDays = n
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// Validate checks the features against the rules of env. Environments without
+// rules are not restricted.
+func (f Features) Validate(env string) error {
+	rules, ok := EnvRules[strings.ToUpper(env)]
+	if !ok {
+		return nil
+	}
+	if f.LockMode == Compliance && !rules.AllowCompliance {
+		return fmt.Errorf("compliance object lock is not allowed in %s", strings.ToUpper(env))
+	}
+	if f.LockDays > rules.MaxLockDays {
+		return fmt.Errorf("object lock retention of %d days exceeds %d days allowed in %s", f.LockDays, rules.MaxLockDays, strings.ToUpper(env))
+	}
+	if f.ExpireDays > rules.MaxExpireDays {
+		return fmt.Errorf("expiration of %d days exceeds %d days allowed in %s", f.ExpireDays, rules.MaxExpireDays, strings.ToUpper(env))
+	}
+	return nil
+}
+
+// CheckExistingBuckets rejects object lock for buckets that exist already.
+// RGW accepts an object lock configuration only on buckets created with
+// object lock enabled. locks holds the Lock value of every bucket in names.
+func CheckExistingBuckets(names, locks []string, exists func(bucket string) bool) error {
+	for i, bucket := range names {
+		if i < len(locks) && locks[i] != "-" && exists(bucket) {
+			return fmt.Errorf("object lock can only be enabled when a bucket is created, bucket %s already exists", bucket)
+		}
+	}
+	return nil
+}
+
+// Lock returns the object lock as stored in the database, "-" when not set
+func (f Features) Lock() string {
+	if f.LockMode == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s:%d", f.LockMode, f.LockDays)
+}
+
+// Expire returns the expiration in days as stored in the database, "-" when not set
+func (f Features) Expire() string {
+	if f.ExpireDays == 0 {
+		return "-"
+	}
+	return strconv.Itoa(f.ExpireDays)
+}
+
+type lifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Rules   []lifecycleRule `xml:"Rule"`
+}
+
+type lifecycleRule struct {
+	ID                          string               `xml:"ID"`
+	Filter                      struct{}             `xml:"Filter"`
+	Status                      string               `xml:"Status"`
+	Expiration                  *lifecycleDays       `xml:"Expiration,omitempty"`
+	NoncurrentVersionExpiration *noncurrentDays      `xml:"NoncurrentVersionExpiration,omitempty"`
+	AbortIncompleteUpload       *abortIncompleteDays `xml:"AbortIncompleteMultipartUpload,omitempty"`
+}
+
+type lifecycleDays struct {
+	Days int `xml:"Days"`
+}
+
+type noncurrentDays struct {
+	NoncurrentDays int `xml:"NoncurrentDays"`
+}
+
+type abortIncompleteDays struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+// LifecycleXML renders the lifecycle configuration expiring objects after the
+// requested number of days. Versioned buckets also expire noncurrent versions.
+func (f Features) LifecycleXML() (string, error) {
+	if f.ExpireDays == 0 {
+		return "", nil
+	}
+	rule := lifecycleRule{
+		ID:                    fmt.Sprintf("expire-%d-days", f.ExpireDays),
+		Status:                "Enabled",
+		Expiration:            &lifecycleDays{Days: f.ExpireDays},
+		AbortIncompleteUpload: &abortIncompleteDays{DaysAfterInitiation: 7},
+	}
+	if f.Versioning {
+		rule.NoncurrentVersionExpiration = &noncurrentDays{NoncurrentDays: f.ExpireDays}
+	}
+	out, err := xml.MarshalIndent(lifecycleConfiguration{Rules: []lifecycleRule{rule}}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to build lifecycle configuration: %v", err)
+	}
+	return xml.Header + string(out), nil
+}
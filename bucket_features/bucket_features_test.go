@@ -0,0 +1,127 @@
+package bucket_features
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOption(t *testing.T) {
+	tests := []struct {
+		option  string
+		known   bool
+		want    Features
+		wantErr bool
+	}{
+		{option: "versioning", known: true, want: Features{Versioning: true}},
+		{option: " Versioning ", known: true, want: Features{Versioning: true}},
+		{option: "lock=GOVERNANCE:30", known: true, want: Features{Versioning: true, LockMode: Governance, LockDays: 30}},
+		{option: "LOCK = compliance : 365", known: true, want: Features{Versioning: true, LockMode: Compliance, LockDays: 365}},
+		{option: "expire=90", known: true, want: Features{ExpireDays: 90}},
+		{option: "objects=100"},
+		{option: "tier=cold"},
+		{option: "lock=GOVERNANCE", known: true, wantErr: true},
+		{option: "lock=LEGAL:30", known: true, wantErr: true},
+		{option: "lock=GOVERNANCE:0", known: true, wantErr: true},
+		{option: "lock=GOVERNANCE:-5", known: true, wantErr: true},
+		{option: "lock=GOVERNANCE:days", known: true, wantErr: true},
+		{option: "expire", known: true, wantErr: true},
+		{option: "expire=0", known: true, wantErr: true},
+		{option: "expire=1y", known: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		var f Features
+		known, err := f.ParseOption(tt.option)
+		if known != tt.known || (err != nil) != tt.wantErr {
+			t.Errorf("ParseOption(%q) = %t, %v, want %t, error %t", tt.option, known, err, tt.known, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && f != tt.want {
+			t.Errorf("ParseOption(%q) features = %+v, want %+v", tt.option, f, tt.want)
+		}
+	}
+}
+
+func TestParseOptionsCombine(t *testing.T) {
+	var f Features
+	for _, option := range []string{"expire=30", "lock=GOVERNANCE:7"} {
+		if _, err := f.ParseOption(option); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := (Features{Versioning: true, LockMode: Governance, LockDays: 7, ExpireDays: 30}); f != want {
+		t.Errorf("features = %+v, want %+v", f, want)
+	}
+	if f.Lock() != "GOVERNANCE:7" || f.Expire() != "30" {
+		t.Errorf("stored as %s, %s", f.Lock(), f.Expire())
+	}
+	if (Features{}).Lock() != "-" || (Features{}).Expire() != "-" {
+		t.Error("unset features not stored as -")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		features Features
+		env      string
+		wantErr  string
+	}{
+		{Features{LockMode: Compliance, LockDays: 3650, ExpireDays: 3650}, "prod", ""},
+		{Features{LockMode: Compliance, LockDays: 1}, "IFT", "compliance object lock is not allowed in IFT"},
+		{Features{LockMode: Governance, LockDays: 31}, "IFT", "exceeds 30 days allowed in IFT"},
+		{Features{LockMode: Governance, LockDays: 365}, "PREPROD", ""},
+		{Features{ExpireDays: 366}, "LT", "expiration of 366 days exceeds 365 days"},
+		{Features{LockMode: Compliance, LockDays: 100000}, "SANDBOX", ""},
+	}
+	for _, tt := range tests {
+		err := tt.features.Validate(tt.env)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%+v.Validate(%s) = %v, want %q", tt.features, tt.env, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckExistingBuckets(t *testing.T) {
+	existing := map[string]bool{"ift-cosd-old": true}
+	exists := func(bucket string) bool { return existing[bucket] }
+
+	tests := []struct {
+		name    string
+		names   []string
+		locks   []string
+		wantErr string
+	}{
+		{"new bucket with lock", []string{"ift-cosd-new"}, []string{"GOVERNANCE:30"}, ""},
+		{"existing bucket without lock", []string{"ift-cosd-old"}, []string{"-"}, ""},
+		{"existing bucket with lock", []string{"ift-cosd-new", "ift-cosd-old"}, []string{"-", "COMPLIANCE:365"}, "bucket ift-cosd-old already exists"},
+	}
+	for _, tt := range tests {
+		err := CheckExistingBuckets(tt.names, tt.locks, exists)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: CheckExistingBuckets() = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLifecycleXML(t *testing.T) {
+	if out, err := (Features{}).LifecycleXML(); err != nil || out != "" {
+		t.Errorf("no expiration rendered %q, %v", out, err)
+	}
+
+	out, err := Features{ExpireDays: 30}.LifecycleXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<ID>expire-30-days</ID>", "<Expiration>\n      <Days>30</Days>", "<DaysAfterInitiation>7</DaysAfterInitiation>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("lifecycle lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "NoncurrentVersionExpiration") {
+		t.Errorf("unversioned bucket expires noncurrent versions:\n%s", out)
+	}
+
+	out, err = Features{Versioning: true, ExpireDays: 30}.LifecycleXML()
+	if err != nil || !strings.Contains(out, "<NoncurrentDays>30</NoncurrentDays>") {
+		t.Errorf("versioned bucket lifecycle = %q, %v", out, err)
+	}
+}
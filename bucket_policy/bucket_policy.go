@@ -0,0 +1,188 @@
+package bucket_policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Access levels that can be granted to a user on a bucket
+const (
+	ReadOnly  = "read-only"
+	ReadWrite = "read-write"
+	Admin     = "admin"
+)
+
+var accessActions = map[string][]string{
+	ReadOnly: {
+		"s3:GetObject",
+		"s3:GetObjectVersion",
+		"s3:ListBucket",
+		"s3:ListBucketVersions",
+		"s3:GetBucketLocation",
+	},
+	ReadWrite: {
+		"s3:GetObject",
+		"s3:GetObjectVersion",
+		"s3:ListBucket",
+		"s3:ListBucketVersions",
+		"s3:GetBucketLocation",
+		"s3:PutObject",
+		"s3:DeleteObject",
+		"s3:DeleteObjectVersion",
+		"s3:AbortMultipartUpload",
+		"s3:ListMultipartUploadParts",
+		"s3:ListBucketMultipartUploads",
+	},
+	Admin: {"s3:*"},
+}
+
+// accessSids name the statements of each access level. The prefix marks the
+// statements zayavki manages, the policy commands refuse to replace others.
+var accessSids = map[string]string{
+	ReadOnly:  "ZayavkiReadOnly",
+	ReadWrite: "ZayavkiReadWrite",
+	Admin:     "ZayavkiAdmin",
+}
+
+// Grant gives a user of the tenant access to one of its buckets
+type Grant struct {
+	User   string `json:"user"`
+	Bucket string `json:"bucket"`
+	Access string `json:"access"`
+}
+
+// ParseGrants parses lines in the "user | bucket | access" format. The access
+// level may be omitted when grants are revoked.
+func ParseGrants(input string, requireAccess bool) ([]Grant, error) {
+	var grants []Grant
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, "|")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid format: %s (expected: user | bucket | access)", line)
+		}
+
+		grant := Grant{
+			User:   strings.ToLower(strings.TrimSpace(parts[0])),
+			Bucket: strings.TrimSpace(parts[1]),
+		}
+		if len(parts) == 3 {
+			grant.Access = strings.ToLower(strings.TrimSpace(parts[2]))
+		}
+		if grant.User == "" || grant.Bucket == "" {
+			return nil, fmt.Errorf("user and bucket cannot be empty: %s", line)
+		}
+		if grant.Access == "" && requireAccess {
+			return nil, fmt.Errorf("access level is missing: %s", line)
+		}
+		if _, ok := accessActions[grant.Access]; grant.Access != "" && !ok {
+			return nil, fmt.Errorf("unknown access level '%s' (expected: %s, %s or %s)", grant.Access, ReadOnly, ReadWrite, Admin)
+		}
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+type policyDocument struct {
+	Version   string      `json:"Version"`
+	Statement []statement `json:"Statement"`
+}
+
+type statement struct {
+	Sid       string              `json:"Sid"`
+	Effect    string              `json:"Effect"`
+	Principal map[string][]string `json:"Principal"`
+	Action    []string            `json:"Action"`
+	Resource  []string            `json:"Resource"`
+}
+
+// GeneratePolicy builds the S3 bucket policy of a bucket from all grants on it.
+// Users with the same access level share one statement.
+func GeneratePolicy(tenant, bucket string, grants []Grant) (string, error) {
+	principals := map[string][]string{}
+	for _, grant := range grants {
+		if grant.Bucket != bucket {
+			continue
+		}
+		if _, ok := accessActions[grant.Access]; !ok {
+			return "", fmt.Errorf("unknown access level '%s' for user %s", grant.Access, grant.User)
+		}
+		principals[grant.Access] = append(principals[grant.Access],
+			fmt.Sprintf("arn:aws:iam::%s:user/%s", tenant, grant.User))
+	}
+	if len(principals) == 0 {
+		return "", nil
+	}
+
+	doc := policyDocument{Version: "2012-10-17"}
+	for _, access := range []string{ReadOnly, ReadWrite, Admin} {
+		users, ok := principals[access]
+		if !ok {
+			continue
+		}
+		sort.Strings(users)
+		doc.Statement = append(doc.Statement, statement{
+			Sid:       accessSids[access],
+			Effect:    "Allow",
+			Principal: map[string][]string{"AWS": users},
+			Action:    accessActions[access],
+			Resource: []string{
+				bucketARN(tenant, bucket),
+				bucketARN(tenant, bucket) + "/*",
+			},
+		})
+	}
+
+	policy, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to build policy for bucket %s: %v", bucket, err)
+	}
+	return string(policy), nil
+}
+
+// bucketARN returns the resource of a bucket. RGW names the buckets of a
+// tenant arn:aws:s3::tenant:bucket, a bucket without one has an empty tenant.
+func bucketARN(tenant, bucket string) string {
+	return fmt.Sprintf("arn:aws:s3::%s:%s", tenant, bucket)
+}
+
+// Merge applies changes to the current grants of a tenant. When revoke is
+// false the changes replace any existing access of the same user to the same
+// bucket, otherwise matching grants are removed.
+func Merge(current, changes []Grant, revoke bool) []Grant {
+	changed := map[[2]string]bool{}
+	for _, change := range changes {
+		changed[[2]string{change.User, change.Bucket}] = true
+	}
+
+	var merged []Grant
+	for _, grant := range current {
+		if !changed[[2]string{grant.User, grant.Bucket}] {
+			merged = append(merged, grant)
+		}
+	}
+	if !revoke {
+		merged = append(merged, changes...)
+	}
+	return merged
+}
+
+// Buckets returns the sorted, distinct buckets referenced by grants
+func Buckets(grants []Grant) []string {
+	seen := map[string]bool{}
+	var buckets []string
+	for _, grant := range grants {
+		if !seen[grant.Bucket] {
+			seen[grant.Bucket] = true
+			buckets = append(buckets, grant.Bucket)
+		}
+	}
+	sort.Strings(buckets)
+	return buckets
+}
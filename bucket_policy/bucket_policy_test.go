@@ -0,0 +1,186 @@
+package bucket_policy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseGrants(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		requireAccess bool
+		want          []Grant
+		wantErr       bool
+	}{
+		{
+			name:          "grants",
+			input:         "  App-Reader | logs | Read-Only \n\nwriter|logs|read-write\nops | backups | admin",
+			requireAccess: true,
+			want: []Grant{
+				{User: "app-reader", Bucket: "logs", Access: ReadOnly},
+				{User: "writer", Bucket: "logs", Access: ReadWrite},
+				{User: "ops", Bucket: "backups", Access: Admin},
+			},
+		},
+		{
+			name:  "revocation without access",
+			input: "writer | logs",
+			want:  []Grant{{User: "writer", Bucket: "logs"}},
+		},
+		{name: "access required", input: "writer | logs", requireAccess: true, wantErr: true},
+		{name: "unknown access", input: "writer | logs | owner", wantErr: true},
+		{name: "missing bucket", input: "writer", wantErr: true},
+		{name: "empty user", input: " | logs | admin", wantErr: true},
+		{name: "too many fields", input: "writer | logs | admin | extra", wantErr: true},
+		{name: "empty input", input: "\n  \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGrants(tt.input, tt.requireAccess)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGrants() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGrants() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratePolicy(t *testing.T) {
+	grants := []Grant{
+		{User: "writer", Bucket: "logs", Access: ReadWrite},
+		{User: "b-reader", Bucket: "logs", Access: ReadOnly},
+		{User: "a-reader", Bucket: "logs", Access: ReadOnly},
+		{User: "other", Bucket: "backups", Access: Admin},
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		bucket string
+		want   []statement
+	}{
+		{
+			name:   "tenanted bucket",
+			tenant: "ten-acme",
+			bucket: "logs",
+			want: []statement{
+				{
+					Sid:       "ZayavkiReadOnly",
+					Effect:    "Allow",
+					Principal: map[string][]string{"AWS": {"arn:aws:iam::ten-acme:user/a-reader", "arn:aws:iam::ten-acme:user/b-reader"}},
+					Action:    accessActions[ReadOnly],
+					Resource:  []string{"arn:aws:s3::ten-acme:logs", "arn:aws:s3::ten-acme:logs/*"},
+				},
+				{
+					Sid:       "ZayavkiReadWrite",
+					Effect:    "Allow",
+					Principal: map[string][]string{"AWS": {"arn:aws:iam::ten-acme:user/writer"}},
+					Action:    accessActions[ReadWrite],
+					Resource:  []string{"arn:aws:s3::ten-acme:logs", "arn:aws:s3::ten-acme:logs/*"},
+				},
+			},
+		},
+		{
+			name:   "bucket without a tenant",
+			bucket: "backups",
+			want: []statement{{
+				Sid:       "ZayavkiAdmin",
+				Effect:    "Allow",
+				Principal: map[string][]string{"AWS": {"arn:aws:iam:::user/other"}},
+				Action:    []string{"s3:*"},
+				Resource:  []string{"arn:aws:s3:::backups", "arn:aws:s3:::backups/*"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := GeneratePolicy(tt.tenant, tt.bucket, grants)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc policyDocument
+			if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+				t.Fatalf("policy is not JSON: %v\n%s", err, policy)
+			}
+			if doc.Version != "2012-10-17" {
+				t.Errorf("Version = %q", doc.Version)
+			}
+			if !reflect.DeepEqual(doc.Statement, tt.want) {
+				t.Errorf("statements = %+v, want %+v", doc.Statement, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratePolicyEmptyAndInvalid(t *testing.T) {
+	policy, err := GeneratePolicy("ten-acme", "logs", []Grant{{User: "u", Bucket: "other", Access: Admin}})
+	if err != nil || policy != "" {
+		t.Errorf("bucket without grants: policy %q, error %v, want an empty policy", policy, err)
+	}
+	if _, err := GeneratePolicy("ten-acme", "logs", []Grant{{User: "u", Bucket: "logs", Access: "owner"}}); err == nil {
+		t.Error("unknown access level accepted")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	current := []Grant{
+		{User: "reader", Bucket: "logs", Access: ReadOnly},
+		{User: "writer", Bucket: "logs", Access: ReadWrite},
+		{User: "reader", Bucket: "backups", Access: ReadOnly},
+	}
+
+	tests := []struct {
+		name    string
+		changes []Grant
+		revoke  bool
+		want    []Grant
+	}{
+		{
+			name:    "grant replaces the access of the same user and bucket",
+			changes: []Grant{{User: "reader", Bucket: "logs", Access: Admin}},
+			want: []Grant{
+				{User: "writer", Bucket: "logs", Access: ReadWrite},
+				{User: "reader", Bucket: "backups", Access: ReadOnly},
+				{User: "reader", Bucket: "logs", Access: Admin},
+			},
+		},
+		{
+			name:    "new grant",
+			changes: []Grant{{User: "ops", Bucket: "logs", Access: Admin}},
+			want:    append(append([]Grant{}, current...), Grant{User: "ops", Bucket: "logs", Access: Admin}),
+		},
+		{
+			name:    "revoke ignores the access level",
+			changes: []Grant{{User: "reader", Bucket: "logs"}},
+			revoke:  true,
+			want: []Grant{
+				{User: "writer", Bucket: "logs", Access: ReadWrite},
+				{User: "reader", Bucket: "backups", Access: ReadOnly},
+			},
+		},
+		{
+			name:    "revoking a missing grant changes nothing",
+			changes: []Grant{{User: "nobody", Bucket: "logs"}},
+			revoke:  true,
+			want:    current,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Merge(current, tt.changes, tt.revoke); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	got := Buckets([]Grant{{Bucket: "logs"}, {Bucket: "backups"}, {Bucket: "logs"}})
+	if want := []string{"backups", "logs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Buckets() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package bucket_usage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NarrativeBias/zayavki/cluster_endpoint_parser"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+)
+
+var client = &http.Client{Timeout: 60 * time.Second}
+
+// CollectClusters returns the clusters whose stats are collected
+func CollectClusters() ([]string, error) {
+	if len(config.AdminOps.Clusters) > 0 {
+		return sortedKeys(config.AdminOps.Clusters), nil
+	}
+	clusters, err := cluster_endpoint_parser.ListClusters("clusters.xlsx")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	names := make([]string, 0)
+	for _, cluster := range clusters {
+		if cluster.Кластер != "" && !seen[cluster.Кластер] {
+			seen[cluster.Кластер] = true
+			names = append(names, cluster.Кластер)
+		}
+	}
+	return names, nil
+}
+
+// Collect reads the stats of every bucket of a cluster from the Admin Ops API
+func Collect(cluster string) ([]postgresql_operations.UsageSnapshot, error) {
+	if !config.AdminOps.Enabled {
+		return nil, fmt.Errorf("admin ops API is disabled, upload radosgw-admin bucket stats instead")
+	}
+
+	settings := config.AdminOps.Clusters[cluster]
+	if settings.Endpoint == "" {
+		endpoint, err := clusterEndpoint(cluster)
+		if err != nil {
+			return nil, err
+		}
+		settings.Endpoint = endpoint
+	}
+	if settings.AccessKey == "" {
+		settings.AccessKey, settings.SecretKey = config.AdminOps.AccessKey, config.AdminOps.SecretKey
+	}
+	if settings.AccessKey == "" || settings.SecretKey == "" {
+		return nil, fmt.Errorf("no admin ops keys configured for cluster %s", cluster)
+	}
+
+	endpoint := strings.TrimSuffix(settings.Endpoint, "/")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	req, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%s/%s/bucket?format=json&stats=true", endpoint, strings.Trim(config.AdminOps.AdminPath, "/")), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin ops request: %v", err)
+	}
+	signRequest(req, settings.AccessKey, settings.SecretKey, config.AdminOps.Region, time.Now().UTC())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("admin ops request to %s failed: %v", cluster, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin ops response of %s: %v", cluster, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("admin ops API of %s returned %s: %s", cluster, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return ParseStats(cluster, body)
+}
+
+// clusterEndpoint returns the TLS endpoint of a cluster from clusters.xlsx
+func clusterEndpoint(cluster string) (string, error) {
+	clusters, err := cluster_endpoint_parser.ListClusters("clusters.xlsx")
+	if err != nil {
+		return "", err
+	}
+	for _, info := range clusters {
+		if strings.EqualFold(info.Кластер, cluster) && info.TLSEndpoint != "" {
+			return info.TLSEndpoint, nil
+		}
+	}
+	return "", fmt.Errorf("no endpoint found for cluster %s", cluster)
+}
+
+// signRequest signs a request without a body with AWS Signature Version 4,
+// which RGW accepts for the Admin Ops API
+func signRequest(req *http.Request, accessKey, secretKey, region string, now time.Time) {
+	const service = "s3"
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex("")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Query parameters are sorted and encoded by url.Values
+	query, _ := url.ParseQuery(req.URL.RawQuery)
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,282 @@
+package bucket_usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/table_export"
+)
+
+// Config holds the usage collection and report settings
+type Config struct {
+	// ThresholdPercent is the share of its quota above which a bucket is
+	// reported as over-utilized
+	ThresholdPercent float64 `json:"threshold_percent"`
+	// UnusedDays is the number of days without changes after which a bucket
+	// is reported as unused
+	UnusedDays int            `json:"unused_days"`
+	AdminOps   AdminOpsConfig `json:"admin_ops"`
+}
+
+// AdminOpsConfig holds the settings of the RGW Admin Ops API
+type AdminOpsConfig struct {
+	// Enabled turns on collecting bucket stats from the API, otherwise they
+	// are uploaded as radosgw-admin bucket stats output
+	Enabled bool `json:"enabled"`
+	// AccessKey and SecretKey belong to a user with the buckets=read cap
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	Region    string `json:"region"`
+	// AdminPath is the rgw_admin_entry of the gateways
+	AdminPath      string `json:"admin_path"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	// Clusters lists the clusters to collect from. An empty endpoint is the
+	// TLS endpoint of the cluster in clusters.xlsx, empty keys are the keys
+	// above. With no clusters every cluster of clusters.xlsx is collected.
+	Clusters map[string]ClusterConfig `json:"clusters"`
+	// CollectIntervalHours collects all clusters periodically, 0 turns it off
+	CollectIntervalHours int `json:"collect_interval_hours"`
+}
+
+// ClusterConfig overrides the Admin Ops settings of a cluster
+type ClusterConfig struct {
+	Endpoint  string `json:"endpoint"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+var config = Config{
+	ThresholdPercent: 90,
+	UnusedDays:       30,
+	AdminOps: AdminOpsConfig{
+		Region:         "default",
+		AdminPath:      "admin",
+		TimeoutSeconds: 60,
+	},
+}
+
+// LoadConfig reads the usage settings from a JSON config file
+func LoadConfig(configPath string) error {
+	file, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read usage config: %v", err)
+	}
+
+	cfg := config
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return fmt.Errorf("failed to parse usage config: %v", err)
+	}
+	if cfg.ThresholdPercent <= 0 {
+		return fmt.Errorf("threshold_percent must be positive")
+	}
+	if cfg.UnusedDays <= 0 {
+		return fmt.Errorf("unused_days must be positive")
+	}
+	if cfg.AdminOps.TimeoutSeconds <= 0 {
+		return fmt.Errorf("timeout_seconds must be positive")
+	}
+	if cfg.AdminOps.CollectIntervalHours < 0 {
+		return fmt.Errorf("collect_interval_hours cannot be negative")
+	}
+
+	config = cfg
+	client = &http.Client{Timeout: time.Duration(cfg.AdminOps.TimeoutSeconds) * time.Second}
+	return nil
+}
+
+// ThresholdPercent returns the configured over-utilization threshold
+func ThresholdPercent() float64 {
+	return config.ThresholdPercent
+}
+
+// UnusedDays returns the configured number of days of an unused bucket
+func UnusedDays() int {
+	return config.UnusedDays
+}
+
+// AdminOpsEnabled reports whether bucket stats are collected from the API
+func AdminOpsEnabled() bool {
+	return config.AdminOps.Enabled
+}
+
+// CollectorEnabled reports whether bucket stats are collected periodically
+func CollectorEnabled() bool {
+	return config.AdminOps.Enabled && config.AdminOps.CollectIntervalHours > 0
+}
+
+// bucketStats is an entry of radosgw-admin bucket stats and of the Admin Ops
+// bucket info. usage holds a category per kind of RGW data, rgw.main for
+// the objects and rgw.multimeta for unfinished multipart uploads.
+type bucketStats struct {
+	Bucket string `json:"bucket"`
+	Tenant string `json:"tenant"`
+	Owner  string `json:"owner"`
+	Usage  map[string]struct {
+		SizeActual int64 `json:"size_actual"`
+		NumObjects int64 `json:"num_objects"`
+	} `json:"usage"`
+}
+
+// ParseStats reads the output of radosgw-admin bucket stats, for all buckets
+// or for one, as usage snapshots of cluster
+func ParseStats(cluster string, data []byte) ([]postgresql_operations.UsageSnapshot, error) {
+	var entries []bucketStats
+	trimmed := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+	if strings.HasPrefix(trimmed, "{") {
+		var entry bucketStats
+		if err := json.Unmarshal([]byte(trimmed), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse bucket stats: %v", err)
+		}
+		entries = append(entries, entry)
+	} else if err := json.Unmarshal([]byte(trimmed), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse bucket stats: %v", err)
+	}
+
+	snapshots := make([]postgresql_operations.UsageSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Bucket == "" {
+			continue
+		}
+		snapshot := postgresql_operations.UsageSnapshot{Cluster: cluster, Tenant: entry.Tenant, Bucket: entry.Bucket}
+		// Older releases print tenant/bucket and leave the tenant out, the
+		// owner is still tenant$user
+		if tenant, bucket, ok := strings.Cut(entry.Bucket, "/"); ok && snapshot.Tenant == "" {
+			snapshot.Tenant, snapshot.Bucket = tenant, bucket
+		}
+		if tenant, _, ok := strings.Cut(entry.Owner, "$"); ok && snapshot.Tenant == "" {
+			snapshot.Tenant = tenant
+		}
+		for _, usage := range entry.Usage {
+			snapshot.SizeActual += usage.SizeActual
+			snapshot.NumObjects += usage.NumObjects
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no buckets found in bucket stats")
+	}
+	return snapshots, nil
+}
+
+// RunCollector collects the bucket stats of all clusters every
+// collect_interval_hours
+func RunCollector(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(config.AdminOps.CollectIntervalHours) * time.Hour)
+	defer ticker.Stop()
+	for {
+		clusters, err := CollectClusters()
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to list clusters for usage collection", "error", err)
+		}
+		for _, cluster := range clusters {
+			snapshots, err := Collect(cluster)
+			if err == nil {
+				_, err = postgresql_operations.SaveUsageSnapshots(snapshots, "admin_ops", "collector")
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to collect usage", "cluster", cluster, "error", err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// Report lists the buckets to talk to their owners about: those close to
+// their quota and those nobody has written to for a while
+type Report struct {
+	Filter       postgresql_operations.ReportFilter `json:"filter"`
+	Threshold    float64                            `json:"threshold_percent"`
+	UnusedDays   int                                `json:"unused_days"`
+	OverUtilized []postgresql_operations.UsageRow   `json:"over_utilized"`
+	Unused       []postgresql_operations.UsageRow   `json:"unused"`
+}
+
+// BuildReport builds the usage report. threshold and unusedDays default to
+// the configured ones.
+func BuildReport(filter postgresql_operations.ReportFilter, threshold float64, unusedDays int) (*Report, error) {
+	if threshold <= 0 {
+		threshold = config.ThresholdPercent
+	}
+	if unusedDays <= 0 {
+		unusedDays = config.UnusedDays
+	}
+	rows, err := postgresql_operations.BucketUsage(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &Report{
+		Filter:       filter,
+		Threshold:    threshold,
+		UnusedDays:   unusedDays,
+		OverUtilized: overUtilized(rows, threshold),
+		Unused:       unused(rows, unusedDays),
+	}, nil
+}
+
+// overUtilized returns the rows using at least threshold percent of their
+// quota, fullest first. Buckets without a quota cannot be over-utilized.
+func overUtilized(rows []postgresql_operations.UsageRow, threshold float64) []postgresql_operations.UsageRow {
+	result := make([]postgresql_operations.UsageRow, 0)
+	for _, row := range rows {
+		if row.QuotaBytes > 0 && row.UsedPercent >= threshold {
+			result = append(result, row)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].UsedPercent > result[j].UsedPercent })
+	return result
+}
+
+// unused returns the rows that have not changed for at least days days,
+// longest unused first
+func unused(rows []postgresql_operations.UsageRow, days int) []postgresql_operations.UsageRow {
+	result := make([]postgresql_operations.UsageRow, 0)
+	for _, row := range rows {
+		if row.IdleDays >= float64(days) {
+			result = append(result, row)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].IdleDays > result[j].IdleDays })
+	return result
+}
+
+// Tables returns the sheets of an export of the report
+func (r *Report) Tables() []table_export.Table {
+	return []table_export.Table{
+		UsageTable(fmt.Sprintf("Заполнены на %g%%", r.Threshold), r.OverUtilized),
+		UsageTable(fmt.Sprintf("Без изменений %d дн", r.UnusedDays), r.Unused),
+	}
+}
+
+// UsageTable returns usage rows as a table
+func UsageTable(name string, rows []postgresql_operations.UsageRow) table_export.Table {
+	table := table_export.Table{
+		Name: name,
+		Columns: []string{"Тенант", "Бакет", "Кластер", "Среда", "Зона безопасности", "РИС", "Владелец",
+			"Квота", "Занято, ГБ", "Занято, %", "Объекты", "Собрано", "Последнее изменение"},
+	}
+	for _, row := range rows {
+		table.Rows = append(table.Rows, []interface{}{
+			row.Tenant, row.Bucket, row.Cluster, row.Env, row.Segment, row.RisId + " " + row.RisCode, row.Owner,
+			row.Quota, float64(row.SizeActual) / 1e9, row.UsedPercent, row.NumObjects, row.CollectedAt, row.LastChange,
+		})
+	}
+	return table
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]ClusterConfig) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
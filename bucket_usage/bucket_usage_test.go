@@ -0,0 +1,93 @@
+package bucket_usage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+)
+
+func usageRows() []postgresql_operations.UsageRow {
+	return []postgresql_operations.UsageRow{
+		{Tenant: "a", Bucket: "no-quota", QuotaBytes: 0, SizeActual: 5000, IdleDays: 0},
+		{Tenant: "b", Bucket: "below", QuotaBytes: 1000, SizeActual: 899, UsedPercent: 89.9, IdleDays: 29.9},
+		{Tenant: "c", Bucket: "at", QuotaBytes: 1000, SizeActual: 900, UsedPercent: 90, IdleDays: 30},
+		{Tenant: "d", Bucket: "full", QuotaBytes: 1000, SizeActual: 1200, UsedPercent: 120, IdleDays: 45.5},
+		{Tenant: "e", Bucket: "also-at", QuotaBytes: 2000, SizeActual: 1800, UsedPercent: 90, IdleDays: 1},
+	}
+}
+
+func buckets(rows []postgresql_operations.UsageRow) []string {
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		names = append(names, row.Bucket)
+	}
+	return names
+}
+
+func TestOverUtilized(t *testing.T) {
+	tests := []struct {
+		threshold float64
+		want      []string
+	}{
+		{90, []string{"full", "at", "also-at"}},
+		{89.9, []string{"full", "at", "also-at", "below"}},
+		{100, []string{"full"}},
+		{150, []string{}},
+	}
+	for _, tt := range tests {
+		if got := buckets(overUtilized(usageRows(), tt.threshold)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("overUtilized(%g) = %q, want %q", tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestUnused(t *testing.T) {
+	tests := []struct {
+		days int
+		want []string
+	}{
+		{30, []string{"full", "at"}},
+		{1, []string{"full", "at", "below", "also-at"}},
+		{46, []string{}},
+	}
+	for _, tt := range tests {
+		if got := buckets(unused(usageRows(), tt.days)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unused(%d) = %q, want %q", tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "usage.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for _, bad := range []string{
+		`{"threshold_percent": 0}`,
+		`{"threshold_percent": -5}`,
+		`{"unused_days": 0}`,
+		`{"admin_ops": {"collect_interval_hours": -1}}`,
+	} {
+		if err := LoadConfig(write(bad)); err == nil {
+			t.Errorf("LoadConfig accepted %s", bad)
+		}
+	}
+
+	if err := LoadConfig(write(`{"threshold_percent": 80, "unused_days": 14}`)); err != nil {
+		t.Fatal(err)
+	}
+	if ThresholdPercent() != 80 || UnusedDays() != 14 {
+		t.Errorf("thresholds = %g%%, %d days, want 80%%, 14 days", ThresholdPercent(), UnusedDays())
+	}
+}
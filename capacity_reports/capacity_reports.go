@@ -0,0 +1,119 @@
+package capacity_reports
+
+import (
+	"fmt"
+
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/table_export"
+)
+
+// DefaultTop is the number of top consumers shown when none is requested
+const DefaultTop = 10
+
+// The reports of the dashboard, replaced in the tests
+var (
+	allocationReport = postgresql_operations.AllocationReport
+	growthReport     = postgresql_operations.GrowthReport
+)
+
+// Dashboard is the capacity dashboard of the active registry rows matching a
+// filter: the allocated quota in total and per environment, segment and
+// cluster, the top RIS and tenants, and the growth of bucket quota
+type Dashboard struct {
+	Filter     postgresql_operations.ReportFilter    `json:"filter"`
+	Interval   string                                `json:"interval"`
+	Total      postgresql_operations.AllocationRow   `json:"total"`
+	ByEnv      []postgresql_operations.AllocationRow `json:"by_env"`
+	BySegment  []postgresql_operations.AllocationRow `json:"by_segment"`
+	ByCluster  []postgresql_operations.AllocationRow `json:"by_cluster"`
+	TopRis     []postgresql_operations.AllocationRow `json:"top_ris"`
+	TopTenants []postgresql_operations.AllocationRow `json:"top_tenants"`
+	Growth     []postgresql_operations.GrowthRow     `json:"growth"`
+}
+
+// Build builds the dashboard. interval defaults to month and top to
+// DefaultTop.
+func Build(filter postgresql_operations.ReportFilter, interval string, top int) (*Dashboard, error) {
+	if interval == "" {
+		interval = "month"
+	}
+	if top <= 0 {
+		top = DefaultTop
+	}
+	dashboard := &Dashboard{Filter: filter, Interval: interval}
+
+	total, err := allocationReport("total", filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(total) > 0 {
+		dashboard.Total = total[0]
+	}
+	dashboard.Total.Key = "Всего"
+
+	reports := []struct {
+		groupBy string
+		limit   int
+		rows    *[]postgresql_operations.AllocationRow
+	}{
+		{"env", 0, &dashboard.ByEnv},
+		{"segment", 0, &dashboard.BySegment},
+		{"cluster", 0, &dashboard.ByCluster},
+		{"ris", top, &dashboard.TopRis},
+		{"tenant", top, &dashboard.TopTenants},
+	}
+	for _, report := range reports {
+		if *report.rows, err = allocationReport(report.groupBy, filter, report.limit); err != nil {
+			return nil, err
+		}
+	}
+
+	if dashboard.Growth, err = growthReport(interval, filter); err != nil {
+		return nil, err
+	}
+	return dashboard, nil
+}
+
+// gigabytes converts bytes to the decimal gigabytes quotas are given in
+func gigabytes(bytes int64) float64 {
+	return float64(bytes) / 1e9
+}
+
+// AllocationTable returns an allocation report as a table, keyed by column
+func AllocationTable(name, column string, rows []postgresql_operations.AllocationRow) table_export.Table {
+	table := table_export.Table{
+		Name: name,
+		Columns: []string{column, "Тенанты", "Бакеты", "Пользователи", "Квота бакетов, ГБ",
+			"Квота пользователей, ГБ", "Бакеты без квоты"},
+	}
+	for _, row := range rows {
+		table.Rows = append(table.Rows, []interface{}{
+			row.Key, row.Tenants, row.Buckets, row.Users, gigabytes(row.BucketQuotaBytes),
+			gigabytes(row.UserQuotaBytes), row.BucketsWithoutQuota,
+		})
+	}
+	return table
+}
+
+// Tables returns the sheets of an export of the dashboard
+func (d *Dashboard) Tables() []table_export.Table {
+	growth := table_export.Table{
+		Name:    "Рост",
+		Columns: []string{"Период", "Бакеты", "Добавлено, ГБ", "Всего, ГБ"},
+	}
+	for _, row := range d.Growth {
+		growth.Rows = append(growth.Rows, []interface{}{
+			row.Period, row.Buckets, gigabytes(row.AddedBytes), gigabytes(row.CumulativeBytes),
+		})
+	}
+
+	return []table_export.Table{
+		AllocationTable("Всего", "", []postgresql_operations.AllocationRow{d.Total}),
+		AllocationTable("По средам", "Среда", d.ByEnv),
+		AllocationTable("По зонам безопасности", "Зона безопасности", d.BySegment),
+		AllocationTable("По кластерам", "Кластер", d.ByCluster),
+		AllocationTable(fmt.Sprintf("Топ %d РИС", len(d.TopRis)), "РИС", d.TopRis),
+		AllocationTable(fmt.Sprintf("Топ %d тенантов", len(d.TopTenants)), "Тенант", d.TopTenants),
+		growth,
+	}
+}
@@ -0,0 +1,111 @@
+package capacity_reports
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+)
+
+// fakeReports answers every allocation report with one row keyed by the
+// grouping and limit, and records the growth interval
+func fakeReports(t *testing.T, failGroup string) *string {
+	t.Helper()
+	savedAllocation, savedGrowth := allocationReport, growthReport
+	t.Cleanup(func() { allocationReport, growthReport = savedAllocation, savedGrowth })
+
+	var interval string
+	allocationReport = func(groupBy string, _ postgresql_operations.ReportFilter, limit int) ([]postgresql_operations.AllocationRow, error) {
+		if groupBy == failGroup {
+			return nil, errors.New("db is down")
+		}
+		if groupBy == "total" && failGroup == "empty total" {
+			return nil, nil
+		}
+		return []postgresql_operations.AllocationRow{{Key: fmt.Sprintf("%s/%d", groupBy, limit), Buckets: 2, BucketQuotaBytes: 1500e9}}, nil
+	}
+	growthReport = func(i string, _ postgresql_operations.ReportFilter) ([]postgresql_operations.GrowthRow, error) {
+		interval = i
+		return []postgresql_operations.GrowthRow{{Period: "2024-01-01", Buckets: 1, AddedBytes: 5e8, CumulativeBytes: 25e8}}, nil
+	}
+	return &interval
+}
+
+func TestBuildDefaults(t *testing.T) {
+	interval := fakeReports(t, "")
+	filter := postgresql_operations.ReportFilter{Env: "PROD"}
+
+	dashboard, err := Build(filter, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Interval != "month" || *interval != "month" {
+		t.Errorf("interval = %s, growth by %s, want month", dashboard.Interval, *interval)
+	}
+	if dashboard.Filter != filter || dashboard.Total.Key != "Всего" || dashboard.Total.Buckets != 2 {
+		t.Errorf("dashboard = %+v", dashboard)
+	}
+	keys := []string{dashboard.ByEnv[0].Key, dashboard.BySegment[0].Key, dashboard.ByCluster[0].Key,
+		dashboard.TopRis[0].Key, dashboard.TopTenants[0].Key}
+	want := []string{"env/0", "segment/0", "cluster/0", fmt.Sprintf("ris/%d", DefaultTop), fmt.Sprintf("tenant/%d", DefaultTop)}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("reports = %q, want %q", keys, want)
+	}
+
+	dashboard, err = Build(filter, "week", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *interval != "week" || dashboard.TopTenants[0].Key != "tenant/3" {
+		t.Errorf("growth by %s, top tenants %s, want week and tenant/3", *interval, dashboard.TopTenants[0].Key)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	fakeReports(t, "cluster")
+	if _, err := Build(postgresql_operations.ReportFilter{}, "", 0); err == nil {
+		t.Error("failed cluster report ignored")
+	}
+
+	fakeReports(t, "empty total")
+	dashboard, err := Build(postgresql_operations.ReportFilter{}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Total != (postgresql_operations.AllocationRow{Key: "Всего"}) {
+		t.Errorf("empty registry total = %+v", dashboard.Total)
+	}
+}
+
+func TestTables(t *testing.T) {
+	fakeReports(t, "")
+	dashboard, err := Build(postgresql_operations.ReportFilter{}, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := dashboard.Tables()
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.Name)
+		for _, row := range table.Rows {
+			if len(row) != len(table.Columns) {
+				t.Errorf("%s: row %v does not match columns %q", table.Name, row, table.Columns)
+			}
+		}
+	}
+	want := []string{"Всего", "По средам", "По зонам безопасности", "По кластерам", "Топ 1 РИС", "Топ 1 тенантов", "Рост"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("sheets = %q, want %q", names, want)
+	}
+
+	// Quotas are exported in decimal gigabytes
+	if got := tables[0].Rows[0][4]; got != 1500.0 {
+		t.Errorf("bucket quota = %v, want 1500", got)
+	}
+	if got := tables[6].Rows[0]; !reflect.DeepEqual(got, []interface{}{"2024-01-01", 1, 0.5, 2.5}) {
+		t.Errorf("growth row = %v", got)
+	}
+}
@@ -6,10 +6,23 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
+	"github.com/NarrativeBias/zayavki/metrics"
 	"github.com/xuri/excelize/v2"
 )
 
+var (
+	inventoryLoads = metrics.NewCounter("zayavki_cluster_inventory_loads_total",
+		"Reads of the cluster inventory by result.", "result")
+	inventoryUp = metrics.NewGauge("zayavki_cluster_inventory_up",
+		"Whether the last read of the cluster inventory succeeded.")
+	inventoryLastSuccess = metrics.NewGauge("zayavki_cluster_inventory_last_success_timestamp_seconds",
+		"Time of the last successful read of the cluster inventory.")
+	inventoryClusters = metrics.NewGauge("zayavki_cluster_inventory_clusters",
+		"Rows of the cluster inventory at the last successful read.")
+)
+
 type ClusterInfo struct {
 	Выдача       string `json:"Выдача"`
 	ЦОД          string `json:"ЦОД"`
@@ -19,9 +32,42 @@ type ClusterInfo struct {
 	MTLSEndpoint string `json:"mtls_endpoint"`
 	Кластер      string `json:"Кластер"`
 	Реалм        string `json:"Реалм"`
+	Шаблон       string `json:"Шаблон"` // command template set, optional column
 }
 
 func FindMatchingClusters(filename, segment, env string) ([]ClusterInfo, error) {
+	clusters, err := ListClusters(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var matchedClusters []ClusterInfo
+	for _, cluster := range clusters {
+		if cluster.Среда == env && cluster.ЗБ == segment {
+			matchedClusters = append(matchedClusters, cluster)
+		}
+	}
+
+	return matchedClusters, nil
+}
+
+// ListClusters returns every cluster of the inventory. The inventory is
+// read on every call, so edits of the file apply at once.
+func ListClusters(filename string) ([]ClusterInfo, error) {
+	clusters, err := readClusters(filename)
+	if err != nil {
+		inventoryLoads.Inc("error")
+		inventoryUp.Set(0)
+		return nil, err
+	}
+	inventoryLoads.Inc("success")
+	inventoryUp.Set(1)
+	inventoryLastSuccess.Set(float64(time.Now().Unix()))
+	inventoryClusters.Set(float64(len(clusters)))
+	return clusters, nil
+}
+
+func readClusters(filename string) ([]ClusterInfo, error) {
 	f, err := excelize.OpenFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("error opening Excel file: %w", err)
@@ -33,17 +79,21 @@ func FindMatchingClusters(filename, segment, env string) ([]ClusterInfo, error)
 		return nil, fmt.Errorf("error reading rows: %w", err)
 	}
 
-	var matchedClusters []ClusterInfo
+	var clusters []ClusterInfo
 	for _, row := range rows[1:] { // Skip header row
-		if len(row) >= 9 && row[3] == env && row[4] == segment {
-			matchedClusters = append(matchedClusters, ClusterInfo{
+		if len(row) >= 9 {
+			cluster := ClusterInfo{
 				Выдача: row[0], ЦОД: row[1], Среда: row[3], ЗБ: row[4],
 				TLSEndpoint: row[5], MTLSEndpoint: row[6], Кластер: row[7], Реалм: row[8],
-			})
+			}
+			if len(row) >= 10 {
+				cluster.Шаблон = row[9]
+			}
+			clusters = append(clusters, cluster)
 		}
 	}
 
-	return matchedClusters, nil
+	return clusters, nil
 }
 
 func GetCluster(filename, segment, env string) (ClusterInfo, error) {
@@ -95,5 +145,6 @@ func (c ClusterInfo) ConvertToMap() map[string]string {
 		"mtls_endpoint": c.MTLSEndpoint,
 		"Кластер":       c.Кластер,
 		"Реалм":         c.Реалм,
+		"Шаблон":        c.Шаблон,
 	}
 }
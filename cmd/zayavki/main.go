@@ -0,0 +1,962 @@
+// Command zayavki runs the operations of the web app from the command line.
+// It works on the same config files, so run it from the app directory or
+// point -C to it.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/NarrativeBias/zayavki/approvals"
+	"github.com/NarrativeBias/zayavki/bucket_usage"
+	"github.com/NarrativeBias/zayavki/capacity_reports"
+	"github.com/NarrativeBias/zayavki/cluster_endpoint_parser"
+	"github.com/NarrativeBias/zayavki/email_template"
+	"github.com/NarrativeBias/zayavki/logging"
+	"github.com/NarrativeBias/zayavki/mailer"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/request_lifecycle"
+	"github.com/NarrativeBias/zayavki/request_processing"
+	"github.com/NarrativeBias/zayavki/rgw_commands"
+	"github.com/NarrativeBias/zayavki/srt_connector"
+	"github.com/NarrativeBias/zayavki/table_export"
+	"github.com/NarrativeBias/zayavki/variables_parser"
+)
+
+const usage = `Usage: zayavki [-C dir] [-json] [-actor name] <command> [flags]
+
+Commands:
+  generate     print the DB rows and commands of a new tenant request
+  push         save a new tenant request in the DB
+  check        search the DB for tenants, users and buckets
+  tenant       show the resources, requests and history of a tenant
+  deactivate   print deletion commands, with -push deactivate in the DB
+  quota        print quota commands, with -push update the DB
+  export       export search results or a tenant inventory as XLSX or CSV
+  report       show the allocated capacity by environment, segment, cluster, RIS and tenant
+  usage        import or collect bucket stats, report over-utilized and unused buckets
+  clusters     list the clusters of clusters.xlsx
+  import-srt   import an SRT ticket from files or the ticket API
+  migrate      apply the database schema migrations
+
+Run "zayavki <command> -h" for the flags of a command.
+`
+
+var (
+	jsonOutput bool
+	actor      string
+)
+
+type command struct {
+	run func(args []string) error
+	// db commands need the database and the templates
+	db bool
+}
+
+var commands = map[string]command{
+	"generate":   {run: runGenerate, db: true},
+	"push":       {run: runPush, db: true},
+	"check":      {run: runCheck, db: true},
+	"tenant":     {run: runTenant, db: true},
+	"deactivate": {run: runDeactivate, db: true},
+	"quota":      {run: runQuota, db: true},
+	"export":     {run: runExport, db: true},
+	"report":     {run: runReport, db: true},
+	"usage":      {run: runUsage, db: true},
+	"clusters":   {run: runClusters},
+	"import-srt": {run: runImportSRT},
+	"migrate":    {run: runMigrate},
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	dir := flag.String("C", ".", "directory with the config files")
+	flag.BoolVar(&jsonOutput, "json", false, "print JSON instead of text")
+	flag.StringVar(&actor, "actor", os.Getenv("USER"), "user recorded as the author of changes")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	if actor == "" {
+		actor = "cli"
+	}
+
+	if err := os.Chdir(*dir); err != nil {
+		log.Fatalf("Failed to open config directory: %v", err)
+	}
+	if err := logging.LoadConfig("logging.json"); err != nil {
+		log.Fatalf("Failed to load logging config: %v", err)
+	}
+	if cmd.db {
+		loadConfig()
+		defer postgresql_operations.CloseDB()
+	}
+
+	if err := cmd.run(flag.Args()[1:]); err != nil {
+		postgresql_operations.CloseDB()
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// loadConfig loads the configs the web app loads for the same operations
+func loadConfig() {
+	if err := postgresql_operations.InitDB("db_config.json"); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := rgw_commands.LoadTemplates("command_templates.json"); err != nil {
+		log.Fatalf("Failed to load command templates: %v", err)
+	}
+	if err := email_template.LoadTemplates("email_templates"); err != nil {
+		log.Fatalf("Failed to load email templates: %v", err)
+	}
+	if err := mailer.LoadConfig("mailer.json"); err != nil {
+		log.Fatalf("Failed to load mailer config: %v", err)
+	}
+	if err := approvals.LoadConfig("approvals.json"); err != nil {
+		log.Fatalf("Failed to load approvals config: %v", err)
+	}
+	if err := srt_connector.LoadConfig("srt.json"); err != nil {
+		log.Fatalf("Failed to load srt config: %v", err)
+	}
+	if err := bucket_usage.LoadConfig("usage.json"); err != nil {
+		log.Fatalf("Failed to load usage config: %v", err)
+	}
+}
+
+// listFlag collects the values of a repeated flag
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ", ") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// splitList splits a comma separated flag value
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// printJSON prints v as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printApproval reports a change saved for review instead of being applied
+func printApproval(approval *postgresql_operations.Approval) error {
+	if jsonOutput {
+		return printJSON(map[string]interface{}{"approval": approval})
+	}
+	fmt.Printf("Изменение требует согласования: заявка #%d сохранена и будет выполнена после одобрения другим пользователем\n\n%s", approval.ID, approval.Plan)
+	return nil
+}
+
+// requestFlags are the flags describing a new tenant request
+type requestFlags struct {
+	form    *string
+	set     listFlag
+	cluster *string
+}
+
+func newRequestFlags(fs *flag.FlagSet) *requestFlags {
+	f := &requestFlags{}
+	f.form = fs.String("form", "", "JSON file with the form fields, such as the output of import-srt -fields")
+	fs.Var(&f.set, "set", "form field as name=value, repeatable")
+	f.cluster = fs.String("cluster", "", "cluster to use when several clusters serve the segment and environment")
+	return f
+}
+
+// values returns the form values of the request, as the new tenant form
+// submits them
+func (f *requestFlags) values() (map[string][]string, error) {
+	fields := map[string]string{}
+	if *f.form != "" {
+		data, err := os.ReadFile(*f.form)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read form file: %v", err)
+		}
+		// Accept the full import-srt output as well
+		var imported struct {
+			Fields map[string]string `json:"fields"`
+		}
+		if err := json.Unmarshal(data, &imported); err == nil && imported.Fields != nil {
+			fields = imported.Fields
+		} else if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("failed to parse form file: %v", err)
+		}
+	}
+	for _, field := range f.set {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q (expected name=value)", field)
+		}
+		fields[strings.TrimSpace(name)] = value
+	}
+	if _, ok := fields["create_tenant"]; !ok {
+		fields["create_tenant"] = "true"
+	}
+
+	values := map[string][]string{}
+	for name, value := range fields {
+		values[name] = []string{value}
+	}
+	return values, nil
+}
+
+// findCluster finds the cluster of a request like the web form, with -cluster
+// choosing among several matches
+func (f *requestFlags) findCluster(vars map[string][]string) (cluster_endpoint_parser.ClusterInfo, error) {
+	clusters, err := cluster_endpoint_parser.FindMatchingClusters("clusters.xlsx", vars["segment"][0], vars["env"][0])
+	if err != nil {
+		return cluster_endpoint_parser.ClusterInfo{}, err
+	}
+	var names []string
+	for _, cluster := range clusters {
+		if len(clusters) == 1 || strings.EqualFold(cluster.Кластер, *f.cluster) {
+			return cluster, nil
+		}
+		names = append(names, cluster.Кластер)
+	}
+	if len(clusters) == 0 {
+		return cluster_endpoint_parser.ClusterInfo{}, fmt.Errorf("no matching clusters found for segment '%s' and environment '%s'", vars["segment"][0], vars["env"][0])
+	}
+	return cluster_endpoint_parser.ClusterInfo{}, fmt.Errorf("multiple clusters found, choose one with -cluster: %s", strings.Join(names, ", "))
+}
+
+func runGenerate(args []string) error {
+	return runCreation("generate", args, false)
+}
+
+func runPush(args []string) error {
+	return runCreation("push", args, true)
+}
+
+// runCreation processes a new tenant request like a form submitted in the web
+// app, including request tracking and approvals
+func runCreation(name string, args []string, pushToDb bool) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	request := newRequestFlags(fs)
+	fs.Parse(args)
+
+	raw, err := request.values()
+	if err != nil {
+		return err
+	}
+	form := map[string]string{}
+	for key, value := range raw {
+		if value[0] != "" {
+			form[key] = value[0]
+		}
+	}
+
+	vars, err := variables_parser.ParseAndProcessVariables(raw)
+	if err != nil {
+		request_processing.TrackRequest(context.Background(), actor, raw, form, request_lifecycle.Failed, err.Error())
+		return fmt.Errorf("error processing variables: %v", err)
+	}
+	cluster, err := request.findCluster(vars)
+	if err != nil {
+		return err
+	}
+
+	result, state, note, err := request_processing.RunCreationRequest(context.Background(), actor, vars, cluster, pushToDb)
+	if err != nil {
+		request_processing.TrackRequest(context.Background(), actor, vars, form, request_lifecycle.Failed, err.Error())
+		return err
+	}
+	request_processing.TrackRequest(context.Background(), actor, vars, form, state, note)
+
+	if jsonOutput {
+		return printJSON(map[string]interface{}{
+			"tenant":  vars["tenant"][0],
+			"cluster": cluster,
+			"state":   state,
+			"note":    note,
+			"result":  result,
+		})
+	}
+	fmt.Print(result)
+	return nil
+}
+
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	segment := fs.String("segment", "", "security segment")
+	env := fs.String("env", "", "environment")
+	risNumber := fs.String("ris-number", "", "RIS number")
+	risName := fs.String("ris-name", "", "RIS name")
+	tenant := fs.String("tenant", "", "tenant")
+	bucket := fs.String("bucket", "", "bucket")
+	user := fs.String("user", "", "user")
+	cluster := fs.String("cluster", "", "cluster")
+	fs.Parse(args)
+
+	results, err := postgresql_operations.CheckDBForExistingEntries(
+		strings.ToUpper(*segment), strings.ToUpper(*env), *risNumber, *risName, *tenant, *bucket, *user, *cluster)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		if results == nil {
+			results = []postgresql_operations.CheckResult{}
+		}
+		return printJSON(map[string]interface{}{"results": results})
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tENV\tTENANT\tUSER\tBUCKET\tQUOTA\tSRT\tDONE\tACTIVE")
+	for _, r := range results {
+		quota := "-"
+		if r.Quota.Valid {
+			quota = r.Quota.String
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\n", r.ClsName, r.Env, r.Tenant,
+			nullString(r.S3User.String, r.S3User.Valid), nullString(r.Bucket.String, r.Bucket.Valid),
+			quota, r.SrtNum, r.DoneDate, r.Active)
+	}
+	return w.Flush()
+}
+
+func runTenant(args []string) error {
+	fs := flag.NewFlagSet("tenant", flag.ExitOnError)
+	tenant := fs.String("tenant", "", "tenant")
+	fs.Parse(args)
+	if *tenant == "" && fs.NArg() > 0 {
+		*tenant = fs.Arg(0)
+	}
+	if *tenant == "" {
+		return fmt.Errorf("-tenant is required")
+	}
+
+	overview, err := request_processing.GetTenantOverview(*tenant)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(overview)
+	}
+
+	status := map[bool]string{true: "active", false: "inactive"}
+	fmt.Printf("Tenant:    %s (%s)\n", overview.Tenant, status[overview.Active])
+	fmt.Printf("Cluster:   %s, realm %s, %s %s\n", overview.Cluster, overview.Realm, overview.Env, overview.Segment)
+	fmt.Printf("Endpoints: %s / %s\n", overview.TLSEndpoint, overview.MTLSEndpoint)
+	fmt.Printf("RIS:       %s %s\n", overview.RisId, overview.RisCode)
+	fmt.Printf("Owners:    %s, %s\n", overview.OwnerGroup, overview.Owner)
+	c := overview.Capacity
+	fmt.Printf("Capacity:  buckets %s (%d active, %d inactive, %d without quota), users %s (%d active, %d inactive)\n\n",
+		c.BucketQuota, c.ActiveBuckets, c.InactiveBuckets, c.BucketsWithoutQuota, c.UserQuota, c.ActiveUsers, c.InactiveUsers)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE\tNAME\tQUOTA\tSTATUS\tSRT\tDONE")
+	for _, r := range overview.Users {
+		fmt.Fprintf(w, "user\t%s\t%s\t%s\t%s\t%s\n", r.S3User.String, nullString(r.Quota.String, r.Quota.Valid), status[r.Active], r.SrtNum, r.DoneDate)
+	}
+	for _, r := range overview.Buckets {
+		fmt.Fprintf(w, "bucket\t%s\t%s\t%s\t%s\t%s\n", r.Bucket.String, nullString(r.Quota.String, r.Quota.Valid), status[r.Active], r.SrtNum, r.DoneDate)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "REQUEST\tSRT\tSTATE\tUPDATED")
+	for _, r := range overview.Requests {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s (%s)\n", r.SdNum, r.SrtNum, r.State, r.UpdatedAt, r.UpdatedBy)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "TIME\tEVENT\tACTOR\tSRT")
+	for _, e := range overview.Timeline {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.At, e.Event, e.Actor, e.SrtNum)
+	}
+	return w.Flush()
+}
+
+func nullString(value string, valid bool) string {
+	if !valid {
+		return "-"
+	}
+	return value
+}
+
+// tenantClusters returns the environment and cluster of an existing tenant
+func tenantClusters(tenant string) (string, map[string]string, error) {
+	results, err := postgresql_operations.CheckDBForExistingEntries("", "", "", "", tenant, "", tenant, "")
+	if err != nil {
+		return "", nil, err
+	}
+	for _, result := range results {
+		if result.S3User.Valid && result.S3User.String == tenant {
+			return result.Env, request_processing.InventoryClusterMap(&result), nil
+		}
+	}
+	return "", nil, request_processing.ErrTenantNotFound
+}
+
+func runDeactivate(args []string) error {
+	fs := flag.NewFlagSet("deactivate", flag.ExitOnError)
+	tenant := fs.String("tenant", "", "tenant (required)")
+	users := fs.String("users", "", "comma separated users")
+	buckets := fs.String("buckets", "", "comma separated buckets")
+	srt := fs.String("srt", "", "SRT number for the notification")
+	lang := fs.String("lang", "ru", "notification language")
+	push := fs.Bool("push", false, "deactivate the resources in the DB")
+	fs.Parse(args)
+
+	if *tenant == "" {
+		return fmt.Errorf("-tenant is required")
+	}
+	request := request_processing.DeactivationRequest{
+		Tenant:       *tenant,
+		Users:        splitList(*users),
+		Buckets:      splitList(*buckets),
+		RequestIdSrt: *srt,
+		EmailLang:    *lang,
+	}
+
+	if !*push {
+		_, clusters, err := tenantClusters(request.Tenant)
+		if err != nil {
+			return err
+		}
+		commands, err := rgw_commands.GenerateDeletionCommands(request.Tenant, request.Users, request.Buckets, clusters)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(map[string]interface{}{"deletion_commands": commands})
+		}
+		fmt.Print(commands)
+		return nil
+	}
+
+	result, approval, err := request_processing.Deactivate(actor, request)
+	if err != nil {
+		return err
+	}
+	if approval != nil {
+		return printApproval(approval)
+	}
+	if jsonOutput {
+		return printJSON(result)
+	}
+	for _, key := range []string{"deactivated_users", "deactivated_buckets"} {
+		if values, _ := result[key].([]string); len(values) > 0 {
+			fmt.Printf("%s: %s\n", key, strings.Join(values, ", "))
+		}
+	}
+	if email, ok := result["email"].(email_template.Email); ok {
+		fmt.Printf("\n%s\n\n%s", email.Subject, email.Text)
+	}
+	return nil
+}
+
+func runQuota(args []string) error {
+	fs := flag.NewFlagSet("quota", flag.ExitOnError)
+	tenant := fs.String("tenant", "", "tenant (required)")
+	var buckets, users listFlag
+	fs.Var(&buckets, "bucket", `bucket quota as "name | size | objects=N", repeatable`)
+	fs.Var(&users, "user", `user quota as "name | size | objects=N", repeatable`)
+	action := fs.String("action", "", "enable or disable the quotas instead of changing them")
+	srt := fs.String("srt", "", "SRT number for the notification")
+	lang := fs.String("lang", "ru", "notification language")
+	push := fs.Bool("push", false, "update the quotas in the DB")
+	fs.Parse(args)
+
+	if *tenant == "" {
+		return fmt.Errorf("-tenant is required")
+	}
+	if *action != "" && *action != "enable" && *action != "disable" {
+		return fmt.Errorf("invalid action: %s (expected enable or disable)", *action)
+	}
+
+	if !*push {
+		env, clusters, err := tenantClusters(*tenant)
+		if err != nil {
+			return err
+		}
+		if *action == "" {
+			if err := request_processing.CheckQuotaLines(env, append(buckets, users...)); err != nil {
+				return err
+			}
+		}
+		commands, err := rgw_commands.GenerateQuotaCommands(*tenant, buckets, users, *action, clusters)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(map[string]interface{}{"commands": commands})
+		}
+		fmt.Print(commands)
+		return nil
+	}
+
+	request := request_processing.QuotaUpdateRequest{
+		Tenant:       *tenant,
+		Buckets:      quotaUpdates(buckets),
+		Users:        quotaUpdates(users),
+		Action:       *action,
+		RequestIdSrt: *srt,
+		EmailLang:    *lang,
+	}
+	response, approval, err := request_processing.ChangeQuotas(actor, request)
+	if err != nil {
+		return err
+	}
+	if approval != nil {
+		return printApproval(approval)
+	}
+	if jsonOutput {
+		return printJSON(response)
+	}
+	for _, update := range response.UpdatedBuckets {
+		fmt.Printf("bucket %s: %s -> %s\n", update.Name, update.OldSize, update.Size)
+	}
+	for _, update := range response.UpdatedUsers {
+		fmt.Printf("user %s: %s -> %s\n", update.Name, update.OldSize, update.Size)
+	}
+	for _, message := range response.Errors {
+		fmt.Printf("error: %s\n", message)
+	}
+	if response.Email != nil {
+		fmt.Printf("\n%s\n\n%s", response.Email.Subject, response.Email.Text)
+	}
+	return nil
+}
+
+// quotaUpdates parses "name | size | objects=N" lines like the quota tab
+func quotaUpdates(lines []string) []postgresql_operations.QuotaUpdate {
+	var updates []postgresql_operations.QuotaUpdate
+	for _, line := range lines {
+		parts := strings.Split(line, "|")
+		update := postgresql_operations.QuotaUpdate{Name: strings.TrimSpace(parts[0])}
+		if len(parts) > 1 {
+			update.Size = strings.TrimSpace(parts[1])
+		}
+		for _, option := range parts[min(len(parts), 2):] {
+			key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+			if strings.EqualFold(strings.TrimSpace(key), "objects") {
+				update.MaxObjects = strings.TrimSpace(value)
+			}
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// runExport writes "export search" or "export tenant" to a file named
+// like the web app downloads, or to -o
+func runExport(args []string) error {
+	kind := "search"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		kind, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("export "+kind, flag.ExitOnError)
+	format := fs.String("format", table_export.XLSX, "xlsx or csv")
+	delimiter := fs.String("delimiter", "comma", "CSV delimiter: comma or semicolon")
+	output := fs.String("o", "", "output file, - for stdout (default: a file named after the export)")
+	tenant := fs.String("tenant", "", "tenant")
+
+	var query postgresql_operations.SearchQuery
+	switch kind {
+	case "search":
+		fs.StringVar(&query.Segment, "segment", "", "security segment")
+		fs.StringVar(&query.Env, "env", "", "environment")
+		fs.StringVar(&query.RisNumber, "ris-number", "", "RIS number")
+		fs.StringVar(&query.RisName, "ris-name", "", "RIS name")
+		fs.StringVar(&query.Cluster, "cluster", "", "cluster")
+		fs.StringVar(&query.Bucket, "bucket", "", "bucket")
+		fs.StringVar(&query.User, "user", "", "user")
+		fs.StringVar(&query.Owner, "owner", "", "owner")
+		fs.StringVar(&query.SdNum, "sd", "", "SD number")
+		fs.StringVar(&query.SrtNum, "srt", "", "SRT number")
+		fs.StringVar(&query.Match, "match", postgresql_operations.MatchContains, "contains, prefix or exact")
+		fs.StringVar(&query.Active, "active", "", "true or false, empty for both")
+		fs.StringVar(&query.DateFrom, "from", "", "first date, YYYY-MM-DD")
+		fs.StringVar(&query.DateTo, "to", "", "last date, YYYY-MM-DD")
+		fs.StringVar(&query.Sort, "sort", "done_date", "column to sort by")
+		fs.StringVar(&query.Order, "order", "desc", "asc or desc")
+	case "tenant":
+	default:
+		return fmt.Errorf("unknown export: %s (expected search or tenant)", kind)
+	}
+	fs.Parse(args)
+
+	options, err := table_export.ParseOptions(*format, *delimiter)
+	if err != nil {
+		return err
+	}
+
+	var name string
+	var table table_export.Table
+	if kind == "tenant" {
+		if *tenant == "" {
+			return fmt.Errorf("-tenant is required")
+		}
+		results, err := postgresql_operations.CheckDBForExistingEntries("", "", "", "", *tenant, "", "", "")
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			return fmt.Errorf("tenant %s not found", *tenant)
+		}
+		name, table = *tenant, table_export.RegistryTable(*tenant, results)
+	} else {
+		query.Tenant = *tenant
+		query.Env = strings.ToUpper(query.Env)
+		results, err := postgresql_operations.ExportEntries(query)
+		if err != nil {
+			return err
+		}
+		name, table = "search_results", table_export.RegistryTable("Результаты поиска", results)
+	}
+
+	if *output == "-" {
+		return table_export.Write(os.Stdout, options, table)
+	}
+	if *output == "" {
+		*output = options.FileName(name)
+	}
+	if err := writeExportFile(*output, options, table); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d rows written to %s\n", len(table.Rows), *output)
+	return nil
+}
+
+// writeExportFile writes tables to a file, - for stdout
+func writeExportFile(path string, options table_export.Options, tables ...table_export.Table) error {
+	if path == "-" {
+		return table_export.Write(os.Stdout, options, tables...)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %v", err)
+	}
+	if err := table_export.Write(file, options, tables...); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+	return nil
+}
+
+// printTables prints tables one after another under their names
+func printTables(tables []table_export.Table) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, table := range tables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, table.Name)
+		fmt.Fprintln(w, strings.Join(table.Columns, "\t"))
+		for _, row := range table.Rows {
+			cells := make([]string, len(row))
+			for j, cell := range row {
+				if value, ok := cell.(float64); ok {
+					cells[j] = fmt.Sprintf("%.2f", value)
+				} else {
+					cells[j] = fmt.Sprint(cell)
+				}
+			}
+			fmt.Fprintln(w, strings.Join(cells, "\t"))
+		}
+	}
+	return w.Flush()
+}
+
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	var filter postgresql_operations.ReportFilter
+	fs.StringVar(&filter.Env, "env", "", "environment")
+	fs.StringVar(&filter.Segment, "segment", "", "security segment")
+	fs.StringVar(&filter.Cluster, "cluster", "", "cluster")
+	fs.StringVar(&filter.RisNumber, "ris-number", "", "RIS number")
+	interval := fs.String("interval", "month", "growth interval: day, week, month, quarter or year")
+	top := fs.Int("top", capacity_reports.DefaultTop, "number of top RIS and tenants")
+	format := fs.String("format", table_export.XLSX, "xlsx or csv, with -o")
+	delimiter := fs.String("delimiter", "comma", "CSV delimiter: comma or semicolon")
+	output := fs.String("o", "", "export the report to a file, - for stdout")
+	fs.Parse(args)
+
+	dashboard, err := capacity_reports.Build(filter, *interval, *top)
+	if err != nil {
+		return err
+	}
+	tables := dashboard.Tables()
+
+	if *output != "" {
+		options, err := table_export.ParseOptions(*format, *delimiter)
+		if err != nil {
+			return err
+		}
+		if err := writeExportFile(*output, options, tables...); err != nil {
+			return err
+		}
+		if *output != "-" {
+			fmt.Fprintf(os.Stderr, "report written to %s\n", *output)
+		}
+		return nil
+	}
+	if jsonOutput {
+		return printJSON(dashboard)
+	}
+	return printTables(tables)
+}
+
+func runUsage(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage subcommand required: import, collect or report")
+	}
+	sub, args := args[0], args[1:]
+
+	switch sub {
+	case "import":
+		fs := flag.NewFlagSet("usage import", flag.ExitOnError)
+		cluster := fs.String("cluster", "", "cluster the stats were collected on")
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return fmt.Errorf("bucket stats files are required, - for stdin")
+		}
+		total := 0
+		for _, path := range fs.Args() {
+			var data []byte
+			var err error
+			if path == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				data, err = os.ReadFile(path)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %v", path, err)
+			}
+			snapshots, err := bucket_usage.ParseStats(*cluster, data)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			saved, err := postgresql_operations.SaveUsageSnapshots(snapshots, "upload", actor)
+			if err != nil {
+				return err
+			}
+			total += saved
+		}
+		fmt.Printf("%d bucket stats saved\n", total)
+		return nil
+
+	case "collect":
+		fs := flag.NewFlagSet("usage collect", flag.ExitOnError)
+		cluster := fs.String("cluster", "", "cluster, all clusters when empty")
+		fs.Parse(args)
+		clusters := []string{*cluster}
+		if *cluster == "" {
+			var err error
+			if clusters, err = bucket_usage.CollectClusters(); err != nil {
+				return err
+			}
+		}
+		failed := 0
+		for _, name := range clusters {
+			snapshots, err := bucket_usage.Collect(name)
+			saved := 0
+			if err == nil {
+				saved, err = postgresql_operations.SaveUsageSnapshots(snapshots, "admin_ops", actor)
+			}
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				continue
+			}
+			fmt.Printf("%s: %d bucket stats saved\n", name, saved)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d clusters failed", failed, len(clusters))
+		}
+		return nil
+
+	case "report":
+		fs := flag.NewFlagSet("usage report", flag.ExitOnError)
+		var filter postgresql_operations.ReportFilter
+		fs.StringVar(&filter.Env, "env", "", "environment")
+		fs.StringVar(&filter.Segment, "segment", "", "security segment")
+		fs.StringVar(&filter.Cluster, "cluster", "", "cluster")
+		fs.StringVar(&filter.RisNumber, "ris-number", "", "RIS number")
+		threshold := fs.Float64("threshold", bucket_usage.ThresholdPercent(), "usage percent of the quota")
+		unusedDays := fs.Int("unused-days", bucket_usage.UnusedDays(), "days without changes")
+		format := fs.String("format", table_export.XLSX, "xlsx or csv, with -o")
+		delimiter := fs.String("delimiter", "comma", "CSV delimiter: comma or semicolon")
+		output := fs.String("o", "", "export the report to a file, - for stdout")
+		fs.Parse(args)
+
+		report, err := bucket_usage.BuildReport(filter, *threshold, *unusedDays)
+		if err != nil {
+			return err
+		}
+		if *output != "" {
+			options, err := table_export.ParseOptions(*format, *delimiter)
+			if err != nil {
+				return err
+			}
+			if err := writeExportFile(*output, options, report.Tables()...); err != nil {
+				return err
+			}
+			if *output != "-" {
+				fmt.Fprintf(os.Stderr, "report written to %s\n", *output)
+			}
+			return nil
+		}
+		if jsonOutput {
+			return printJSON(report)
+		}
+		return printTables(report.Tables())
+
+	default:
+		return fmt.Errorf("unknown usage subcommand: %s (expected import, collect or report)", sub)
+	}
+}
+
+func runClusters(args []string) error {
+	fs := flag.NewFlagSet("clusters", flag.ExitOnError)
+	segment := fs.String("segment", "", "only clusters of a security segment")
+	env := fs.String("env", "", "only clusters of an environment")
+	// "clusters list" reads better in scripts, list is the only action
+	if len(args) > 0 && args[0] == "list" {
+		args = args[1:]
+	}
+	fs.Parse(args)
+
+	all, err := cluster_endpoint_parser.ListClusters("clusters.xlsx")
+	if err != nil {
+		return err
+	}
+	clusters := []cluster_endpoint_parser.ClusterInfo{}
+	for _, cluster := range all {
+		if (*segment == "" || strings.EqualFold(cluster.ЗБ, *segment)) && (*env == "" || strings.EqualFold(cluster.Среда, *env)) {
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	if jsonOutput {
+		return printJSON(clusters)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tREALM\tENV\tSEGMENT\tDC\tTLS ENDPOINT\tMTLS ENDPOINT\tTEMPLATES")
+	for _, c := range clusters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Кластер, c.Реалм, c.Среда, c.ЗБ, c.ЦОД,
+			c.TLSEndpoint, c.MTLSEndpoint, c.Шаблон)
+	}
+	return w.Flush()
+}
+
+func runImportSRT(args []string) error {
+	fs := flag.NewFlagSet("import-srt", flag.ExitOnError)
+	ticketFile := fs.String("ticket", "", "ticket JSON file")
+	paramsFile := fs.String("params", "", "ticket parameters JSON file")
+	number := fs.String("number", "", "fetch the ticket from the ticket API instead")
+	fieldsOnly := fs.Bool("fields", false, "print only the form fields as JSON, for generate -form")
+	fs.Parse(args)
+
+	var ticket, params []byte
+	var err error
+	switch {
+	case *number != "":
+		if err := srt_connector.LoadConfig("srt.json"); err != nil {
+			return fmt.Errorf("failed to load srt config: %v", err)
+		}
+		if !srt_connector.Enabled() {
+			return fmt.Errorf("SRT connector is not enabled")
+		}
+		normalized, err := srt_connector.NormalizeNumber(*number)
+		if err != nil {
+			return err
+		}
+		if ticket, params, err = srt_connector.FetchTicket(normalized); err != nil {
+			return err
+		}
+	case *ticketFile != "" || *paramsFile != "":
+		if *ticketFile != "" {
+			if ticket, err = os.ReadFile(*ticketFile); err != nil {
+				return fmt.Errorf("failed to read ticket file: %v", err)
+			}
+		}
+		if *paramsFile != "" {
+			if params, err = os.ReadFile(*paramsFile); err != nil {
+				return fmt.Errorf("failed to read parameters file: %v", err)
+			}
+		}
+	default:
+		return fmt.Errorf("-number, -ticket or -params is required")
+	}
+
+	result, err := variables_parser.ImportSRT(ticket, params)
+	if err != nil {
+		return err
+	}
+
+	if *fieldsOnly {
+		return printJSON(result.Fields)
+	}
+	if jsonOutput {
+		return printJSON(result)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tSTATUS\tVALUE\tMESSAGE")
+	for _, d := range result.Diagnostics {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Field, d.Status, d.Value, d.Message)
+	}
+	return w.Flush()
+}
+
+// runMigrate applies the schema migrations. It connects without the schema
+// check of the other commands, so it needs only db_config.json, with a role
+// that may run DDL.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only list the migrations that would be applied")
+	fs.Parse(args)
+
+	if err := postgresql_operations.OpenDB("db_config.json"); err != nil {
+		return err
+	}
+	defer postgresql_operations.CloseDB()
+
+	var names []string
+	var err error
+	if *dryRun {
+		names, err = postgresql_operations.PendingMigrations()
+	} else {
+		names, err = postgresql_operations.Migrate()
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "schema is up to date")
+	}
+	return nil
+}
@@ -2,75 +2,252 @@ package email_template
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
 )
 
-func PopulateEmailTemplate(variables map[string][]string, clusters map[string]string) (string, error) {
-	// Helper function to safely get the first element of a slice or return a default value
-	getFirst := func(slice []string, defaultValue string) string {
-		if len(slice) > 0 && slice[0] != "" {
-			return slice[0]
+// Operation types with their own email templates
+const (
+	OpNewTenant   = "new_tenant"
+	OpTenantMod   = "tenant_mod"
+	OpQuotaChange = "quota_change"
+	OpDeletion    = "deletion"
+)
+
+// Operations lists the operation types every template directory must cover
+var Operations = []string{OpNewTenant, OpTenantMod, OpQuotaChange, OpDeletion}
+
+// DefaultLanguage is used when no language is requested or the requested one
+// has no template
+const DefaultLanguage = "ru"
+
+// Email is a rendered email
+type Email struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+	// Version identifies the template files the email was rendered from
+	Version string `json:"version"`
+}
+
+type compiledTemplate struct {
+	text    *template.Template
+	html    *htmltemplate.Template
+	version string
+}
+
+// templates by operation and language
+var templates map[string]map[string]*compiledTemplate
+
+// LoadTemplates reads the templates from dir. Files are named
+// <operation>.<language>.txt with an optional <operation>.<language>.html
+// variant. Each template defines the subject in a "subject" block. Every
+// operation needs a text template in the default language, and every template
+// is trial-rendered so syntax errors fail at startup.
+func LoadTemplates(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return fmt.Errorf("failed to list email templates: %v", err)
+	}
+
+	loaded := map[string]map[string]*compiledTemplate{}
+	for _, path := range paths {
+		operation, language, ok := strings.Cut(strings.TrimSuffix(filepath.Base(path), ".txt"), ".")
+		if !ok || !knownOperation(operation) {
+			return fmt.Errorf("unexpected email template %s (expected <operation>.<language>.txt)", filepath.Base(path))
 		}
-		return defaultValue
+
+		compiled, err := compileTemplate(strings.TrimSuffix(path, ".txt"))
+		if err != nil {
+			return err
+		}
+		if loaded[operation] == nil {
+			loaded[operation] = map[string]*compiledTemplate{}
+		}
+		loaded[operation][language] = compiled
 	}
 
-	// Get tenant from either tenant or tenant_override
-	tenant := getFirst(variables["tenant"], getFirst(variables["tenant_override"], "N/A"))
-
-	data := map[string]interface{}{
-		"email":          getFirst(variables["email"], "N/A"),
-		"request_id_sd":  getFirst(variables["request_id_sd"], "N/A"),
-		"request_id_srt": getFirst(variables["request_id_srt"], "N/A"),
-		"segment":        getFirst(variables["segment"], "N/A"),
-		"env":            getFirst(variables["env"], "N/A"),
-		"tenant":         tenant,
-		"users":          variables["users"],
-		"bucketnames":    variables["bucketnames"],
-		"bucketquotas":   variables["bucketquotas"],
-		"tls_endpoint":   clusters["tls_endpoint"],
-		"mtls_endpoint":  clusters["mtls_endpoint"],
-	}
-
-	tmpl, err := template.New("email").Parse(emailTemplate)
+	for _, operation := range Operations {
+		if loaded[operation][DefaultLanguage] == nil {
+			return fmt.Errorf("email template %s.%s.txt is missing", operation, DefaultLanguage)
+		}
+	}
+
+	templates = loaded
+	return nil
+}
+
+func knownOperation(operation string) bool {
+	for _, op := range Operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// compileTemplate parses the text and optional HTML variant at base and
+// renders them with sample data
+func compileTemplate(base string) (*compiledTemplate, error) {
+	name := filepath.Base(base)
+	textSource, err := os.ReadFile(base + ".txt")
 	if err != nil {
-		return "", fmt.Errorf("error parsing template: %v", err)
+		return nil, fmt.Errorf("failed to read email template %s: %v", name, err)
 	}
+	hash := sha256.New()
+	hash.Write(textSource)
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("error executing template: %v", err)
+	compiled := &compiledTemplate{}
+	compiled.text, err = template.New(name).Option("missingkey=zero").Parse(string(textSource))
+	if err != nil {
+		return nil, fmt.Errorf("invalid email template %s.txt: %v", name, err)
+	}
+	if compiled.text.Lookup("subject") == nil {
+		return nil, fmt.Errorf("email template %s.txt has no subject block", name)
+	}
+
+	htmlSource, err := os.ReadFile(base + ".html")
+	if err == nil {
+		hash.Write(htmlSource)
+		compiled.html, err = htmltemplate.New(name).Option("missingkey=zero").Parse(string(htmlSource))
+		if err != nil {
+			return nil, fmt.Errorf("invalid email template %s.html: %v", name, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read email template %s.html: %v", name, err)
 	}
+	compiled.version = hex.EncodeToString(hash.Sum(nil))[:12]
 
-	return buf.String(), nil
+	if _, err := compiled.render(templateData(SampleVariables(), sampleClusters)); err != nil {
+		return nil, fmt.Errorf("email template %s: %v", name, err)
+	}
+	return compiled, nil
 }
 
-// Keep your existing emailTemplate const
-const emailTemplate = `
-Добрый день.
-
-Вы указаны получателем данных от УЗ созданных в рамках обращения {{.request_id_sd}} / {{.request_id_srt}}.
-
-Сегмент: {{.segment}}
-Окружение: {{.env}}
-Тенант: {{.tenant}}
-Endpoints для подключения:
-{{.tls_endpoint}}
-{{.mtls_endpoint}}
-
-Пользователи созданы:
-{{- range .users}}
-{{- if .}}
-- {{.}}
-{{- end}}
-{{- end}}
-
-Бакеты созданы:
-{{- range $index, $bucket := .bucketnames}}
-{{- if $bucket}}
-- {{$bucket}} ({{index $.bucketquotas $index}}G)
-{{- end}}
-{{- end}}
-
-Данные от созданных УЗ переданы {{.email}}
-`
+func (t *compiledTemplate) render(data map[string]interface{}) (Email, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Email{}, fmt.Errorf("error executing subject: %v", err)
+	}
+	if err := t.text.Execute(&text, data); err != nil {
+		return Email{}, fmt.Errorf("error executing template: %v", err)
+	}
+	if t.html != nil {
+		if err := t.html.Execute(&html, data); err != nil {
+			return Email{}, fmt.Errorf("error executing HTML template: %v", err)
+		}
+	}
+	return Email{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+		Version: t.version,
+	}, nil
+}
+
+// Render renders the email of an operation in a language, falling back to the
+// default language
+func Render(operation, language string, variables map[string][]string, clusters map[string]string) (Email, error) {
+	byLanguage, ok := templates[operation]
+	if !ok {
+		return Email{}, fmt.Errorf("no email template for operation '%s'", operation)
+	}
+	tmpl, ok := byLanguage[strings.ToLower(language)]
+	if !ok {
+		tmpl = byLanguage[DefaultLanguage]
+	}
+	return tmpl.render(templateData(variables, clusters))
+}
+
+// Languages returns the languages with a template for operation
+func Languages(operation string) []string {
+	var languages []string
+	for language := range templates[operation] {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// PopulateEmailTemplate returns the text of the email for created users and
+// buckets: the new tenant template when a tenant is created, otherwise the
+// template for resources added to an existing tenant
+func PopulateEmailTemplate(variables map[string][]string, clusters map[string]string) (string, error) {
+	email, err := Render(Operation(variables), first(variables["email_lang"], DefaultLanguage), variables, clusters)
+	if err != nil {
+		return "", err
+	}
+	return email.Text, nil
+}
+
+// Operation returns the operation type of a creation request
+func Operation(variables map[string][]string) string {
+	if first(variables["create_tenant"], "") == "true" {
+		return OpNewTenant
+	}
+	return OpTenantMod
+}
+
+func first(values []string, defaultValue string) string {
+	if len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return defaultValue
+}
+
+// templateData exposes every variable list to the templates, with the
+// request fields and endpoints as single values
+func templateData(variables map[string][]string, clusters map[string]string) map[string]interface{} {
+	data := map[string]interface{}{}
+	for key, values := range variables {
+		data[key] = values
+	}
+
+	for _, key := range []string{"email", "request_id_sd", "request_id_srt", "segment", "env"} {
+		data[key] = first(variables[key], "N/A")
+	}
+	// Get tenant from either tenant or tenant_override
+	data["tenant"] = first(variables["tenant"], first(variables["tenant_override"], "N/A"))
+	for _, key := range []string{"owner", "zam_owner", "encrypted_file", "quota_state"} {
+		data[key] = first(variables[key], "")
+	}
+	data["tls_endpoint"] = clusters["tls_endpoint"]
+	data["mtls_endpoint"] = clusters["mtls_endpoint"]
+	return data
+}
+
+var sampleClusters = map[string]string{
+	"tls_endpoint":  "https://s3.example.ru",
+	"mtls_endpoint": "https://s3-mtls.example.ru",
+}
+
+// SampleVariables returns request data covering every template field, used
+// to validate templates and for previews
+func SampleVariables() map[string][]string {
+	return map[string][]string{
+		"email":             {"user@example.ru"},
+		"owner":             {"owner@example.ru"},
+		"zam_owner":         {"deputy@example.ru"},
+		"request_id_sd":     {"SD-0000001"},
+		"request_id_srt":    {"SRT-0000001"},
+		"segment":           {"INET"},
+		"env":               {"PROD"},
+		"tenant":            {"ift-cosd-tenant"},
+		"users":             {"ift_cosd_user1", "ift_cosd_user2"},
+		"bucketnames":       {"ift-cosd-bucket1", "ift-cosd-bucket2"},
+		"bucketquotas":      {"100G", "1T"},
+		"quotausers":        {"ift_cosd_user1"},
+		"quotausersizes":    {"500G"},
+		"bucketoldquotas":   {"50G", "500G"},
+		"quotauseroldsizes": {"200G"},
+		"encrypted_file":    {"ift-cosd-tenant_credentials.txt.asc"},
+		"quota_state":       {"enable"},
+	}
+}
@@ -0,0 +1,252 @@
+package email_template
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// loadRepoTemplates loads the shipped templates for one test and restores
+// the previous ones afterwards
+func loadRepoTemplates(t *testing.T) {
+	t.Helper()
+	saved := templates
+	t.Cleanup(func() { templates = saved })
+	if err := LoadTemplates("../email_templates"); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+}
+
+// goldenCases are the requests rendered for every language, shaped like the
+// variables the request handlers pass
+var goldenCases = []struct {
+	name      string
+	operation string
+	variables map[string][]string
+	clusters  map[string]string
+}{
+	{name: "new_tenant", operation: OpNewTenant, variables: SampleVariables(), clusters: sampleClusters},
+	{name: "tenant_mod", operation: OpTenantMod, variables: SampleVariables(), clusters: sampleClusters},
+	{
+		name:      "quota_change.sizes",
+		operation: OpQuotaChange,
+		variables: map[string][]string{
+			"tenant":            {"ift-cosd-tenant"},
+			"request_id_srt":    {"SRT-0000001"},
+			"quota_state":       {""},
+			"bucketnames":       {"ift-cosd-bucket1", "ift-cosd-bucket2"},
+			"bucketoldquotas":   {"50G", "-"},
+			"bucketquotas":      {"100G, objects=1000", "1T"},
+			"quotausers":        {"ift_cosd_user1"},
+			"quotauseroldsizes": {"200G"},
+			"quotausersizes":    {"500G"},
+		},
+	},
+	{
+		name:      "quota_change.disable",
+		operation: OpQuotaChange,
+		variables: map[string][]string{
+			"tenant":         {"ift-cosd-tenant"},
+			"request_id_srt": {""},
+			"quota_state":    {"disable"},
+			"bucketnames":    {"ift-cosd-bucket1"},
+		},
+	},
+	{
+		name:      "deletion",
+		operation: OpDeletion,
+		variables: map[string][]string{
+			"tenant":         {"ift-cosd-tenant"},
+			"request_id_srt": {"SRT-0000001"},
+			"users":          {"ift_cosd_user1"},
+			"bucketnames":    {"ift-cosd-bucket1", "ift-cosd-bucket2"},
+		},
+	},
+	{
+		name:      "deletion.users_only",
+		operation: OpDeletion,
+		variables: map[string][]string{
+			"tenant":         {"ift-cosd-tenant"},
+			"request_id_srt": {""},
+			"users":          {"ift_cosd_user1"},
+		},
+	},
+}
+
+func TestTemplatesGolden(t *testing.T) {
+	loadRepoTemplates(t)
+
+	for _, tc := range goldenCases {
+		languages := Languages(tc.operation)
+		if len(languages) < 2 {
+			t.Errorf("%s: templates only in %q", tc.operation, languages)
+		}
+		for _, language := range languages {
+			t.Run(tc.name+"."+language, func(t *testing.T) {
+				email, err := Render(tc.operation, language, tc.variables, tc.clusters)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if email.HTML == "" {
+					t.Errorf("no HTML variant rendered")
+				}
+				got := "## subject\n" + email.Subject + "\n\n## text\n" + email.Text + "\n\n## html\n" + email.HTML + "\n"
+
+				golden := filepath.Join("testdata", tc.name+"."+language+".golden")
+				if *update {
+					if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%v (run go test -update to create it)", err)
+				}
+				if got != string(want) {
+					t.Errorf("email differs from %s:\n%s", golden, got)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderLanguage(t *testing.T) {
+	loadRepoTemplates(t)
+	variables := goldenCases[4].variables
+
+	english, err := Render(OpDeletion, "en", variables, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	russian, err := Render(OpDeletion, "ru", variables, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if english.Subject == russian.Subject || english.Version == russian.Version {
+		t.Fatalf("en and ru rendered alike: %q, %q", english.Subject, russian.Subject)
+	}
+
+	for language, want := range map[string]Email{"EN": english, "": russian, "de": russian} {
+		got, err := Render(OpDeletion, language, variables, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("language %q rendered %q, want %q", language, got.Subject, want.Subject)
+		}
+	}
+
+	if _, err := Render("unknown", "ru", variables, nil); err == nil {
+		t.Error("unknown operation rendered")
+	}
+}
+
+func TestPopulateEmailTemplate(t *testing.T) {
+	loadRepoTemplates(t)
+	variables := SampleVariables()
+	variables["email_lang"] = []string{"en"}
+
+	variables["create_tenant"] = []string{"true"}
+	text, err := PopulateEmailTemplate(variables, sampleClusters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := Render(OpNewTenant, "en", variables, sampleClusters)
+	if text != want.Text {
+		t.Errorf("new tenant email = %q, want %q", text, want.Text)
+	}
+
+	delete(variables, "create_tenant")
+	if got := Operation(variables); got != OpTenantMod {
+		t.Errorf("Operation() = %s, want %s", got, OpTenantMod)
+	}
+}
+
+// writeTemplates creates a template directory with a text template of every
+// operation in the default language and the given files on top
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, operation := range Operations {
+		path := filepath.Join(dir, operation+"."+DefaultLanguage+".txt")
+		if err := os.WriteFile(path, []byte(`{{define "subject"}}Тема{{end}}Текст {{.tenant}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if content == "" {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadTemplatesRejects(t *testing.T) {
+	saved := templates
+	t.Cleanup(func() { templates = saved })
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"missing default language", map[string]string{"deletion.ru.txt": "", "deletion.en.txt": `{{define "subject"}}S{{end}}`}, "deletion.ru.txt is missing"},
+		{"unknown operation", map[string]string{"welcome.ru.txt": `{{define "subject"}}S{{end}}`}, "unexpected email template welcome.ru.txt"},
+		{"no language", map[string]string{"deletion.txt": `{{define "subject"}}S{{end}}`}, "unexpected email template deletion.txt"},
+		{"syntax error", map[string]string{"deletion.en.txt": `{{define "subject"}}S{{end}}{{.tenant`}, "invalid email template deletion.en.txt"},
+		{"no subject", map[string]string{"deletion.en.txt": `Текст`}, "has no subject block"},
+		{"HTML syntax error", map[string]string{"deletion.ru.html": `<p>{{if .tenant}}</p>`}, "invalid email template deletion.ru.html"},
+		{"render error", map[string]string{"deletion.ru.txt": `{{define "subject"}}S{{end}}{{index .users 5}}`}, "email template deletion.ru"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := LoadTemplates(writeTemplates(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadTemplates() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateVersion(t *testing.T) {
+	saved := templates
+	t.Cleanup(func() { templates = saved })
+
+	render := func(files map[string]string) Email {
+		t.Helper()
+		if err := LoadTemplates(writeTemplates(t, files)); err != nil {
+			t.Fatal(err)
+		}
+		email, err := Render(OpDeletion, "ru", map[string][]string{"tenant": {"ift-cosd-tenant"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return email
+	}
+
+	text := render(nil)
+	if text.HTML != "" || text.Text != "Текст ift-cosd-tenant" || text.Subject != "Тема" {
+		t.Errorf("text only template rendered %+v", text)
+	}
+	if again := render(nil); again.Version != text.Version {
+		t.Errorf("version of unchanged files changed: %s, %s", text.Version, again.Version)
+	}
+	withHTML := render(map[string]string{"deletion.ru.html": "<p>{{.tenant}}</p>"})
+	if withHTML.HTML != "<p>ift-cosd-tenant</p>" {
+		t.Errorf("HTML = %q", withHTML.HTML)
+	}
+	if withHTML.Version == text.Version {
+		t.Error("adding the HTML variant kept the version")
+	}
+}
@@ -3,11 +3,13 @@ module github.com/NarrativeBias/zayavki
 go 1.22
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.6
 	github.com/lib/pq v1.10.9
 	github.com/xuri/excelize/v2 v2.8.1
 )
 
 require (
+	github.com/cloudflare/circl v1.3.7 // indirect
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
 	github.com/richardlehane/mscfb v1.0.4 // indirect
 	github.com/richardlehane/msoleps v1.0.3 // indirect
@@ -15,5 +17,6 @@ require (
 	github.com/xuri/nfp v0.0.0-20230919160717-d98342af3f05 // indirect
 	golang.org/x/crypto v0.19.0 // indirect
 	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader carries the request ID, taken from a proxy when it sets
+// one and returned to the client
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// maxErrorBody limits how much of an error response is logged
+const maxErrorBody = 512
+
+// responseRecorder keeps the status code and the start of an error body
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status >= http.StatusInternalServerError && len(r.body) < maxErrorBody {
+		r.body = append(r.body, data[:min(len(data), maxErrorBody-len(r.body))]...)
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+// Middleware gives every request an ID and logs it when it is done. The
+// request context carries the ID and the actor, and handlers add the ticket
+// numbers and tenant with Annotate. Server errors are logged with the start
+// of their response, as that is where handlers put the error.
+func Middleware(next http.Handler, actor func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := NewContext(r.Context(), slog.String("request_id", id), slog.String("actor", actor(r)))
+		r = r.WithContext(ctx)
+
+		// Handlers such as stripPrefix rewrite the path, so the one requested is
+		// kept for the log line
+		path := r.URL.Path
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", path),
+			slog.Int("status", recorder.status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		lvl := slog.LevelInfo
+		switch {
+		case recorder.status >= http.StatusInternalServerError:
+			lvl = slog.LevelError
+			attrs = append(attrs, slog.String("error", strings.TrimSpace(string(recorder.body))))
+		case recorder.status >= http.StatusBadRequest:
+			lvl = slog.LevelWarn
+		case strings.Contains(path, "/static/") || path == "/metrics":
+			lvl = slog.LevelDebug
+		}
+		slog.LogAttrs(ctx, lvl, "request", attrs...)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
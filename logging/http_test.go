@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLogs makes slog write JSON lines to the returned buffer for the
+// duration of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	saved, savedLevel := slog.Default(), level.Level()
+	t.Cleanup(func() {
+		slog.SetDefault(saved)
+		level.Set(savedLevel)
+	})
+	var buf bytes.Buffer
+	if err := Setup(&buf, Config{Level: "debug", Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestMiddlewareLogsRequestedPath(t *testing.T) {
+	logs := captureLogs(t)
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Like stripPrefix in the web app
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/zayavki")
+		Annotate(r.Context(), "tenant", "tenant-a", "sd_num", "-")
+		slog.InfoContext(r.Context(), "handled")
+		http.Error(w, "db is down", http.StatusInternalServerError)
+	}), func(*http.Request) string { return "operator" })
+
+	request := httptest.NewRequest("POST", "/zayavki/submit", nil)
+	request.Header.Set(RequestIDHeader, "req-1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if got := recorder.Header().Get(RequestIDHeader); got != "req-1" {
+		t.Errorf("%s = %q, want req-1", RequestIDHeader, got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2:\n%s", len(lines), logs)
+	}
+	var handled, done map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &handled); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &done); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range []map[string]interface{}{handled, done} {
+		if entry["request_id"] != "req-1" || entry["actor"] != "operator" || entry["tenant"] != "tenant-a" {
+			t.Errorf("log line lacks the request attributes: %v", entry)
+		}
+		if _, ok := entry["sd_num"]; ok {
+			t.Errorf("empty annotation logged: %v", entry)
+		}
+	}
+	if done["path"] != "/zayavki/submit" {
+		t.Errorf("path = %v, want the requested /zayavki/submit", done["path"])
+	}
+	if done["level"] != "ERROR" || done["error"] != "db is down" || done["status"] != float64(500) {
+		t.Errorf("server error logged as %v", done)
+	}
+}
+
+func TestMiddlewareReplacesInvalidRequestID(t *testing.T) {
+	captureLogs(t)
+	handler := Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		func(*http.Request) string { return "" })
+
+	request := httptest.NewRequest("GET", "/zayavki/", nil)
+	request.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if got := recorder.Header().Get(RequestIDHeader); !requestIDPattern.MatchString(got) || got == "bad id\nwith newline" {
+		t.Errorf("%s = %q, want a generated ID", RequestIDHeader, got)
+	}
+}
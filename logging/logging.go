@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Config holds the logging settings
+type Config struct {
+	// Level is debug, info, warn or error
+	Level string `json:"level"`
+	// Format is json, or text for reading logs in a terminal
+	Format string `json:"format"`
+}
+
+var level = new(slog.LevelVar)
+
+// LoadConfig reads the logging settings from a JSON config file and makes
+// slog log to stderr with them. The standard log package writes through
+// slog too, at the info level.
+func LoadConfig(configPath string) error {
+	file, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read logging config: %v", err)
+	}
+
+	cfg := Config{Level: "info", Format: "json"}
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return fmt.Errorf("failed to parse logging config: %v", err)
+	}
+	return Setup(os.Stderr, cfg)
+}
+
+// Setup makes slog log to w with the settings
+func Setup(w io.Writer, cfg Config) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return fmt.Errorf("unknown log level: %s (expected debug, info, warn or error)", cfg.Level)
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return fmt.Errorf("unknown log format: %s (expected json or text)", cfg.Format)
+	}
+
+	level.Set(lvl)
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// fields are the attributes of a request, such as its ID, actor and the
+// ticket it works on. They are filled in as the request is read, so they
+// are shared by pointer.
+type fields struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type fieldsKey struct{}
+
+// NewContext returns a context whose log lines carry attrs and the
+// attributes of ctx
+func NewContext(ctx context.Context, attrs ...slog.Attr) context.Context {
+	f := &fields{attrs: append(contextAttrs(ctx), attrs...)}
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// Annotate adds key-value pairs to the log lines of a context created by
+// NewContext, replacing earlier values of the same keys. Empty values are
+// skipped, so optional fields can be passed as they are.
+func Annotate(ctx context.Context, args ...string) {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		key, value := args[i], args[i+1]
+		if value == "" || value == "-" {
+			continue
+		}
+		replaced := false
+		for j := range f.attrs {
+			if f.attrs[j].Key == key {
+				f.attrs[j] = slog.String(key, value)
+				replaced = true
+			}
+		}
+		if !replaced {
+			f.attrs = append(f.attrs, slog.String(key, value))
+		}
+	}
+}
+
+func contextAttrs(ctx context.Context) []slog.Attr {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]slog.Attr(nil), f.attrs...)
+}
+
+// contextHandler adds the attributes of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(contextAttrs(ctx)...)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
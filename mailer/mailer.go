@@ -0,0 +1,201 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the SMTP relay settings
+type Config struct {
+	// Enabled turns on sending, otherwise emails are only shown as text
+	Enabled  bool   `json:"enabled"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+var config = Config{Host: "localhost", Port: 25}
+
+// LoadConfig reads the SMTP settings from a JSON config file
+func LoadConfig(configPath string) error {
+	file, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read mailer config: %v", err)
+	}
+
+	cfg := config
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return fmt.Errorf("failed to parse mailer config: %v", err)
+	}
+	if !cfg.Enabled {
+		config = cfg
+		return nil
+	}
+
+	if cfg.Host == "" || cfg.Port <= 0 || cfg.From == "" {
+		return fmt.Errorf("host, port and from are required when the mailer is enabled")
+	}
+
+	config = cfg
+	return nil
+}
+
+// Enabled reports whether emails are sent
+func Enabled() bool {
+	return config.Enabled
+}
+
+func (c Config) addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// Attachment is a file sent with a message
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an email to send
+type Message struct {
+	To      []string
+	Cc      []string
+	Subject string
+	Body    string
+	// HTML is an optional HTML variant of Body
+	HTML        string
+	Attachments []Attachment
+}
+
+// Recipients returns the distinct non-empty addresses of the message
+func (m Message) Recipients() []string {
+	seen := map[string]bool{}
+	var recipients []string
+	for _, address := range append(append([]string{}, m.To...), m.Cc...) {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if address != "" && !seen[address] {
+			seen[address] = true
+			recipients = append(recipients, address)
+		}
+	}
+	return recipients
+}
+
+// Send delivers a message through the configured SMTP relay
+func Send(msg Message) error {
+	if !config.Enabled {
+		return fmt.Errorf("mailer is not enabled")
+	}
+	recipients := msg.Recipients()
+	if len(recipients) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+
+	data := buildMessage(config.From, msg)
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+	if err := smtp.SendMail(config.addr(), auth, config.From, recipients, data); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+func buildMessage(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+
+	header("From", from)
+	header("To", strings.Join(nonEmpty(msg.To), ", "))
+	if cc := nonEmpty(msg.Cc); len(cc) > 0 {
+		header("Cc", strings.Join(cc, ", "))
+	}
+	header("Subject", mime.BEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if len(msg.Attachments) == 0 {
+		writeBody(&buf, msg)
+		return buf.Bytes()
+	}
+
+	boundary := fmt.Sprintf("zayavki-%d", time.Now().UnixNano())
+	header("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%q", boundary))
+	buf.WriteString("\r\n")
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	writeBody(&buf, msg)
+
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		header("Content-Type", contentType)
+		header("Content-Transfer-Encoding", "base64")
+		header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+		buf.WriteString("\r\n")
+		writeBase64(&buf, attachment.Data)
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes()
+}
+
+// writeBody writes the text part of a message, with the HTML variant as a
+// multipart/alternative when it is set
+func writeBody(buf *bytes.Buffer, msg Message) {
+	part := func(contentType, content string) {
+		fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n", contentType)
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64(buf, []byte(content))
+	}
+
+	if msg.HTML == "" {
+		part("text/plain", msg.Body)
+		return
+	}
+
+	boundary := fmt.Sprintf("zayavki-alt-%d", time.Now().UnixNano())
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(buf, "--%s\r\n", boundary)
+	part("text/plain", msg.Body)
+	fmt.Fprintf(buf, "--%s\r\n", boundary)
+	part("text/html", msg.HTML)
+	fmt.Fprintf(buf, "--%s--\r\n", boundary)
+}
+
+// writeBase64 writes data base64-encoded in lines of 76 characters
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
+
+func nonEmpty(addresses []string) []string {
+	var result []string
+	for _, address := range addresses {
+		if address = strings.TrimSpace(address); address != "" {
+			result = append(result, address)
+		}
+	}
+	return result
+}
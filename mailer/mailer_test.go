@@ -0,0 +1,191 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func startRelay(t *testing.T) *TestServer {
+	t.Helper()
+	server, err := StartTestServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	saved := config
+	t.Cleanup(func() { config = saved })
+	host, port := splitAddr(server.Addr())
+	config = Config{Enabled: true, Host: host, Port: port, From: "zayavki@example.com"}
+	return server
+}
+
+// readPart returns the media type and decoded body of a part
+func readPart(t *testing.T, part *multipart.Part) (string, []byte) {
+	t.Helper()
+	mediaType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body io.Reader = part
+	if part.Header.Get("Content-Transfer-Encoding") == "base64" {
+		body = base64.NewDecoder(base64.StdEncoding, part)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mediaType, data
+}
+
+func multipartReader(t *testing.T, contentType string, body io.Reader, want string) *multipart.Reader {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != want {
+		t.Fatalf("content type %s, want %s", mediaType, want)
+	}
+	return multipart.NewReader(body, params["boundary"])
+}
+
+func TestSend(t *testing.T) {
+	server := startRelay(t)
+
+	err := Send(Message{
+		To:      []string{"owner@example.com", " "},
+		Cc:      []string{"Team@Example.com", "team@example.com", "OWNER@example.com"},
+		Subject: "Заявка SRT-1234",
+		Body:    "Ресурсы созданы",
+		HTML:    "<p>Ресурсы созданы</p>",
+		Attachments: []Attachment{
+			{Name: "ten-acme_credentials.txt.asc", ContentType: "application/pgp-encrypted", Data: []byte("secret")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("relay received %d messages", len(messages))
+	}
+	received := messages[0]
+	if received.From != "zayavki@example.com" {
+		t.Errorf("MAIL FROM %s", received.From)
+	}
+	if got := strings.Join(received.To, ","); got != "owner@example.com,team@example.com" {
+		t.Errorf("RCPT TO %s, want each address once", got)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(received.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Заявка SRT-1234" {
+		t.Errorf("Subject %q, %v", subject, err)
+	}
+	if to := msg.Header.Get("To"); to != "owner@example.com" {
+		t.Errorf("To header %q", to)
+	}
+
+	mixed := multipartReader(t, msg.Header.Get("Content-Type"), msg.Body, "multipart/mixed")
+
+	bodyPart, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alternative := multipartReader(t, bodyPart.Header.Get("Content-Type"), bodyPart, "multipart/alternative")
+	for _, want := range []struct{ mediaType, content string }{
+		{"text/plain", "Ресурсы созданы"},
+		{"text/html", "<p>Ресурсы созданы</p>"},
+	} {
+		part, err := alternative.NextPart()
+		if err != nil {
+			t.Fatalf("%s part: %v", want.mediaType, err)
+		}
+		mediaType, content := readPart(t, part)
+		if mediaType != want.mediaType || string(content) != want.content {
+			t.Errorf("got %s %q, want %s %q", mediaType, content, want.mediaType, want.content)
+		}
+	}
+	if _, err := alternative.NextPart(); err != io.EOF {
+		t.Errorf("unexpected part after the HTML variant: %v", err)
+	}
+
+	attachment, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := attachment.FileName(); name != "ten-acme_credentials.txt.asc" {
+		t.Errorf("attachment name %q", name)
+	}
+	mediaType, content := readPart(t, attachment)
+	if mediaType != "application/pgp-encrypted" || string(content) != "secret" {
+		t.Errorf("attachment %s %q", mediaType, content)
+	}
+	if _, err := mixed.NextPart(); err != io.EOF {
+		t.Errorf("unexpected part after the attachment: %v", err)
+	}
+}
+
+func TestSendRequiresRecipients(t *testing.T) {
+	server := startRelay(t)
+	if err := Send(Message{To: []string{" "}, Subject: "empty"}); err == nil {
+		t.Error("message without recipients was sent")
+	}
+	config.Enabled = false
+	if err := Send(Message{To: []string{"owner@example.com"}}); err == nil {
+		t.Error("message was sent with the mailer disabled")
+	}
+	if n := len(server.Messages()); n != 0 {
+		t.Errorf("relay received %d messages", n)
+	}
+}
+
+func TestBuildMessagePlainText(t *testing.T) {
+	data := buildMessage("zayavki@example.com", Message{
+		To:      []string{"owner@example.com"},
+		Subject: "Заявка",
+		Body:    strings.Repeat("длинная строка ", 20),
+	})
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc := msg.Header.Get("Cc"); cc != "" {
+		t.Errorf("empty Cc is written as %q", cc)
+	}
+	mediaType, _, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/plain" {
+		t.Fatalf("content type %s, %v", mediaType, err)
+	}
+	raw, err := io.ReadAll(msg.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(raw), "\r\n"), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("body line is %d characters long", len(line))
+		}
+	}
+	body, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(raw)))
+	if err != nil || string(body) != strings.Repeat("длинная строка ", 20) {
+		t.Errorf("body %q, %v", body, err)
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	msg := Message{To: []string{"A@example.com", ""}, Cc: []string{" a@example.com ", "b@example.com"}}
+	if got := strings.Join(msg.Recipients(), ","); got != "a@example.com,b@example.com" {
+		t.Errorf("Recipients() = %s", got)
+	}
+}
@@ -0,0 +1,150 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ReceivedMessage is a message accepted by the test server
+type ReceivedMessage struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// TestServer is a minimal in-process SMTP server that accepts every message
+// and keeps it in memory, standing in for the relay in the tests
+type TestServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []ReceivedMessage
+}
+
+// StartTestServer listens on addr, use port 0 for a random port
+func StartTestServer(addr string) (*TestServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start test SMTP server: %v", err)
+	}
+	server := &TestServer{listener: listener}
+	go server.serve()
+	return server, nil
+}
+
+// Addr returns the address the server listens on
+func (s *TestServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Messages returns the messages received so far
+func (s *TestServer) Messages() []ReceivedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ReceivedMessage(nil), s.messages...)
+}
+
+// Close stops the server
+func (s *TestServer) Close() error {
+	return s.listener.Close()
+}
+
+func (s *TestServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *TestServer) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(code int, message string) bool {
+		return text.PrintfLine("%d %s", code, message) == nil
+	}
+
+	if !reply(220, "zayavki test SMTP server") {
+		return
+	}
+
+	var current ReceivedMessage
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			reply(250, "localhost")
+		case "MAIL":
+			current = ReceivedMessage{From: addressArg(arg)}
+			reply(250, "OK")
+		case "RCPT":
+			current.To = append(current.To, addressArg(arg))
+			reply(250, "OK")
+		case "DATA":
+			if current.From == "" || len(current.To) == 0 {
+				reply(503, "need MAIL and RCPT first")
+				continue
+			}
+			reply(354, "end data with <CR><LF>.<CR><LF>")
+			data, err := readData(text.R)
+			if err != nil {
+				return
+			}
+			current.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, current)
+			s.mu.Unlock()
+			current = ReceivedMessage{}
+			reply(250, "OK")
+		case "RSET":
+			current = ReceivedMessage{}
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+func readData(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return buf.Bytes(), nil
+		}
+		// Undo dot-stuffing
+		buf.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func splitAddr(addr string) (string, int) {
+	host, port, _ := net.SplitHostPort(addr)
+	n, _ := strconv.Atoi(port)
+	return host, n
+}
+
+// addressArg extracts the address from "FROM:<a@b>" or "TO:<a@b>"
+func addressArg(arg string) string {
+	_, address, _ := strings.Cut(arg, ":")
+	address, _, _ = strings.Cut(strings.TrimSpace(address), " ")
+	return strings.Trim(address, "<>")
+}
@@ -1,19 +1,39 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
-
+	"time"
+
+	"github.com/NarrativeBias/zayavki/access_keys"
+	"github.com/NarrativeBias/zayavki/approvals"
+	"github.com/NarrativeBias/zayavki/batch_import"
+	"github.com/NarrativeBias/zayavki/bucket_features"
+	"github.com/NarrativeBias/zayavki/bucket_policy"
+	"github.com/NarrativeBias/zayavki/bucket_usage"
+	"github.com/NarrativeBias/zayavki/capacity_reports"
 	"github.com/NarrativeBias/zayavki/cluster_endpoint_parser"
 	"github.com/NarrativeBias/zayavki/email_template"
+	"github.com/NarrativeBias/zayavki/logging"
+	"github.com/NarrativeBias/zayavki/mailer"
+	"github.com/NarrativeBias/zayavki/metrics"
+	"github.com/NarrativeBias/zayavki/pgp_delivery"
 	"github.com/NarrativeBias/zayavki/postgresql_operations"
-	"github.com/NarrativeBias/zayavki/prep_db_table_data"
+	"github.com/NarrativeBias/zayavki/request_lifecycle"
+	"github.com/NarrativeBias/zayavki/request_processing"
 	"github.com/NarrativeBias/zayavki/rgw_commands"
-	"github.com/NarrativeBias/zayavki/tenant_name_generation"
+	"github.com/NarrativeBias/zayavki/srt_connector"
+	"github.com/NarrativeBias/zayavki/table_export"
 	"github.com/NarrativeBias/zayavki/variables_parser"
 )
 
@@ -29,12 +49,54 @@ func init() {
 }
 
 func main() {
+	if err := logging.LoadConfig("logging.json"); err != nil {
+		log.Fatalf("Failed to load logging config: %v", err)
+	}
+
 	err := postgresql_operations.InitDB("db_config.json")
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer postgresql_operations.CloseDB()
 
+	if err := rgw_commands.LoadTemplates("command_templates.json"); err != nil {
+		log.Fatalf("Failed to load command templates: %v", err)
+	}
+
+	if err := email_template.LoadTemplates("email_templates"); err != nil {
+		log.Fatalf("Failed to load email templates: %v", err)
+	}
+
+	if err := access_keys.LoadConfig("access_keys.json"); err != nil {
+		log.Fatalf("Failed to load access key config: %v", err)
+	}
+
+	if err := pgp_delivery.LoadConfig("pgp_delivery.json"); err != nil {
+		log.Fatalf("Failed to load pgp config: %v", err)
+	}
+
+	if err := mailer.LoadConfig("mailer.json"); err != nil {
+		log.Fatalf("Failed to load mailer config: %v", err)
+	}
+
+	if err := approvals.LoadConfig("approvals.json"); err != nil {
+		log.Fatalf("Failed to load approvals config: %v", err)
+	}
+
+	if err := srt_connector.LoadConfig("srt.json"); err != nil {
+		log.Fatalf("Failed to load srt config: %v", err)
+	}
+	if srt_connector.WriteBackEnabled() {
+		go request_processing.RunSRTOutbox(context.Background())
+	}
+
+	if err := bucket_usage.LoadConfig("usage.json"); err != nil {
+		log.Fatalf("Failed to load usage config: %v", err)
+	}
+	if bucket_usage.CollectorEnabled() {
+		go bucket_usage.RunCollector(context.Background())
+	}
+
 	mux := http.NewServeMux()
 
 	fs := http.FileServer(http.Dir("web/static"))
@@ -44,12 +106,126 @@ func main() {
 	mux.HandleFunc("/zayavki/", stripPrefix(handleIndex))
 	mux.HandleFunc("/zayavki/cluster", stripPrefix(handleClusterSelection))
 	mux.HandleFunc("/zayavki/check", stripPrefix(handleCheck))
+	mux.HandleFunc("/zayavki/search", stripPrefix(handleSearch))
+	mux.HandleFunc("/zayavki/search/text", stripPrefix(handleTextSearch))
+	mux.HandleFunc("/zayavki/export/search", stripPrefix(handleExportSearch))
+	mux.HandleFunc("/zayavki/export/tenant", stripPrefix(handleExportTenant))
+	mux.HandleFunc("/zayavki/tenant", stripPrefix(handleTenant))
+	mux.HandleFunc("/zayavki/reports", stripPrefix(handleReports))
+	mux.HandleFunc("/zayavki/reports/allocation", stripPrefix(handleAllocationReport))
+	mux.HandleFunc("/zayavki/reports/growth", stripPrefix(handleGrowthReport))
+	mux.HandleFunc("/zayavki/export/report", stripPrefix(handleExportReport))
+	mux.HandleFunc("/zayavki/usage/import", stripPrefix(handleUsageImport))
+	mux.HandleFunc("/zayavki/usage/collect", stripPrefix(handleUsageCollect))
+	mux.HandleFunc("/zayavki/usage/report", stripPrefix(handleUsageReport))
+	mux.HandleFunc("/zayavki/export/usage", stripPrefix(handleExportUsage))
 	mux.HandleFunc("/zayavki/cluster-info", stripPrefix(handleClusterInfo))
 	mux.HandleFunc("/zayavki/check-tenant-resources", stripPrefix(handleCheckTenantResources))
 	mux.HandleFunc("/zayavki/deactivate-resources", stripPrefix(handleDeactivateResources))
-	mux.HandleFunc("/zayavki/update-bucket-quotas", stripPrefix(handleUpdateBucketQuotas))
+	mux.HandleFunc("/zayavki/update-quotas", stripPrefix(handleUpdateQuotas))
+	// The old path of the quota update, kept for existing clients and scripts
+	mux.HandleFunc("/zayavki/update-bucket-quotas", stripPrefix(handleUpdateQuotas))
+	mux.HandleFunc("/zayavki/bucket-policies", stripPrefix(handleBucketPolicies))
+	mux.HandleFunc("/zayavki/access-keys", stripPrefix(handleAccessKeys))
+	mux.HandleFunc("/zayavki/encrypt-credentials", stripPrefix(handleEncryptCredentials))
+	mux.HandleFunc("/zayavki/email-deliveries", stripPrefix(handleEmailDeliveries))
+	mux.HandleFunc("/zayavki/email-preview", stripPrefix(handleEmailPreview))
+	mux.HandleFunc("/zayavki/import-srt", stripPrefix(handleImportSRT))
+	mux.HandleFunc("/zayavki/fetch-srt", stripPrefix(handleFetchSRT))
+	mux.HandleFunc("/zayavki/srt-outbox", stripPrefix(handleSRTOutbox))
+	mux.HandleFunc("/zayavki/srt-outbox/retry", stripPrefix(handleSRTOutboxRetry))
+	mux.HandleFunc("/zayavki/requests", stripPrefix(handleRequests))
+	mux.HandleFunc("/zayavki/request", stripPrefix(handleRequest))
+	mux.HandleFunc("/zayavki/requests/state", stripPrefix(handleRequestState))
+	mux.HandleFunc("/zayavki/approvals", stripPrefix(handleApprovals))
+	mux.HandleFunc("/zayavki/approvals/review", stripPrefix(handleApprovalReview))
+	mux.HandleFunc("/zayavki/approvals/execute", stripPrefix(handleApprovalExecute))
+	mux.HandleFunc("/zayavki/batch", stripPrefix(handleBatch))
+	mux.HandleFunc("/zayavki/batch-template", stripPrefix(handleBatchTemplate))
+
+	mux.HandleFunc("/metrics", metrics.Handler)
+	go runInventoryMetrics()
+
+	slog.Info("listening", "addr", ":8080")
+	log.Fatal(http.ListenAndServe(":8080", logging.Middleware(metrics.Instrument(mux), requestActor)))
+}
+
+var (
+	registryTenants = metrics.NewGauge("zayavki_registry_tenants",
+		"Tenants with active rows in the registry by environment.", "environment")
+	registryBuckets = metrics.NewGauge("zayavki_registry_buckets",
+		"Active buckets in the registry by environment.", "environment")
+	registryUsers = metrics.NewGauge("zayavki_registry_users",
+		"Active users in the registry by environment.", "environment")
+	pendingApprovals = metrics.NewGauge("zayavki_approvals_pending",
+		"Approvals waiting for a review by operation.", "operation")
+	inventoryRefreshErrors = metrics.NewCounter("zayavki_inventory_refresh_errors_total",
+		"Failed background refreshes of the inventory metrics by source.", "source")
+)
+
+// inventoryRefreshInterval is how often the inventory gauges are read
+const inventoryRefreshInterval = time.Minute
+
+// runInventoryMetrics refreshes the inventory gauges in the background, so
+// scrapes serve the last values instead of querying the registry each time
+func runInventoryMetrics() {
+	ticker := time.NewTicker(inventoryRefreshInterval)
+	defer ticker.Stop()
+	for {
+		updateInventoryMetrics()
+		<-ticker.C
+	}
+}
+
+// updateInventoryMetrics reads the registry, the pending approvals and the
+// cluster inventory. A failed read is counted and logged, and keeps the last
+// values of its gauges.
+func updateInventoryMetrics() {
+	if rows, err := postgresql_operations.AllocationReport("env", postgresql_operations.ReportFilter{}, 0); err != nil {
+		inventoryRefreshErrors.Inc("registry")
+		slog.Error("failed to refresh registry metrics", "error", err)
+	} else {
+		registryTenants.Replace(func(set func(float64, ...string)) {
+			for _, row := range rows {
+				set(float64(row.Tenants), row.Key)
+			}
+		})
+		registryBuckets.Replace(func(set func(float64, ...string)) {
+			for _, row := range rows {
+				set(float64(row.Buckets), row.Key)
+			}
+		})
+		registryUsers.Replace(func(set func(float64, ...string)) {
+			for _, row := range rows {
+				set(float64(row.Users), row.Key)
+			}
+		})
+	}
+
+	if pending, err := postgresql_operations.ListApprovals(approvals.Pending); err != nil {
+		inventoryRefreshErrors.Inc("approvals")
+		slog.Error("failed to refresh approval metrics", "error", err)
+	} else {
+		// Every operation is reported, so an empty queue shows as zeros
+		counts := map[string]int{}
+		for _, operation := range approvals.Operations {
+			counts[operation] = 0
+		}
+		for _, approval := range pending {
+			counts[approval.Operation]++
+		}
+		pendingApprovals.Replace(func(set func(float64, ...string)) {
+			for operation, count := range counts {
+				set(float64(count), operation)
+			}
+		})
+	}
 
-	log.Fatal(http.ListenAndServe(":8080", mux))
+	// ListClusters sets the cluster inventory gauges itself
+	if _, err := cluster_endpoint_parser.ListClusters("clusters.xlsx"); err != nil {
+		inventoryRefreshErrors.Inc("clusters")
+		slog.Error("failed to reload the cluster inventory", "error", err)
+	}
 }
 
 func stripPrefix(h http.HandlerFunc) http.HandlerFunc {
@@ -80,12 +256,16 @@ func handleSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	pushToDb := r.FormValue("push_to_db") == "true"
+	form := request_processing.FormValues(r.MultipartForm.Value)
+	annotateVariables(r, r.MultipartForm.Value)
 
 	processedVars, err := variables_parser.ParseAndProcessVariables(r.MultipartForm.Value)
 	if err != nil {
+		request_processing.TrackRequest(r.Context(), requestActor(r), r.MultipartForm.Value, form, request_lifecycle.Failed, err.Error())
 		http.Error(w, fmt.Sprintf("Error processing variables: %v", err), http.StatusInternalServerError)
 		return
 	}
+	annotateVariables(r, processedVars)
 
 	var cluster cluster_endpoint_parser.ClusterInfo
 	cluster, err = cluster_endpoint_parser.GetCluster("clusters.xlsx", processedVars["segment"][0], processedVars["env"][0])
@@ -95,6 +275,7 @@ func handleSubmit(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err.Error() == "multiple clusters found" {
+			request_processing.TrackRequest(r.Context(), requestActor(r), processedVars, form, request_lifecycle.Validated, "")
 			clusters, _ := cluster_endpoint_parser.FindMatchingClusters("clusters.xlsx", processedVars["segment"][0], processedVars["env"][0])
 			clusterJSON, _ := json.Marshal(clusters)
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -106,11 +287,13 @@ func handleSubmit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process data with the cluster
-	result, err := processDataWithCluster(processedVars, cluster, pushToDb)
+	result, state, note, err := request_processing.RunCreationRequest(r.Context(), requestActor(r), processedVars, cluster, pushToDb)
 	if err != nil {
+		request_processing.TrackRequest(r.Context(), requestActor(r), processedVars, form, request_lifecycle.Failed, err.Error())
 		handleError(w, err)
 		return
 	}
+	request_processing.TrackRequest(r.Context(), requestActor(r), processedVars, form, state, note)
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write([]byte(result))
@@ -129,11 +312,15 @@ func handleClusterSelection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process the variables
+	form := request_processing.FormValues(requestData.ProcessedVars)
+	annotateVariables(r, requestData.ProcessedVars)
 	processedVars, err := variables_parser.ParseAndProcessVariables(requestData.ProcessedVars)
 	if err != nil {
+		request_processing.TrackRequest(r.Context(), requestActor(r), requestData.ProcessedVars, form, request_lifecycle.Failed, err.Error())
 		http.Error(w, fmt.Sprintf("Error processing variables: %v", err), http.StatusInternalServerError)
 		return
 	}
+	annotateVariables(r, processedVars)
 
 	// Convert map to ClusterInfo
 	clusterInfo := cluster_endpoint_parser.ClusterInfo{
@@ -145,94 +332,38 @@ func handleClusterSelection(w http.ResponseWriter, r *http.Request) {
 		MTLSEndpoint: requestData.SelectedCluster["mtls_endpoint"],
 		Кластер:      requestData.SelectedCluster["Кластер"],
 		Реалм:        requestData.SelectedCluster["Реалм"],
+		Шаблон:       requestData.SelectedCluster["Шаблон"],
 	}
 
 	// Process data with the selected cluster
-	result, err := processDataWithCluster(processedVars, clusterInfo, requestData.PushToDb)
+	result, state, note, err := request_processing.RunCreationRequest(r.Context(), requestActor(r), processedVars, clusterInfo, requestData.PushToDb)
 	if err != nil {
+		request_processing.TrackRequest(r.Context(), requestActor(r), processedVars, form, request_lifecycle.Failed, err.Error())
 		http.Error(w, fmt.Sprintf("Error processing data: %v", err), http.StatusInternalServerError)
 		return
 	}
+	request_processing.TrackRequest(r.Context(), requestActor(r), processedVars, form, state, note)
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write([]byte(result))
 }
 
-func checkTenantExists(processedVars map[string][]string, clusterMap map[string]string) error {
-	if createTenant, ok := processedVars["create_tenant"]; !ok || len(createTenant) == 0 || createTenant[0] != "true" {
-		return nil // Not creating a tenant, skip check
-	}
-
-	// Determine tenant name (override or generated)
-	var tenantName string
-	if len(processedVars["tenant_override"]) > 0 && processedVars["tenant_override"][0] != "" {
-		tenantName = processedVars["tenant_override"][0]
-	} else {
-		var err error
-		tenantName, err = tenant_name_generation.GenerateTenantName(processedVars, clusterMap)
-		if err != nil {
-			return fmt.Errorf("error generating tenant name: %v", err)
-		}
-	}
-
-	// Check if tenant already exists
-	results, err := postgresql_operations.CheckDBForExistingEntries(
-		processedVars["segment"][0],
-		processedVars["env"][0],
-		"", // ris_number
-		"", // ris_name
-		tenantName,
-		"",         // bucket
-		tenantName, // user
-		"",         // cluster
-	)
-	if err != nil {
-		return fmt.Errorf("error checking database: %v", err)
-	}
-
-	if len(results) > 0 {
-		return fmt.Errorf("tenant '%s' already exists in the database", tenantName)
-	}
-
-	return nil
+// requestActor returns the user authenticated by a trusted reverse proxy, or
+// approvals.Anonymous
+func requestActor(r *http.Request) string {
+	return approvals.Actor(r)
 }
 
-func processDataWithCluster(variables map[string][]string, cluster cluster_endpoint_parser.ClusterInfo, pushToDb bool) (string, error) {
-	// Convert ClusterInfo to map for easier handling
-	clusterMap := cluster.ConvertToMap()
-
-	// Skip tenant name generation if we have tenant_override or existing tenant
-	if _, hasExistingTenant := variables["tenant"]; !hasExistingTenant {
-		if override, hasOverride := variables["tenant_override"]; hasOverride && len(override) > 0 && override[0] != "" {
-			variables["tenant"] = []string{override[0]}
-		} else {
-			// Generate tenant name only for new tenant creation without override
-			tenant, err := tenant_name_generation.GenerateTenantName(variables, clusterMap)
-			if err != nil {
-				return "", fmt.Errorf("error generating tenant name: %v", err)
-			}
-			variables["tenant"] = []string{tenant}
-		}
-	}
-
-	// Validate the data
-	// Client-side validation is now handled in JavaScript
-
-	// Check for existing tenant first
-	if err := checkTenantExists(variables, clusterMap); err != nil {
-		return "", err
-	}
-
-	// Set up tenant and users
-	if err := setupTenantAndUsers(variables, clusterMap); err != nil {
-		return "", err
-	}
-
-	if pushToDb {
-		return pushToDatabase(variables, clusterMap)
-	}
+// annotateRequest adds the ticket numbers and tenant a request works on to
+// its log lines
+func annotateRequest(r *http.Request, sdNum, srtNum, tenant string) {
+	logging.Annotate(r.Context(), "sd_num", sdNum, "srt_num", srtNum, "tenant", tenant)
+}
 
-	return generateFullResult(variables, clusterMap)
+// annotateVariables annotates a request with the fields of its form
+func annotateVariables(r *http.Request, variables map[string][]string) {
+	form := request_processing.FormValues(variables)
+	annotateRequest(r, form["request_id_sd"], form["request_id_srt"], form["tenant"])
 }
 
 func handleCheck(w http.ResponseWriter, r *http.Request) {
@@ -283,207 +414,544 @@ func handleCheck(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func setupTenantAndUsers(processedVars map[string][]string, _ map[string]string) error {
-	// Check if creating tenant is needed and add to users list if so
-	if createTenant, ok := processedVars["create_tenant"]; ok && len(createTenant) > 0 && createTenant[0] == "true" {
-		if len(processedVars["users"]) == 0 {
-			processedVars["users"] = []string{processedVars["tenant"][0]}
-		} else {
-			processedVars["users"] = append([]string{processedVars["tenant"][0]}, processedVars["users"]...)
-		}
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	return nil
-}
 
-func pushToDatabase(processedVars map[string][]string, clusterMap map[string]string) (string, error) {
-	var result strings.Builder
+	var query postgresql_operations.SearchQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
 
-	// Get database push result
-	dbResult, err := postgresql_operations.PushToDB(processedVars, clusterMap)
+	page, err := postgresql_operations.SearchEntries(query)
+	if errors.Is(err, postgresql_operations.ErrInvalidSearch) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to push to database: %v", err)
+		jsonError(w, fmt.Sprintf("Error searching database: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	// Add database result to output
-	result.WriteString("~~~~~~~Результат отправки данных в БД~~~~~~~\n")
-	result.WriteString(dbResult)
-	result.WriteString("\n\n")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
 
-	// Generate and add email template
-	emailTemplate, err := email_template.PopulateEmailTemplate(processedVars, clusterMap)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate email template: %v", err)
+func handleTextSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	result.WriteString("~~~~~~~Шаблон для закрытия задания и письма с данными УЗ~~~~~~~\n")
-	result.WriteString(emailTemplate)
 
-	return result.String(), nil
-}
+	var request struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
 
-func generateFullResult(processedVars map[string][]string, clusterMap map[string]string) (string, error) {
-	var result strings.Builder
+	result, err := postgresql_operations.TextSearch(request.Query, request.Limit)
+	if errors.Is(err, postgresql_operations.ErrInvalidSearch) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error searching database: %v", err), http.StatusInternalServerError)
+		return
+	}
 
-	// Client-side validation is now handled in JavaScript
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
 
-	// Generate other parts of the result
-	result.WriteString("~~~~~~~Таблица пользователей и бакетов для отправки в БД~~~~~~~\n")
-	result.WriteString(prep_db_table_data.PopulateUsers(processedVars, clusterMap))
-	result.WriteString("\n")
-	result.WriteString(prep_db_table_data.PopulateBuckets(processedVars, clusterMap))
-	result.WriteString("\n\n")
+// handleTenant returns the overview of a tenant
+func handleTenant(w http.ResponseWriter, r *http.Request) {
+	tenant := strings.TrimSpace(r.URL.Query().Get("tenant"))
+	if tenant == "" {
+		jsonError(w, "tenant is required", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, "", "", tenant)
 
-	result.WriteString("~~~~~~~Список терминальных команд для создания пользователей и бакетов~~~~~~~\n")
-	result.WriteString(rgw_commands.BucketCreation(processedVars, clusterMap))
-	result.WriteString("\n")
-	result.WriteString(rgw_commands.UserCreation(processedVars, clusterMap))
-	result.WriteString("\n")
-	result.WriteString(rgw_commands.ResultCheck(processedVars, clusterMap))
-	result.WriteString("\n\n")
+	overview, err := request_processing.GetTenantOverview(tenant)
+	if errors.Is(err, request_processing.ErrTenantNotFound) {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error reading tenant: %v", err), http.StatusInternalServerError)
+		return
+	}
 
-	return result.String(), nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		*request_processing.TenantOverview
+		States map[string]string `json:"states"`
+	}{overview, request_lifecycle.Labels})
 }
 
-func jsonError(w http.ResponseWriter, message string, code int) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+// reportFilter reads the filter of a report request
+func reportFilter(r *http.Request) postgresql_operations.ReportFilter {
+	query := r.URL.Query()
+	return postgresql_operations.ReportFilter{
+		Env:       query.Get("env"),
+		Segment:   query.Get("segment"),
+		Cluster:   query.Get("cluster"),
+		RisNumber: query.Get("ris_number"),
+	}
 }
 
-func handleError(w http.ResponseWriter, err error) {
-	log.Printf("Error processing data: %v", err)
-	http.Error(w, err.Error(), http.StatusInternalServerError)
+// buildDashboard builds the capacity dashboard of a request, writing the
+// error when it fails
+func buildDashboard(w http.ResponseWriter, r *http.Request) *capacity_reports.Dashboard {
+	top, _ := strconv.Atoi(r.URL.Query().Get("top"))
+	dashboard, err := capacity_reports.Build(reportFilter(r), r.URL.Query().Get("interval"), top)
+	if errors.Is(err, postgresql_operations.ErrInvalidReport) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error building report: %v", err), http.StatusInternalServerError)
+		return nil
+	}
+	return dashboard
 }
 
-func handleClusterInfo(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		Segment string `json:"segment"`
-		Env     string `json:"env"`
-		Cluster string `json:"cluster"`
+// handleReports returns the capacity dashboard
+func handleReports(w http.ResponseWriter, r *http.Request) {
+	dashboard := buildDashboard(w, r)
+	if dashboard == nil {
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dashboard)
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// handleAllocationReport returns the allocated quota grouped by group_by
+func handleAllocationReport(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	rows, err := postgresql_operations.AllocationReport(r.URL.Query().Get("group_by"), reportFilter(r), limit)
+	if errors.Is(err, postgresql_operations.ErrInvalidReport) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	clusters, err := cluster_endpoint_parser.FindMatchingClusters("clusters.xlsx", request.Segment, request.Env)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		jsonError(w, fmt.Sprintf("Error building report: %v", err), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"rows": rows})
+}
 
-	// Find the specific cluster
-	for _, cluster := range clusters {
-		if cluster.Кластер == request.Cluster {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(cluster)
-			return
-		}
+// handleGrowthReport returns the growth of bucket quota per interval
+func handleGrowthReport(w http.ResponseWriter, r *http.Request) {
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "month"
 	}
-
-	http.Error(w, "Cluster not found", http.StatusNotFound)
+	rows, err := postgresql_operations.GrowthReport(interval, reportFilter(r))
+	if errors.Is(err, postgresql_operations.ErrInvalidReport) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error building report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"rows": rows})
 }
 
-func handleCheckTenantResources(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		Tenant       string   `json:"tenant"`
-		Users        []string `json:"users"`
-		Buckets      []string `json:"buckets"`
-		Mode         string   `json:"mode"`
-		RequestIdSrt string   `json:"request_id_srt,omitempty"`
+// handleExportReport exports the capacity dashboard as XLSX or CSV
+func handleExportReport(w http.ResponseWriter, r *http.Request) {
+	options, err := table_export.ParseOptions(r.URL.Query().Get("format"), r.URL.Query().Get("delimiter"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dashboard := buildDashboard(w, r)
+	if dashboard == nil {
+		return
 	}
+	writeExport(w, options, "capacity_report", dashboard.Tables()...)
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// handleUsageImport stores the bucket stats of an uploaded radosgw-admin
+// bucket stats output
+func handleUsageImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get all entries for this tenant
-	results, err := postgresql_operations.CheckDBForExistingEntries(
-		"", "", "", "", request.Tenant, "", "", "",
-	)
+	if err := r.ParseMultipartForm(64 << 20); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("usage_file")
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error checking database: %v", err), http.StatusInternalServerError)
+		jsonError(w, "Bucket stats file is required", http.StatusBadRequest)
 		return
 	}
-
-	if len(results) == 0 {
-		http.Error(w, "Tenant not found", http.StatusNotFound)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error reading bucket stats file: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	// Find the row where s3_user equals tenant
-	var tenantInfo *postgresql_operations.CheckResult
-	for _, result := range results {
-		if result.S3User.Valid && result.S3User.String == request.Tenant {
-			tenantInfo = &result
-			break
-		}
+	snapshots, err := bucket_usage.ParseStats(strings.TrimSpace(r.FormValue("cluster")), data)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	saved, err := postgresql_operations.SaveUsageSnapshots(snapshots, "upload", requestActor(r))
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error saving bucket usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"saved": saved})
+}
 
-	if tenantInfo == nil {
-		http.Error(w, "Tenant info not found", http.StatusNotFound)
+// handleUsageCollect collects bucket stats from the Admin Ops API of a
+// cluster, or of every cluster when none is given
+func handleUsageCollect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !bucket_usage.AdminOpsEnabled() {
+		jsonError(w, "Admin Ops API is disabled, upload radosgw-admin bucket stats instead", http.StatusBadRequest)
 		return
 	}
 
-	result := map[string]interface{}{
-		"tenant": map[string]string{
-			"name":        request.Tenant,
-			"cluster":     tenantInfo.ClsName,
-			"env":         tenantInfo.Env,
-			"segment":     tenantInfo.NetSeg,
-			"realm":       tenantInfo.Realm,
-			"ris_code":    tenantInfo.RisCode,
-			"ris_id":      tenantInfo.RisId,
-			"owner_group": tenantInfo.OwnerGroup,
-			"owner":       tenantInfo.OwnerPerson,
-		},
-		"users":   make([]map[string]interface{}, 0),
-		"buckets": make([]map[string]interface{}, 0),
+	var requestData struct {
+		Cluster string `json:"cluster"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	// Check users if any provided
-	for _, user := range request.Users {
-		var userInfo *postgresql_operations.CheckResult
-		for _, entry := range results {
-			if entry.S3User.Valid && entry.S3User.String == user {
-				userInfo = &entry
-				break
-			}
+	clusters := []string{strings.TrimSpace(requestData.Cluster)}
+	if clusters[0] == "" {
+		var err error
+		if clusters, err = bucket_usage.CollectClusters(); err != nil {
+			jsonError(w, fmt.Sprintf("Error listing clusters: %v", err), http.StatusInternalServerError)
+			return
 		}
-		result["users"] = append(result["users"].([]map[string]interface{}), map[string]interface{}{
-			"name":   user,
-			"exists": userInfo != nil,
-			"status": getUserStatusFromResult(userInfo),
-		})
 	}
 
-	// Check buckets if any provided
-	for _, bucket := range request.Buckets {
-		bucketName := strings.Split(bucket, "|")[0]
-		bucketName = strings.TrimSpace(bucketName)
-		var bucketInfo *postgresql_operations.CheckResult
-		for _, entry := range results {
-			if entry.Bucket.Valid && entry.Bucket.String == bucketName {
-				bucketInfo = &entry
-				break
-			}
+	// A cluster that cannot be reached does not stop the others
+	type clusterResult struct {
+		Cluster string `json:"cluster"`
+		Saved   int    `json:"saved"`
+		Error   string `json:"error,omitempty"`
+	}
+	results := make([]clusterResult, 0, len(clusters))
+	for _, cluster := range clusters {
+		result := clusterResult{Cluster: cluster}
+		snapshots, err := bucket_usage.Collect(cluster)
+		if err == nil {
+			result.Saved, err = postgresql_operations.SaveUsageSnapshots(snapshots, "admin_ops", requestActor(r))
 		}
-		result["buckets"] = append(result["buckets"].([]map[string]interface{}), map[string]interface{}{
-			"name":   bucketName,
-			"exists": bucketInfo != nil,
-			"size":   getBucketSizeFromResult(bucketInfo),
-			"status": getBucketStatusFromResult(bucketInfo),
-		})
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"clusters": results})
+}
 
-	// Add appropriate commands based on mode
-	if request.Mode == "create" {
-		// Generate creation commands
-		// Use input field value if provided, otherwise fall back to tenant info
-		requestIdSrt := tenantInfo.SrtNum
-		if request.RequestIdSrt != "" {
-			requestIdSrt = request.RequestIdSrt
+// buildUsageReport builds the usage report of a request, writing the error
+// when it fails
+func buildUsageReport(w http.ResponseWriter, r *http.Request) *bucket_usage.Report {
+	threshold, _ := strconv.ParseFloat(r.URL.Query().Get("threshold"), 64)
+	unusedDays, _ := strconv.Atoi(r.URL.Query().Get("unused_days"))
+	report, err := bucket_usage.BuildReport(reportFilter(r), threshold, unusedDays)
+	if errors.Is(err, postgresql_operations.ErrInvalidReport) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error building usage report: %v", err), http.StatusInternalServerError)
+		return nil
+	}
+	return report
+}
+
+// handleUsageReport returns the over-utilized and unused buckets
+func handleUsageReport(w http.ResponseWriter, r *http.Request) {
+	report := buildUsageReport(w, r)
+	if report == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleExportUsage exports the usage report as XLSX or CSV
+func handleExportUsage(w http.ResponseWriter, r *http.Request) {
+	options, err := table_export.ParseOptions(r.URL.Query().Get("format"), r.URL.Query().Get("delimiter"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report := buildUsageReport(w, r)
+	if report == nil {
+		return
+	}
+	writeExport(w, options, "usage_report", report.Tables()...)
+}
+
+// handleExportSearch exports every row of a search as XLSX or CSV
+func handleExportSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		postgresql_operations.SearchQuery
+		Format    string `json:"format"`
+		Delimiter string `json:"delimiter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	options, err := table_export.ParseOptions(request.Format, request.Delimiter)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := postgresql_operations.ExportEntries(request.SearchQuery)
+	if errors.Is(err, postgresql_operations.ErrInvalidSearch) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error searching database: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeExport(w, options, "search_results", table_export.RegistryTable("Результаты поиска", results))
+}
+
+// handleExportTenant exports the inventory of a tenant as XLSX or CSV
+func handleExportTenant(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenant := strings.TrimSpace(r.URL.Query().Get("tenant"))
+	if tenant == "" {
+		jsonError(w, "tenant is required", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, "", "", tenant)
+	options, err := table_export.ParseOptions(r.URL.Query().Get("format"), r.URL.Query().Get("delimiter"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := postgresql_operations.CheckDBForExistingEntries("", "", "", "", tenant, "", "", "")
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error checking database: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(results) == 0 {
+		jsonError(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+
+	writeExport(w, options, tenant, table_export.RegistryTable(tenant, results))
+}
+
+func writeExport(w http.ResponseWriter, options table_export.Options, name string, tables ...table_export.Table) {
+	var buf bytes.Buffer
+	if err := table_export.Write(&buf, options, tables...); err != nil {
+		jsonError(w, fmt.Sprintf("Error exporting: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", options.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", options.FileName(name)))
+	w.Write(buf.Bytes())
+}
+
+func jsonError(w http.ResponseWriter, message string, code int) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func handleError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// processingStatus returns the HTTP status for an error of request_processing
+func processingStatus(err error) int {
+	switch {
+	case errors.Is(err, request_processing.ErrTenantNotFound), errors.Is(err, request_processing.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, request_processing.ErrInvalidRequest), errors.Is(err, request_processing.ErrInvalidQuota):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func handleClusterInfo(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Segment string `json:"segment"`
+		Env     string `json:"env"`
+		Cluster string `json:"cluster"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	clusters, err := cluster_endpoint_parser.FindMatchingClusters("clusters.xlsx", request.Segment, request.Env)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Find the specific cluster
+	for _, cluster := range clusters {
+		if cluster.Кластер == request.Cluster {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(cluster)
+			return
+		}
+	}
+
+	http.Error(w, "Cluster not found", http.StatusNotFound)
+}
+
+func handleCheckTenantResources(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Tenant       string   `json:"tenant"`
+		Users        []string `json:"users"`
+		Buckets      []string `json:"buckets"`
+		UserQuotas   []string `json:"user_quotas,omitempty"`
+		Mode         string   `json:"mode"`
+		QuotaAction  string   `json:"quota_action,omitempty"`
+		RequestIdSrt string   `json:"request_id_srt,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, "", request.RequestIdSrt, request.Tenant)
+
+	// Get all entries for this tenant
+	results, err := postgresql_operations.CheckDBForExistingEntries(
+		"", "", "", "", request.Tenant, "", "", "",
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error checking database: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if len(results) == 0 {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+
+	// Find the row where s3_user equals tenant
+	var tenantInfo *postgresql_operations.CheckResult
+	for _, result := range results {
+		if result.S3User.Valid && result.S3User.String == request.Tenant {
+			tenantInfo = &result
+			break
+		}
+	}
+
+	if tenantInfo == nil {
+		http.Error(w, "Tenant info not found", http.StatusNotFound)
+		return
+	}
+
+	result := map[string]interface{}{
+		"tenant": map[string]string{
+			"name":        request.Tenant,
+			"cluster":     tenantInfo.ClsName,
+			"env":         tenantInfo.Env,
+			"segment":     tenantInfo.NetSeg,
+			"realm":       tenantInfo.Realm,
+			"ris_code":    tenantInfo.RisCode,
+			"ris_id":      tenantInfo.RisId,
+			"owner_group": tenantInfo.OwnerGroup,
+			"owner":       tenantInfo.OwnerPerson,
+		},
+		"users":   make([]map[string]interface{}, 0),
+		"buckets": make([]map[string]interface{}, 0),
+	}
+
+	// Check users if any provided
+	for _, user := range request.Users {
+		userName := strings.TrimSpace(strings.Split(user, "|")[0])
+		var userInfo *postgresql_operations.CheckResult
+		for _, entry := range results {
+			if entry.S3User.Valid && entry.S3User.String == userName {
+				userInfo = &entry
+				break
+			}
+		}
+		result["users"] = append(result["users"].([]map[string]interface{}), map[string]interface{}{
+			"name":          userName,
+			"exists":        userInfo != nil,
+			"quota":         getBucketSizeFromResult(userInfo),
+			"max_objects":   getMaxObjectsFromResult(userInfo),
+			"quota_enabled": getQuotaStateFromResult(userInfo),
+			"status":        getUserStatusFromResult(userInfo),
+		})
+	}
+
+	// Check buckets if any provided
+	for _, bucket := range request.Buckets {
+		bucketName := strings.Split(bucket, "|")[0]
+		bucketName = strings.TrimSpace(bucketName)
+		var bucketInfo *postgresql_operations.CheckResult
+		for _, entry := range results {
+			if entry.Bucket.Valid && entry.Bucket.String == bucketName {
+				bucketInfo = &entry
+				break
+			}
+		}
+		result["buckets"] = append(result["buckets"].([]map[string]interface{}), map[string]interface{}{
+			"name":          bucketName,
+			"exists":        bucketInfo != nil,
+			"size":          getBucketSizeFromResult(bucketInfo),
+			"max_objects":   getMaxObjectsFromResult(bucketInfo),
+			"quota_enabled": getQuotaStateFromResult(bucketInfo),
+			"features":      getBucketFeaturesFromResult(bucketInfo),
+			"status":        getBucketStatusFromResult(bucketInfo),
+		})
+	}
+
+	clusters := request_processing.InventoryClusterMap(tenantInfo)
+
+	// Add appropriate commands based on mode
+	if request.Mode == "create" {
+		// Generate creation commands
+		// Use input field value if provided, otherwise fall back to tenant info
+		requestIdSrt := tenantInfo.SrtNum
+		if request.RequestIdSrt != "" {
+			requestIdSrt = request.RequestIdSrt
 		}
 
 		vars := map[string][]string{
@@ -494,35 +962,86 @@ func handleCheckTenantResources(w http.ResponseWriter, r *http.Request) {
 			"owner":          {tenantInfo.OwnerPerson},
 		}
 
-		// Split buckets and their quotas
-		var bucketNames, bucketQuotas []string
-		for _, bucket := range request.Buckets {
-			parts := strings.Split(bucket, "|")
-			name := strings.TrimSpace(parts[0])
-			quota := "0"
-			if len(parts) > 1 {
-				quota = strings.TrimSpace(parts[1])
+		// Split buckets and user quotas into names, quotas and object limits
+		bucketVars, err := variables_parser.ParseBucketLines(strings.Join(request.Buckets, "\n"), tenantInfo.Env)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error parsing buckets: %v", err), http.StatusBadRequest)
+			return
+		}
+		for key, values := range bucketVars {
+			vars[key] = values
+		}
+		err = bucket_features.CheckExistingBuckets(bucketVars["bucketnames"], bucketVars["bucketlock"], func(bucket string) bool {
+			for _, entry := range results {
+				if entry.Active && entry.Bucket.Valid && entry.Bucket.String == bucket {
+					return true
+				}
 			}
-			bucketNames = append(bucketNames, name)
-			bucketQuotas = append(bucketQuotas, quota)
+			return false
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error parsing buckets: %v", err), http.StatusBadRequest)
+			return
 		}
-		vars["bucketnames"] = bucketNames
-		vars["bucketquotas"] = bucketQuotas
 
-		clusters := map[string]string{
-			"Кластер": tenantInfo.ClsName,
-			"Реалм":   tenantInfo.Realm,
+		quotaUsers, quotaSizes, quotaObjects, err := variables_parser.ParseQuotaLines(strings.Join(request.UserQuotas, "\n"), tenantInfo.Env)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error parsing user quotas: %v", err), http.StatusBadRequest)
+			return
 		}
+		vars["quotausers"] = quotaUsers
+		vars["quotausersizes"] = quotaSizes
+		vars["quotauserobjects"] = quotaObjects
 
-		bucketCommands := rgw_commands.BucketCreation(vars, clusters)
-		userCommands := rgw_commands.UserCreation(vars, clusters)
-		checkCommands := rgw_commands.ResultCheck(vars, clusters)
+		bucketCommands, err := rgw_commands.BucketCreation(vars, clusters)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		userCommands, err := rgw_commands.UserCreation(vars, clusters)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		quotaCommands, err := rgw_commands.QuotaCreation(vars, clusters)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		userCommands += "\n" + quotaCommands
+		featureCommands, err := rgw_commands.BucketFeatures(vars, clusters)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		userCommands += featureCommands
+		checkCommands, err := rgw_commands.ResultCheck(vars, clusters)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		result["creation_commands"] = bucketCommands + "\n" + userCommands + "\n" + checkCommands
 	} else if request.Mode == "quota" {
-		result["commands"] = rgw_commands.GenerateQuotaCommands(request.Tenant, request.Buckets, tenantInfo.Realm)
+		if request.QuotaAction != "enable" && request.QuotaAction != "disable" {
+			if err := request_processing.CheckQuotaLines(tenantInfo.Env, append(request.Buckets, request.Users...)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		commands, err := rgw_commands.GenerateQuotaCommands(request.Tenant, request.Buckets, request.Users, request.QuotaAction, clusters)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result["commands"] = commands
 	} else {
-		result["deletion_commands"] = rgw_commands.GenerateDeletionCommands(request.Tenant, request.Users, request.Buckets, tenantInfo.Realm)
+		commands, err := rgw_commands.GenerateDeletionCommands(request.Tenant, request.Users, request.Buckets, clusters)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result["deletion_commands"] = commands
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -536,6 +1055,43 @@ func getBucketSizeFromResult(info *postgresql_operations.CheckResult) string {
 	return info.Quota.String
 }
 
+func getMaxObjectsFromResult(info *postgresql_operations.CheckResult) string {
+	if info == nil || info.MaxObjects == "" {
+		return "-"
+	}
+	return info.MaxObjects
+}
+
+func getQuotaStateFromResult(info *postgresql_operations.CheckResult) string {
+	if info == nil {
+		return "-"
+	}
+	if info.QuotaEnabled {
+		return "Включена"
+	}
+	return "Выключена"
+}
+
+func getBucketFeaturesFromResult(info *postgresql_operations.CheckResult) string {
+	if info == nil {
+		return "-"
+	}
+	var features []string
+	if info.Versioning {
+		features = append(features, "versioning")
+	}
+	if info.ObjectLock != "" && info.ObjectLock != "-" {
+		features = append(features, "lock="+info.ObjectLock)
+	}
+	if info.Expire != "" && info.Expire != "-" {
+		features = append(features, "expire="+info.Expire)
+	}
+	if len(features) == 0 {
+		return "-"
+	}
+	return strings.Join(features, ", ")
+}
+
 func getBucketStatusFromResult(info *postgresql_operations.CheckResult) string {
 	if info == nil {
 		return "Не найден"
@@ -556,28 +1112,460 @@ func getUserStatusFromResult(info *postgresql_operations.CheckResult) string {
 	return "Не активен"
 }
 
-func handleDeactivateResources(w http.ResponseWriter, r *http.Request) {
+func handleBucketPolicies(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Tenant  string   `json:"tenant"`
-		Users   []string `json:"users"`
-		Buckets []string `json:"buckets"`
+		Tenant       string   `json:"tenant"`
+		Grants       []string `json:"grants"`
+		Action       string   `json:"action"`
+		RequestIdSrt string   `json:"request_id_srt,omitempty"`
+		PushToDb     bool     `json:"push_to_db"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	annotateRequest(r, "", request.RequestIdSrt, request.Tenant)
 
-	// Check if tenant is provided
+	results, err := postgresql_operations.CheckDBForExistingEntries(
+		"", "", "", "", request.Tenant, "", "", "",
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error checking database: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var tenantInfo *postgresql_operations.CheckResult
+	activeUsers := map[string]bool{}
+	activeBuckets := map[string]bool{}
+	for i, result := range results {
+		if result.S3User.Valid && result.S3User.String == request.Tenant && tenantInfo == nil {
+			tenantInfo = &results[i]
+		}
+		if !result.Active {
+			continue
+		}
+		if result.S3User.Valid {
+			activeUsers[result.S3User.String] = true
+		}
+		if result.Bucket.Valid {
+			activeBuckets[result.Bucket.String] = true
+		}
+	}
+	if tenantInfo == nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+
+	revoke := request.Action == "revoke"
+	grants, err := bucket_policy.ParseGrants(strings.Join(request.Grants, "\n"), !revoke)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing grants: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var problems []string
+	for _, grant := range grants {
+		if !activeUsers[grant.User] {
+			problems = append(problems, fmt.Sprintf("пользователь '%s' не найден в тенанте", grant.User))
+		}
+		if !activeBuckets[grant.Bucket] {
+			problems = append(problems, fmt.Sprintf("бакет '%s' не найден в тенанте", grant.Bucket))
+		}
+	}
+	if len(problems) > 0 {
+		http.Error(w, strings.Join(problems, "\n"), http.StatusBadRequest)
+		return
+	}
+
+	records, err := postgresql_operations.ListPolicyGrants(request.Tenant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	current := make([]bucket_policy.Grant, 0, len(records))
+	for _, record := range records {
+		current = append(current, record.Grant)
+	}
+
+	response := map[string]interface{}{
+		"tenant": map[string]string{
+			"name":        request.Tenant,
+			"cluster":     tenantInfo.ClsName,
+			"env":         tenantInfo.Env,
+			"segment":     tenantInfo.NetSeg,
+			"realm":       tenantInfo.Realm,
+			"ris_code":    tenantInfo.RisCode,
+			"ris_id":      tenantInfo.RisId,
+			"owner_group": tenantInfo.OwnerGroup,
+			"owner":       tenantInfo.OwnerPerson,
+		},
+		"grants": records,
+	}
+
+	if len(grants) > 0 {
+		// Policies are regenerated for every bucket touched by the request
+		merged := bucket_policy.Merge(current, grants, revoke)
+		buckets := bucket_policy.Buckets(grants)
+		policies := map[string]string{}
+		for _, bucket := range buckets {
+			policy, err := bucket_policy.GeneratePolicy(request.Tenant, bucket, merged)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			policies[bucket] = policy
+		}
+
+		commands, err := rgw_commands.BucketPolicyCommands(request.Tenant, buckets, policies, request_processing.InventoryClusterMap(tenantInfo))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response["policies"] = policies
+		response["commands"] = commands
+		response["pending_grants"] = merged
+
+		if request.PushToDb {
+			requestIdSrt := request.RequestIdSrt
+			if requestIdSrt == "" {
+				requestIdSrt = tenantInfo.SrtNum
+			}
+			if err := postgresql_operations.SavePolicyGrants(request.Tenant, strings.ToUpper(requestIdSrt), grants, revoke); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if response["grants"], err = postgresql_operations.ListPolicyGrants(request.Tenant); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			response["saved"] = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleAccessKeys(w http.ResponseWriter, r *http.Request) {
+	var request request_processing.AccessKeyRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, "", request.RequestIdSrt, request.Tenant)
+
+	response, err := request_processing.ManageAccessKeys(request)
+	if err != nil {
+		http.Error(w, err.Error(), processingStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleEncryptCredentials(w http.ResponseWriter, r *http.Request) {
+	var request request_processing.CredentialsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, request.RequestIdSd, request.RequestIdSrt, request.Tenant)
+
+	response, err := request_processing.EncryptCredentials(request)
+	if err != nil {
+		http.Error(w, err.Error(), processingStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleEmailDeliveries(w http.ResponseWriter, r *http.Request) {
+	srtNum := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("request_id_srt")))
+	if srtNum == "" {
+		http.Error(w, "request_id_srt is required", http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := postgresql_operations.ListEmailDeliveries(srtNum)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": deliveries,
+	})
+}
+
+// handleEmailPreview renders an email template with the given request data,
+// or with sample data when none is given
+func handleEmailPreview(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Operation string              `json:"operation"`
+		Language  string              `json:"lang"`
+		Variables map[string][]string `json:"variables"`
+		Clusters  map[string]string   `json:"clusters"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(request.Variables) == 0 {
+		request.Variables = email_template.SampleVariables()
+	}
+	if request.Clusters == nil {
+		request.Clusters = map[string]string{}
+	}
+
+	email, err := email_template.Render(request.Operation, request.Language, request.Variables, request.Clusters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"email":     email,
+		"languages": email_template.Languages(request.Operation),
+	})
+}
+
+func handleImportSRT(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		SrtJson    string `json:"srt_json"`
+		ParamsJson string `json:"params_json"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(request.SrtJson) == "" && strings.TrimSpace(request.ParamsJson) == "" {
+		http.Error(w, "SRT JSON or parameters JSON is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := variables_parser.ImportSRT([]byte(request.SrtJson), []byte(request.ParamsJson))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trackImportedRequest(r, result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// trackImportedRequest records an imported ticket as a draft, so it can be
+// resumed from the request list
+func trackImportedRequest(r *http.Request, result *variables_parser.SRTImport) {
+	variables := map[string][]string{}
+	for field, value := range result.Fields {
+		variables[field] = []string{value}
+	}
+	annotateVariables(r, variables)
+	request_processing.TrackRequest(r.Context(), requestActor(r), variables, result.Fields, request_lifecycle.Draft, "")
+}
+
+func handleFetchSRT(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RequestIdSrt string `json:"request_id_srt"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, "", request.RequestIdSrt, "")
+	if !srt_connector.Enabled() {
+		http.Error(w, "SRT connector is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	number, err := srt_connector.NormalizeNumber(request.RequestIdSrt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ticket, params, err := srt_connector.FetchTicket(number)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	result, err := variables_parser.ImportSRT(ticket, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	trackImportedRequest(r, result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func handleSRTOutbox(w http.ResponseWriter, r *http.Request) {
+	srtNum := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("request_id_srt")))
+	if srtNum == "" {
+		http.Error(w, "request_id_srt is required", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := postgresql_operations.ListOutbox(srtNum)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"updates": entries,
+	})
+}
+
+// handleSRTOutboxRetry queues the failed updates of a ticket again
+func handleSRTOutboxRetry(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RequestIdSrt string `json:"request_id_srt"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	srtNum, err := srt_connector.NormalizeNumber(request.RequestIdSrt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count, err := postgresql_operations.RetryOutbox(srtNum)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	request_processing.WakeSRTOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"requeued": count,
+	})
+}
+
+// handleRequests lists the open requests of the current user, or of all
+// users with all=true. closed=true includes closed requests.
+func handleRequests(w http.ResponseWriter, r *http.Request) {
+	actor := requestActor(r)
+	if r.URL.Query().Get("all") == "true" {
+		actor = ""
+	}
+
+	requests, err := postgresql_operations.ListRequests(actor, r.URL.Query().Get("closed") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"actor":    requestActor(r),
+		"requests": requests,
+		"states":   request_lifecycle.Labels,
+	})
+}
+
+func handleRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid request id", http.StatusBadRequest)
+		return
+	}
+
+	request, err := postgresql_operations.GetRequest(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
+
+// handleRequestState moves a request to a state by hand, e.g. once the
+// commands were executed or the ticket was closed. A request can be given by
+// id or by its SD and SRT numbers, optionally with the form to resume it.
+func handleRequestState(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID           int               `json:"id"`
+		RequestIdSd  string            `json:"request_id_sd"`
+		RequestIdSrt string            `json:"request_id_srt"`
+		State        string            `json:"state"`
+		Note         string            `json:"note"`
+		Form         map[string]string `json:"form"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, request.RequestIdSd, request.RequestIdSrt, request.Form["tenant_override"])
+	if !request_lifecycle.Valid(request.State) {
+		http.Error(w, fmt.Sprintf("Unknown state: %s", request.State), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := postgresql_operations.RecordRequestState(postgresql_operations.RequestUpdate{
+		ID:     request.ID,
+		SdNum:  request.RequestIdSd,
+		SrtNum: request.RequestIdSrt,
+		Tenant: request.Form["tenant_override"],
+		State:  request.State,
+		Actor:  requestActor(r),
+		Note:   request.Note,
+		Form:   request.Form,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func handleDeactivateResources(w http.ResponseWriter, r *http.Request) {
+	var request request_processing.DeactivationRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, "", request.RequestIdSrt, request.Tenant)
+
+	// Check if tenant is provided
 	if request.Tenant == "" {
 		http.Error(w, "Tenant name is required", http.StatusBadRequest)
 		return
 	}
 
-	// Deactivate resources in database
-	result, err := postgresql_operations.DeactivateResources(request.Tenant, request.Users, request.Buckets)
+	result, approval, err := request_processing.Deactivate(requestActor(r), request)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deactivating resources: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if approval != nil {
+		writeApproval(w, approval)
 		return
 	}
 
@@ -585,27 +1573,237 @@ func handleDeactivateResources(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
-func handleUpdateBucketQuotas(w http.ResponseWriter, r *http.Request) {
+func handleUpdateQuotas(w http.ResponseWriter, r *http.Request) {
+	var request request_processing.QuotaUpdateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	annotateRequest(r, "", request.RequestIdSrt, request.Tenant)
+
+	response, approval, err := request_processing.ChangeQuotas(requestActor(r), request)
+	switch {
+	case errors.Is(err, request_processing.ErrTenantNotFound):
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, request_processing.ErrInvalidQuota):
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if approval != nil {
+		writeApproval(w, approval)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeApproval responds with a change saved for review instead of its result
+func writeApproval(w http.ResponseWriter, approval *postgresql_operations.Approval) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"approval": approval,
+	})
+}
+
+// handleApprovals lists the approvals with a status, pending by default
+func handleApprovals(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = approvals.Pending
+	}
+	if status == "all" {
+		status = ""
+	}
+
+	list, err := postgresql_operations.ListApprovals(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"actor":     requestActor(r),
+		"approvals": list,
+		"labels":    approvals.Labels,
+	})
+}
+
+// handleApprovalReview approves or rejects a pending approval. Only
+// approvers other than the author can review.
+func handleApprovalReview(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Tenant  string `json:"tenant"`
-		Buckets []struct {
-			Name string `json:"name"`
-			Size string `json:"size"`
-		} `json:"buckets"`
+		ID      int    `json:"id"`
+		Approve bool   `json:"approve"`
+		Comment string `json:"comment"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !request.Approve && strings.TrimSpace(request.Comment) == "" {
+		http.Error(w, "A comment is required to reject a request", http.StatusBadRequest)
+		return
+	}
+
+	approval, err := postgresql_operations.GetApproval(request.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	reviewer := requestActor(r)
+	if err := approvals.CheckReviewer(reviewer, approval.RequestedBy); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	approval, err = postgresql_operations.ReviewApproval(request.ID, reviewer, request.Approve, strings.TrimSpace(request.Comment))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
+
+// handleApprovalExecute runs an approved change with the request saved at
+// approval time and responds with the result of the original operation
+func handleApprovalExecute(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID int `json:"id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Update bucket quotas in the database
-	result, err := postgresql_operations.UpdateBucketQuotas(request.Tenant, request.Buckets)
+	if err := approvals.CheckIdentified(requestActor(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	approval, err := postgresql_operations.ClaimApproval(request.ID, requestActor(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	annotateRequest(r, approval.SdNum, approval.SrtNum, approval.Tenant)
+
+	result, err := request_processing.ExecuteApproval(r.Context(), requestActor(r), approval)
+	if err != nil {
+		if releaseErr := postgresql_operations.ReleaseApproval(approval.ID, err.Error()); releaseErr != nil {
+			slog.ErrorContext(r.Context(), "failed to release approval", "approval_id", approval.ID, "error", releaseErr)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"operation": approval.Operation,
+		"result":    result,
+	})
+}
+
+// handleBatch validates a CSV or XLSX file of new tenants as a whole. When
+// every row is valid it responds with one command script for all of them and
+// pushes them to the DB in one transaction if requested.
+func handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("batch_file")
+	if err != nil {
+		jsonError(w, "Batch file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		jsonError(w, fmt.Sprintf("Error reading batch file: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := batch_import.Parse(header.Filename, data)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, entries := request_processing.PrepareBatch(r.Context(), requestActor(r), rows)
+	response := map[string]interface{}{
+		"rows":  report,
+		"valid": entries != nil,
+	}
+	if entries == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	script, err := request_processing.BatchScript(entries)
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	response["script"] = script
+
+	if r.FormValue("push_to_db") != "true" {
+		for i, entry := range entries {
+			request_processing.TrackRequest(r.Context(), requestActor(r), entry.Variables, request_processing.FormValues(rows[i].Values), request_lifecycle.CommandsGenerated, "")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if request_processing.BatchNeedsApproval(entries) {
+		approval, err := request_processing.RequestBatchApproval(requestActor(r), entries, script)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range report {
+			report[i].Status = request_processing.BatchPending
+		}
+		response["approval"] = approval
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 
+	pushed, err := request_processing.PushBatch(r.Context(), requestActor(r), entries, "")
+	for i := range report {
+		report[i].Status = pushed[i].Status
+		report[i].Errors = pushed[i].Errors
+		report[i].Result = pushed[i].Result
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleBatchTemplate serves an example batch file
+func handleBatchTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="batch_template.csv"`)
+	w.Write(batch_import.Template())
 }
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = NewCounter("zayavki_http_requests_total",
+		"HTTP requests by handler, method and status code.", "handler", "method", "code")
+	httpDuration = NewHistogram("zayavki_http_request_duration_seconds",
+		"HTTP request latency by handler.", DefaultBuckets, "handler")
+)
+
+// statusRecorder keeps the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Instrument counts and times the requests of every handler of a mux. The
+// handler label is the pattern the handler is registered with, so paths
+// that nothing serves do not add series.
+func Instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(recorder, r)
+
+		httpRequests.Inc(pattern, r.Method, strconv.Itoa(recorder.status))
+		httpDuration.ObserveSince(start, pattern)
+	})
+}
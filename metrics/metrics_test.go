@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	Handler(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	return recorder.Body.String()
+}
+
+func TestGaugeReplace(t *testing.T) {
+	gauge := NewGauge("test_replace", "A gauge replaced by a refresh.", "environment")
+	gauge.Set(3, "TEST")
+	gauge.Replace(func(set func(float64, ...string)) {
+		set(1, "PROD")
+		set(2, `I"FT`)
+	})
+
+	out := scrape(t)
+	want := "# HELP test_replace A gauge replaced by a refresh.\n# TYPE test_replace gauge\n" +
+		"test_replace{environment=\"I\\\"FT\"} 2\ntest_replace{environment=\"PROD\"} 1\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("scrape = %q, want it to contain %q", out, want)
+	}
+	if strings.Contains(out, `environment="TEST"`) {
+		t.Errorf("replaced series still reported: %q", out)
+	}
+}
+
+func TestGaugeReplaceConcurrentScrape(t *testing.T) {
+	gauge := NewGauge("test_replace_concurrent", "A gauge replaced while scraped.", "key")
+	fill := func(set func(float64, ...string)) {
+		set(1, "a")
+		set(1, "b")
+	}
+	gauge.Replace(fill)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			gauge.Replace(fill)
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		out := scrape(t)
+		if strings.Count(out, "test_replace_concurrent{") != 2 {
+			t.Fatalf("scrape saw a partial gauge: %q", out)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,221 @@
+package pgp_delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// Config holds the location of the recipients' public keys
+type Config struct {
+	// KeyringDir contains armored (.asc) or binary (.gpg, .pgp) public keys
+	KeyringDir string `json:"keyring_dir"`
+}
+
+var config = Config{KeyringDir: "keyring"}
+
+// LoadConfig reads the keyring settings from a JSON config file
+func LoadConfig(configPath string) error {
+	file, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read pgp config: %v", err)
+	}
+
+	cfg := config
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return fmt.Errorf("failed to parse pgp config: %v", err)
+	}
+	if strings.TrimSpace(cfg.KeyringDir) == "" {
+		return fmt.Errorf("keyring_dir cannot be empty")
+	}
+
+	config = cfg
+	return nil
+}
+
+// Credential is an S3 key pair returned by user creation
+type Credential struct {
+	User      string
+	AccessKey string
+	SecretKey string
+}
+
+var keyEntry = regexp.MustCompile(`"user"\s*:\s*"([^"]+)"\s*,\s*"access_key"\s*:\s*"([^"]+)"\s*,\s*"secret_key"\s*:\s*"([^"]+)"`)
+
+// ParseCredentials reads the key pairs from radosgw-admin user create output.
+// Lines in the "user | access_key | secret_key" format are accepted as well.
+// The tenant prefix of user names is dropped.
+func ParseCredentials(text string) ([]Credential, error) {
+	var creds []Credential
+	if matches := keyEntry.FindAllStringSubmatch(text, -1); len(matches) > 0 {
+		for _, match := range matches {
+			creds = append(creds, newCredential(match[1], match[2], match[3]))
+		}
+		return creds, nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) != 3 {
+			// Do not echo the line, it may contain a secret key
+			return nil, fmt.Errorf("invalid credentials line (expected: user | access_key | secret_key)")
+		}
+		cred := newCredential(parts[0], parts[1], parts[2])
+		if cred.User == "" || cred.AccessKey == "" || cred.SecretKey == "" {
+			return nil, fmt.Errorf("user, access key and secret key cannot be empty")
+		}
+		creds = append(creds, cred)
+	}
+	return creds, nil
+}
+
+func newCredential(user, accessKey, secretKey string) Credential {
+	user = strings.TrimSpace(user)
+	if i := strings.Index(user, "$"); i >= 0 {
+		user = user[i+1:]
+	}
+	return Credential{
+		User:      strings.ToLower(user),
+		AccessKey: strings.TrimSpace(accessKey),
+		SecretKey: strings.TrimSpace(secretKey),
+	}
+}
+
+// Users returns the distinct users of the credentials in their original order
+func Users(creds []Credential) []string {
+	seen := map[string]bool{}
+	var users []string
+	for _, cred := range creds {
+		if !seen[cred.User] {
+			seen[cred.User] = true
+			users = append(users, cred.User)
+		}
+	}
+	return users
+}
+
+// FindRecipient returns the public key in the keyring with a user ID for email
+func FindRecipient(email string) (*openpgp.Entity, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, fmt.Errorf("recipient email is empty")
+	}
+
+	files, err := os.ReadDir(config.KeyringDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %v", err)
+	}
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Name()))
+		if file.IsDir() || (ext != ".asc" && ext != ".gpg" && ext != ".pgp") {
+			continue
+		}
+		entities, err := readKeyFile(filepath.Join(config.KeyringDir, file.Name()), ext == ".asc")
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range entities {
+			for _, identity := range entity.Identities {
+				if strings.ToLower(identity.UserId.Email) == email {
+					return entity, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("no public key found for %s", email)
+}
+
+func readKeyFile(path string, armored bool) (openpgp.EntityList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file %s: %v", filepath.Base(path), err)
+	}
+	defer file.Close()
+
+	var entities openpgp.EntityList
+	if armored {
+		entities, err = openpgp.ReadArmoredKeyRing(file)
+	} else {
+		entities, err = openpgp.ReadKeyRing(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %v", filepath.Base(path), err)
+	}
+	return entities, nil
+}
+
+// Identity returns the primary user ID and fingerprint of a key
+func Identity(entity *openpgp.Entity) string {
+	names := make([]string, 0, len(entity.Identities))
+	for name := range entity.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if identity := entity.Identities[name]; identity.SelfSignature != nil &&
+			identity.SelfSignature.IsPrimaryId != nil && *identity.SelfSignature.IsPrimaryId {
+			return fmt.Sprintf("%s (%X)", name, entity.PrimaryKey.Fingerprint)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+	}
+	return fmt.Sprintf("%s (%X)", names[0], entity.PrimaryKey.Fingerprint)
+}
+
+// FileName returns the name of the encrypted credentials file of a tenant
+func FileName(tenant string) string {
+	return fmt.Sprintf("%s_credentials.txt.asc", tenant)
+}
+
+// EncryptCredentials encrypts the credentials of a tenant for the recipient
+// and returns an ASCII-armored OpenPGP message. The plaintext is only kept in
+// memory.
+func EncryptCredentials(recipient *openpgp.Entity, tenant string, endpoints []string, creds []Credential) ([]byte, error) {
+	var plain bytes.Buffer
+	fmt.Fprintf(&plain, "Тенант: %s\n", tenant)
+	for _, endpoint := range endpoints {
+		if endpoint != "" {
+			fmt.Fprintf(&plain, "Endpoint: %s\n", endpoint)
+		}
+	}
+	for _, cred := range creds {
+		fmt.Fprintf(&plain, "\nПользователь: %s\nAccess key: %s\nSecret key: %s\n", cred.User, cred.AccessKey, cred.SecretKey)
+	}
+
+	var out bytes.Buffer
+	armored, err := armor.Encode(&out, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start armored output: %v", err)
+	}
+	hints := &openpgp.FileHints{FileName: strings.TrimSuffix(FileName(tenant), ".asc")}
+	writer, err := openpgp.Encrypt(armored, []*openpgp.Entity{recipient}, nil, hints, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt credentials: %v", err)
+	}
+	if _, err := writer.Write(plain.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to encrypt credentials: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt credentials: %v", err)
+	}
+	if err := armored.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish armored output: %v", err)
+	}
+
+	// Overwrite the plaintext before the buffer is released
+	clear(plain.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+package pgp_delivery
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+const keyOutput = `{
+    "keys": [
+        {
+            "user": "ten-acme$ten-acme-app",
+            "access_key": "AKIAEXAMPLE00001",
+            "secret_key": "s3cr3t/value+1"
+        }
+    ]
+}`
+
+func TestParseCredentials(t *testing.T) {
+	creds, err := ParseCredentials(keyOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Credential{{User: "ten-acme-app", AccessKey: "AKIAEXAMPLE00001", SecretKey: "s3cr3t/value+1"}}
+	if !reflect.DeepEqual(creds, want) {
+		t.Errorf("got %+v, want %+v", creds, want)
+	}
+
+	_, err = ParseCredentials("user | AKIAEXAMPLE00001")
+	if err == nil || strings.Contains(err.Error(), "AKIA") {
+		t.Errorf("invalid line: got %v, want an error without the line", err)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	config.KeyringDir = "testdata"
+	recipient, err := FindRecipient("Recipient@Example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(Identity(recipient), "Test Recipient (zayavki tests) <recipient@example.com> (") {
+		t.Errorf("Identity() = %s", Identity(recipient))
+	}
+
+	creds, _ := ParseCredentials(keyOutput)
+	encrypted, err := EncryptCredentials(recipient, "ten-acme", []string{"https://s3.example"}, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, []byte("s3cr3t")) {
+		t.Fatal("the secret key is in the output")
+	}
+
+	secret, err := os.Open("testdata/recipient-secret.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secret.Close()
+	keyring, err := openpgp.ReadArmoredKeyRing(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := openpgp.ReadMessage(armorDecode(t, encrypted), keyring, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(block.UnverifiedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Тенант: ten-acme\nEndpoint: https://s3.example\n\nПользователь: ten-acme-app\nAccess key: AKIAEXAMPLE00001\nSecret key: s3cr3t/value+1\n"
+	if string(plain) != want {
+		t.Errorf("decrypted:\n%s\nwant:\n%s", plain, want)
+	}
+	if block.LiteralData.FileName != "ten-acme_credentials.txt" {
+		t.Errorf("file name %q", block.LiteralData.FileName)
+	}
+}
+
+func TestFindRecipientUnknown(t *testing.T) {
+	config.KeyringDir = "testdata"
+	if _, err := FindRecipient("nobody@example.com"); err == nil {
+		t.Error("found a key for an unknown recipient")
+	}
+}
+
+func armorDecode(t *testing.T, data []byte) io.Reader {
+	t.Helper()
+	block, err := armor.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Type != "PGP MESSAGE" {
+		t.Fatalf("armor type %s", block.Type)
+	}
+	return block.Body
+}
@@ -0,0 +1,110 @@
+package postgresql_operations
+
+import (
+	"fmt"
+)
+
+// AccessKeyRecord is an access key ID tracked for a user. Secret keys are
+// never stored.
+type AccessKeyRecord struct {
+	Tenant    string `json:"tenant"`
+	User      string `json:"user"`
+	AccessKey string `json:"access_key"`
+	SrtNum    string `json:"srt_num"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+	AgeDays   int    `json:"age_days"`
+}
+
+// ReplaceAccessKeys records the newly issued access key IDs of a user and
+// revokes the replaced ones in one transaction, so a failure leaves neither
+// change behind. Keys with a validity of 0 days never expire. Keys that are
+// already recorded are skipped. Revoked keys that were never recorded, such
+// as those issued before keys were tracked, are recorded as revoked.
+func ReplaceAccessKeys(tenant, user, srtNum string, issued, revoke []string, validityDays int) (recorded, revoked []string, err error) {
+	if db == nil {
+		return nil, nil, ErrNotInitialized
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	recorded = make([]string, 0, len(issued))
+	for _, key := range issued {
+		res, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s.access_keys (tenant, s3_user, access_key, srt_num, expires_at)
+			VALUES ($1, $2, $3, $4, CASE WHEN $5 > 0 THEN now() + make_interval(days => $5) END)
+			ON CONFLICT (tenant, access_key) DO NOTHING`, config.Schema),
+			tenant, user, key, srtNum, validityDays)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error recording access key of %s: %w", user, err)
+		}
+		if inserted, err := res.RowsAffected(); err == nil && inserted > 0 {
+			recorded = append(recorded, key)
+		}
+	}
+
+	revoked = make([]string, 0, len(revoke))
+	for _, key := range revoke {
+		res, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s.access_keys AS k (tenant, s3_user, access_key, srt_num, revoked_at, active)
+			VALUES ($1, $2, $3, $4, now(), false)
+			ON CONFLICT (tenant, access_key) DO UPDATE
+			SET active = false, revoked_at = now()
+			WHERE k.s3_user = $2 AND k.active = true`, config.Schema),
+			tenant, user, key, srtNum)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error revoking access key of %s/%s: %w", tenant, user, err)
+		}
+		if updated, err := res.RowsAffected(); err == nil && updated > 0 {
+			revoked = append(revoked, key)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return recorded, revoked, nil
+}
+
+// ListAccessKeys returns the active access keys that are at least minAgeDays
+// old. Empty tenant or user match all tenants or users.
+func ListAccessKeys(tenant, user string, minAgeDays int) ([]AccessKeyRecord, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT tenant, s3_user, access_key, srt_num,
+			to_char(created_at, 'YYYY-MM-DD HH24:MI:SS'),
+			COALESCE(to_char(expires_at, 'YYYY-MM-DD'), '-'),
+			date_part('day', now() - created_at)::int
+		FROM %s.access_keys
+		WHERE active = true
+		AND ($1 = '' OR tenant = $1)
+		AND ($2 = '' OR s3_user = $2)
+		AND created_at <= now() - make_interval(days => $3)
+		ORDER BY created_at`, config.Schema), tenant, user, minAgeDays)
+	if err != nil {
+		return nil, fmt.Errorf("error listing access keys: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]AccessKeyRecord, 0)
+	for rows.Next() {
+		var record AccessKeyRecord
+		err := rows.Scan(&record.Tenant, &record.User, &record.AccessKey, &record.SrtNum,
+			&record.CreatedAt, &record.ExpiresAt, &record.AgeDays)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning access key: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating access keys: %w", err)
+	}
+	return records, nil
+}
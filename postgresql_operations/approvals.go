@@ -0,0 +1,181 @@
+package postgresql_operations
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/NarrativeBias/zayavki/approvals"
+)
+
+// Approval is a change waiting for a second user to review it. Payload holds
+// the request that is executed once it is approved, Plan what the reviewer
+// is shown.
+type Approval struct {
+	ID            int             `json:"id"`
+	Operation     string          `json:"operation"`
+	SdNum         string          `json:"sd_num"`
+	SrtNum        string          `json:"srt_num"`
+	Tenant        string          `json:"tenant"`
+	Env           string          `json:"env"`
+	Payload       json.RawMessage `json:"-"`
+	Plan          string          `json:"plan"`
+	Status        string          `json:"status"`
+	RequestedBy   string          `json:"requested_by"`
+	RequestedAt   string          `json:"requested_at"`
+	ReviewedBy    string          `json:"reviewed_by,omitempty"`
+	ReviewedAt    string          `json:"reviewed_at,omitempty"`
+	ReviewComment string          `json:"review_comment,omitempty"`
+	ExecutedBy    string          `json:"executed_by,omitempty"`
+	ExecutedAt    string          `json:"executed_at,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// CreateApproval stores a pending approval and returns it
+func CreateApproval(approval Approval) (*Approval, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	var id int
+	err := db.QueryRow(fmt.Sprintf(`
+		INSERT INTO %s.approvals (operation, sd_num, srt_num, tenant, env, payload, plan, requested_by)
+		VALUES ($1, COALESCE(NULLIF($2, ''), '-'), COALESCE(NULLIF($3, ''), '-'), $4, COALESCE(NULLIF($5, ''), '-'), $6, $7, $8)
+		RETURNING id`, config.Schema),
+		approval.Operation, approval.SdNum, approval.SrtNum, approval.Tenant, approval.Env,
+		string(approval.Payload), approval.Plan, approval.RequestedBy).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error saving approval: %w", err)
+	}
+	return GetApproval(id)
+}
+
+const approvalColumns = `id, operation, sd_num, srt_num, tenant, env, payload, plan, status, requested_by,
+	to_char(requested_at, 'YYYY-MM-DD HH24:MI:SS'), COALESCE(reviewed_by, ''),
+	COALESCE(to_char(reviewed_at, 'YYYY-MM-DD HH24:MI:SS'), ''), COALESCE(review_comment, ''),
+	COALESCE(executed_by, ''), COALESCE(to_char(executed_at, 'YYYY-MM-DD HH24:MI:SS'), ''), COALESCE(error, '')`
+
+func scanApproval(row rowScanner) (*Approval, error) {
+	var approval Approval
+	var payload []byte
+	err := row.Scan(&approval.ID, &approval.Operation, &approval.SdNum, &approval.SrtNum, &approval.Tenant,
+		&approval.Env, &payload, &approval.Plan, &approval.Status, &approval.RequestedBy, &approval.RequestedAt,
+		&approval.ReviewedBy, &approval.ReviewedAt, &approval.ReviewComment, &approval.ExecutedBy,
+		&approval.ExecutedAt, &approval.Error)
+	if err != nil {
+		return nil, err
+	}
+	approval.Payload = payload
+	return &approval, nil
+}
+
+// GetApproval returns an approval with its payload
+func GetApproval(id int) (*Approval, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	approval, err := scanApproval(db.QueryRow(fmt.Sprintf(`
+		SELECT %s FROM %s.approvals WHERE id = $1`, approvalColumns, config.Schema), id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("approval %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading approval: %w", err)
+	}
+	return approval, nil
+}
+
+// ListApprovals returns the approvals with a status, or all when status is
+// empty, newest first
+func ListApprovals(status string) ([]Approval, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s FROM %s.approvals
+		WHERE $1 = '' OR status = $1
+		ORDER BY id DESC
+		LIMIT 200`, approvalColumns, config.Schema), status)
+	if err != nil {
+		return nil, fmt.Errorf("error listing approvals: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]Approval, 0)
+	for rows.Next() {
+		approval, err := scanApproval(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning approval: %w", err)
+		}
+		list = append(list, *approval)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating approvals: %w", err)
+	}
+	return list, nil
+}
+
+// ReviewApproval approves or rejects a pending approval. The reviewer must
+// have been checked with approvals.CheckReviewer.
+func ReviewApproval(id int, reviewer string, approve bool, comment string) (*Approval, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	status := approvals.Rejected
+	if approve {
+		status = approvals.Approved
+	}
+	result, err := db.Exec(fmt.Sprintf(`
+		UPDATE %s.approvals
+		SET status = $2, reviewed_by = $3, reviewed_at = now(), review_comment = NULLIF($4, '')
+		WHERE id = $1 AND status = $5 AND requested_by <> $3`, config.Schema),
+		id, status, reviewer, comment, approvals.Pending)
+	if err != nil {
+		return nil, fmt.Errorf("error reviewing approval: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("approval %d is not pending review by %s", id, reviewer)
+	}
+	return GetApproval(id)
+}
+
+// ClaimApproval marks an approved approval as executed by actor and returns
+// it. Only one caller can claim an approval, so its change runs once.
+func ClaimApproval(id int, actor string) (*Approval, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	result, err := db.Exec(fmt.Sprintf(`
+		UPDATE %s.approvals
+		SET status = $2, executed_by = $3, executed_at = now(), error = NULL
+		WHERE id = $1 AND status = $4`, config.Schema),
+		id, approvals.Executed, actor, approvals.Approved)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming approval: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("approval %d is not approved or was already executed", id)
+	}
+	return GetApproval(id)
+}
+
+// ReleaseApproval returns a claimed approval to approved after its change
+// failed, so it can be executed again
+func ReleaseApproval(id int, executionError string) error {
+	if db == nil {
+		return ErrNotInitialized
+	}
+
+	_, err := db.Exec(fmt.Sprintf(`
+		UPDATE %s.approvals
+		SET status = $2, executed_by = NULL, executed_at = NULL, error = $3
+		WHERE id = $1`, config.Schema), id, approvals.Approved, executionError)
+	if err != nil {
+		return fmt.Errorf("error releasing approval: %w", err)
+	}
+	return nil
+}
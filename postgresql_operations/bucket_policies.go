@@ -0,0 +1,85 @@
+package postgresql_operations
+
+import (
+	"fmt"
+
+	"github.com/NarrativeBias/zayavki/bucket_policy"
+)
+
+// PolicyRecord is a bucket access grant stored in the database
+type PolicyRecord struct {
+	bucket_policy.Grant
+	SrtNum    string `json:"srt_num"`
+	GrantedAt string `json:"granted_at"`
+}
+
+// ListPolicyGrants returns the active bucket access grants of a tenant
+func ListPolicyGrants(tenant string) ([]PolicyRecord, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT s3_user, bucket, access, srt_num, to_char(granted_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM %s.bucket_policies
+		WHERE tenant = $1 AND active = true
+		ORDER BY bucket, s3_user`, config.Schema), tenant)
+	if err != nil {
+		return nil, fmt.Errorf("error listing bucket policies: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]PolicyRecord, 0)
+	for rows.Next() {
+		var record PolicyRecord
+		if err := rows.Scan(&record.User, &record.Bucket, &record.Access, &record.SrtNum, &record.GrantedAt); err != nil {
+			return nil, fmt.Errorf("error scanning bucket policy: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bucket policies: %w", err)
+	}
+	return records, nil
+}
+
+// SavePolicyGrants records granted or revoked bucket access. A new grant
+// replaces any active access of the same user to the same bucket.
+func SavePolicyGrants(tenant, srtNum string, grants []bucket_policy.Grant, revoke bool) error {
+	if db == nil {
+		return ErrNotInitialized
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, grant := range grants {
+		_, err := tx.Exec(fmt.Sprintf(`
+			UPDATE %s.bucket_policies
+			SET active = false, revoked_at = now()
+			WHERE tenant = $1 AND bucket = $2 AND s3_user = $3 AND active = true`, config.Schema),
+			tenant, grant.Bucket, grant.User)
+		if err != nil {
+			return fmt.Errorf("error revoking access of %s to %s: %w", grant.User, grant.Bucket, err)
+		}
+		if revoke {
+			continue
+		}
+
+		_, err = tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s.bucket_policies (tenant, bucket, s3_user, access, srt_num)
+			VALUES ($1, $2, $3, $4, $5)`, config.Schema),
+			tenant, grant.Bucket, grant.User, grant.Access, srtNum)
+		if err != nil {
+			return fmt.Errorf("error granting access of %s to %s: %w", grant.User, grant.Bucket, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package postgresql_operations
+
+import (
+	"fmt"
+)
+
+// UsageSnapshot is the space a bucket actually used when its stats were
+// collected
+type UsageSnapshot struct {
+	Cluster    string `json:"cluster"`
+	Tenant     string `json:"tenant"`
+	Bucket     string `json:"bucket"`
+	SizeActual int64  `json:"size_actual"`
+	NumObjects int64  `json:"num_objects"`
+}
+
+// SaveUsageSnapshots stores bucket stats collected at the same time. source
+// tells where they came from, an uploaded file or the Admin Ops API.
+func SaveUsageSnapshots(snapshots []UsageSnapshot, source, actor string) (int, error) {
+	if db == nil {
+		return 0, ErrNotInitialized
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO %s.bucket_usage (cluster, tenant, bucket, size_actual, num_objects, source, collected_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, config.Schema))
+	if err != nil {
+		return 0, fmt.Errorf("error preparing usage insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, s := range snapshots {
+		if _, err := stmt.Exec(s.Cluster, s.Tenant, s.Bucket, s.SizeActual, s.NumObjects, source, actor); err != nil {
+			return 0, fmt.Errorf("error saving usage of %s/%s: %w", s.Tenant, s.Bucket, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(snapshots), nil
+}
+
+// UsageRow is an active registry bucket with its latest usage. LastChange is
+// the first snapshot since which size and object count have not changed,
+// IdleDays the days passed since then.
+type UsageRow struct {
+	Tenant      string  `json:"tenant"`
+	Bucket      string  `json:"bucket"`
+	Cluster     string  `json:"cluster"`
+	Env         string  `json:"environment"`
+	Segment     string  `json:"segment"`
+	RisId       string  `json:"ris_id"`
+	RisCode     string  `json:"ris_code"`
+	Owner       string  `json:"owner"`
+	Quota       string  `json:"quota"`
+	QuotaBytes  int64   `json:"quota_bytes"`
+	SizeActual  int64   `json:"size_actual"`
+	NumObjects  int64   `json:"num_objects"`
+	UsedPercent float64 `json:"used_percent"`
+	CollectedAt string  `json:"collected_at"`
+	LastChange  string  `json:"last_change"`
+	IdleDays    float64 `json:"idle_days"`
+}
+
+// BucketUsage returns the active buckets matching filter that have
+// collected usage, with their latest snapshot and last change. The reports
+// pick the over-utilized and unused ones from them.
+func BucketUsage(filter ReportFilter) ([]UsageRow, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	where, args := filter.where()
+
+	rows, err := db.Query(fmt.Sprintf(`
+		WITH registry AS (
+			SELECT tenant, bucket, cls_name, env, net_seg, ris_id, ris_code, owner_person,
+				coalesce(quota, '-') AS quota, coalesce(quota_bytes, 0) AS quota_bytes
+			FROM %[1]s.%[2]s
+			%[3]s AND coalesce(bucket, '-') <> '-'
+		), latest AS (
+			SELECT DISTINCT ON (tenant, bucket) tenant, bucket, size_actual, num_objects, collected_at
+			FROM %[1]s.bucket_usage
+			ORDER BY tenant, bucket, collected_at DESC
+		), changes AS (
+			SELECT tenant, bucket, collected_at,
+				size_actual IS DISTINCT FROM lag(size_actual) OVER w
+					OR num_objects IS DISTINCT FROM lag(num_objects) OVER w AS changed
+			FROM %[1]s.bucket_usage
+			WINDOW w AS (PARTITION BY tenant, bucket ORDER BY collected_at)
+		), activity AS (
+			SELECT tenant, bucket, max(collected_at) FILTER (WHERE changed) AS last_change
+			FROM changes GROUP BY tenant, bucket
+		)
+		SELECT r.tenant, r.bucket, r.cls_name, r.env, r.net_seg, r.ris_id, r.ris_code, r.owner_person,
+			r.quota, r.quota_bytes, l.size_actual, l.num_objects,
+			to_char(l.collected_at, 'YYYY-MM-DD HH24:MI:SS'), to_char(a.last_change, 'YYYY-MM-DD HH24:MI:SS'),
+			extract(epoch FROM now() - a.last_change) / 86400
+		FROM registry r
+		JOIN latest l ON l.tenant = r.tenant AND l.bucket = r.bucket
+		JOIN activity a ON a.tenant = r.tenant AND a.bucket = r.bucket
+		ORDER BY r.tenant, r.bucket`, config.Schema, config.Table, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error building usage report: %w", err)
+	}
+	defer rows.Close()
+
+	report := make([]UsageRow, 0)
+	for rows.Next() {
+		var row UsageRow
+		if err := rows.Scan(&row.Tenant, &row.Bucket, &row.Cluster, &row.Env, &row.Segment, &row.RisId, &row.RisCode,
+			&row.Owner, &row.Quota, &row.QuotaBytes, &row.SizeActual, &row.NumObjects, &row.CollectedAt,
+			&row.LastChange, &row.IdleDays); err != nil {
+			return nil, fmt.Errorf("error scanning usage report: %w", err)
+		}
+		if row.QuotaBytes > 0 {
+			row.UsedPercent = float64(row.SizeActual) * 100 / float64(row.QuotaBytes)
+		}
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage report: %w", err)
+	}
+	return report, nil
+}
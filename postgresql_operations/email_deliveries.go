@@ -0,0 +1,82 @@
+package postgresql_operations
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Email delivery statuses
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+)
+
+// EmailDelivery is the outcome of sending an email for a request
+type EmailDelivery struct {
+	SdNum      string   `json:"sd_num"`
+	SrtNum     string   `json:"srt_num"`
+	Tenant     string   `json:"tenant"`
+	Recipients []string `json:"recipients"`
+	Subject    string   `json:"subject"`
+	Template   string   `json:"template_version"`
+	Status     string   `json:"status"`
+	Error      string   `json:"error,omitempty"`
+	SentAt     string   `json:"sent_at"`
+}
+
+// RecordEmailDelivery stores the delivery status of an email
+func RecordEmailDelivery(delivery EmailDelivery) error {
+	if db == nil {
+		return ErrNotInitialized
+	}
+
+	var deliveryError sql.NullString
+	if delivery.Error != "" {
+		deliveryError = sql.NullString{String: delivery.Error, Valid: true}
+	}
+	_, err := db.Exec(fmt.Sprintf(`
+		INSERT INTO %s.email_deliveries (sd_num, srt_num, tenant, recipients, subject, template_version, status, error)
+		VALUES (COALESCE(NULLIF($1, ''), '-'), COALESCE(NULLIF($2, ''), '-'), $3, $4, $5, $6, $7, $8)`, config.Schema),
+		delivery.SdNum, delivery.SrtNum, delivery.Tenant, strings.Join(delivery.Recipients, ", "),
+		delivery.Subject, delivery.Template, delivery.Status, deliveryError)
+	if err != nil {
+		return fmt.Errorf("error recording email delivery: %w", err)
+	}
+	return nil
+}
+
+// ListEmailDeliveries returns the emails sent for an SRT request, newest first
+func ListEmailDeliveries(srtNum string) ([]EmailDelivery, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT sd_num, srt_num, tenant, recipients, subject, template_version, status, COALESCE(error, ''),
+			to_char(sent_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM %s.email_deliveries
+		WHERE srt_num = $1
+		ORDER BY sent_at DESC`, config.Schema), srtNum)
+	if err != nil {
+		return nil, fmt.Errorf("error listing email deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]EmailDelivery, 0)
+	for rows.Next() {
+		var delivery EmailDelivery
+		var recipients string
+		err := rows.Scan(&delivery.SdNum, &delivery.SrtNum, &delivery.Tenant, &recipients,
+			&delivery.Subject, &delivery.Template, &delivery.Status, &delivery.Error, &delivery.SentAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning email delivery: %w", err)
+		}
+		delivery.Recipients = strings.Split(recipients, ", ")
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating email deliveries: %w", err)
+	}
+	return deliveries, nil
+}
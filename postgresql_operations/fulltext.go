@@ -0,0 +1,143 @@
+package postgresql_operations
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// searchDocument is the lower-cased text of a registry row searched by
+// TextSearch. The indexes are built on this exact expression, so it must not
+// change without renaming them.
+const searchDocument = `lower(coalesce(tenant, '') || ' ' || coalesce(bucket, '') || ' ' ||
+		coalesce(s3_user, '') || ' ' || coalesce(owner_person, '') || ' ' || coalesce(owner_group, '') || ' ' ||
+		coalesce(applicant, '') || ' ' || coalesce(email, '') || ' ' || coalesce(sd_num, '') || ' ' ||
+		coalesce(srt_num, '') || ' ' || coalesce(cspp_comment, ''))`
+
+const (
+	defaultTextSearchLimit = 200
+	maxTextSearchLimit     = 1000
+	maxTextSearchTerms     = 10
+)
+
+// trigramSearch is set when the pg_trgm index of the migrations exists, so
+// fragments inside words are found by index and ranked by similarity.
+// Without it the search still works, only slower and ranked by full-text
+// matches.
+var trigramSearch bool
+
+func detectTrigramIndex() error {
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = $1 AND indexname = $2)`,
+		config.Schema, config.Table+"_trigram_search_idx").Scan(&trigramSearch)
+	if err != nil {
+		return fmt.Errorf("failed to check the trigram search index: %w", err)
+	}
+	if !trigramSearch {
+		slog.Warn("trigram search index is missing, text search will scan the registry")
+	}
+	return nil
+}
+
+// TextSearchGroup is a tenant with its rows matching a text search. Rank is
+// the rank of its best row.
+type TextSearchGroup struct {
+	Tenant  string        `json:"tenant"`
+	Cluster string        `json:"cluster"`
+	Env     string        `json:"environment"`
+	Segment string        `json:"segment"`
+	Rank    float64       `json:"rank"`
+	Rows    []CheckResult `json:"rows"`
+}
+
+// TextSearchResult holds the groups of a text search, best first. Truncated
+// is set when more rows matched than the limit.
+type TextSearchResult struct {
+	Query     string            `json:"query"`
+	Groups    []TextSearchGroup `json:"groups"`
+	Rows      int               `json:"rows"`
+	Truncated bool              `json:"truncated"`
+}
+
+// TextSearch finds the registry rows whose tenant, bucket, user, owner,
+// applicant, email, SD or SRT number or comment contain every word of text,
+// or match it as full-text, and groups them by tenant
+func TextSearch(text string, limit int) (*TextSearchResult, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	terms := strings.Fields(strings.ToLower(text))
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: search text is empty", ErrInvalidSearch)
+	}
+	if len(terms) > maxTextSearchTerms {
+		return nil, fmt.Errorf("%w: at most %d words can be searched", ErrInvalidSearch, maxTextSearchTerms)
+	}
+	if limit <= 0 {
+		limit = defaultTextSearchLimit
+	}
+	if limit > maxTextSearchLimit {
+		limit = maxTextSearchLimit
+	}
+
+	query := strings.Join(terms, " ")
+	args := []interface{}{query}
+	var fragments []string
+	for _, term := range terms {
+		args = append(args, "%"+escapeLike(term)+"%")
+		fragments = append(fragments, fmt.Sprintf("%s LIKE $%d", searchDocument, len(args)))
+	}
+
+	rank := fmt.Sprintf(`ts_rank(to_tsvector('simple', %s), plainto_tsquery('simple', $1))`, searchDocument)
+	if trigramSearch {
+		rank += fmt.Sprintf(` + similarity(%s, $1)`, searchDocument)
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s, %s AS rank
+		FROM %s.%s
+		WHERE to_tsvector('simple', %s) @@ plainto_tsquery('simple', $1)
+		OR (%s)
+		ORDER BY rank DESC, done_date DESC
+		LIMIT %d`, checkColumns, rank, config.Schema, config.Table, searchDocument,
+		strings.Join(fragments, " AND "), limit+1), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing text search: %w", err)
+	}
+	defer rows.Close()
+
+	result := &TextSearchResult{Query: query, Groups: make([]TextSearchGroup, 0)}
+	groups := map[string]int{}
+	for rows.Next() {
+		var rowRank float64
+		row, err := scanCheckResult(rows, &rowRank)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		if result.Rows == limit {
+			result.Truncated = true
+			break
+		}
+		result.Rows++
+
+		// Rows come best first, so the first row of a tenant sets its rank
+		key := row.ClsName + "/" + row.Tenant
+		i, ok := groups[key]
+		if !ok {
+			i = len(result.Groups)
+			groups[key] = i
+			result.Groups = append(result.Groups, TextSearchGroup{
+				Tenant:  row.Tenant,
+				Cluster: row.ClsName,
+				Env:     row.Env,
+				Segment: row.NetSeg,
+				Rank:    rowRank,
+			})
+		}
+		result.Groups[i].Rows = append(result.Groups[i].Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package postgresql_operations
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/NarrativeBias/zayavki/metrics"
+)
+
+var (
+	queryDuration = metrics.NewHistogram("zayavki_db_query_duration_seconds",
+		"Database query latency by the postgresql_operations function running it.", metrics.DefaultBuckets, "operation")
+	queryErrors = metrics.NewCounter("zayavki_db_query_errors_total",
+		"Failed database queries by the postgresql_operations function running them.", "operation")
+)
+
+// pqConn is what the pq connections implement and the instrumented
+// connections forward
+type pqConn interface {
+	driver.Conn
+	driver.QueryerContext
+	driver.ExecerContext
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// instrumentedConnector times every query of the connections it opens
+type instrumentedConnector struct {
+	driver.Connector
+}
+
+func (c instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pq, ok := conn.(pqConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected database connection type %T", conn)
+	}
+	return instrumentedConn{pq}, nil
+}
+
+type instrumentedConn struct {
+	pqConn
+}
+
+func (c instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	defer queryDuration.ObserveSince(time.Now(), operation())
+	rows, err := c.pqConn.QueryContext(ctx, query, args)
+	countError(err)
+	return rows, err
+}
+
+func (c instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	defer queryDuration.ObserveSince(time.Now(), operation())
+	result, err := c.pqConn.ExecContext(ctx, query, args)
+	countError(err)
+	return result, err
+}
+
+func (c instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.pqConn.PrepareContext(ctx, query)
+	if err != nil {
+		countError(err)
+		return nil, err
+	}
+	return instrumentedStmt{stmt.(pqStmt)}, nil
+}
+
+func (c instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+type pqStmt interface {
+	driver.Stmt
+	driver.StmtQueryContext
+	driver.StmtExecContext
+}
+
+type instrumentedStmt struct {
+	pqStmt
+}
+
+func (s instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	defer queryDuration.ObserveSince(time.Now(), operation())
+	rows, err := s.pqStmt.QueryContext(ctx, args)
+	countError(err)
+	return rows, err
+}
+
+func (s instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer queryDuration.ObserveSince(time.Now(), operation())
+	result, err := s.pqStmt.ExecContext(ctx, args)
+	countError(err)
+	return result, err
+}
+
+// countError counts a failed query against the function that ran it. The
+// caller is looked up again, as errors are rare.
+func countError(err error) {
+	if err != nil && err != driver.ErrSkip {
+		queryErrors.Inc(operation())
+	}
+}
+
+const packagePrefix = "github.com/NarrativeBias/zayavki/postgresql_operations."
+
+// operation returns the postgresql_operations function a query runs for,
+// skipping the instrumentation and database/sql frames
+func operation() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, packagePrefix); ok &&
+			!strings.HasPrefix(name, "instrumented") && name != "countError" {
+			// Closures are named after the function they are in
+			name, _, _ = strings.Cut(name, ".")
+			return name
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
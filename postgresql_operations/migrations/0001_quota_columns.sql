@@ -0,0 +1,3 @@
+-- Object limits and quota enable/disable
+ALTER TABLE {{.Schema}}.{{.Table}} ADD COLUMN IF NOT EXISTS max_objects text NOT NULL DEFAULT '-';
+ALTER TABLE {{.Schema}}.{{.Table}} ADD COLUMN IF NOT EXISTS quota_enabled boolean NOT NULL DEFAULT true;
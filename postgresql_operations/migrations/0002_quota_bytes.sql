@@ -0,0 +1,6 @@
+-- Canonical quota sizes in bytes. quota_bytes is filled for the rows saved
+-- before it existed, whose quota is a plain number of gigabytes. The quota
+-- column itself is left as it is, as other systems read the registry.
+ALTER TABLE {{.Schema}}.{{.Table}} ADD COLUMN IF NOT EXISTS quota_bytes bigint;
+UPDATE {{.Schema}}.{{.Table}} SET quota_bytes = quota::bigint * 1000000000
+	WHERE quota_bytes IS NULL AND quota ~ '^[0-9]+$';
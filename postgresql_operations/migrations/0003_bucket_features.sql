@@ -0,0 +1,4 @@
+-- Versioning, object lock and lifecycle expiration of buckets
+ALTER TABLE {{.Schema}}.{{.Table}} ADD COLUMN IF NOT EXISTS versioning boolean NOT NULL DEFAULT false;
+ALTER TABLE {{.Schema}}.{{.Table}} ADD COLUMN IF NOT EXISTS object_lock text NOT NULL DEFAULT '-';
+ALTER TABLE {{.Schema}}.{{.Table}} ADD COLUMN IF NOT EXISTS lifecycle_expire text NOT NULL DEFAULT '-';
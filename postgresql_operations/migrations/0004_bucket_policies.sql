@@ -0,0 +1,13 @@
+-- Bucket access granted to the users of a tenant
+CREATE TABLE IF NOT EXISTS {{.Schema}}.bucket_policies (
+	id serial PRIMARY KEY,
+	tenant text NOT NULL,
+	bucket text NOT NULL,
+	s3_user text NOT NULL,
+	access text NOT NULL,
+	srt_num text NOT NULL DEFAULT '-',
+	granted_at timestamp NOT NULL DEFAULT now(),
+	revoked_at timestamp,
+	active boolean NOT NULL DEFAULT true
+);
+CREATE INDEX IF NOT EXISTS bucket_policies_tenant_idx ON {{.Schema}}.bucket_policies (tenant) WHERE active;
@@ -0,0 +1,13 @@
+-- Access keys issued to users, for rotation and revocation
+CREATE TABLE IF NOT EXISTS {{.Schema}}.access_keys (
+	id serial PRIMARY KEY,
+	tenant text NOT NULL,
+	s3_user text NOT NULL,
+	access_key text NOT NULL,
+	srt_num text NOT NULL DEFAULT '-',
+	created_at timestamp NOT NULL DEFAULT now(),
+	expires_at timestamp,
+	revoked_at timestamp,
+	active boolean NOT NULL DEFAULT true
+);
+CREATE UNIQUE INDEX IF NOT EXISTS access_keys_key_idx ON {{.Schema}}.access_keys (tenant, access_key);
@@ -0,0 +1,13 @@
+-- Delivery status of the emails sent to customers
+CREATE TABLE IF NOT EXISTS {{.Schema}}.email_deliveries (
+	id serial PRIMARY KEY,
+	sd_num text NOT NULL DEFAULT '-',
+	srt_num text NOT NULL DEFAULT '-',
+	tenant text NOT NULL,
+	recipients text NOT NULL,
+	subject text NOT NULL,
+	status text NOT NULL,
+	error text,
+	sent_at timestamp NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS email_deliveries_srt_idx ON {{.Schema}}.email_deliveries (srt_num);
@@ -0,0 +1,16 @@
+-- Ticket updates waiting to be sent to SRT
+CREATE TABLE IF NOT EXISTS {{.Schema}}.srt_outbox (
+	id serial PRIMARY KEY,
+	srt_num text NOT NULL,
+	kind text NOT NULL,
+	payload text NOT NULL,
+	status text NOT NULL DEFAULT 'pending',
+	attempts integer NOT NULL DEFAULT 0,
+	last_error text,
+	created_at timestamp NOT NULL DEFAULT now(),
+	next_attempt_at timestamp NOT NULL DEFAULT now(),
+	sent_at timestamp
+);
+-- Updates of a ticket wait for every earlier update that is not sent, failed
+-- ones included
+CREATE INDEX IF NOT EXISTS srt_outbox_unsent_idx ON {{.Schema}}.srt_outbox (srt_num, id) WHERE status <> 'sent';
@@ -0,0 +1,28 @@
+-- Request lifecycle states and their history
+CREATE TABLE IF NOT EXISTS {{.Schema}}.requests (
+	id serial PRIMARY KEY,
+	sd_num text NOT NULL DEFAULT '-',
+	srt_num text NOT NULL DEFAULT '-',
+	tenant text NOT NULL DEFAULT '-',
+	state text NOT NULL,
+	form jsonb NOT NULL DEFAULT '{}',
+	note text,
+	created_by text NOT NULL,
+	updated_by text NOT NULL,
+	created_at timestamp NOT NULL DEFAULT now(),
+	updated_at timestamp NOT NULL DEFAULT now()
+);
+-- Requests are keyed by id. Only the requests with a real SD or SRT number
+-- are looked up by them, the ones with neither are separate requests.
+CREATE UNIQUE INDEX IF NOT EXISTS requests_number_idx ON {{.Schema}}.requests (sd_num, srt_num)
+	WHERE sd_num <> '-' OR srt_num <> '-';
+CREATE TABLE IF NOT EXISTS {{.Schema}}.request_transitions (
+	id serial PRIMARY KEY,
+	request_id integer NOT NULL REFERENCES {{.Schema}}.requests (id),
+	from_state text,
+	to_state text NOT NULL,
+	actor text NOT NULL,
+	note text,
+	created_at timestamp NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS request_transitions_request_idx ON {{.Schema}}.request_transitions (request_id);
@@ -0,0 +1,21 @@
+-- Changes waiting for a second user to review them
+CREATE TABLE IF NOT EXISTS {{.Schema}}.approvals (
+	id serial PRIMARY KEY,
+	operation text NOT NULL,
+	sd_num text NOT NULL DEFAULT '-',
+	srt_num text NOT NULL DEFAULT '-',
+	tenant text NOT NULL,
+	env text NOT NULL DEFAULT '-',
+	payload jsonb NOT NULL,
+	plan text NOT NULL,
+	status text NOT NULL DEFAULT 'pending',
+	requested_by text NOT NULL,
+	requested_at timestamp NOT NULL DEFAULT now(),
+	reviewed_by text,
+	reviewed_at timestamp,
+	review_comment text,
+	executed_by text,
+	executed_at timestamp,
+	error text
+);
+CREATE INDEX IF NOT EXISTS approvals_status_idx ON {{.Schema}}.approvals (status);
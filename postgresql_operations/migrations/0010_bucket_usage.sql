@@ -0,0 +1,13 @@
+-- Bucket stats imported from radosgw-admin or collected over the Admin Ops API
+CREATE TABLE IF NOT EXISTS {{.Schema}}.bucket_usage (
+	id serial PRIMARY KEY,
+	cluster text NOT NULL DEFAULT '-',
+	tenant text NOT NULL,
+	bucket text NOT NULL,
+	size_actual bigint NOT NULL,
+	num_objects bigint NOT NULL,
+	source text NOT NULL,
+	collected_by text NOT NULL,
+	collected_at timestamp NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS bucket_usage_bucket_idx ON {{.Schema}}.bucket_usage (tenant, bucket, collected_at);
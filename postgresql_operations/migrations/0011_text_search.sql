@@ -0,0 +1,4 @@
+-- zayavki:no-transaction
+-- Full-text index of the registry, built without locking out writes
+CREATE INDEX CONCURRENTLY IF NOT EXISTS {{.Table}}_text_search_idx ON {{.Schema}}.{{.Table}}
+	USING gin (to_tsvector('simple', {{.SearchDocument}}));
@@ -0,0 +1,6 @@
+-- zayavki:no-transaction
+-- Trigram index for fragments inside words. pg_trgm is a trusted extension,
+-- so the owner of the database can install it.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX CONCURRENTLY IF NOT EXISTS {{.Table}}_trigram_search_idx ON {{.Schema}}.{{.Table}}
+	USING gin (({{.SearchDocument}}) gin_trgm_ops);
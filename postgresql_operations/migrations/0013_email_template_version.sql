@@ -0,0 +1,2 @@
+-- Version of the email template each delivery was rendered from
+ALTER TABLE {{.Schema}}.email_deliveries ADD COLUMN IF NOT EXISTS template_version text NOT NULL DEFAULT '-';
@@ -3,11 +3,13 @@ package postgresql_operations
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/NarrativeBias/zayavki/quota"
 	"github.com/lib/pq"
 )
 
@@ -22,25 +24,31 @@ type DBConfig struct {
 }
 
 type CheckResult struct {
-	ClsName     string         `json:"cluster"`
-	NetSeg      string         `json:"segment"`
-	Env         string         `json:"environment"`
-	Realm       string         `json:"realm"`
-	Tenant      string         `json:"tenant"`
-	S3User      sql.NullString `json:"-"` // Using custom JSON marshaling
-	Bucket      sql.NullString `json:"-"` // Using custom JSON marshaling
-	Quota       sql.NullString `json:"-"` // Using custom JSON marshaling
-	SdNum       string         `json:"sd_num"`
-	SrtNum      string         `json:"srt_num"`
-	DoneDate    string         `json:"done_date"`
-	RisCode     string         `json:"ris_code"`
-	RisId       string         `json:"ris_id"`
-	OwnerGroup  string         `json:"owner_group"`
-	OwnerPerson string         `json:"owner"`
-	Applicant   string         `json:"applicant"`
-	Email       sql.NullString `json:"-"` // Using custom JSON marshaling
-	CsppComment sql.NullString `json:"-"` // Using custom JSON marshaling
-	Active      bool           `json:"active"`
+	ClsName      string         `json:"cluster"`
+	NetSeg       string         `json:"segment"`
+	Env          string         `json:"environment"`
+	Realm        string         `json:"realm"`
+	Tenant       string         `json:"tenant"`
+	S3User       sql.NullString `json:"-"` // Using custom JSON marshaling
+	Bucket       sql.NullString `json:"-"` // Using custom JSON marshaling
+	Quota        sql.NullString `json:"-"` // Using custom JSON marshaling
+	SdNum        string         `json:"sd_num"`
+	SrtNum       string         `json:"srt_num"`
+	DoneDate     string         `json:"done_date"`
+	RisCode      string         `json:"ris_code"`
+	RisId        string         `json:"ris_id"`
+	OwnerGroup   string         `json:"owner_group"`
+	OwnerPerson  string         `json:"owner"`
+	Applicant    string         `json:"applicant"`
+	Email        sql.NullString `json:"-"` // Using custom JSON marshaling
+	CsppComment  sql.NullString `json:"-"` // Using custom JSON marshaling
+	Active       bool           `json:"active"`
+	MaxObjects   string         `json:"max_objects"`
+	QuotaEnabled bool           `json:"quota_enabled"`
+	QuotaBytes   sql.NullInt64  `json:"-"`
+	Versioning   bool           `json:"versioning"`
+	ObjectLock   string         `json:"object_lock"`
+	Expire       string         `json:"lifecycle_expire"`
 }
 
 func (cr CheckResult) MarshalJSON() ([]byte, error) {
@@ -76,33 +84,51 @@ var (
 	DB     *sql.DB
 )
 
+// ErrNotInitialized is returned when the database is used before InitDB
+var ErrNotInitialized = errors.New("database connection not initialized")
+
+// InitDB connects to the database and checks that its schema is up to date
 func InitDB(configPath string) error {
+	if err := OpenDB(configPath); err != nil {
+		return err
+	}
+	if err := checkSchema(); err != nil {
+		CloseDB()
+		return err
+	}
+	return nil
+}
+
+// OpenDB connects to the database without checking its schema, for applying
+// the migrations
+func OpenDB(configPath string) error {
 	// Read the config file
 	file, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %v", err)
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	// Parse the JSON into our DBConfig struct
 	err = json.Unmarshal(file, &config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config file: %v", err)
+		return fmt.Errorf("failed to parse config file: %w", err)
 	}
 
 	// Construct the connection string
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		config.Host, config.Port, config.User, config.Password, config.DBName)
 
-	// Open the database connection
-	db, err = sql.Open("postgres", connStr)
+	// Open the database connection, timing every query for the metrics
+	connector, err := pq.NewConnector(connStr)
 	if err != nil {
-		return fmt.Errorf("failed to open database connection: %v", err)
+		return fmt.Errorf("failed to open database connection: %w", err)
 	}
+	db = sql.OpenDB(instrumentedConnector{connector})
 
 	// Test the connection
 	err = db.Ping()
 	if err != nil {
-		return fmt.Errorf("failed to ping database: %v", err)
+		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	DB = db // Assign to the public DB variable
@@ -120,23 +146,43 @@ func rowExists(tx *sql.Tx, schema, table string, params ...interface{}) (bool, e
 	var exists bool
 	err := tx.QueryRow(query, params...).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("error checking row existence: %v", err)
+		return false, fmt.Errorf("error checking row existence: %w", err)
 	}
 	return exists, nil
 }
 
+// checkColumns are the registry columns read into a CheckResult
+const checkColumns = `cls_name, net_seg, env, realm, tenant,
+            s3_user, bucket, quota, sd_num, srt_num,
+            done_date, ris_code, ris_id, owner_group,
+            owner_person, applicant, email, cspp_comment,
+            active, max_objects, quota_enabled, quota_bytes,
+            versioning, object_lock, lifecycle_expire`
+
+// scanCheckResult scans the checkColumns of a row, followed by the columns
+// of extra
+func scanCheckResult(row rowScanner, extra ...interface{}) (CheckResult, error) {
+	var result CheckResult
+	dest := []interface{}{
+		&result.ClsName, &result.NetSeg, &result.Env, &result.Realm,
+		&result.Tenant, &result.S3User, &result.Bucket, &result.Quota,
+		&result.SdNum, &result.SrtNum, &result.DoneDate, &result.RisCode,
+		&result.RisId, &result.OwnerGroup, &result.OwnerPerson,
+		&result.Applicant, &result.Email, &result.CsppComment, &result.Active,
+		&result.MaxObjects, &result.QuotaEnabled, &result.QuotaBytes,
+		&result.Versioning, &result.ObjectLock, &result.Expire,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return result, err
+}
+
 func CheckDBForExistingEntries(segment, env, risNumber, risName, tenant, bucket, user, clusterName string) ([]CheckResult, error) {
 	if db == nil {
-		return nil, fmt.Errorf("database connection not initialized")
+		return nil, ErrNotInitialized
 	}
 
 	query := fmt.Sprintf(`
-        SELECT DISTINCT 
-            cls_name, net_seg, env, realm, tenant, 
-            s3_user, bucket, quota, sd_num, srt_num, 
-            done_date, ris_code, ris_id, owner_group, 
-            owner_person, applicant, email, cspp_comment,
-            active
+        SELECT DISTINCT %s
         FROM %s.%s
         WHERE ($1 = '' OR net_seg = $1)
         AND ($2 = '' OR env = $2)
@@ -146,61 +192,145 @@ func CheckDBForExistingEntries(segment, env, risNumber, risName, tenant, bucket,
         AND ($6 = '' OR bucket = $6)
         AND ($7 = '' OR s3_user = $7)
         AND ($8 = '' OR cls_name = $8)
-        ORDER BY done_date DESC`, config.Schema, config.Table)
+        ORDER BY done_date DESC`, checkColumns, config.Schema, config.Table)
 
 	rows, err := db.Query(query, segment, env, risNumber, risName, tenant, bucket, user, clusterName)
 	if err != nil {
-		return nil, fmt.Errorf("error executing query: %v", err)
+		return nil, fmt.Errorf("error executing query: %w", err)
 	}
 	defer rows.Close()
 
 	var results []CheckResult
 	for rows.Next() {
-		var result CheckResult
-		err := rows.Scan(
-			&result.ClsName, &result.NetSeg, &result.Env, &result.Realm,
-			&result.Tenant, &result.S3User, &result.Bucket, &result.Quota,
-			&result.SdNum, &result.SrtNum, &result.DoneDate, &result.RisCode,
-			&result.RisId, &result.OwnerGroup, &result.OwnerPerson,
-			&result.Applicant, &result.Email, &result.CsppComment, &result.Active,
-		)
+		result, err := scanCheckResult(rows)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning row: %v", err)
+			return nil, fmt.Errorf("error scanning row: %w", err)
 		}
 		results = append(results, result)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %v", err)
+		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
 	return results, nil
 }
 
-func PushToDB(variables map[string][]string, clusters map[string]string) (string, error) {
+// PushToDB saves a creation request together with the ticket updates returned
+// by outbox, which may be nil
+func PushToDB(variables map[string][]string, clusters map[string]string, outbox OutboxFunc) (string, error) {
 	if db == nil {
-		return "", fmt.Errorf("database connection not initialized")
+		return "", ErrNotInitialized
 	}
 
 	// Start a transaction
 	tx, err := db.Begin()
 	if err != nil {
-		return "", fmt.Errorf("failed to start transaction: %v", err)
+		return "", fmt.Errorf("failed to start transaction: %w", err)
 	}
 	defer tx.Rollback() // Rollback the transaction if it hasn't been committed
 
+	result, err := insertRequestRows(tx, variables, clusters, time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return "", err
+	}
+	if err := enqueueOutbox(tx, outbox, result); err != nil {
+		return "", err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit %s: %w", requestName(variables), err)
+	}
+
+	return result, nil
+}
+
+// requestName names a creation request in errors by its tenant and ticket
+// numbers
+func requestName(variables map[string][]string) string {
+	first := func(key string) string {
+		if len(variables[key]) > 0 && variables[key][0] != "" {
+			return variables[key][0]
+		}
+		return "-"
+	}
+	return fmt.Sprintf("tenant %s (%s/%s)", first("tenant"), first("request_id_sd"), first("request_id_srt"))
+}
+
+// BatchEntry is one creation request of a batch push
+type BatchEntry struct {
+	Variables map[string][]string
+	Clusters  map[string]string
+	// Outbox returns the ticket updates of the entry, it may be nil
+	Outbox OutboxFunc
+}
+
+// BatchResult is the push result or the error of a batch entry
+type BatchResult struct {
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PushBatchToDB pushes the creation requests of a batch in one transaction.
+// Every entry is tried so all failing entries are reported, but nothing is
+// saved unless all of them succeed.
+func PushBatchToDB(entries []BatchEntry) ([]BatchResult, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	doneDate := time.Now().Format("2006-01-02 15:04:05")
+	results := make([]BatchResult, len(entries))
+	failed := 0
+	for i, entry := range entries {
+		// A failed statement aborts the transaction, the savepoint lets the
+		// remaining entries be checked
+		if _, err := tx.Exec("SAVEPOINT batch_entry"); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		result, err := insertRequestRows(tx, entry.Variables, entry.Clusters, doneDate)
+		if err == nil {
+			err = enqueueOutbox(tx, entry.Outbox, result)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			failed++
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT batch_entry"); err != nil {
+				return nil, fmt.Errorf("failed to roll back to savepoint: %w", err)
+			}
+			continue
+		}
+		results[i].Result = result
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("batch not saved: %d of %d entries failed", failed, len(entries))
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return results, nil
+}
+
+// insertRequestRows inserts the user and bucket rows of a creation request
+// after checking that none of them exist
+func insertRequestRows(tx *sql.Tx, variables map[string][]string, clusters map[string]string, done_date string) (string, error) {
 	// Prepare the SQL insert statement
 	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT INTO %s.%s
-        (cls_name, net_seg, env, realm, tenant, s3_user, bucket, quota, sd_num, srt_num, done_date, ris_code, ris_id, owner_group, owner_person, applicant, email, cspp_comment) 
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`, config.Schema, config.Table))
+        (cls_name, net_seg, env, realm, tenant, s3_user, bucket, quota, sd_num, srt_num, done_date, ris_code, ris_id, owner_group, owner_person, applicant, email, cspp_comment, max_objects, quota_bytes, versioning, object_lock, lifecycle_expire) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)`, config.Schema, config.Table))
 	if err != nil {
-		return "", fmt.Errorf("failed to prepare SQL statement: %v", err)
+		return "", fmt.Errorf("failed to prepare SQL statement for %s: %w", requestName(variables), err)
 	}
 	defer stmt.Close()
 
-	// Get current date
-	done_date := time.Now().Format("2006-01-02 15:04:05")
-
 	var duplicates []string
 
 	// Check and collect duplicates for users
@@ -211,7 +341,7 @@ func PushToDB(variables map[string][]string, clusters map[string]string) (string
 				clusters["Кластер"], variables["segment"][0], variables["env"][0],
 				clusters["Реалм"], variables["tenant"][0], username, "-")
 			if err != nil {
-				return "", fmt.Errorf("error checking row existence: %v", err)
+				return "", fmt.Errorf("error checking row existence for %s: %w", requestName(variables), err)
 			}
 			if exists {
 				duplicates = append(duplicates, fmt.Sprintf("user: %s", username))
@@ -226,7 +356,7 @@ func PushToDB(variables map[string][]string, clusters map[string]string) (string
 				clusters["Кластер"], variables["segment"][0], variables["env"][0],
 				clusters["Реалм"], variables["tenant"][0], "-", bucket)
 			if err != nil {
-				return "", fmt.Errorf("error checking row existence: %v", err)
+				return "", fmt.Errorf("error checking row existence for %s: %w", requestName(variables), err)
 			}
 			if exists {
 				duplicates = append(duplicates, fmt.Sprintf("bucket: %s", bucket))
@@ -239,6 +369,12 @@ func PushToDB(variables map[string][]string, clusters map[string]string) (string
 		return "", fmt.Errorf("the following entries already exist: %s", strings.Join(duplicates, ", "))
 	}
 
+	// User-scope quotas requested for the new users
+	userQuotas := map[string][2]string{}
+	for i, user := range variables["quotausers"] {
+		userQuotas[strings.ToLower(user)] = [2]string{variables["quotausersizes"][i], variables["quotauserobjects"][i]}
+	}
+
 	insertedUsers := []string{}
 	insertedBuckets := []string{}
 
@@ -246,17 +382,22 @@ func PushToDB(variables map[string][]string, clusters map[string]string) (string
 	for _, username := range variables["users"] {
 		username = strings.ToLower(username)
 		if username != "" {
+			size, maxObjects := "-", "-"
+			if userQuota, ok := userQuotas[username]; ok {
+				size, maxObjects = userQuota[0], userQuota[1]
+			}
 			_, err = stmt.Exec(
 				clusters["Кластер"], variables["segment"][0], variables["env"][0],
-				clusters["Реалм"], variables["tenant"][0], username, "-", "-",
+				clusters["Реалм"], variables["tenant"][0], username, "-", quota.Legacy(size),
 				variables["request_id_sd"][0], variables["request_id_srt"][0],
 				done_date, variables["ris_name"][0], variables["ris_number"][0],
 				variables["resp_group"][0],
 				fmt.Sprintf("%s; %s", variables["owner"][0], variables["zam_owner"][0]),
-				variables["requester"][0], variables["email"][0], "-",
+				variables["requester"][0], variables["email"][0], "-", maxObjects, quotaBytes(size),
+				false, "-", "-",
 			)
 			if err != nil {
-				return "", fmt.Errorf("failed to insert row for user %s: %v", username, err)
+				return "", fmt.Errorf("failed to insert row for user %s of %s: %w", username, requestName(variables), err)
 			}
 			insertedUsers = append(insertedUsers, username)
 		}
@@ -264,27 +405,31 @@ func PushToDB(variables map[string][]string, clusters map[string]string) (string
 
 	for i, bucket := range variables["bucketnames"] {
 		if bucket != "" {
+			maxObjects, versioning, lock, expire := "-", false, "-", "-"
+			if i < len(variables["bucketobjects"]) {
+				maxObjects = variables["bucketobjects"][i]
+			}
+			if i < len(variables["bucketversioning"]) {
+				versioning = variables["bucketversioning"][i] == "true"
+				lock, expire = variables["bucketlock"][i], variables["bucketexpire"][i]
+			}
 			_, err = stmt.Exec(
 				clusters["Кластер"], variables["segment"][0], variables["env"][0],
-				clusters["Реалм"], variables["tenant"][0], "-", bucket, variables["bucketquotas"][i],
+				clusters["Реалм"], variables["tenant"][0], "-", bucket, quota.Legacy(variables["bucketquotas"][i]),
 				variables["request_id_sd"][0], variables["request_id_srt"][0],
 				done_date, variables["ris_name"][0], variables["ris_number"][0],
 				variables["resp_group"][0],
 				fmt.Sprintf("%s; %s", variables["owner"][0], variables["zam_owner"][0]),
-				variables["requester"][0], "-", "-",
+				variables["requester"][0], "-", "-", maxObjects, quotaBytes(variables["bucketquotas"][i]),
+				versioning, lock, expire,
 			)
 			if err != nil {
-				return "", fmt.Errorf("failed to insert row for bucket %s: %v", bucket, err)
+				return "", fmt.Errorf("failed to insert row for bucket %s of %s: %w", bucket, requestName(variables), err)
 			}
 			insertedBuckets = append(insertedBuckets, bucket)
 		}
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return "", fmt.Errorf("failed to commit transaction: %v", err)
-	}
-
 	result := fmt.Sprintf("Successfully pushed to database:\nTenant: %s\nInserted users: %s\nInserted buckets: %s",
 		variables["tenant"][0],
 		strings.Join(insertedUsers, ", "),
@@ -305,7 +450,7 @@ func CloseDB() error {
 
 func CheckUserExists(tenant, user string) (bool, error) {
 	if db == nil {
-		return false, fmt.Errorf("database connection not initialized")
+		return false, ErrNotInitialized
 	}
 
 	var exists bool
@@ -317,14 +462,14 @@ func CheckUserExists(tenant, user string) (bool, error) {
 
 	err := db.QueryRow(query, tenant, user).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("error checking user existence: %v", err)
+		return false, fmt.Errorf("error checking user existence: %w", err)
 	}
 	return exists, nil
 }
 
 func GetBucketInfo(tenant, bucket string) (*BucketInfo, error) {
 	if db == nil {
-		return nil, fmt.Errorf("database connection not initialized")
+		return nil, ErrNotInitialized
 	}
 
 	var info BucketInfo
@@ -339,7 +484,7 @@ func GetBucketInfo(tenant, bucket string) (*BucketInfo, error) {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("error getting bucket info: %v", err)
+		return nil, fmt.Errorf("error getting bucket info: %w", err)
 	}
 	return &info, nil
 }
@@ -351,12 +496,12 @@ type BucketInfo struct {
 
 func DeactivateResources(tenant string, users []string, buckets []string) (map[string]interface{}, error) {
 	if db == nil {
-		return nil, fmt.Errorf("database connection not initialized")
+		return nil, ErrNotInitialized
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to start transaction: %v", err)
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -375,7 +520,7 @@ func DeactivateResources(tenant string, users []string, buckets []string) (map[s
 
 		rows, err := tx.Query(query, tenant, pq.Array(users))
 		if err != nil {
-			return nil, fmt.Errorf("failed to deactivate users: %v", err)
+			return nil, fmt.Errorf("failed to deactivate users of tenant %s: %w", tenant, err)
 		}
 		defer rows.Close()
 
@@ -383,7 +528,7 @@ func DeactivateResources(tenant string, users []string, buckets []string) (map[s
 		for rows.Next() {
 			var user string
 			if err := rows.Scan(&user); err != nil {
-				return nil, fmt.Errorf("error scanning deactivated user: %v", err)
+				return nil, fmt.Errorf("error scanning deactivated user: %w", err)
 			}
 			deactivatedUsers = append(deactivatedUsers, user)
 		}
@@ -400,7 +545,7 @@ func DeactivateResources(tenant string, users []string, buckets []string) (map[s
 
 		rows, err := tx.Query(query, tenant, pq.Array(buckets))
 		if err != nil {
-			return nil, fmt.Errorf("failed to deactivate buckets: %v", err)
+			return nil, fmt.Errorf("failed to deactivate buckets of tenant %s: %w", tenant, err)
 		}
 		defer rows.Close()
 
@@ -408,7 +553,7 @@ func DeactivateResources(tenant string, users []string, buckets []string) (map[s
 		for rows.Next() {
 			var bucket string
 			if err := rows.Scan(&bucket); err != nil {
-				return nil, fmt.Errorf("error scanning deactivated bucket: %v", err)
+				return nil, fmt.Errorf("error scanning deactivated bucket: %w", err)
 			}
 			deactivatedBuckets = append(deactivatedBuckets, bucket)
 		}
@@ -416,40 +561,83 @@ func DeactivateResources(tenant string, users []string, buckets []string) (map[s
 	}
 
 	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return result, nil
 }
 
-type BucketQuotaUpdateResult struct {
-	UpdatedBuckets []struct {
-		Name string `json:"name"`
-		Size string `json:"size"`
-	} `json:"updated_buckets"`
-	Errors []string `json:"errors"`
+// QuotaUpdate describes a new quota for a bucket or a user
+type QuotaUpdate struct {
+	Name       string `json:"name"`
+	Size       string `json:"size"`
+	MaxObjects string `json:"max_objects,omitempty"`
+	// Values before the update
+	OldSize       string `json:"old_size,omitempty"`
+	OldMaxObjects string `json:"old_max_objects,omitempty"`
+}
+
+type QuotaUpdateResult struct {
+	UpdatedBuckets []QuotaUpdate `json:"updated_buckets"`
+	UpdatedUsers   []QuotaUpdate `json:"updated_users"`
+	Errors         []string      `json:"errors"`
 }
 
-func UpdateBucketQuotas(tenant string, buckets []struct {
-	Name string `json:"name"`
-	Size string `json:"size"`
-}) (*BucketQuotaUpdateResult, error) {
-	result := &BucketQuotaUpdateResult{
-		UpdatedBuckets: make([]struct {
-			Name string `json:"name"`
-			Size string `json:"size"`
-		}, 0),
-		Errors: make([]string, 0),
+// normalizeQuotaUpdate replaces the size with its canonical form. An empty
+// size or "-" keeps the current quota.
+func normalizeQuotaUpdate(update *QuotaUpdate) error {
+	if update.Size == "" || update.Size == "-" {
+		update.Size = ""
+		return nil
 	}
+	size, err := quota.Parse(update.Size)
+	if err != nil {
+		return err
+	}
+	update.Size = size.String()
+	return nil
+}
+
+// storedQuota renders a quota read from the registry, from its exact byte
+// value when the row has one
+func storedQuota(legacy string, bytes sql.NullInt64) string {
+	if !bytes.Valid {
+		return legacy
+	}
+	return quota.Size(bytes.Int64).String()
+}
+
+// quotaBytes returns the byte value stored next to a quota, or NULL when the
+// quota is not set
+func quotaBytes(value string) sql.NullInt64 {
+	size, err := quota.Parse(value)
+	if err != nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(size), Valid: true}
+}
+
+func newQuotaUpdateResult() *QuotaUpdateResult {
+	return &QuotaUpdateResult{
+		UpdatedBuckets: make([]QuotaUpdate, 0),
+		UpdatedUsers:   make([]QuotaUpdate, 0),
+		Errors:         make([]string, 0),
+	}
+}
+
+func UpdateBucketQuotas(tenant string, buckets []QuotaUpdate) (*QuotaUpdateResult, error) {
+	result := newQuotaUpdateResult()
 
 	for _, bucket := range buckets {
 		// Check if bucket exists and is active
 		var active bool
+		var oldBytes sql.NullInt64
 		err := db.QueryRow(fmt.Sprintf(`
-			SELECT active 
+			SELECT active, COALESCE(quota, ''), quota_bytes, COALESCE(max_objects, '')
 			FROM %s.%s 
 			WHERE tenant = $1 AND bucket = $2
-		`, config.Schema, config.Table), tenant, bucket.Name).Scan(&active)
+		`, config.Schema, config.Table), tenant, bucket.Name).Scan(&active, &bucket.OldSize, &oldBytes, &bucket.OldMaxObjects)
+		bucket.OldSize = storedQuota(bucket.OldSize, oldBytes)
 
 		if err == sql.ErrNoRows {
 			result.Errors = append(result.Errors,
@@ -457,7 +645,7 @@ func UpdateBucketQuotas(tenant string, buckets []struct {
 			continue
 		}
 		if err != nil {
-			return nil, fmt.Errorf("error checking bucket status: %v", err)
+			return nil, fmt.Errorf("error checking status of bucket %s/%s: %w", tenant, bucket.Name, err)
 		}
 
 		if !active {
@@ -466,24 +654,122 @@ func UpdateBucketQuotas(tenant string, buckets []struct {
 			continue
 		}
 
-		// Update quota for active bucket
+		if err := normalizeQuotaUpdate(&bucket); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Бакет '%s': %v", bucket.Name, err))
+			continue
+		}
+
+		// Update quota for active bucket, keeping the current values unless new ones are given
 		_, err = db.Exec(fmt.Sprintf(`
 			UPDATE %s.%s 
-			SET quota = $1 
+			SET quota = COALESCE(NULLIF($1, ''), quota),
+				quota_bytes = COALESCE($5, quota_bytes),
+				max_objects = COALESCE(NULLIF($4, ''), max_objects)
 			WHERE tenant = $2 AND bucket = $3 AND active = true
-		`, config.Schema, config.Table), bucket.Size, tenant, bucket.Name)
+		`, config.Schema, config.Table), quota.Legacy(bucket.Size), tenant, bucket.Name, bucket.MaxObjects, quotaBytes(bucket.Size))
 
 		if err != nil {
-			return nil, fmt.Errorf("error updating bucket quota: %v", err)
+			return nil, fmt.Errorf("error updating quota of bucket %s/%s: %w", tenant, bucket.Name, err)
+		}
+
+		result.UpdatedBuckets = append(result.UpdatedBuckets, bucket)
+	}
+
+	return result, nil
+}
+
+// UpdateUserQuotas stores user-scope quotas in the row of each active user
+func UpdateUserQuotas(tenant string, users []QuotaUpdate) (*QuotaUpdateResult, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	result := newQuotaUpdateResult()
+
+	for _, user := range users {
+		if err := normalizeQuotaUpdate(&user); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Пользователь '%s': %v", user.Name, err))
+			continue
 		}
 
-		result.UpdatedBuckets = append(result.UpdatedBuckets, struct {
-			Name string `json:"name"`
-			Size string `json:"size"`
-		}{
-			Name: bucket.Name,
-			Size: bucket.Size,
-		})
+		var oldBytes sql.NullInt64
+		err := db.QueryRow(fmt.Sprintf(`
+			SELECT COALESCE(quota, ''), quota_bytes, COALESCE(max_objects, '')
+			FROM %s.%s
+			WHERE tenant = $1 AND s3_user = $2 AND active = true
+			LIMIT 1
+		`, config.Schema, config.Table), tenant, user.Name).Scan(&user.OldSize, &oldBytes, &user.OldMaxObjects)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("error reading quota of user %s/%s: %w", tenant, user.Name, err)
+		}
+		user.OldSize = storedQuota(user.OldSize, oldBytes)
+
+		res, err := db.Exec(fmt.Sprintf(`
+			UPDATE %s.%s 
+			SET quota = COALESCE(NULLIF($1, ''), quota),
+				quota_bytes = COALESCE($5, quota_bytes),
+				max_objects = COALESCE(NULLIF($4, ''), max_objects)
+			WHERE tenant = $2 AND s3_user = $3 AND active = true
+		`, config.Schema, config.Table), quota.Legacy(user.Size), tenant, user.Name, user.MaxObjects, quotaBytes(user.Size))
+		if err != nil {
+			return nil, fmt.Errorf("error updating quota of user %s/%s: %w", tenant, user.Name, err)
+		}
+
+		updated, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("error updating quota of user %s/%s: %w", tenant, user.Name, err)
+		}
+		if updated == 0 {
+			result.Errors = append(result.Errors,
+				fmt.Sprintf("Пользователь '%s' не найден в базе данных или неактивен", user.Name))
+			continue
+		}
+
+		result.UpdatedUsers = append(result.UpdatedUsers, user)
+	}
+
+	return result, nil
+}
+
+// SetQuotaEnabled marks the quotas of active buckets and users as enabled or disabled
+func SetQuotaEnabled(tenant string, users []string, buckets []string, enabled bool) (*QuotaUpdateResult, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	result := newQuotaUpdateResult()
+
+	targets := []struct {
+		column  string
+		names   []string
+		message string
+		updated *[]QuotaUpdate
+	}{
+		{"s3_user", users, "Пользователь '%s' не найден в базе данных или неактивен", &result.UpdatedUsers},
+		{"bucket", buckets, "Бакет '%s' не найден в базе данных или неактивен", &result.UpdatedBuckets},
+	}
+
+	for _, target := range targets {
+		for _, name := range target.names {
+			res, err := db.Exec(fmt.Sprintf(`
+				UPDATE %s.%s 
+				SET quota_enabled = $1
+				WHERE tenant = $2 AND %s = $3 AND active = true
+			`, config.Schema, config.Table, target.column), enabled, tenant, name)
+			if err != nil {
+				return nil, fmt.Errorf("error updating quota state of %s/%s: %w", tenant, name, err)
+			}
+
+			updated, err := res.RowsAffected()
+			if err != nil {
+				return nil, fmt.Errorf("error updating quota state of %s/%s: %w", tenant, name, err)
+			}
+			if updated == 0 {
+				result.Errors = append(result.Errors, fmt.Sprintf(target.message, name))
+				continue
+			}
+			*target.updated = append(*target.updated, QuotaUpdate{Name: name})
+		}
 	}
 
 	return result, nil
@@ -0,0 +1,185 @@
+package postgresql_operations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidReport is returned for report parameters that cannot be applied
+var ErrInvalidReport = errors.New("invalid report")
+
+// Report groupings and the registry expressions they group by. total puts
+// every row in one group.
+var reportGroups = map[string]string{
+	"total":   "'total'::text",
+	"env":     "env",
+	"segment": "net_seg",
+	"cluster": "cls_name",
+	"ris":     "ris_id || ' ' || ris_code",
+	"tenant":  "tenant",
+}
+
+// Growth report intervals, as understood by date_trunc
+var reportIntervals = map[string]bool{"day": true, "week": true, "month": true, "quarter": true, "year": true}
+
+// ReportFilter limits a report to the active rows of an environment,
+// segment, cluster or RIS number. Empty fields do not filter.
+type ReportFilter struct {
+	Env       string `json:"env"`
+	Segment   string `json:"segment"`
+	Cluster   string `json:"cluster"`
+	RisNumber string `json:"ris_number"`
+}
+
+func (f ReportFilter) where() (string, []interface{}) {
+	conditions := []string{"active"}
+	var args []interface{}
+	for _, filter := range []struct{ column, value string }{
+		{"env", f.Env},
+		{"net_seg", f.Segment},
+		{"cls_name", f.Cluster},
+		{"ris_id", f.RisNumber},
+	} {
+		if value := strings.TrimSpace(filter.value); value != "" {
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("upper(%s) = upper($%d)", filter.column, len(args)))
+		}
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// AllocationRow is the quota allocated to the active buckets and users of a
+// group. Buckets without a quota are counted but add nothing.
+type AllocationRow struct {
+	Key                 string `json:"key"`
+	Tenants             int    `json:"tenants"`
+	Buckets             int    `json:"buckets"`
+	Users               int    `json:"users"`
+	BucketQuotaBytes    int64  `json:"bucket_quota_bytes"`
+	UserQuotaBytes      int64  `json:"user_quota_bytes"`
+	BucketsWithoutQuota int    `json:"buckets_without_quota"`
+}
+
+// AllocationReport returns the allocated quota grouped by env, segment,
+// cluster, ris or tenant, largest first. A positive limit keeps the top
+// groups only.
+func AllocationReport(groupBy string, filter ReportFilter, limit int) ([]AllocationRow, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	query, args, err := allocationQuery(groupBy, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error building allocation report: %w", err)
+	}
+	defer rows.Close()
+
+	report := make([]AllocationRow, 0)
+	for rows.Next() {
+		var row AllocationRow
+		if err := rows.Scan(&row.Key, &row.Tenants, &row.Buckets, &row.Users, &row.BucketQuotaBytes,
+			&row.UserQuotaBytes, &row.BucketsWithoutQuota); err != nil {
+			return nil, fmt.Errorf("error scanning allocation report: %w", err)
+		}
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating allocation report: %w", err)
+	}
+	return report, nil
+}
+
+// allocationQuery returns the query of an allocation report. The grouping is
+// checked against reportGroups as it is not a query parameter.
+func allocationQuery(groupBy string, filter ReportFilter, limit int) (string, []interface{}, error) {
+	key, ok := reportGroups[groupBy]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown report grouping %s", ErrInvalidReport, groupBy)
+	}
+	where, args := filter.where()
+	limitClause := ""
+	if limit > 0 {
+		limitClause = fmt.Sprintf("LIMIT %d", limit)
+	}
+
+	return fmt.Sprintf(`
+		SELECT %s AS key,
+			count(DISTINCT tenant),
+			count(*) FILTER (WHERE coalesce(bucket, '-') <> '-'),
+			count(*) FILTER (WHERE coalesce(s3_user, '-') <> '-'),
+			coalesce(sum(quota_bytes) FILTER (WHERE coalesce(bucket, '-') <> '-'), 0),
+			coalesce(sum(quota_bytes) FILTER (WHERE coalesce(s3_user, '-') <> '-'), 0),
+			count(*) FILTER (WHERE coalesce(bucket, '-') <> '-' AND quota_bytes IS NULL)
+		FROM %s.%s
+		%s
+		GROUP BY 1
+		ORDER BY 5 DESC, 1
+		%s`, key, config.Schema, config.Table, where, limitClause), args, nil
+}
+
+// GrowthRow is the bucket quota allocated in a period and up to its end
+type GrowthRow struct {
+	Period          string `json:"period"`
+	Buckets         int    `json:"buckets"`
+	AddedBytes      int64  `json:"added_bytes"`
+	CumulativeBytes int64  `json:"cumulative_bytes"`
+}
+
+// GrowthReport returns the bucket quota allocated per day, week, month,
+// quarter or year by done_date. Only active buckets are counted, as the
+// registry does not record when a bucket was deactivated, so the last
+// cumulative value is the current allocation.
+func GrowthReport(interval string, filter ReportFilter) ([]GrowthRow, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	query, args, err := growthQuery(interval, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error building growth report: %w", err)
+	}
+	defer rows.Close()
+
+	report := make([]GrowthRow, 0)
+	var total int64
+	for rows.Next() {
+		var row GrowthRow
+		if err := rows.Scan(&row.Period, &row.Buckets, &row.AddedBytes); err != nil {
+			return nil, fmt.Errorf("error scanning growth report: %w", err)
+		}
+		total += row.AddedBytes
+		row.CumulativeBytes = total
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating growth report: %w", err)
+	}
+	return report, nil
+}
+
+// growthQuery returns the query of a growth report, with the interval passed
+// to date_trunc as the last parameter
+func growthQuery(interval string, filter ReportFilter) (string, []interface{}, error) {
+	if !reportIntervals[interval] {
+		return "", nil, fmt.Errorf("%w: unknown report interval %s", ErrInvalidReport, interval)
+	}
+	where, args := filter.where()
+	args = append(args, interval)
+
+	return fmt.Sprintf(`
+		SELECT to_char(date_trunc($%d, done_date::timestamp), 'YYYY-MM-DD'),
+			count(*), coalesce(sum(quota_bytes), 0)
+		FROM %s.%s
+		%s AND coalesce(bucket, '-') <> '-'
+		GROUP BY 1
+		ORDER BY 1`, len(args), config.Schema, config.Table, where), args, nil
+}
@@ -0,0 +1,94 @@
+package postgresql_operations
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportFilterWhere(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ReportFilter
+		where  string
+		args   []interface{}
+	}{
+		{name: "active rows only", filter: ReportFilter{Env: "  "}, where: "WHERE active"},
+		{
+			name:   "trimmed and case-insensitive",
+			filter: ReportFilter{Env: " prod ", Cluster: "K37"},
+			where:  "WHERE active AND upper(env) = upper($1) AND upper(cls_name) = upper($2)",
+			args:   []interface{}{"prod", "K37"},
+		},
+		{
+			name:   "every filter",
+			filter: ReportFilter{Env: "PROD", Segment: "INET", Cluster: "K37", RisNumber: "1763"},
+			where: "WHERE active AND upper(env) = upper($1) AND upper(net_seg) = upper($2) " +
+				"AND upper(cls_name) = upper($3) AND upper(ris_id) = upper($4)",
+			args: []interface{}{"PROD", "INET", "K37", "1763"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.filter.where()
+			if where != tt.where {
+				t.Errorf("where = %q, want %q", where, tt.where)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestAllocationQuery(t *testing.T) {
+	for groupBy, key := range reportGroups {
+		query, args, err := allocationQuery(groupBy, ReportFilter{Env: "PROD"}, 0)
+		if err != nil {
+			t.Errorf("group by %s: %v", groupBy, err)
+			continue
+		}
+		if !strings.Contains(query, "SELECT "+key+" AS key") || !strings.Contains(query, "WHERE active AND upper(env) = upper($1)") {
+			t.Errorf("group by %s: query lacks the key or filter:\n%s", groupBy, query)
+		}
+		if strings.Contains(query, "LIMIT") || !reflect.DeepEqual(args, []interface{}{"PROD"}) {
+			t.Errorf("group by %s: unexpected limit or args %v:\n%s", groupBy, args, query)
+		}
+	}
+
+	query, _, err := allocationQuery("tenant", ReportFilter{}, 5)
+	if err != nil || !strings.Contains(query, "LIMIT 5") {
+		t.Errorf("top 5 tenants: %v\n%s", err, query)
+	}
+
+	for _, groupBy := range []string{"", "bucket", "env; DROP TABLE registry"} {
+		if _, _, err := allocationQuery(groupBy, ReportFilter{}, 0); !errors.Is(err, ErrInvalidReport) {
+			t.Errorf("allocationQuery(%q) error = %v, want ErrInvalidReport", groupBy, err)
+		}
+	}
+}
+
+func TestGrowthQuery(t *testing.T) {
+	for interval := range reportIntervals {
+		query, args, err := growthQuery(interval, ReportFilter{Env: "PROD", Segment: "INET"})
+		if err != nil {
+			t.Errorf("interval %s: %v", interval, err)
+			continue
+		}
+		// The interval is a parameter after the filter values
+		if want := []interface{}{"PROD", "INET", interval}; !reflect.DeepEqual(args, want) {
+			t.Errorf("interval %s: args = %v, want %v", interval, args, want)
+		}
+		if !strings.Contains(query, fmt.Sprintf("date_trunc($%d,", len(args))) {
+			t.Errorf("interval %s: date_trunc does not use the last parameter:\n%s", interval, query)
+		}
+	}
+
+	for _, interval := range []string{"", "hour", "MONTH", "month'"} {
+		if _, _, err := growthQuery(interval, ReportFilter{}); !errors.Is(err, ErrInvalidReport) {
+			t.Errorf("growthQuery(%q) error = %v, want ErrInvalidReport", interval, err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package postgresql_operations
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/NarrativeBias/zayavki/request_lifecycle"
+)
+
+// Request is a tracked request. It is keyed by its ID; a request with an SD
+// or SRT number can also be found by them.
+type Request struct {
+	ID        int               `json:"id"`
+	SdNum     string            `json:"sd_num"`
+	SrtNum    string            `json:"srt_num"`
+	Tenant    string            `json:"tenant"`
+	State     string            `json:"state"`
+	Note      string            `json:"note,omitempty"`
+	Form      map[string]string `json:"form"`
+	CreatedBy string            `json:"created_by"`
+	UpdatedBy string            `json:"updated_by"`
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+	// Transitions are only loaded by GetRequest
+	Transitions []RequestTransition `json:"transitions,omitempty"`
+}
+
+// RequestTransition is a recorded state change of a request
+type RequestTransition struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Actor     string `json:"actor"`
+	Note      string `json:"note,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// RequestUpdate moves a request to a state. The request is found by ID, or
+// by its SD and SRT numbers and created when it does not exist. An update
+// without an ID or numbers creates a new request. Form and Tenant are only
+// stored when set.
+type RequestUpdate struct {
+	ID     int
+	SdNum  string
+	SrtNum string
+	Tenant string
+	State  string
+	Actor  string
+	Note   string
+	Form   map[string]string
+}
+
+// RecordRequestState applies a request update and records the transition
+func RecordRequestState(update RequestUpdate) (*Request, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	sdNum := requestNumber(update.SdNum)
+	srtNum := requestNumber(update.SrtNum)
+
+	var form sql.NullString
+	if update.Form != nil {
+		data, err := json.Marshal(update.Form)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request form: %w", err)
+		}
+		form = sql.NullString{String: string(data), Valid: true}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	var from, tenant string
+	switch {
+	case update.ID != 0:
+		err = tx.QueryRow(fmt.Sprintf(`
+			SELECT id, state, tenant FROM %s.requests WHERE id = $1 FOR UPDATE`, config.Schema),
+			update.ID).Scan(&id, &from, &tenant)
+	case sdNum != "-" || srtNum != "-":
+		err = tx.QueryRow(fmt.Sprintf(`
+			SELECT id, state, tenant FROM %s.requests
+			WHERE sd_num = $1 AND srt_num = $2 AND (sd_num <> '-' OR srt_num <> '-')
+			FOR UPDATE`, config.Schema),
+			sdNum, srtNum).Scan(&id, &from, &tenant)
+	default:
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows && update.ID != 0 {
+		return nil, fmt.Errorf("request %d not found", update.ID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error reading request: %w", err)
+	}
+	// Another tenant under the same numbers is another request, it must not
+	// take over the state and form of this one
+	if update.ID == 0 && id != 0 && update.Tenant != "" && tenant != "-" && !strings.EqualFold(tenant, update.Tenant) {
+		return nil, fmt.Errorf("request %s/%s is tracked for tenant %s, not %s: update it by id", sdNum, srtNum, tenant, update.Tenant)
+	}
+
+	if err := request_lifecycle.CheckTransition(from, update.State); err != nil {
+		return nil, err
+	}
+
+	var note sql.NullString
+	if update.Note != "" {
+		note = sql.NullString{String: update.Note, Valid: true}
+	}
+
+	if id == 0 {
+		err = tx.QueryRow(fmt.Sprintf(`
+			INSERT INTO %s.requests (sd_num, srt_num, tenant, state, form, note, created_by, updated_by)
+			VALUES ($1, $2, COALESCE(NULLIF($3, ''), '-'), $4, COALESCE($5::jsonb, '{}'), $6, $7, $7)
+			RETURNING id`, config.Schema),
+			sdNum, srtNum, update.Tenant, update.State, form, note, update.Actor).Scan(&id)
+	} else {
+		_, err = tx.Exec(fmt.Sprintf(`
+			UPDATE %s.requests
+			SET tenant = COALESCE(NULLIF($2, ''), tenant), state = $3, form = COALESCE($4::jsonb, form),
+				note = $5, updated_by = $6, updated_at = now()
+			WHERE id = $1`, config.Schema),
+			id, update.Tenant, update.State, form, note, update.Actor)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error saving request: %w", err)
+	}
+
+	if from != update.State {
+		var fromState sql.NullString
+		if from != "" {
+			fromState = sql.NullString{String: from, Valid: true}
+		}
+		_, err = tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s.request_transitions (request_id, from_state, to_state, actor, note)
+			VALUES ($1, $2, $3, $4, $5)`, config.Schema),
+			id, fromState, update.State, update.Actor, note)
+		if err != nil {
+			return nil, fmt.Errorf("error recording request transition: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return GetRequest(id)
+}
+
+func requestNumber(number string) string {
+	number = strings.ToUpper(strings.TrimSpace(number))
+	if number == "" {
+		return "-"
+	}
+	return number
+}
+
+const requestColumns = `id, sd_num, srt_num, tenant, state, COALESCE(note, ''), form, created_by, updated_by,
+	to_char(created_at, 'YYYY-MM-DD HH24:MI:SS'), to_char(updated_at, 'YYYY-MM-DD HH24:MI:SS')`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRequest(row rowScanner) (*Request, error) {
+	var request Request
+	var form []byte
+	err := row.Scan(&request.ID, &request.SdNum, &request.SrtNum, &request.Tenant, &request.State, &request.Note,
+		&form, &request.CreatedBy, &request.UpdatedBy, &request.CreatedAt, &request.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(form, &request.Form); err != nil {
+		return nil, fmt.Errorf("error decoding request form: %w", err)
+	}
+	return &request, nil
+}
+
+// GetRequest returns a request with its transitions
+func GetRequest(id int) (*Request, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	request, err := scanRequest(db.QueryRow(fmt.Sprintf(`
+		SELECT %s FROM %s.requests WHERE id = $1`, requestColumns, config.Schema), id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("request %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading request: %w", err)
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT COALESCE(from_state, ''), to_state, actor, COALESCE(note, ''),
+			to_char(created_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM %s.request_transitions
+		WHERE request_id = $1
+		ORDER BY id`, config.Schema), id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request transitions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transition RequestTransition
+		if err := rows.Scan(&transition.From, &transition.To, &transition.Actor, &transition.Note, &transition.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning request transition: %w", err)
+		}
+		request.Transitions = append(request.Transitions, transition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating request transitions: %w", err)
+	}
+	return request, nil
+}
+
+// ListRequests returns the requests created or last changed by actor, or of
+// all actors when actor is empty, most recently changed first. Closed
+// requests are skipped unless includeClosed is set.
+func ListRequests(actor string, includeClosed bool) ([]Request, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s FROM %s.requests
+		WHERE ($1 = '' OR created_by = $1 OR updated_by = $1)
+		AND ($2 OR state <> $3)
+		ORDER BY updated_at DESC
+		LIMIT 200`, requestColumns, config.Schema), actor, includeClosed, request_lifecycle.Closed)
+	if err != nil {
+		return nil, fmt.Errorf("error listing requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]Request, 0)
+	for rows.Next() {
+		request, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning request: %w", err)
+		}
+		requests = append(requests, *request)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating requests: %w", err)
+	}
+	return requests, nil
+}
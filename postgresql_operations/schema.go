@@ -0,0 +1,242 @@
+package postgresql_operations
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/lib/pq"
+)
+
+// The schema is changed only by the versioned migrations, which an operator
+// applies with "zayavki migrate" using a role that may run DDL. The app
+// itself only checks that the schema is recent enough.
+//
+// Migrations are text/templates executed against migrationData. A file that
+// starts with the noTransactionMarker line runs statement by statement
+// outside a transaction, for CREATE INDEX CONCURRENTLY; its statements must
+// end with a semicolon at the end of a line.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const noTransactionMarker = "-- zayavki:no-transaction"
+
+type migration struct {
+	version       int
+	name          string
+	sql           string
+	noTransaction bool
+}
+
+type migrationData struct {
+	Schema         string
+	Table          string
+	SearchDocument string
+}
+
+// loadMigrations returns the migrations ordered by version, rendered for the
+// configured schema and table
+func loadMigrations() ([]migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	data := migrationData{Schema: config.Schema, Table: config.Table, SearchDocument: searchDocument}
+	var migrations []migration
+	for _, file := range names {
+		name := strings.TrimSuffix(path.Base(file), ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no version number", file)
+		}
+		src, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration %s: %w", name, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render migration %s: %w", name, err)
+		}
+		migrations = append(migrations, migration{
+			version:       version,
+			name:          name,
+			sql:           buf.String(),
+			noTransaction: strings.HasPrefix(string(src), noTransactionMarker),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", migrations[i-1].name, migrations[i].name)
+		}
+	}
+	return migrations, nil
+}
+
+// LatestSchemaVersion returns the version of the newest migration
+func LatestSchemaVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].version, nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database, 0 when none was applied
+func SchemaVersion() (int, error) {
+	if db == nil {
+		return 0, ErrNotInitialized
+	}
+	var version int
+	err := db.QueryRow(fmt.Sprintf(`SELECT coalesce(max(version), 0) FROM %s.schema_migrations`, config.Schema)).Scan(&version)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P01" {
+		// undefined_table: no migration was applied yet
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// checkSchema makes sure the migrations the app needs were applied
+func checkSchema() error {
+	current, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current < latest {
+		return fmt.Errorf("database schema is at version %d, the app needs version %d: run \"zayavki migrate\"", current, latest)
+	}
+	return detectTrigramIndex()
+}
+
+// PendingMigrations returns the names of the migrations not applied yet
+func PendingMigrations() ([]string, error) {
+	current, err := SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, m := range migrations {
+		if m.version > current {
+			pending = append(pending, m.name)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the migrations not applied yet, in order, and returns
+// their names. It stops at the first failure; the migrations before it stay
+// applied.
+func Migrate() ([]string, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamp NOT NULL DEFAULT now()
+	)`, config.Schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	current, err := SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(m); err != nil {
+			return applied, fmt.Errorf("migration %s failed: %w", m.name, err)
+		}
+		applied = append(applied, m.name)
+	}
+	return applied, nil
+}
+
+func applyMigration(m migration) error {
+	record := fmt.Sprintf(`INSERT INTO %s.schema_migrations (version, name) VALUES ($1, $2)`, config.Schema)
+
+	if m.noTransaction {
+		for _, statement := range splitStatements(m.sql) {
+			if _, err := db.Exec(statement); err != nil {
+				return err
+			}
+		}
+		_, err := db.Exec(record, m.version, m.name)
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(record, m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements splits a migration at the semicolons that end a line,
+// dropping comments and empty statements
+func splitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line + "\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
@@ -0,0 +1,77 @@
+package postgresql_operations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	config = DBConfig{Schema: "s3", Table: "registry"}
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("%s: version %d, want %d (versions must not leave gaps)", m.name, m.version, i+1)
+		}
+		if strings.Contains(m.sql, "{{") {
+			t.Errorf("%s: unrendered template: %s", m.name, m.sql)
+		}
+		if strings.Contains(m.sql, "CONCURRENTLY") && !m.noTransaction {
+			t.Errorf("%s: CREATE INDEX CONCURRENTLY needs %q", m.name, noTransactionMarker)
+		}
+	}
+
+	latest, err := LatestSchemaVersion()
+	if err != nil || latest != migrations[len(migrations)-1].version {
+		t.Errorf("LatestSchemaVersion() = %d, %v", latest, err)
+	}
+}
+
+func TestSearchIndexMatchesQueries(t *testing.T) {
+	config = DBConfig{Schema: "s3", Table: "registry"}
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The planner only uses the indexes for the exact search document
+	found := 0
+	for _, m := range migrations {
+		if strings.Contains(m.sql, "_search_idx") {
+			found++
+			if !strings.Contains(m.sql, searchDocument) {
+				t.Errorf("%s does not index the search document", m.name)
+			}
+		}
+	}
+	if found != 2 {
+		t.Errorf("found %d search index migrations, want 2", found)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- zayavki:no-transaction
+-- comment
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx ON s3.registry
+	USING gin ((lower(tenant || ';')) gin_trgm_ops);
+`
+	got := splitStatements(sql)
+	want := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm;",
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx ON s3.registry\n\tUSING gin ((lower(tenant || ';')) gin_trgm_ops);",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d statements: %q", len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package postgresql_operations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSearch is returned for search parameters that cannot be applied
+var ErrInvalidSearch = errors.New("invalid search")
+
+// Match modes of the text filters of a search
+const (
+	MatchContains = "contains"
+	MatchPrefix   = "prefix"
+	MatchExact    = "exact"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// MaxExportRows limits the rows of an export, which is built in memory
+const MaxExportRows = 100000
+
+// SearchQuery filters, sorts and pages the registry. Text filters match
+// according to Match, case insensitively unless it is exact. Active is
+// "true", "false" or empty for both, the dates are YYYY-MM-DD and inclusive.
+type SearchQuery struct {
+	Segment   string `json:"segment"`
+	Env       string `json:"env"`
+	RisNumber string `json:"ris_number"`
+	RisName   string `json:"ris_name"`
+	Cluster   string `json:"cluster"`
+	Tenant    string `json:"tenant"`
+	Bucket    string `json:"bucket"`
+	User      string `json:"user"`
+	Owner     string `json:"owner"`
+	SdNum     string `json:"sd_num"`
+	SrtNum    string `json:"srt_num"`
+	Match     string `json:"match"`
+	Active    string `json:"active"`
+	DateFrom  string `json:"date_from"`
+	DateTo    string `json:"date_to"`
+	Sort      string `json:"sort"`
+	Order     string `json:"order"`
+	Page      int    `json:"page"`
+	PageSize  int    `json:"page_size"`
+}
+
+// SearchPage is a page of search results with the total number of matches
+type SearchPage struct {
+	Results  []CheckResult `json:"results"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	Sort     string        `json:"sort"`
+	Order    string        `json:"order"`
+}
+
+// searchSortColumns maps the sortable result fields to their columns
+var searchSortColumns = map[string]string{
+	"active":      "active",
+	"cluster":     "cls_name",
+	"segment":     "net_seg",
+	"environment": "env",
+	"realm":       "realm",
+	"tenant":      "tenant",
+	"user":        "s3_user",
+	"bucket":      "bucket",
+	"quota":       "quota_bytes",
+	"sd_num":      "sd_num",
+	"srt_num":     "srt_num",
+	"done_date":   "done_date",
+	"ris_code":    "ris_code",
+	"ris_id":      "ris_id",
+	"owner_group": "owner_group",
+	"owner":       "owner_person",
+	"applicant":   "applicant",
+}
+
+// searchPlan is the SQL of a search query with its normalized parameters
+type searchPlan struct {
+	where    string
+	args     []interface{}
+	orderBy  string
+	sort     string
+	order    string
+	page     int
+	pageSize int
+}
+
+func planSearch(query SearchQuery) (*searchPlan, error) {
+	match := query.Match
+	if match == "" {
+		match = MatchContains
+	}
+	if match != MatchContains && match != MatchPrefix && match != MatchExact {
+		return nil, fmt.Errorf("%w: unknown match mode %s", ErrInvalidSearch, query.Match)
+	}
+
+	plan := &searchPlan{}
+	var conditions []string
+	addCondition := func(format string, value interface{}) {
+		plan.args = append(plan.args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(plan.args)))
+	}
+
+	textFilters := []struct{ column, value string }{
+		{"net_seg", query.Segment},
+		{"ris_id", query.RisNumber},
+		{"ris_code", query.RisName},
+		{"cls_name", query.Cluster},
+		{"tenant", query.Tenant},
+		{"bucket", query.Bucket},
+		{"s3_user", query.User},
+		{"owner_person", query.Owner},
+		{"sd_num", query.SdNum},
+		{"srt_num", query.SrtNum},
+	}
+	for _, filter := range textFilters {
+		value := strings.TrimSpace(filter.value)
+		if value == "" {
+			continue
+		}
+		switch match {
+		case MatchExact:
+			addCondition(filter.column+" = $%d", value)
+		case MatchPrefix:
+			addCondition(filter.column+" ILIKE $%d", escapeLike(value)+"%")
+		default:
+			addCondition(filter.column+" ILIKE $%d", "%"+escapeLike(value)+"%")
+		}
+	}
+	if env := strings.TrimSpace(query.Env); env != "" {
+		addCondition("env = $%d", env)
+	}
+
+	switch query.Active {
+	case "":
+	case "true", "false":
+		addCondition("active = $%d", query.Active == "true")
+	default:
+		return nil, fmt.Errorf("%w: active must be true or false", ErrInvalidSearch)
+	}
+
+	// done_date is compared as text, which sorts like the date for the
+	// YYYY-MM-DD HH:MM:SS values stored in it
+	if query.DateFrom != "" {
+		from, err := time.Parse("2006-01-02", query.DateFrom)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid date_from %s", ErrInvalidSearch, query.DateFrom)
+		}
+		addCondition("done_date::text >= $%d", from.Format("2006-01-02"))
+	}
+	if query.DateTo != "" {
+		to, err := time.Parse("2006-01-02", query.DateTo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid date_to %s", ErrInvalidSearch, query.DateTo)
+		}
+		addCondition("done_date::text < $%d", to.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	if len(conditions) > 0 {
+		plan.where = "WHERE " + strings.Join(conditions, "\n\t\tAND ")
+	}
+
+	plan.sort = query.Sort
+	if plan.sort == "" {
+		plan.sort = "done_date"
+	}
+	sortColumn, ok := searchSortColumns[plan.sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort column %s", ErrInvalidSearch, query.Sort)
+	}
+	plan.order = strings.ToLower(query.Order)
+	if plan.order == "" {
+		plan.order = "desc"
+	}
+	if plan.order != "asc" && plan.order != "desc" {
+		return nil, fmt.Errorf("%w: order must be asc or desc", ErrInvalidSearch)
+	}
+	// The secondary keys keep the order of equal rows stable between pages
+	plan.orderBy = fmt.Sprintf("%s %s NULLS LAST, done_date DESC, tenant, s3_user, bucket", sortColumn, plan.order)
+
+	plan.page = query.Page
+	if plan.page < 1 {
+		plan.page = 1
+	}
+	plan.pageSize = query.PageSize
+	if plan.pageSize <= 0 {
+		plan.pageSize = defaultPageSize
+	}
+	if plan.pageSize > maxPageSize {
+		plan.pageSize = maxPageSize
+	}
+	return plan, nil
+}
+
+// SearchEntries returns a page of the registry rows matching a search
+func SearchEntries(query SearchQuery) (*SearchPage, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	plan, err := planSearch(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT count(*) FROM (
+			SELECT DISTINCT %s FROM %s.%s
+			%s
+		) matches`, checkColumns, config.Schema, config.Table, plan.where), plan.args...).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("error counting search results: %w", err)
+	}
+
+	results, err := querySearch(plan, fmt.Sprintf("LIMIT %d OFFSET %d", plan.pageSize, (plan.page-1)*plan.pageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchPage{
+		Results:  results,
+		Total:    total,
+		Page:     plan.page,
+		PageSize: plan.pageSize,
+		Sort:     plan.sort,
+		Order:    plan.order,
+	}, nil
+}
+
+// ExportEntries returns every registry row matching a search, in its sort
+// order and ignoring its page, up to MaxExportRows
+func ExportEntries(query SearchQuery) ([]CheckResult, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	plan, err := planSearch(query)
+	if err != nil {
+		return nil, err
+	}
+	results, err := querySearch(plan, fmt.Sprintf("LIMIT %d", MaxExportRows+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > MaxExportRows {
+		return nil, fmt.Errorf("%w: more than %d rows match, narrow the search", ErrInvalidSearch, MaxExportRows)
+	}
+	return results, nil
+}
+
+func querySearch(plan *searchPlan, limit string) ([]CheckResult, error) {
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s FROM (
+			SELECT DISTINCT %s FROM %s.%s
+			%s
+		) matches
+		ORDER BY %s
+		%s`, checkColumns, checkColumns, config.Schema, config.Table, plan.where, plan.orderBy, limit),
+		plan.args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing search: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]CheckResult, 0)
+	for rows.Next() {
+		result, err := scanCheckResult(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return results, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,133 @@
+package postgresql_operations
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanSearchDefaults(t *testing.T) {
+	plan, err := planSearch(SearchQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.where != "" || len(plan.args) != 0 {
+		t.Errorf("empty query planned filters: %q %v", plan.where, plan.args)
+	}
+	if plan.sort != "done_date" || plan.order != "desc" {
+		t.Errorf("sort = %s %s, want done_date desc", plan.sort, plan.order)
+	}
+	if want := "done_date desc NULLS LAST, done_date DESC, tenant, s3_user, bucket"; plan.orderBy != want {
+		t.Errorf("orderBy = %q, want %q", plan.orderBy, want)
+	}
+	if plan.page != 1 || plan.pageSize != defaultPageSize {
+		t.Errorf("page = %d/%d, want 1/%d", plan.page, plan.pageSize, defaultPageSize)
+	}
+}
+
+func TestPlanSearchSort(t *testing.T) {
+	for field, column := range searchSortColumns {
+		plan, err := planSearch(SearchQuery{Sort: field, Order: "ASC"})
+		if err != nil {
+			t.Errorf("sort %s: %v", field, err)
+			continue
+		}
+		if !strings.HasPrefix(plan.orderBy, column+" asc NULLS LAST,") {
+			t.Errorf("sort %s: orderBy = %q, want column %s", field, plan.orderBy, column)
+		}
+	}
+
+	for _, query := range []SearchQuery{
+		{Sort: "quota_bytes"},
+		{Sort: "tenant; DROP TABLE registry"},
+		{Sort: "tenant", Order: "sideways"},
+	} {
+		if _, err := planSearch(query); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("planSearch(%+v) error = %v, want ErrInvalidSearch", query, err)
+		}
+	}
+}
+
+func TestPlanSearchFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query SearchQuery
+		where []string
+		args  []interface{}
+	}{
+		{
+			name:  "contains escapes wildcards",
+			query: SearchQuery{Tenant: " ten_a% ", Bucket: "logs"},
+			where: []string{"tenant ILIKE $1", "bucket ILIKE $2"},
+			args:  []interface{}{`%ten\_a\%%`, "%logs%"},
+		},
+		{
+			name:  "prefix",
+			query: SearchQuery{Match: MatchPrefix, User: "svc", Owner: "Ivanov"},
+			where: []string{"s3_user ILIKE $1", "owner_person ILIKE $2"},
+			args:  []interface{}{"svc%", "Ivanov%"},
+		},
+		{
+			name:  "exact",
+			query: SearchQuery{Match: MatchExact, SdNum: "SD-1", SrtNum: "SRT-2"},
+			where: []string{"sd_num = $1", "srt_num = $2"},
+			args:  []interface{}{"SD-1", "SRT-2"},
+		},
+		{
+			name:  "env active and dates",
+			query: SearchQuery{Env: "PROD", Active: "false", DateFrom: "2024-02-01", DateTo: "2024-02-29"},
+			where: []string{"env = $1", "active = $2", "done_date::text >= $3", "done_date::text < $4"},
+			args:  []interface{}{"PROD", false, "2024-02-01", "2024-03-01"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planSearch(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "WHERE " + strings.Join(tt.where, "\n\t\tAND "); plan.where != want {
+				t.Errorf("where = %q, want %q", plan.where, want)
+			}
+			if !reflect.DeepEqual(plan.args, tt.args) {
+				t.Errorf("args = %#v, want %#v", plan.args, tt.args)
+			}
+		})
+	}
+}
+
+func TestPlanSearchRejects(t *testing.T) {
+	for _, query := range []SearchQuery{
+		{Match: "regex"},
+		{Active: "yes"},
+		{DateFrom: "01.02.2024"},
+		{DateTo: "2024-02-30"},
+	} {
+		if _, err := planSearch(query); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("planSearch(%+v) error = %v, want ErrInvalidSearch", query, err)
+		}
+	}
+}
+
+func TestPlanSearchPaging(t *testing.T) {
+	tests := []struct {
+		page, pageSize         int
+		wantPage, wantPageSize int
+	}{
+		{0, 0, 1, defaultPageSize},
+		{-3, -1, 1, defaultPageSize},
+		{4, 20, 4, 20},
+		{2, maxPageSize + 1, 2, maxPageSize},
+	}
+	for _, tt := range tests {
+		plan, err := planSearch(SearchQuery{Page: tt.page, PageSize: tt.pageSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.page != tt.wantPage || plan.pageSize != tt.wantPageSize {
+			t.Errorf("page %d size %d planned as %d/%d, want %d/%d",
+				tt.page, tt.pageSize, plan.page, plan.pageSize, tt.wantPage, tt.wantPageSize)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package postgresql_operations
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Outbox entry statuses. Failed entries have used up their attempts and are
+// only retried on request.
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// OutboxEntry is an update waiting to be written back to an SRT ticket
+type OutboxEntry struct {
+	ID        int    `json:"id"`
+	SrtNum    string `json:"srt_num"`
+	Kind      string `json:"kind"`
+	Payload   string `json:"-"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	SentAt    string `json:"sent_at,omitempty"`
+}
+
+// OutboxFunc returns the ticket updates of a pushed request given the push
+// result. They are stored in the transaction of the push, so the request is
+// not saved without its updates. An empty ticket number queues nothing.
+type OutboxFunc func(dbResult string) (srtNum string, entries []OutboxEntry, err error)
+
+// enqueueOutbox stores the updates of a request in tx, they are sent in the
+// given order
+func enqueueOutbox(tx *sql.Tx, outbox OutboxFunc, dbResult string) error {
+	if outbox == nil {
+		return nil
+	}
+	srtNum, entries, err := outbox(dbResult)
+	if err != nil {
+		return fmt.Errorf("failed to prepare SRT updates: %w", err)
+	}
+	if srtNum == "" {
+		return nil
+	}
+
+	for _, entry := range entries {
+		_, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s.srt_outbox (srt_num, kind, payload) VALUES ($1, $2, $3)`, config.Schema),
+			srtNum, entry.Kind, entry.Payload)
+		if err != nil {
+			return fmt.Errorf("error queuing %s update for %s: %w", entry.Kind, srtNum, err)
+		}
+	}
+	return nil
+}
+
+// ClaimOutboxEntries returns the pending entries ready to be sent and holds
+// them back from other claims for lease, which must cover their delivery. An
+// entry waits while an earlier entry of the same ticket is not sent, so the
+// updates of a ticket keep their order and stop after one that failed.
+// Entries claimed by a concurrent run are skipped.
+func ClaimOutboxEntries(limit int, lease time.Duration) ([]OutboxEntry, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		UPDATE %[1]s.srt_outbox
+		SET next_attempt_at = now() + $2::integer * interval '1 second'
+		WHERE id IN (
+			SELECT o.id
+			FROM %[1]s.srt_outbox o
+			WHERE o.status = 'pending' AND o.next_attempt_at <= now()
+			AND NOT EXISTS (
+				SELECT 1 FROM %[1]s.srt_outbox earlier
+				WHERE earlier.srt_num = o.srt_num AND earlier.status <> 'sent' AND earlier.id < o.id
+			)
+			ORDER BY o.id
+			LIMIT $1
+			FOR UPDATE OF o SKIP LOCKED
+		)
+		RETURNING id, srt_num, kind, payload, attempts`, config.Schema), limit, int(lease.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("error reading outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		entry := OutboxEntry{Status: OutboxPending}
+		if err := rows.Scan(&entry.ID, &entry.SrtNum, &entry.Kind, &entry.Payload, &entry.Attempts); err != nil {
+			return nil, fmt.Errorf("error scanning outbox entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox: %w", err)
+	}
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// MarkOutboxSent records a delivered entry
+func MarkOutboxSent(id int) error {
+	if db == nil {
+		return ErrNotInitialized
+	}
+
+	_, err := db.Exec(fmt.Sprintf(`
+		UPDATE %s.srt_outbox
+		SET status = 'sent', attempts = attempts + 1, last_error = NULL, sent_at = now()
+		WHERE id = $1`, config.Schema), id)
+	if err != nil {
+		return fmt.Errorf("error updating outbox entry %d: %w", id, err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed records a failed attempt. The entry is retried after
+// retryAfter, or marked as failed when final is set.
+func MarkOutboxFailed(id int, deliveryError string, retryAfter time.Duration, final bool) error {
+	if db == nil {
+		return ErrNotInitialized
+	}
+
+	status := OutboxPending
+	if final {
+		status = OutboxFailed
+	}
+	_, err := db.Exec(fmt.Sprintf(`
+		UPDATE %s.srt_outbox
+		SET status = $2, attempts = attempts + 1, last_error = $3,
+			next_attempt_at = now() + $4::integer * interval '1 second'
+		WHERE id = $1`, config.Schema), id, status, deliveryError, int(retryAfter.Seconds()))
+	if err != nil {
+		return fmt.Errorf("error updating outbox entry %d: %w", id, err)
+	}
+	return nil
+}
+
+// RetryOutbox puts the failed entries of a ticket back in the queue and
+// returns their number
+func RetryOutbox(srtNum string) (int64, error) {
+	if db == nil {
+		return 0, ErrNotInitialized
+	}
+
+	result, err := db.Exec(fmt.Sprintf(`
+		UPDATE %s.srt_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE srt_num = $1 AND status = 'failed'`, config.Schema), srtNum)
+	if err != nil {
+		return 0, fmt.Errorf("error retrying outbox for %s: %w", srtNum, err)
+	}
+	return result.RowsAffected()
+}
+
+// ListOutbox returns the write-back updates of a ticket in queue order
+func ListOutbox(srtNum string) ([]OutboxEntry, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT id, srt_num, kind, status, attempts, COALESCE(last_error, ''),
+			to_char(created_at, 'YYYY-MM-DD HH24:MI:SS'),
+			COALESCE(to_char(sent_at, 'YYYY-MM-DD HH24:MI:SS'), '')
+		FROM %s.srt_outbox
+		WHERE srt_num = $1
+		ORDER BY id`, config.Schema), srtNum)
+	if err != nil {
+		return nil, fmt.Errorf("error listing outbox: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]OutboxEntry, 0)
+	for rows.Next() {
+		var entry OutboxEntry
+		err := rows.Scan(&entry.ID, &entry.SrtNum, &entry.Kind, &entry.Status, &entry.Attempts,
+			&entry.Error, &entry.CreatedAt, &entry.SentAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning outbox entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox: %w", err)
+	}
+	return entries, nil
+}
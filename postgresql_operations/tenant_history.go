@@ -0,0 +1,176 @@
+package postgresql_operations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NarrativeBias/zayavki/approvals"
+	"github.com/NarrativeBias/zayavki/request_lifecycle"
+	"github.com/lib/pq"
+)
+
+// TimelineEvent is something that happened to a tenant, as recorded by the
+// registry, requests, approvals, bucket policies, access keys and emails
+type TimelineEvent struct {
+	At     string `json:"at"`
+	Kind   string `json:"kind"`
+	Actor  string `json:"actor,omitempty"`
+	SrtNum string `json:"srt_num,omitempty"`
+	Event  string `json:"event"`
+}
+
+const maxTimelineEvents = 500
+
+// TenantTimeline returns the events of a tenant, newest first
+func TenantTimeline(tenant string) ([]TimelineEvent, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	// Every part returns at, kind, actor, srt_num, subject, detail and note,
+	// which describeEvent turns into the text of the event
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT at, kind, actor, srt_num, subject, detail, note FROM (
+			SELECT left(done_date::text, 19) AS at, 'created' AS kind, applicant AS actor, srt_num,
+				string_agg(CASE WHEN coalesce(bucket, '-') <> '-' THEN 'бакет ' || bucket
+					ELSE 'пользователь ' || coalesce(s3_user, '-') END, ', ' ORDER BY bucket, s3_user) AS subject,
+				sd_num AS detail, '' AS note
+			FROM %[1]s.%[2]s WHERE tenant = $1
+			GROUP BY done_date, applicant, srt_num, sd_num
+			UNION ALL
+			SELECT to_char(t.created_at, 'YYYY-MM-DD HH24:MI:SS'), 'request', t.actor, r.srt_num,
+				r.sd_num, t.to_state, coalesce(t.note, '')
+			FROM %[1]s.request_transitions t JOIN %[1]s.requests r ON r.id = t.request_id
+			WHERE r.tenant = $1
+			UNION ALL
+			SELECT to_char(requested_at, 'YYYY-MM-DD HH24:MI:SS'), 'approval_requested', requested_by, srt_num,
+				operation, id::text, ''
+			FROM %[1]s.approvals WHERE tenant = $1
+			UNION ALL
+			SELECT to_char(reviewed_at, 'YYYY-MM-DD HH24:MI:SS'), 'approval_reviewed', reviewed_by, srt_num,
+				operation, id::text, CASE WHEN status = $2 THEN status ELSE '' END || coalesce(': ' || review_comment, '')
+			FROM %[1]s.approvals WHERE tenant = $1 AND reviewed_at IS NOT NULL
+			UNION ALL
+			SELECT to_char(executed_at, 'YYYY-MM-DD HH24:MI:SS'), 'approval_executed', executed_by, srt_num,
+				operation, id::text, ''
+			FROM %[1]s.approvals WHERE tenant = $1 AND executed_at IS NOT NULL
+			UNION ALL
+			SELECT to_char(granted_at, 'YYYY-MM-DD HH24:MI:SS'), 'policy_granted', '', srt_num,
+				bucket, s3_user, access
+			FROM %[1]s.bucket_policies WHERE tenant = $1
+			UNION ALL
+			SELECT to_char(revoked_at, 'YYYY-MM-DD HH24:MI:SS'), 'policy_revoked', '', srt_num,
+				bucket, s3_user, access
+			FROM %[1]s.bucket_policies WHERE tenant = $1 AND revoked_at IS NOT NULL
+			UNION ALL
+			SELECT to_char(created_at, 'YYYY-MM-DD HH24:MI:SS'), 'key_created', '', srt_num,
+				s3_user, access_key, ''
+			FROM %[1]s.access_keys WHERE tenant = $1
+			UNION ALL
+			SELECT to_char(revoked_at, 'YYYY-MM-DD HH24:MI:SS'), 'key_revoked', '', srt_num,
+				s3_user, access_key, ''
+			FROM %[1]s.access_keys WHERE tenant = $1 AND revoked_at IS NOT NULL
+			UNION ALL
+			SELECT to_char(sent_at, 'YYYY-MM-DD HH24:MI:SS'), 'email', recipients, srt_num,
+				subject, status, coalesce(error, '')
+			FROM %[1]s.email_deliveries WHERE tenant = $1
+		) events
+		ORDER BY at DESC
+		LIMIT %[3]d`, config.Schema, config.Table, maxTimelineEvents), tenant, approvals.Rejected)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tenant timeline: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]TimelineEvent, 0)
+	for rows.Next() {
+		var event TimelineEvent
+		var subject, detail, note string
+		if err := rows.Scan(&event.At, &event.Kind, &event.Actor, &event.SrtNum, &subject, &detail, &note); err != nil {
+			return nil, fmt.Errorf("error scanning tenant event: %w", err)
+		}
+		if event.SrtNum == "-" {
+			event.SrtNum = ""
+		}
+		event.Event = describeEvent(event.Kind, subject, detail, note)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tenant timeline: %w", err)
+	}
+	return events, nil
+}
+
+func describeEvent(kind, subject, detail, note string) string {
+	var event string
+	switch kind {
+	case "created":
+		event = fmt.Sprintf("Внесены в БД по обращению %s: %s", detail, subject)
+	case "request":
+		state := request_lifecycle.Labels[detail]
+		if state == "" {
+			state = detail
+		}
+		event = fmt.Sprintf("Заявка %s: %s", subject, state)
+	case "approval_requested":
+		event = fmt.Sprintf("Запрошено согласование #%s: %s", detail, approvals.Labels[subject])
+	case "approval_reviewed":
+		verdict := "одобрено"
+		if strings.HasPrefix(note, approvals.Rejected) {
+			verdict = "отклонено"
+			note = strings.TrimPrefix(note, approvals.Rejected)
+		}
+		event = fmt.Sprintf("Согласование #%s %s%s", detail, verdict, note)
+		note = ""
+	case "approval_executed":
+		event = fmt.Sprintf("Выполнено согласование #%s: %s", detail, approvals.Labels[subject])
+	case "policy_granted":
+		event = fmt.Sprintf("Пользователю %s выдан доступ %s к бакету %s", detail, note, subject)
+		note = ""
+	case "policy_revoked":
+		event = fmt.Sprintf("У пользователя %s отозван доступ %s к бакету %s", detail, note, subject)
+		note = ""
+	case "key_created":
+		event = fmt.Sprintf("Пользователю %s создан ключ %s", subject, detail)
+	case "key_revoked":
+		event = fmt.Sprintf("У пользователя %s отозван ключ %s", subject, detail)
+	case "email":
+		event = fmt.Sprintf("Письмо «%s»: %s", subject, detail)
+	default:
+		event = strings.TrimSpace(subject + " " + detail)
+	}
+	if note != "" {
+		event += ": " + note
+	}
+	return event
+}
+
+// TenantRequests returns the tracked requests of a tenant, found by tenant
+// name or by the SRT numbers of its rows, oldest first
+func TenantRequests(tenant string, srtNums []string) ([]Request, error) {
+	if db == nil {
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %s FROM %s.requests
+		WHERE tenant = $1 OR (srt_num <> '-' AND srt_num = ANY($2))
+		ORDER BY created_at`, requestColumns, config.Schema), tenant, pq.Array(srtNums))
+	if err != nil {
+		return nil, fmt.Errorf("error listing tenant requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]Request, 0)
+	for rows.Next() {
+		request, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning request: %w", err)
+		}
+		requests = append(requests, *request)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating requests: %w", err)
+	}
+	return requests, nil
+}
@@ -0,0 +1,31 @@
+package postgresql_operations
+
+import "testing"
+
+func TestDescribeEvent(t *testing.T) {
+	tests := []struct {
+		kind, subject, detail, note string
+		want                        string
+	}{
+		{"created", "бакет ift-logs, пользователь ift_app", "SD-1", "", "Внесены в БД по обращению SD-1: бакет ift-logs, пользователь ift_app"},
+		{"request", "SD-1", "pushed_to_db", "", "Заявка SD-1: Внесена в БД"},
+		{"request", "SD-1", "archived", "вручную", "Заявка SD-1: archived: вручную"},
+		{"approval_requested", "quota_decrease", "7", "", "Запрошено согласование #7: Уменьшение квот"},
+		{"approval_reviewed", "quota_decrease", "7", "", "Согласование #7 одобрено"},
+		{"approval_reviewed", "quota_decrease", "7", ": всё верно", "Согласование #7 одобрено: всё верно"},
+		{"approval_reviewed", "quota_decrease", "7", "rejected: слишком мало", "Согласование #7 отклонено: слишком мало"},
+		{"approval_executed", "batch_create", "8", "", "Выполнено согласование #8: Пакетное создание тенантов"},
+		{"policy_granted", "ift-logs", "ift_app", "read-only", "Пользователю ift_app выдан доступ read-only к бакету ift-logs"},
+		{"policy_revoked", "ift-logs", "ift_app", "admin", "У пользователя ift_app отозван доступ admin к бакету ift-logs"},
+		{"key_created", "ift_app", "AKIA1", "", "Пользователю ift_app создан ключ AKIA1"},
+		{"key_revoked", "ift_app", "AKIA1", "", "У пользователя ift_app отозван ключ AKIA1"},
+		{"email", "Доступ к S3", "sent", "", "Письмо «Доступ к S3»: sent"},
+		{"email", "Доступ к S3", "failed", "timeout", "Письмо «Доступ к S3»: failed: timeout"},
+		{"unknown", "subject", "", "", "subject"},
+	}
+	for _, tt := range tests {
+		if got := describeEvent(tt.kind, tt.subject, tt.detail, tt.note); got != tt.want {
+			t.Errorf("describeEvent(%s, %q, %q, %q) = %q, want %q", tt.kind, tt.subject, tt.detail, tt.note, got, tt.want)
+		}
+	}
+}
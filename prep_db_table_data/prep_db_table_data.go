@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/NarrativeBias/zayavki/quota"
 )
 
 func PopulateUsers(variables map[string][]string, clusters map[string]string) string {
@@ -20,16 +22,27 @@ func PopulateUsers(variables map[string][]string, clusters map[string]string) st
 		ownerInfo = fmt.Sprintf("%s; %s", ownerInfo, zamOwner[0])
 	}
 
+	// Map user-scope quotas by user name
+	userQuotas := make(map[string][2]string)
+	for i, user := range variables["quotausers"] {
+		userQuotas[user] = [2]string{variables["quotausersizes"][i], variables["quotauserobjects"][i]}
+	}
+
 	// Create a row for each username
 	for i, username := range variables["users"] {
 		username = strings.ToLower(username)
 		if username != "" {
-			row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
+			size, maxObjects := "-", "-"
+			if q, ok := userQuotas[username]; ok {
+				size, maxObjects = q[0], q[1]
+			}
+
+			row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
 				clusters["Кластер"], variables["segment"][0], variables["env"][0],
-				clusters["Реалм"], variables["tenant"][0], username, "-", "-",
+				clusters["Реалм"], variables["tenant"][0], username, "-", quota.Legacy(size),
 				variables["request_id_sd"][0], variables["request_id_srt"][0], current_date,
 				variables["ris_name"][0], variables["ris_number"][0], variables["resp_group"][0],
-				ownerInfo, variables["requester"][0])
+				ownerInfo, variables["requester"][0], maxObjects, "false", "-", "-")
 			rows.WriteString(row)
 
 			// Add newline character only if it's not the last row
@@ -58,17 +71,26 @@ func PopulateBuckets(variables map[string][]string, clusters map[string]string)
 	for i, bucket := range variables["bucketnames"] {
 		if bucket != "" {
 			// Get quota and clean it up
-			quota := variables["bucketquotas"][i]
+			size := variables["bucketquotas"][i]
 			// Remove any pipe characters and trim spaces
-			quota = strings.ReplaceAll(quota, "|", "")
-			quota = strings.TrimSpace(quota)
+			size = strings.ReplaceAll(size, "|", "")
+			size = strings.TrimSpace(size)
+
+			maxObjects, versioning, lock, expire := "-", "false", "-", "-"
+			if i < len(variables["bucketobjects"]) {
+				maxObjects = variables["bucketobjects"][i]
+			}
+			if i < len(variables["bucketversioning"]) {
+				versioning, lock, expire = variables["bucketversioning"][i], variables["bucketlock"][i], variables["bucketexpire"][i]
+			}
 
-			row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
+			row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
 				clusters["Кластер"], variables["segment"][0], variables["env"][0],
-				clusters["Реалм"], variables["tenant"][0], "-", bucket, quota,
+				clusters["Реалм"], variables["tenant"][0], "-", bucket, quota.Legacy(size),
 				variables["request_id_sd"][0], variables["request_id_srt"][0],
 				current_date, variables["ris_name"][0], variables["ris_number"][0],
-				variables["resp_group"][0], ownerInfo, variables["requester"][0])
+				variables["resp_group"][0], ownerInfo, variables["requester"][0], maxObjects,
+				versioning, lock, expire)
 			rows.WriteString(row)
 
 			// Add newline character only if it's not the last row
@@ -0,0 +1,147 @@
+package quota
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Size is a quota size in bytes
+type Size int64
+
+type unit struct {
+	suffix string
+	bytes  int64
+}
+
+// Units recognised when parsing, matched case-insensitively. Decimal units
+// (G, GB) are powers of 1000, binary units (GiB) are powers of 1024.
+var units = map[string]int64{
+	"b": 1,
+	"k": 1e3, "kb": 1e3, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gib": 1 << 30,
+	"t": 1e12, "tb": 1e12, "tib": 1 << 40,
+	"p": 1e15, "pb": 1e15, "pib": 1 << 50,
+}
+
+// Units used to render a size, largest first
+var renderUnits = []unit{
+	{"P", 1e15}, {"PiB", 1 << 50},
+	{"T", 1e12}, {"TiB", 1 << 40},
+	{"G", 1e9}, {"GiB", 1 << 30},
+	{"M", 1e6}, {"MiB", 1 << 20},
+	{"K", 1e3}, {"KiB", 1 << 10},
+}
+
+// Limits holds the allowed quota range for an environment
+type Limits struct {
+	Min Size
+	Max Size
+}
+
+// EnvLimits are the quota bounds per environment. Environments that are not
+// listed are not bounded.
+var EnvLimits = map[string]Limits{
+	"PROD":    {Min: 1e9, Max: 500e12},
+	"PREPROD": {Min: 1e9, Max: 100e12},
+	"IFT":     {Min: 1e9, Max: 10e12},
+	"HOTFIX":  {Min: 1e9, Max: 10e12},
+	"LT":      {Min: 1e9, Max: 100e12},
+}
+
+// Parse parses a quota such as 500G, 1.5T or 200GiB. A plain number is taken
+// as gigabytes, which is how quotas were entered before units were supported.
+func Parse(s string) (Size, error) {
+	value := strings.TrimSpace(s)
+	if value == "" {
+		return 0, fmt.Errorf("quota is empty")
+	}
+
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, suffix := value, "g"
+	if i >= 0 {
+		number, suffix = value[:i], strings.ToLower(strings.TrimSpace(value[i:]))
+	}
+
+	multiplier, ok := units[suffix]
+	if number == "" || !ok {
+		return 0, fmt.Errorf("invalid quota %q (expected e.g. 500G, 1.5T, 200GiB)", s)
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quota value %q", s)
+	}
+
+	bytes := math.Round(amount * float64(multiplier))
+	if bytes <= 0 {
+		return 0, fmt.Errorf("quota must be positive: %q", s)
+	}
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("quota is too large: %q", s)
+	}
+	return Size(bytes), nil
+}
+
+// ParseForEnv parses a quota and checks it against the limits of env
+func ParseForEnv(s, env string) (Size, error) {
+	size, err := Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	if err := size.CheckEnv(env); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// CheckEnv reports whether the size is within the limits of env
+func (s Size) CheckEnv(env string) error {
+	limits, ok := EnvLimits[strings.ToUpper(env)]
+	if !ok {
+		return nil
+	}
+	if s < limits.Min || s > limits.Max {
+		return fmt.Errorf("quota %s is out of range for %s (%s - %s)", s, strings.ToUpper(env), limits.Min, limits.Max)
+	}
+	return nil
+}
+
+// Bytes returns the size in bytes as used by radosgw-admin
+func (s Size) Bytes() string {
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// Gigabytes renders the size as a whole number of gigabytes, rounded up.
+// This is how the quota column of the registry holds quotas, as other
+// systems read it.
+func (s Size) Gigabytes() string {
+	return strconv.FormatInt((int64(s)+1e9-1)/1e9, 10)
+}
+
+// Legacy returns a quota as the plain number of gigabytes the quota column
+// of the registry holds, for the systems that read it. quota_bytes holds the
+// exact value. Values that are not quotas, such as "-", are returned as they
+// are.
+func Legacy(s string) string {
+	size, err := Parse(s)
+	if err != nil {
+		return s
+	}
+	return size.Gigabytes()
+}
+
+// String renders the size in the largest unit that represents it exactly,
+// preferring decimal units, e.g. 1.5T is rendered as 1500G.
+func (s Size) String() string {
+	for _, u := range renderUnits {
+		if int64(s) >= u.bytes && int64(s)%u.bytes == 0 {
+			return fmt.Sprintf("%d%s", int64(s)/u.bytes, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", int64(s))
+}
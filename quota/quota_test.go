@@ -0,0 +1,124 @@
+package quota
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Size
+	}{
+		{"500", 500e9},
+		{" 20 ", 20e9},
+		{"500G", 500e9},
+		{"500gb", 500e9},
+		{"1.5T", 1500e9},
+		{"1.5 TB", 1500e9},
+		{"200GiB", 200 << 30},
+		{"1tib", 1 << 40},
+		{"512MiB", 512 << 20},
+		{"750M", 750e6},
+		{"2P", 2e15},
+		{"1024b", 1024},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, in := range []string{"", "  ", "G", "abc", "10X", "10 GiBs", "1.2.3G", "0", "0G", "-5G", "9999999P"} {
+		if got, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		size Size
+		want string
+	}{
+		{500e9, "500G"},
+		{1e12, "1T"},
+		{1500e9, "1500G"},
+		{200 << 30, "200GiB"},
+		{1 << 40, "1TiB"},
+		{750e6, "750M"},
+		{1001, "1001B"},
+	}
+	for _, tt := range tests {
+		got := tt.size.String()
+		if got != tt.want {
+			t.Errorf("Size(%d).String() = %q, want %q", int64(tt.size), got, tt.want)
+		}
+		if back, err := Parse(got); err != nil || back != tt.size {
+			t.Errorf("Parse(%q) = %d, %v, want %d", got, back, err, int64(tt.size))
+		}
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"500", "500"},
+		{"500G", "500"},
+		{"1.5T", "1500"},
+		// Binary and sub-gigabyte quotas are rounded up to whole gigabytes
+		{"200GiB", "215"},
+		{"500M", "1"},
+		{"-", "-"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Legacy(tt.in); got != tt.want {
+			t.Errorf("Legacy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	if got := Size(200 << 30).Bytes(); got != "214748364800" {
+		t.Errorf("Bytes() = %q", got)
+	}
+}
+
+func TestCheckEnv(t *testing.T) {
+	tests := []struct {
+		size Size
+		env  string
+		ok   bool
+	}{
+		{1e9, "PROD", true},
+		{500e12, "prod", true},
+		{500e12 + 1, "PROD", false},
+		{999e6, "PROD", false},
+		{10e12, "IFT", true},
+		{11e12, "IFT", false},
+		{1e6, "DEV", true},
+		{1e18, "", true},
+	}
+	for _, tt := range tests {
+		err := tt.size.CheckEnv(tt.env)
+		if (err == nil) != tt.ok {
+			t.Errorf("Size(%s).CheckEnv(%q) = %v, want ok %t", tt.size, tt.env, err, tt.ok)
+		}
+	}
+}
+
+func TestParseForEnv(t *testing.T) {
+	if size, err := ParseForEnv("1T", "PROD"); err != nil || size != 1e12 {
+		t.Errorf("ParseForEnv(1T, PROD) = %d, %v", size, err)
+	}
+	_, err := ParseForEnv("20T", "IFT")
+	if err == nil || !strings.Contains(err.Error(), "out of range for IFT") {
+		t.Errorf("ParseForEnv(20T, IFT) error = %v", err)
+	}
+	if _, err := ParseForEnv("junk", "PROD"); err == nil {
+		t.Error("ParseForEnv(junk, PROD) accepted")
+	}
+}
@@ -0,0 +1,78 @@
+package request_lifecycle
+
+import "fmt"
+
+// Request states
+const (
+	Draft             = "draft"
+	Validated         = "validated"
+	CommandsGenerated = "commands_generated"
+	Executed          = "executed"
+	PushedToDB        = "pushed_to_db"
+	Closed            = "closed"
+	Failed            = "failed"
+)
+
+// States lists the request states in their usual order
+var States = []string{Draft, Validated, CommandsGenerated, Executed, PushedToDB, Closed, Failed}
+
+// Labels are the state names shown in the UI
+var Labels = map[string]string{
+	Draft:             "Черновик",
+	Validated:         "Проверена",
+	CommandsGenerated: "Команды сформированы",
+	Executed:          "Команды выполнены",
+	PushedToDB:        "Внесена в БД",
+	Closed:            "Закрыта",
+	Failed:            "Ошибка",
+}
+
+// Valid reports whether state is a known state
+func Valid(state string) bool {
+	_, ok := Labels[state]
+	return ok
+}
+
+// Open reports whether work on a request in state is not finished
+func Open(state string) bool {
+	return state != Closed
+}
+
+// transitions lists the states a request may move to from each state. The
+// empty state is a new request. Work before the push to the DB may be
+// repeated; a pushed request can only be closed and a closed one stays
+// closed.
+var transitions = map[string][]string{
+	"":                {Draft, Validated, CommandsGenerated, PushedToDB, Failed},
+	Draft:             {Draft, Validated, CommandsGenerated, PushedToDB, Failed},
+	Validated:         {Validated, CommandsGenerated, PushedToDB, Failed},
+	CommandsGenerated: {Validated, CommandsGenerated, Executed, PushedToDB, Failed},
+	Executed:          {Executed, PushedToDB, Failed},
+	PushedToDB:        {Closed},
+	Failed:            {Validated, CommandsGenerated, PushedToDB, Failed},
+	Closed:            {},
+}
+
+// CheckTransition returns an error when a request cannot move from one state
+// to another
+func CheckTransition(from, to string) error {
+	if !Valid(to) {
+		return fmt.Errorf("unknown request state: %s", to)
+	}
+	next, ok := transitions[from]
+	if !ok {
+		return fmt.Errorf("unknown request state: %s", from)
+	}
+	for _, state := range next {
+		if state == to {
+			return nil
+		}
+	}
+	if from == Closed {
+		return fmt.Errorf("request is closed")
+	}
+	if from == "" {
+		return fmt.Errorf("a new request cannot start as %s", to)
+	}
+	return fmt.Errorf("request cannot move from %s to %s", from, to)
+}
@@ -0,0 +1,58 @@
+package request_lifecycle
+
+import "testing"
+
+func TestCheckTransition(t *testing.T) {
+	allowed := map[string][]string{
+		"":                {Draft, Validated, CommandsGenerated, PushedToDB, Failed},
+		Draft:             {Draft, Validated, CommandsGenerated, PushedToDB, Failed},
+		Validated:         {Validated, CommandsGenerated, PushedToDB, Failed},
+		CommandsGenerated: {Validated, CommandsGenerated, Executed, PushedToDB, Failed},
+		Executed:          {Executed, PushedToDB, Failed},
+		PushedToDB:        {Closed},
+		Failed:            {Validated, CommandsGenerated, PushedToDB, Failed},
+		Closed:            {},
+	}
+
+	// Every pair of states is checked, so a state added without a row in
+	// the table fails here
+	for _, from := range append([]string{""}, States...) {
+		want := map[string]bool{}
+		for _, to := range allowed[from] {
+			want[to] = true
+		}
+		for _, to := range States {
+			err := CheckTransition(from, to)
+			if want[to] && err != nil {
+				t.Errorf("%q -> %s rejected: %v", from, to, err)
+			}
+			if !want[to] && err == nil {
+				t.Errorf("%q -> %s allowed", from, to)
+			}
+		}
+	}
+}
+
+func TestCheckTransitionUnknownState(t *testing.T) {
+	if err := CheckTransition(Draft, "archived"); err == nil {
+		t.Error("unknown target state accepted")
+	}
+	if err := CheckTransition("archived", Closed); err == nil {
+		t.Error("unknown current state accepted")
+	}
+}
+
+func TestCheckTransitionErrors(t *testing.T) {
+	tests := []struct{ from, to, want string }{
+		{Closed, Validated, "request is closed"},
+		{"", Closed, "a new request cannot start as closed"},
+		{PushedToDB, CommandsGenerated, "request cannot move from pushed_to_db to commands_generated"},
+		{Failed, Closed, "request cannot move from failed to closed"},
+	}
+	for _, tt := range tests {
+		err := CheckTransition(tt.from, tt.to)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("CheckTransition(%q, %s) = %v, want %q", tt.from, tt.to, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package request_processing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NarrativeBias/zayavki/access_keys"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/rgw_commands"
+)
+
+// AccessKeyRequest issues, rotates or revokes the access keys of a user.
+// Action list returns the keys due for rotation instead. Revocation removes
+// the listed keys, or every key of the user with RevokeAll.
+type AccessKeyRequest struct {
+	Tenant       string `json:"tenant"`
+	User         string `json:"user"`
+	Action       string `json:"action"`
+	AccessKeys   string `json:"access_keys"`
+	RevokeAll    bool   `json:"revoke_all"`
+	KeyOutput    string `json:"key_output"`
+	MinAgeDays   int    `json:"min_age_days"`
+	RequestIdSrt string `json:"request_id_srt,omitempty"`
+	PushToDb     bool   `json:"push_to_db"`
+}
+
+// ManageAccessKeys returns the key commands of a request with the keys of the
+// user. With PushToDb the issued key is recorded and the replaced keys are
+// revoked together.
+func ManageAccessKeys(request AccessKeyRequest) (map[string]interface{}, error) {
+	request.User = strings.ToLower(strings.TrimSpace(request.User))
+
+	// Listing keys due for rotation does not need a user
+	if request.Action == "list" {
+		minAge := request.MinAgeDays
+		if minAge <= 0 {
+			minAge = access_keys.MaxAgeDays()
+		}
+		keys, err := postgresql_operations.ListAccessKeys(request.Tenant, request.User, minAge)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"keys":         keys,
+			"min_age_days": minAge,
+		}, nil
+	}
+
+	if request.Tenant == "" || request.User == "" {
+		return nil, invalidRequest("Tenant and user are required")
+	}
+
+	results, err := postgresql_operations.CheckDBForExistingEntries(
+		"", "", "", "", request.Tenant, "", "", "",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking database: %v", err)
+	}
+	var tenantInfo *postgresql_operations.CheckResult
+	userActive := false
+	for i, result := range results {
+		if result.S3User.Valid && result.S3User.String == request.Tenant && tenantInfo == nil {
+			tenantInfo = &results[i]
+		}
+		if result.Active && result.S3User.Valid && result.S3User.String == request.User {
+			userActive = true
+		}
+	}
+	if tenantInfo == nil {
+		return nil, ErrTenantNotFound
+	}
+	if !userActive {
+		return nil, userNotFound(request.User)
+	}
+
+	listedKeys, err := access_keys.ExtractAccessKeys(request.AccessKeys)
+	if err != nil {
+		return nil, invalidRequest("Error parsing access keys: %v", err)
+	}
+	current, err := postgresql_operations.ListAccessKeys(request.Tenant, request.User, 0)
+	if err != nil {
+		return nil, err
+	}
+	// The keys the user has before a new one is issued: the recorded ones and
+	// the listed ones, which cover users whose keys were never recorded
+	var previousKeys []string
+	for _, key := range current {
+		previousKeys = append(previousKeys, key.AccessKey)
+	}
+	previousKeys = append(previousKeys, listedKeys...)
+
+	oldKeys, err := replacedKeys(request.Action, listedKeys, previousKeys, request.RevokeAll)
+	if err != nil {
+		return nil, err
+	}
+
+	commands, err := rgw_commands.KeyCommands(request.Tenant, request.User, request.Action, oldKeys, InventoryClusterMap(tenantInfo))
+	if err != nil {
+		return nil, invalidRequest("%v", err)
+	}
+
+	response := map[string]interface{}{
+		"tenant": map[string]string{
+			"name":        request.Tenant,
+			"cluster":     tenantInfo.ClsName,
+			"env":         tenantInfo.Env,
+			"segment":     tenantInfo.NetSeg,
+			"realm":       tenantInfo.Realm,
+			"ris_code":    tenantInfo.RisCode,
+			"ris_id":      tenantInfo.RisId,
+			"owner_group": tenantInfo.OwnerGroup,
+			"owner":       tenantInfo.OwnerPerson,
+		},
+		"commands": commands,
+	}
+
+	if request.PushToDb {
+		requestIdSrt := request.RequestIdSrt
+		if requestIdSrt == "" {
+			requestIdSrt = "-"
+		}
+		requestIdSrt = strings.ToUpper(requestIdSrt)
+
+		var issued []string
+		if request.Action != "revoke" {
+			// Only key IDs are taken from the command output, secrets are dropped
+			outputKeys, err := access_keys.ExtractAccessKeys(request.KeyOutput)
+			if err != nil {
+				return nil, invalidRequest("Error parsing new keys: %v", err)
+			}
+			key, _, err := access_keys.SplitIssuedKeys(outputKeys, previousKeys)
+			if err != nil {
+				return nil, invalidRequest("%v", err)
+			}
+			issued = []string{key}
+		}
+		recorded, revoked, err := postgresql_operations.ReplaceAccessKeys(request.Tenant, request.User, requestIdSrt, issued, oldKeys, access_keys.ValidityDays())
+		if err != nil {
+			return nil, err
+		}
+		if request.Action != "revoke" {
+			response["recorded"] = recorded
+		}
+		if request.Action != "issue" {
+			response["revoked"] = revoked
+		}
+		response["saved"] = true
+	}
+
+	keys, err := postgresql_operations.ListAccessKeys(request.Tenant, request.User, 0)
+	if err != nil {
+		return nil, err
+	}
+	response["keys"] = keys
+	return response, nil
+}
+
+// replacedKeys returns the keys a request removes. Rotation removes every key
+// the user had and issuing none. Revocation removes the listed keys; removing
+// every key has to be asked for with revokeAll, so a forgotten list does not
+// lock the user out.
+func replacedKeys(action string, listed, previous []string, revokeAll bool) ([]string, error) {
+	switch action {
+	case "issue":
+		return nil, nil
+	case "rotate":
+		return previous, nil
+	case "revoke":
+		if revokeAll {
+			if len(listed) > 0 {
+				return nil, invalidRequest("List the keys to revoke or revoke all keys, not both")
+			}
+			if len(previous) == 0 {
+				return nil, invalidRequest("The user has no recorded keys to revoke")
+			}
+			return previous, nil
+		}
+		if len(listed) == 0 {
+			return nil, invalidRequest("List the keys to revoke or choose to revoke all keys")
+		}
+		return listed, nil
+	}
+	return nil, invalidRequest("Unknown key action '%s'", action)
+}
@@ -0,0 +1,45 @@
+package request_processing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReplacedKeys(t *testing.T) {
+	listed := []string{"LISTED1"}
+	previous := []string{"RECORDED1", "RECORDED2"}
+
+	tests := []struct {
+		name      string
+		action    string
+		listed    []string
+		previous  []string
+		revokeAll bool
+		want      []string
+		wantErr   bool
+	}{
+		{name: "issue keeps every key", action: "issue", listed: listed, previous: previous},
+		{name: "rotate removes every previous key", action: "rotate", previous: previous, want: previous},
+		{name: "revoke removes the listed keys", action: "revoke", listed: listed, previous: previous, want: listed},
+		{name: "revoke all", action: "revoke", previous: previous, revokeAll: true, want: previous},
+		{name: "revoke without keys is refused", action: "revoke", previous: previous, wantErr: true},
+		{name: "revoke all with listed keys is refused", action: "revoke", listed: listed, previous: previous, revokeAll: true, wantErr: true},
+		{name: "revoke all without keys", action: "revoke", revokeAll: true, wantErr: true},
+		{name: "unknown action", action: "delete", listed: listed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := replacedKeys(tt.action, tt.listed, tt.previous, tt.revokeAll)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replacedKeys() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("replacedKeys() error = %v, want ErrInvalidRequest", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replacedKeys() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package request_processing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/NarrativeBias/zayavki/approvals"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/request_lifecycle"
+)
+
+// RequestApproval saves a change requested by actor for review by another
+// user. The request is stored to be executed once approved, the plan is
+// shown to the reviewer.
+func RequestApproval(actor, operation, tenant, requestIdSrt string, request interface{}, plan string) (*postgresql_operations.Approval, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode request for approval: %v", err)
+	}
+
+	return postgresql_operations.CreateApproval(postgresql_operations.Approval{
+		Operation:   operation,
+		SrtNum:      strings.ToUpper(requestIdSrt),
+		Tenant:      tenant,
+		Payload:     payload,
+		Plan:        plan,
+		RequestedBy: actor,
+	})
+}
+
+// ExecuteApproval runs an approved change with the request saved at approval
+// time and returns the result of the original operation
+func ExecuteApproval(ctx context.Context, actor string, approval *postgresql_operations.Approval) (interface{}, error) {
+	switch approval.Operation {
+	case approvals.OpCreate:
+		var payload CreateApprovalPayload
+		if err := json.Unmarshal(approval.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid approval payload: %v", err)
+		}
+		if err := CheckTenantExists(payload.Variables, payload.Cluster); err != nil {
+			return nil, err
+		}
+		result, err := PushToDatabase(ctx, payload.Variables, payload.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		TrackRequest(ctx, actor, payload.Variables, nil, request_lifecycle.PushedToDB, fmt.Sprintf("Согласовано #%d", approval.ID))
+		return result, nil
+	case approvals.OpDeactivate:
+		var payload DeactivationRequest
+		if err := json.Unmarshal(approval.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid approval payload: %v", err)
+		}
+		return DeactivateResources(payload)
+	case approvals.OpQuotaDecrease:
+		var payload QuotaUpdateRequest
+		if err := json.Unmarshal(approval.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid approval payload: %v", err)
+		}
+		result, err := UpdateQuotas(payload)
+		if err != nil {
+			return nil, err
+		}
+		return QuotaResponse(payload.Tenant, payload.RequestIdSrt, "", payload.EmailLang, result)
+	case approvals.OpQuotaToggle:
+		var payload QuotaUpdateRequest
+		if err := json.Unmarshal(approval.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid approval payload: %v", err)
+		}
+		return ToggleQuotas(payload)
+	case approvals.OpBatchCreate:
+		var entries []BatchEntry
+		if err := json.Unmarshal(approval.Payload, &entries); err != nil {
+			return nil, fmt.Errorf("invalid approval payload: %v", err)
+		}
+		report, err := PushBatch(ctx, actor, entries, fmt.Sprintf("Согласовано #%d", approval.ID))
+		if err != nil {
+			var problems []string
+			for _, row := range report {
+				for _, problem := range row.Errors {
+					problems = append(problems, fmt.Sprintf("строка %d: %s", row.Line, problem))
+				}
+			}
+			return nil, fmt.Errorf("%v: %s", err, strings.Join(problems, "; "))
+		}
+		return map[string]interface{}{"rows": report}, nil
+	}
+	return nil, fmt.Errorf("unknown approval operation: %s", approval.Operation)
+}
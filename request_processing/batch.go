@@ -0,0 +1,266 @@
+package request_processing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/NarrativeBias/zayavki/approvals"
+	"github.com/NarrativeBias/zayavki/batch_import"
+	"github.com/NarrativeBias/zayavki/cluster_endpoint_parser"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/prep_db_table_data"
+	"github.com/NarrativeBias/zayavki/request_lifecycle"
+	"github.com/NarrativeBias/zayavki/variables_parser"
+)
+
+// Batch row statuses
+const (
+	BatchInvalid   = "invalid"
+	BatchValid     = "valid"
+	BatchPushed    = "pushed"
+	BatchNotPushed = "not_pushed"
+	BatchPending   = "pending_approval"
+)
+
+// BatchRow is the report of a batch row
+type BatchRow struct {
+	Line    int      `json:"line"`
+	SdNum   string   `json:"sd_num"`
+	SrtNum  string   `json:"srt_num"`
+	Tenant  string   `json:"tenant"`
+	Env     string   `json:"env"`
+	Cluster string   `json:"cluster"`
+	Status  string   `json:"status"`
+	Errors  []string `json:"errors,omitempty"`
+	Result  string   `json:"result,omitempty"`
+}
+
+// BatchEntry is a validated batch row, ready to be pushed. Entries are also
+// the payload of batch approvals.
+type BatchEntry struct {
+	Line      int                 `json:"line"`
+	Variables map[string][]string `json:"variables"`
+	Cluster   map[string]string   `json:"cluster"`
+}
+
+// prepareRow processes a batch row, replaced in the tests as it reads the
+// cluster inventory and the DB
+var prepareRow = prepareBatchRow
+
+// PrepareBatch processes and checks every row of a batch. The entries are
+// nil unless all rows are valid.
+func PrepareBatch(ctx context.Context, actor string, rows []batch_import.Row) ([]BatchRow, []BatchEntry) {
+	report := make([]BatchRow, len(rows))
+	entries := make([]BatchEntry, len(rows))
+	tenants := map[string]int{}
+	valid := true
+
+	for i, row := range rows {
+		report[i] = BatchRow{Line: row.Line, Status: BatchValid}
+		vars, cluster, problems := prepareRow(row)
+		if vars != nil {
+			getFirst := func(key string) string {
+				if len(vars[key]) > 0 {
+					return vars[key][0]
+				}
+				return ""
+			}
+			report[i].SdNum, report[i].SrtNum = getFirst("request_id_sd"), getFirst("request_id_srt")
+			report[i].Tenant, report[i].Env = getFirst("tenant"), getFirst("env")
+			report[i].Cluster = cluster["Кластер"]
+		}
+		if tenant := report[i].Tenant; tenant != "" && len(problems) == 0 {
+			if line, ok := tenants[tenant]; ok {
+				problems = append(problems, fmt.Sprintf("Тенант %s уже создается в строке %d", tenant, line))
+			} else {
+				tenants[tenant] = row.Line
+			}
+		}
+
+		if len(problems) > 0 {
+			report[i].Status = BatchInvalid
+			report[i].Errors = problems
+			valid = false
+			TrackRequest(ctx, actor, row.Values, FormValues(row.Values), request_lifecycle.Failed, strings.Join(problems, "; "))
+			continue
+		}
+		entries[i] = BatchEntry{Line: row.Line, Variables: vars, Cluster: cluster}
+	}
+
+	if !valid {
+		return report, nil
+	}
+	return report, entries
+}
+
+// prepareBatchRow processes a batch row like a submitted new tenant form. It
+// returns the processed variables when they could be parsed, the cluster when
+// it was found, and the problems of the row.
+func prepareBatchRow(row batch_import.Row) (map[string][]string, map[string]string, []string) {
+	vars, err := variables_parser.ParseAndProcessVariables(row.Values)
+	if err != nil {
+		return nil, nil, []string{err.Error()}
+	}
+	if problems := batch_import.CheckRow(vars); len(problems) > 0 {
+		return vars, nil, problems
+	}
+
+	clusters, err := cluster_endpoint_parser.FindMatchingClusters("clusters.xlsx", vars["segment"][0], vars["env"][0])
+	if err != nil {
+		return vars, nil, []string{err.Error()}
+	}
+	var cluster *cluster_endpoint_parser.ClusterInfo
+	var names []string
+	for i := range clusters {
+		names = append(names, clusters[i].Кластер)
+		if len(clusters) == 1 || strings.EqualFold(clusters[i].Кластер, row.Cluster) {
+			cluster = &clusters[i]
+		}
+	}
+	switch {
+	case len(clusters) == 0:
+		return vars, nil, []string{fmt.Sprintf("Не найден кластер для зоны %s и среды %s", vars["segment"][0], vars["env"][0])}
+	case cluster == nil && row.Cluster != "":
+		return vars, nil, []string{fmt.Sprintf("Кластер %s не обслуживает зону %s и среду %s, доступны: %s",
+			row.Cluster, vars["segment"][0], vars["env"][0], strings.Join(names, ", "))}
+	case cluster == nil:
+		return vars, nil, []string{fmt.Sprintf("Найдено несколько кластеров, укажите один из них в колонке cluster: %s",
+			strings.Join(names, ", "))}
+	}
+
+	clusterMap, err := PrepareCreation(vars, *cluster)
+	if err != nil {
+		return vars, nil, []string{err.Error()}
+	}
+	return vars, clusterMap, nil
+}
+
+// BatchScript returns the DB table rows and the terminal commands of all
+// batch entries as one script
+func BatchScript(entries []BatchEntry) (string, error) {
+	var table, commands strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&table, "# Строка %d: %s\n", entry.Line, entry.Variables["tenant"][0])
+		table.WriteString(prep_db_table_data.PopulateUsers(entry.Variables, entry.Cluster))
+		table.WriteString("\n")
+		table.WriteString(prep_db_table_data.PopulateBuckets(entry.Variables, entry.Cluster))
+		table.WriteString("\n\n")
+
+		rowCommands, err := CreationCommands(entry.Variables, entry.Cluster)
+		if err != nil {
+			return "", fmt.Errorf("row %d: %v", entry.Line, err)
+		}
+		fmt.Fprintf(&commands, "# Строка %d: %s\n", entry.Line, entry.Variables["tenant"][0])
+		commands.WriteString(rowCommands)
+		commands.WriteString("\n\n")
+	}
+
+	return "~~~~~~~Таблица пользователей и бакетов для отправки в БД~~~~~~~\n" + table.String() +
+		"~~~~~~~Список терминальных команд для создания пользователей и бакетов~~~~~~~\n" + commands.String(), nil
+}
+
+// BatchNeedsApproval reports whether any tenant of a batch is created in an
+// environment that requires an approval
+func BatchNeedsApproval(entries []BatchEntry) bool {
+	for _, entry := range entries {
+		if approvals.Required(approvals.OpBatchCreate, entry.Variables["env"][0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestBatchApproval saves a whole batch for review, it is pushed as one
+// once approved
+func RequestBatchApproval(actor string, entries []BatchEntry, script string) (*postgresql_operations.Approval, error) {
+	payload, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch for approval: %v", err)
+	}
+
+	var tenants, envs, srtNums []string
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		tenants = append(tenants, entry.Variables["tenant"][0])
+		if env := entry.Variables["env"][0]; !seen["env:"+env] {
+			seen["env:"+env] = true
+			envs = append(envs, env)
+		}
+		if srtNum := entry.Variables["request_id_srt"][0]; !seen["srt:"+srtNum] {
+			seen["srt:"+srtNum] = true
+			srtNums = append(srtNums, srtNum)
+		}
+	}
+
+	return postgresql_operations.CreateApproval(postgresql_operations.Approval{
+		Operation:   approvals.OpBatchCreate,
+		SrtNum:      strings.Join(srtNums, ", "),
+		Tenant:      strings.Join(tenants, ", "),
+		Env:         strings.Join(envs, ", "),
+		Payload:     payload,
+		Plan:        script,
+		RequestedBy: actor,
+	})
+}
+
+// PushBatch pushes the batch entries to the DB in one transaction, then sends
+// the emails and SRT updates of each pushed request. It returns the report of
+// every entry.
+func PushBatch(ctx context.Context, actor string, entries []BatchEntry, note string) ([]BatchRow, error) {
+	report := make([]BatchRow, len(entries))
+	batch := make([]postgresql_operations.BatchEntry, len(entries))
+	failed := false
+	for i, entry := range entries {
+		report[i] = BatchRow{
+			Line:    entry.Line,
+			SdNum:   entry.Variables["request_id_sd"][0],
+			SrtNum:  entry.Variables["request_id_srt"][0],
+			Tenant:  entry.Variables["tenant"][0],
+			Env:     entry.Variables["env"][0],
+			Cluster: entry.Cluster["Кластер"],
+			Status:  BatchNotPushed,
+		}
+		// The tenants may have been created since the batch was checked
+		if err := CheckTenantExists(entry.Variables, entry.Cluster); err != nil {
+			report[i].Status = BatchInvalid
+			report[i].Errors = []string{err.Error()}
+			failed = true
+		}
+		batch[i] = postgresql_operations.BatchEntry{
+			Variables: entry.Variables,
+			Clusters:  entry.Cluster,
+			Outbox:    SRTWriteBack(entry.Variables, entry.Cluster),
+		}
+	}
+	if failed {
+		return report, fmt.Errorf("batch not saved: some tenants already exist")
+	}
+
+	results, err := postgresql_operations.PushBatchToDB(batch)
+	if results == nil {
+		return report, fmt.Errorf("failed to push batch to database: %v", err)
+	}
+	for i, result := range results {
+		if result.Error != "" {
+			report[i].Status = BatchInvalid
+			report[i].Errors = []string{result.Error}
+		}
+	}
+	if err != nil {
+		return report, err
+	}
+
+	for i, entry := range entries {
+		report[i].Status = BatchPushed
+		output, err := CompletePush(ctx, entry.Variables, entry.Cluster, results[i].Result)
+		if err != nil {
+			report[i].Errors = []string{err.Error()}
+			output = results[i].Result
+		}
+		report[i].Result = output
+		TrackRequest(ctx, actor, entry.Variables, nil, request_lifecycle.PushedToDB, note)
+	}
+	return report, nil
+}
@@ -0,0 +1,95 @@
+package request_processing
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/NarrativeBias/zayavki/batch_import"
+)
+
+// fakeBatchRows makes PrepareBatch process rows whose "tenant" value is the
+// tenant generated for them and whose "problem" value is a problem of the row
+func fakeBatchRows(t *testing.T) {
+	t.Helper()
+	saved := prepareRow
+	t.Cleanup(func() { prepareRow = saved })
+	prepareRow = func(row batch_import.Row) (map[string][]string, map[string]string, []string) {
+		vars := map[string][]string{"tenant": row.Values["tenant"], "env": {"IFT"}}
+		if problem := row.Values["problem"]; len(problem) > 0 {
+			return vars, nil, problem
+		}
+		return vars, map[string]string{"Кластер": "IFT-K12"}, nil
+	}
+}
+
+func batchRows(rows ...[]string) []batch_import.Row {
+	var batch []batch_import.Row
+	for i, row := range rows {
+		values := map[string][]string{"tenant": {row[0]}}
+		if len(row) > 1 {
+			values["problem"] = row[1:]
+		}
+		batch = append(batch, batch_import.Row{Line: i + 2, Values: values})
+	}
+	return batch
+}
+
+func TestPrepareBatchDuplicateTenants(t *testing.T) {
+	fakeBatchRows(t)
+
+	tests := []struct {
+		name       string
+		rows       []batch_import.Row
+		wantErrors map[int][]string
+	}{
+		{
+			name: "distinct tenants",
+			rows: batchRows([]string{"ift-cosd-1"}, []string{"ift-cosd-2"}),
+		},
+		{
+			name: "duplicates point to the first row",
+			rows: batchRows([]string{"ift-cosd-1"}, []string{"ift-cosd-2"}, []string{"ift-cosd-1"}, []string{"ift-cosd-1"}),
+			wantErrors: map[int][]string{
+				4: {"Тенант ift-cosd-1 уже создается в строке 2"},
+				5: {"Тенант ift-cosd-1 уже создается в строке 2"},
+			},
+		},
+		{
+			name: "invalid rows do not claim the tenant",
+			rows: batchRows([]string{"ift-cosd-1", "Неверный email: x"}, []string{"ift-cosd-1"}),
+			wantErrors: map[int][]string{
+				2: {"Неверный email: x"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, entries := PrepareBatch(context.Background(), "operator", tt.rows)
+
+			errors := map[int][]string{}
+			for _, row := range report {
+				if row.Status == BatchInvalid {
+					errors[row.Line] = row.Errors
+				} else if row.Status != BatchValid {
+					t.Errorf("line %d: status %s", row.Line, row.Status)
+				}
+				if row.Cluster == "" && row.Status == BatchValid {
+					t.Errorf("line %d: valid row without a cluster", row.Line)
+				}
+			}
+			if len(errors) == 0 && len(tt.wantErrors) == 0 {
+				if len(entries) != len(tt.rows) {
+					t.Errorf("got %d entries, want %d", len(entries), len(tt.rows))
+				}
+				return
+			}
+			if !reflect.DeepEqual(errors, tt.wantErrors) {
+				t.Errorf("errors = %q, want %q", errors, tt.wantErrors)
+			}
+			if entries != nil {
+				t.Errorf("entries returned for an invalid batch: %+v", entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package request_processing
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/NarrativeBias/zayavki/approvals"
+	"github.com/NarrativeBias/zayavki/cluster_endpoint_parser"
+	"github.com/NarrativeBias/zayavki/email_template"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/quota"
+)
+
+// ErrTenantNotFound is returned for changes of tenants missing in the DB
+var ErrTenantNotFound = errors.New("Tenant not found")
+
+// ErrInvalidQuota is returned for quotas outside the limits of the tenant
+// environment
+var ErrInvalidQuota = errors.New("invalid quota")
+
+// DeactivationRequest lists the users and buckets of a tenant to deactivate
+type DeactivationRequest struct {
+	Tenant       string   `json:"tenant"`
+	Users        []string `json:"users"`
+	Buckets      []string `json:"buckets"`
+	RequestIdSrt string   `json:"request_id_srt,omitempty"`
+	EmailLang    string   `json:"email_lang,omitempty"`
+}
+
+// Deactivate deactivates the resources of a request, or saves the request for
+// review by another user when deactivations need an approval
+func Deactivate(actor string, request DeactivationRequest) (map[string]interface{}, *postgresql_operations.Approval, error) {
+	if approvals.Required(approvals.OpDeactivate, "") {
+		plan := fmt.Sprintf("Тенант: %s\nДеактивировать пользователей: %s\nДеактивировать бакеты: %s\n",
+			request.Tenant, listOrDash(request.Users), listOrDash(request.Buckets))
+		approval, err := RequestApproval(actor, approvals.OpDeactivate, request.Tenant, request.RequestIdSrt, request, plan)
+		return nil, approval, err
+	}
+
+	result, err := DeactivateResources(request)
+	return result, nil, err
+}
+
+// DeactivateResources deactivates the resources in the database and adds the
+// customer notification to the result
+func DeactivateResources(request DeactivationRequest) (map[string]interface{}, error) {
+	result, err := postgresql_operations.DeactivateResources(request.Tenant, request.Users, request.Buckets)
+	if err != nil {
+		return nil, fmt.Errorf("Error deactivating resources: %v", err)
+	}
+
+	users, _ := result["deactivated_users"].([]string)
+	buckets, _ := result["deactivated_buckets"].([]string)
+	if len(users) > 0 || len(buckets) > 0 {
+		email, err := deletionEmail(request, users, buckets)
+		if err != nil {
+			return nil, err
+		}
+		result["email"] = email
+	}
+	return result, nil
+}
+
+// deletionEmail returns the notification listing the deactivated users and
+// buckets of a request
+func deletionEmail(request DeactivationRequest, users, buckets []string) (email_template.Email, error) {
+	email, err := email_template.Render(email_template.OpDeletion, request.EmailLang, map[string][]string{
+		"tenant":         {request.Tenant},
+		"request_id_srt": {strings.ToUpper(request.RequestIdSrt)},
+		"users":          users,
+		"bucketnames":    buckets,
+	}, map[string]string{})
+	if err != nil {
+		return email_template.Email{}, fmt.Errorf("Failed to generate email template: %v", err)
+	}
+	return email, nil
+}
+
+func listOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
+
+// QuotaUpdateRequest changes the quotas of a tenant. Action enable or disable
+// toggles the quotas and ignores the sizes.
+type QuotaUpdateRequest struct {
+	Tenant  string                              `json:"tenant"`
+	Buckets []postgresql_operations.QuotaUpdate `json:"buckets"`
+	Users   []postgresql_operations.QuotaUpdate `json:"users"`
+	Action  string                              `json:"action"`
+
+	RequestIdSrt string `json:"request_id_srt,omitempty"`
+	EmailLang    string `json:"email_lang,omitempty"`
+}
+
+// ChangeQuotas applies a quota update request and returns the result with
+// the customer notification. Decreases that need an approval are saved for
+// review by another user instead.
+func ChangeQuotas(actor string, request QuotaUpdateRequest) (*QuotaUpdateResponse, *postgresql_operations.Approval, error) {
+	if request.Action == "enable" || request.Action == "disable" {
+		if approvals.Required(approvals.OpQuotaToggle, "") {
+			action := "Включить"
+			if request.Action == "disable" {
+				action = "Выключить"
+			}
+			users, buckets := quotaNames(request)
+			plan := fmt.Sprintf("Тенант: %s\n%s квоты пользователей: %s\n%s квоты бакетов: %s\n",
+				request.Tenant, action, listOrDash(users), action, listOrDash(buckets))
+			approval, err := RequestApproval(actor, approvals.OpQuotaToggle, request.Tenant, request.RequestIdSrt, request, plan)
+			return nil, approval, err
+		}
+		response, err := ToggleQuotas(request)
+		return response, nil, err
+	}
+
+	// Check new quotas against the limits of the tenant environment
+	tenantRows, err := postgresql_operations.CheckDBForExistingEntries("", "", "", "", request.Tenant, "", request.Tenant, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tenantRows) == 0 {
+		return nil, nil, ErrTenantNotFound
+	}
+	var lines []string
+	for _, update := range append(request.Buckets, request.Users...) {
+		lines = append(lines, update.Name+" | "+update.Size)
+	}
+	if err := CheckQuotaLines(tenantRows[0].Env, lines); err != nil {
+		return nil, nil, err
+	}
+
+	if decreases := QuotaDecreases(tenantRows, request); len(decreases) > 0 &&
+		approvals.Required(approvals.OpQuotaDecrease, tenantRows[0].Env) {
+		plan := fmt.Sprintf("Тенант: %s\nУменьшение квот:\n%s\n", request.Tenant, strings.Join(decreases, "\n"))
+		approval, err := RequestApproval(actor, approvals.OpQuotaDecrease, request.Tenant, request.RequestIdSrt, request, plan)
+		return nil, approval, err
+	}
+
+	result, err := UpdateQuotas(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := QuotaResponse(request.Tenant, request.RequestIdSrt, "", request.EmailLang, result)
+	return response, nil, err
+}
+
+// ToggleQuotas enables or disables the quotas of a request, as given by its
+// action
+func ToggleQuotas(request QuotaUpdateRequest) (*QuotaUpdateResponse, error) {
+	users, buckets := quotaNames(request)
+	result, err := postgresql_operations.SetQuotaEnabled(request.Tenant, users, buckets, request.Action == "enable")
+	if err != nil {
+		return nil, err
+	}
+	return QuotaResponse(request.Tenant, request.RequestIdSrt, request.Action, request.EmailLang, result)
+}
+
+func quotaNames(request QuotaUpdateRequest) (users, buckets []string) {
+	for _, user := range request.Users {
+		users = append(users, user.Name)
+	}
+	for _, bucket := range request.Buckets {
+		buckets = append(buckets, bucket.Name)
+	}
+	return users, buckets
+}
+
+// UpdateQuotas updates the bucket and user quotas in the database
+func UpdateQuotas(request QuotaUpdateRequest) (*postgresql_operations.QuotaUpdateResult, error) {
+	result, err := postgresql_operations.UpdateBucketQuotas(request.Tenant, request.Buckets)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(request.Users) > 0 {
+		userResult, err := postgresql_operations.UpdateUserQuotas(request.Tenant, request.Users)
+		if err != nil {
+			return nil, err
+		}
+		result.UpdatedUsers = userResult.UpdatedUsers
+		result.Errors = append(result.Errors, userResult.Errors...)
+	}
+	return result, nil
+}
+
+// QuotaDecreases lists the buckets and users whose quota or object limit
+// gets smaller as "name: old -> new". A current value that is not set
+// (unlimited) or cannot be read counts as larger than any new one, so
+// limiting it needs the same approval as a decrease.
+func QuotaDecreases(tenantRows []postgresql_operations.CheckResult, request QuotaUpdateRequest) []string {
+	current := func(match func(row postgresql_operations.CheckResult) bool) (postgresql_operations.CheckResult, bool) {
+		for _, row := range tenantRows {
+			if row.Active && match(row) {
+				return row, true
+			}
+		}
+		return postgresql_operations.CheckResult{}, false
+	}
+
+	var decreases []string
+	check := func(update postgresql_operations.QuotaUpdate, row postgresql_operations.CheckResult, found bool) {
+		// Updates of unknown buckets and users are rejected when applied
+		if !found {
+			return
+		}
+		if update.Size != "" && update.Size != "-" {
+			if size, err := quota.Parse(update.Size); err == nil {
+				old, known := storedSize(row)
+				if !known {
+					decreases = append(decreases, fmt.Sprintf("%s: %s -> %s", update.Name, unlimited(row.Quota.String), size))
+				} else if size < old {
+					decreases = append(decreases, fmt.Sprintf("%s: %s -> %s", update.Name, old, size))
+				}
+			}
+		}
+		if update.MaxObjects != "" && update.MaxObjects != "-" {
+			if objects, err := strconv.ParseInt(update.MaxObjects, 10, 64); err == nil {
+				old, err := strconv.ParseInt(row.MaxObjects, 10, 64)
+				if err != nil {
+					decreases = append(decreases, fmt.Sprintf("%s: объекты %s -> %d", update.Name, unlimited(row.MaxObjects), objects))
+				} else if objects < old {
+					decreases = append(decreases, fmt.Sprintf("%s: объекты %d -> %d", update.Name, old, objects))
+				}
+			}
+		}
+	}
+	for _, bucket := range request.Buckets {
+		row, found := current(func(row postgresql_operations.CheckResult) bool {
+			return row.Bucket.Valid && row.Bucket.String == bucket.Name
+		})
+		check(bucket, row, found)
+	}
+	for _, user := range request.Users {
+		row, found := current(func(row postgresql_operations.CheckResult) bool {
+			return row.S3User.Valid && row.S3User.String == user.Name
+		})
+		check(user, row, found)
+	}
+	return decreases
+}
+
+// storedSize returns the quota of a registry row, from its exact byte value
+// when it has one
+func storedSize(row postgresql_operations.CheckResult) (quota.Size, bool) {
+	if row.QuotaBytes.Valid {
+		return quota.Size(row.QuotaBytes.Int64), true
+	}
+	size, err := quota.Parse(row.Quota.String)
+	return size, err == nil
+}
+
+// unlimited renders a current value that is not a limit
+func unlimited(value string) string {
+	if value == "" || value == "-" {
+		return "без ограничений"
+	}
+	return value
+}
+
+// CheckQuotaLines validates the sizes of "name | size" lines against the
+// limits of env. A size of "-" keeps the current quota.
+func CheckQuotaLines(env string, lines []string) error {
+	for _, line := range lines {
+		parts := strings.Split(line, "|")
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) == "-" || strings.TrimSpace(parts[1]) == "" {
+			continue
+		}
+		if _, err := quota.ParseForEnv(parts[1], env); err != nil {
+			return fmt.Errorf("%w for %s: %v", ErrInvalidQuota, strings.TrimSpace(parts[0]), err)
+		}
+	}
+	return nil
+}
+
+// QuotaUpdateResponse is a quota update result with the customer
+// notification
+type QuotaUpdateResponse struct {
+	*postgresql_operations.QuotaUpdateResult
+	Email *email_template.Email `json:"email,omitempty"`
+}
+
+// QuotaResponse adds the notification listing the old and new quotas to a
+// quota update result
+func QuotaResponse(tenant, requestIdSrt, action, lang string, result *postgresql_operations.QuotaUpdateResult) (*QuotaUpdateResponse, error) {
+	response := &QuotaUpdateResponse{QuotaUpdateResult: result}
+
+	if len(result.UpdatedBuckets) > 0 || len(result.UpdatedUsers) > 0 {
+		variables := map[string][]string{
+			"tenant":         {tenant},
+			"request_id_srt": {strings.ToUpper(requestIdSrt)},
+			"quota_state":    {action},
+		}
+		for _, bucket := range result.UpdatedBuckets {
+			variables["bucketnames"] = append(variables["bucketnames"], bucket.Name)
+		}
+		for _, user := range result.UpdatedUsers {
+			variables["quotausers"] = append(variables["quotausers"], user.Name)
+		}
+		// Enabling or disabling quotas keeps the values
+		if action == "" {
+			for _, bucket := range result.UpdatedBuckets {
+				variables["bucketoldquotas"] = append(variables["bucketoldquotas"], quotaValue(bucket.OldSize, bucket.OldMaxObjects))
+				variables["bucketquotas"] = append(variables["bucketquotas"], newQuotaValue(bucket))
+			}
+			for _, user := range result.UpdatedUsers {
+				variables["quotauseroldsizes"] = append(variables["quotauseroldsizes"], quotaValue(user.OldSize, user.OldMaxObjects))
+				variables["quotausersizes"] = append(variables["quotausersizes"], newQuotaValue(user))
+			}
+		}
+
+		email, err := email_template.Render(email_template.OpQuotaChange, lang, variables, map[string]string{})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to generate email template: %v", err)
+		}
+		response.Email = &email
+	}
+	return response, nil
+}
+
+// quotaValue formats a quota with its object limit for notifications
+func quotaValue(size, maxObjects string) string {
+	if size == "" {
+		size = "-"
+	}
+	if maxObjects != "" && maxObjects != "-" {
+		return fmt.Sprintf("%s, objects=%s", size, maxObjects)
+	}
+	return size
+}
+
+// newQuotaValue returns the quota after an update, where empty values keep
+// the old ones
+func newQuotaValue(update postgresql_operations.QuotaUpdate) string {
+	size, maxObjects := update.Size, update.MaxObjects
+	if size == "" {
+		size = update.OldSize
+	}
+	if maxObjects == "" {
+		maxObjects = update.OldMaxObjects
+	}
+	return quotaValue(size, maxObjects)
+}
+
+// InventoryClusterMap returns the cluster inventory entry for a tenant, so that
+// per-cluster settings such as the command template set are applied. Falls back
+// to the cluster and realm recorded in the database.
+func InventoryClusterMap(tenantInfo *postgresql_operations.CheckResult) map[string]string {
+	clusters, err := cluster_endpoint_parser.FindMatchingClusters("clusters.xlsx", tenantInfo.NetSeg, tenantInfo.Env)
+	if err == nil {
+		for _, cluster := range clusters {
+			if cluster.Кластер == tenantInfo.ClsName && cluster.Реалм == tenantInfo.Realm {
+				return cluster.ConvertToMap()
+			}
+		}
+	}
+	return map[string]string{
+		"Кластер": tenantInfo.ClsName,
+		"Реалм":   tenantInfo.Realm,
+	}
+}
@@ -0,0 +1,93 @@
+package request_processing
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+)
+
+func TestQuotaDecreases(t *testing.T) {
+	bucket := func(name, size string, bytes int64, objects string) postgresql_operations.CheckResult {
+		row := postgresql_operations.CheckResult{
+			Active:     true,
+			Bucket:     sql.NullString{String: name, Valid: true},
+			Quota:      sql.NullString{String: size, Valid: size != ""},
+			MaxObjects: objects,
+		}
+		if bytes > 0 {
+			row.QuotaBytes = sql.NullInt64{Int64: bytes, Valid: true}
+		}
+		return row
+	}
+	rows := []postgresql_operations.CheckResult{
+		bucket("exact", "215", 200<<30, "1000"),
+		bucket("legacy", "500", 0, "-"),
+		bucket("unlimited", "-", 0, "-"),
+		bucket("junk", "lots", 0, "many"),
+		{Active: true, S3User: sql.NullString{String: "svc", Valid: true}, MaxObjects: "-"},
+		{Active: false, Bucket: sql.NullString{String: "gone", Valid: true}, Quota: sql.NullString{String: "1", Valid: true}},
+	}
+
+	tests := []struct {
+		name    string
+		buckets []postgresql_operations.QuotaUpdate
+		users   []postgresql_operations.QuotaUpdate
+		want    []string
+	}{
+		{
+			name:    "exact bytes are compared, not the rounded column",
+			buckets: []postgresql_operations.QuotaUpdate{{Name: "exact", Size: "214G"}},
+			want:    []string{"exact: 200GiB -> 214G"},
+		},
+		{
+			name:    "increases and unchanged sizes need no approval",
+			buckets: []postgresql_operations.QuotaUpdate{{Name: "exact", Size: "1T"}, {Name: "legacy", Size: "500G"}, {Name: "legacy", Size: "-"}},
+		},
+		{
+			name:    "legacy gigabytes",
+			buckets: []postgresql_operations.QuotaUpdate{{Name: "legacy", Size: "400"}},
+			want:    []string{"legacy: 500G -> 400G"},
+		},
+		{
+			name:    "limiting an unlimited quota",
+			buckets: []postgresql_operations.QuotaUpdate{{Name: "unlimited", Size: "10T"}},
+			want:    []string{"unlimited: без ограничений -> 10T"},
+		},
+		{
+			name:    "unreadable current values",
+			buckets: []postgresql_operations.QuotaUpdate{{Name: "junk", Size: "1T", MaxObjects: "5"}},
+			want:    []string{"junk: lots -> 1T", "junk: объекты many -> 5"},
+		},
+		{
+			name:    "object limits",
+			buckets: []postgresql_operations.QuotaUpdate{{Name: "exact", MaxObjects: "999"}, {Name: "legacy", MaxObjects: "10"}},
+			want:    []string{"exact: объекты 1000 -> 999", "legacy: объекты без ограничений -> 10"},
+		},
+		{
+			name:    "raising an object limit",
+			buckets: []postgresql_operations.QuotaUpdate{{Name: "exact", MaxObjects: "2000"}},
+		},
+		{
+			name:  "user quota",
+			users: []postgresql_operations.QuotaUpdate{{Name: "svc", Size: "1T"}},
+			want:  []string{"svc: без ограничений -> 1T"},
+		},
+		{
+			name:    "unknown and inactive buckets are left to the update",
+			buckets: []postgresql_operations.QuotaUpdate{{Name: "missing", Size: "1G"}, {Name: "gone", Size: "1G"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := QuotaDecreases(rows, QuotaUpdateRequest{Tenant: "t", Buckets: tt.buckets, Users: tt.users})
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QuotaDecreases() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package request_processing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/NarrativeBias/zayavki/approvals"
+	"github.com/NarrativeBias/zayavki/cluster_endpoint_parser"
+	"github.com/NarrativeBias/zayavki/email_template"
+	"github.com/NarrativeBias/zayavki/mailer"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/prep_db_table_data"
+	"github.com/NarrativeBias/zayavki/request_lifecycle"
+	"github.com/NarrativeBias/zayavki/rgw_commands"
+	"github.com/NarrativeBias/zayavki/srt_connector"
+	"github.com/NarrativeBias/zayavki/tenant_name_generation"
+)
+
+// RunCreationRequest generates the commands of a creation request or pushes
+// it to the DB. Pushes that need an approval are saved for review with the
+// generated commands as the plan instead. It returns the result with the
+// request state and a note for tracking.
+func RunCreationRequest(ctx context.Context, actor string, variables map[string][]string, cluster cluster_endpoint_parser.ClusterInfo, pushToDb bool) (string, string, string, error) {
+	if !pushToDb || !approvals.Required(approvals.OpCreate, variables["env"][0]) {
+		result, err := ProcessWithCluster(ctx, variables, cluster, pushToDb)
+		if pushToDb {
+			return result, request_lifecycle.PushedToDB, "", err
+		}
+		return result, request_lifecycle.CommandsGenerated, "", err
+	}
+
+	plan, err := ProcessWithCluster(ctx, variables, cluster, false)
+	if err != nil {
+		return "", "", "", err
+	}
+	payload, err := json.Marshal(CreateApprovalPayload{Variables: variables, Cluster: cluster.ConvertToMap()})
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to encode request for approval: %v", err)
+	}
+
+	getFirst := func(key string) string {
+		if len(variables[key]) > 0 {
+			return variables[key][0]
+		}
+		return ""
+	}
+	approval, err := postgresql_operations.CreateApproval(postgresql_operations.Approval{
+		Operation:   approvals.OpCreate,
+		SdNum:       getFirst("request_id_sd"),
+		SrtNum:      getFirst("request_id_srt"),
+		Tenant:      getFirst("tenant"),
+		Env:         getFirst("env"),
+		Payload:     payload,
+		Plan:        plan,
+		RequestedBy: actor,
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+
+	note := fmt.Sprintf("Ожидает согласования #%d", approval.ID)
+	result := plan + fmt.Sprintf("~~~~~~~Согласование~~~~~~~\nСоздание ресурсов в %s требует согласования. Заявка на согласование #%d сохранена, данные будут внесены в БД после одобрения другим пользователем.\n",
+		approval.Env, approval.ID)
+	return result, request_lifecycle.CommandsGenerated, note, nil
+}
+
+// CreateApprovalPayload is the creation request saved for approval, with the
+// variables as processed for the push
+type CreateApprovalPayload struct {
+	Variables map[string][]string `json:"variables"`
+	Cluster   map[string]string   `json:"cluster"`
+}
+
+// CheckTenantExists returns an error when the tenant of a creation request
+// is already in the DB
+func CheckTenantExists(processedVars map[string][]string, clusterMap map[string]string) error {
+	if createTenant, ok := processedVars["create_tenant"]; !ok || len(createTenant) == 0 || createTenant[0] != "true" {
+		return nil // Not creating a tenant, skip check
+	}
+
+	// Determine tenant name (override or generated)
+	var tenantName string
+	if len(processedVars["tenant_override"]) > 0 && processedVars["tenant_override"][0] != "" {
+		tenantName = processedVars["tenant_override"][0]
+	} else {
+		var err error
+		tenantName, err = tenant_name_generation.GenerateTenantName(processedVars, clusterMap)
+		if err != nil {
+			return fmt.Errorf("error generating tenant name: %v", err)
+		}
+	}
+
+	// Check if tenant already exists
+	results, err := postgresql_operations.CheckDBForExistingEntries(
+		processedVars["segment"][0],
+		processedVars["env"][0],
+		"", // ris_number
+		"", // ris_name
+		tenantName,
+		"",         // bucket
+		tenantName, // user
+		"",         // cluster
+	)
+	if err != nil {
+		return fmt.Errorf("error checking database: %v", err)
+	}
+
+	if len(results) > 0 {
+		return fmt.Errorf("tenant '%s' already exists in the database", tenantName)
+	}
+
+	return nil
+}
+
+// ProcessWithCluster prepares a creation request for the cluster and returns
+// its commands, or pushes it to the DB when pushToDb is set
+func ProcessWithCluster(ctx context.Context, variables map[string][]string, cluster cluster_endpoint_parser.ClusterInfo, pushToDb bool) (string, error) {
+	clusterMap, err := PrepareCreation(variables, cluster)
+	if err != nil {
+		return "", err
+	}
+
+	if pushToDb {
+		return PushToDatabase(ctx, variables, clusterMap)
+	}
+
+	return GenerateFullResult(variables, clusterMap)
+}
+
+// PrepareCreation sets the tenant name and users of a creation request and
+// checks that the tenant does not exist yet. It returns the cluster as a map.
+func PrepareCreation(variables map[string][]string, cluster cluster_endpoint_parser.ClusterInfo) (map[string]string, error) {
+	// Convert ClusterInfo to map for easier handling
+	clusterMap := cluster.ConvertToMap()
+
+	// Skip tenant name generation if we have tenant_override or existing tenant
+	if _, hasExistingTenant := variables["tenant"]; !hasExistingTenant {
+		if override, hasOverride := variables["tenant_override"]; hasOverride && len(override) > 0 && override[0] != "" {
+			variables["tenant"] = []string{override[0]}
+		} else {
+			// Generate tenant name only for new tenant creation without override
+			tenant, err := tenant_name_generation.GenerateTenantName(variables, clusterMap)
+			if err != nil {
+				return nil, fmt.Errorf("error generating tenant name: %v", err)
+			}
+			variables["tenant"] = []string{tenant}
+		}
+	}
+
+	// Validate the data
+	// Client-side validation is now handled in JavaScript
+
+	// Check for existing tenant first
+	if err := CheckTenantExists(variables, clusterMap); err != nil {
+		return nil, err
+	}
+
+	// Set up tenant and users
+	if err := setupTenantAndUsers(variables, clusterMap); err != nil {
+		return nil, err
+	}
+
+	return clusterMap, nil
+}
+
+func setupTenantAndUsers(processedVars map[string][]string, _ map[string]string) error {
+	// Check if creating tenant is needed and add to users list if so
+	if createTenant, ok := processedVars["create_tenant"]; ok && len(createTenant) > 0 && createTenant[0] == "true" {
+		if len(processedVars["users"]) == 0 {
+			processedVars["users"] = []string{processedVars["tenant"][0]}
+		} else {
+			processedVars["users"] = append([]string{processedVars["tenant"][0]}, processedVars["users"]...)
+		}
+	}
+	return nil
+}
+
+// PushToDatabase saves a prepared creation request in the DB
+func PushToDatabase(ctx context.Context, processedVars map[string][]string, clusterMap map[string]string) (string, error) {
+	// Get database push result
+	dbResult, err := postgresql_operations.PushToDB(processedVars, clusterMap, SRTWriteBack(processedVars, clusterMap))
+	if err != nil {
+		return "", fmt.Errorf("failed to push to database: %v", err)
+	}
+
+	return CompletePush(ctx, processedVars, clusterMap, dbResult)
+}
+
+// CompletePush adds the closing email template to the result of a pushed
+// request, sends the email and reports the SRT write-back queued with the push
+func CompletePush(ctx context.Context, processedVars map[string][]string, clusterMap map[string]string, dbResult string) (string, error) {
+	var result strings.Builder
+
+	// Add database result to output
+	result.WriteString("~~~~~~~Результат отправки данных в БД~~~~~~~\n")
+	result.WriteString(dbResult)
+	result.WriteString("\n\n")
+
+	// Generate and add email template
+	emailTemplate, err := email_template.PopulateEmailTemplate(processedVars, clusterMap)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate email template: %v", err)
+	}
+	result.WriteString("~~~~~~~Шаблон для закрытия задания и письма с данными УЗ~~~~~~~\n")
+	result.WriteString(emailTemplate)
+
+	if len(processedVars["send_email"]) > 0 && processedVars["send_email"][0] == "true" {
+		result.WriteString("\n~~~~~~~Отправка письма~~~~~~~\n")
+		result.WriteString(sendCredentialsEmail(ctx, processedVars, clusterMap))
+	}
+
+	if srt_connector.WriteBackEnabled() {
+		result.WriteString("\n~~~~~~~Обновление SRT~~~~~~~\n")
+		result.WriteString(srtWriteBackStatus(processedVars))
+	}
+
+	return result.String(), nil
+}
+
+// sendCredentialsEmail sends the populated email to the credentials recipient
+// with the owners in copy and records the delivery status. Failures are
+// reported in the result, the request itself is already saved.
+func sendCredentialsEmail(ctx context.Context, processedVars map[string][]string, clusterMap map[string]string) string {
+	if !mailer.Enabled() {
+		return "Отправка писем выключена в mailer.json\n"
+	}
+
+	email, err := email_template.Render(email_template.Operation(processedVars),
+		processedVars["email_lang"][0], processedVars, clusterMap)
+	if err != nil {
+		return fmt.Sprintf("Ошибка подготовки письма: %v\n", err)
+	}
+
+	getFirst := func(key string) string {
+		if len(processedVars[key]) > 0 {
+			return processedVars[key][0]
+		}
+		return ""
+	}
+	msg := mailer.Message{
+		To:      []string{getFirst("email")},
+		Cc:      []string{getFirst("owner"), getFirst("zam_owner")},
+		Subject: email.Subject,
+		Body:    email.Text,
+		HTML:    email.HTML,
+	}
+	delivery := postgresql_operations.EmailDelivery{
+		SdNum:      getFirst("request_id_sd"),
+		SrtNum:     getFirst("request_id_srt"),
+		Tenant:     getFirst("tenant"),
+		Recipients: msg.Recipients(),
+		Subject:    msg.Subject,
+		Template:   email.Version,
+		Status:     postgresql_operations.DeliverySent,
+	}
+
+	var status string
+	if err := mailer.Send(msg); err != nil {
+		slog.ErrorContext(ctx, "failed to send email", "sd_num", delivery.SdNum, "srt_num", delivery.SrtNum, "tenant", delivery.Tenant, "error", err)
+		delivery.Status = postgresql_operations.DeliveryFailed
+		delivery.Error = err.Error()
+		status = fmt.Sprintf("Ошибка отправки письма: %v\n", err)
+	} else {
+		status = fmt.Sprintf("Письмо отправлено: %s\n", strings.Join(delivery.Recipients, ", "))
+	}
+
+	if err := postgresql_operations.RecordEmailDelivery(delivery); err != nil {
+		slog.ErrorContext(ctx, "failed to record email delivery", "sd_num", delivery.SdNum, "srt_num", delivery.SrtNum, "tenant", delivery.Tenant, "error", err)
+		status += fmt.Sprintf("Статус отправки не сохранен в БД: %v\n", err)
+	}
+	return status
+}
+
+// GenerateFullResult returns the DB table rows and terminal commands of a
+// prepared creation request
+func GenerateFullResult(processedVars map[string][]string, clusterMap map[string]string) (string, error) {
+	var result strings.Builder
+
+	// Client-side validation is now handled in JavaScript
+
+	// Generate other parts of the result
+	result.WriteString("~~~~~~~Таблица пользователей и бакетов для отправки в БД~~~~~~~\n")
+	result.WriteString(prep_db_table_data.PopulateUsers(processedVars, clusterMap))
+	result.WriteString("\n")
+	result.WriteString(prep_db_table_data.PopulateBuckets(processedVars, clusterMap))
+	result.WriteString("\n\n")
+
+	commands, err := CreationCommands(processedVars, clusterMap)
+	if err != nil {
+		return "", err
+	}
+	result.WriteString("~~~~~~~Список терминальных команд для создания пользователей и бакетов~~~~~~~\n")
+	result.WriteString(commands)
+	result.WriteString("\n\n")
+
+	return result.String(), nil
+}
+
+// CreationCommands returns the terminal commands creating the users and
+// buckets of a request
+func CreationCommands(processedVars map[string][]string, clusterMap map[string]string) (string, error) {
+	var result strings.Builder
+
+	bucketCommands, err := rgw_commands.BucketCreation(processedVars, clusterMap)
+	if err != nil {
+		return "", err
+	}
+	userCommands, err := rgw_commands.UserCreation(processedVars, clusterMap)
+	if err != nil {
+		return "", err
+	}
+	quotaCommands, err := rgw_commands.QuotaCreation(processedVars, clusterMap)
+	if err != nil {
+		return "", err
+	}
+	featureCommands, err := rgw_commands.BucketFeatures(processedVars, clusterMap)
+	if err != nil {
+		return "", err
+	}
+	checkCommands, err := rgw_commands.ResultCheck(processedVars, clusterMap)
+	if err != nil {
+		return "", err
+	}
+
+	result.WriteString(bucketCommands)
+	result.WriteString("\n")
+	result.WriteString(userCommands)
+	result.WriteString("\n")
+	if quotaCommands != "" {
+		result.WriteString(quotaCommands)
+	}
+	if featureCommands != "" {
+		result.WriteString(featureCommands)
+	}
+	result.WriteString(checkCommands)
+
+	return result.String(), nil
+}
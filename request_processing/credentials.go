@@ -0,0 +1,109 @@
+package request_processing
+
+import (
+	"fmt"
+
+	"github.com/NarrativeBias/zayavki/email_template"
+	"github.com/NarrativeBias/zayavki/pgp_delivery"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+)
+
+// CredentialsRequest encrypts the credentials from the key output of a
+// tenant for the recipient
+type CredentialsRequest struct {
+	Tenant       string `json:"tenant"`
+	Email        string `json:"email"`
+	RequestIdSd  string `json:"request_id_sd,omitempty"`
+	RequestIdSrt string `json:"request_id_srt,omitempty"`
+	KeyOutput    string `json:"key_output"`
+}
+
+// EncryptCredentials encrypts the credentials of a request with the PGP key
+// of the recipient and returns them with the closing email
+func EncryptCredentials(request CredentialsRequest) (map[string]interface{}, error) {
+	if request.Tenant == "" || request.Email == "" {
+		return nil, invalidRequest("Tenant and recipient email are required")
+	}
+
+	creds, err := pgp_delivery.ParseCredentials(request.KeyOutput)
+	if err != nil {
+		return nil, invalidRequest("Error parsing credentials: %v", err)
+	}
+	if len(creds) == 0 {
+		return nil, invalidRequest("No credentials found in the command output")
+	}
+
+	results, err := postgresql_operations.CheckDBForExistingEntries(
+		"", "", "", "", request.Tenant, "", "", "",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking database: %v", err)
+	}
+	var tenantInfo *postgresql_operations.CheckResult
+	activeUsers := map[string]bool{}
+	var buckets, quotas []string
+	for i, result := range results {
+		if result.S3User.Valid && result.S3User.String == request.Tenant && tenantInfo == nil {
+			tenantInfo = &results[i]
+		}
+		if !result.Active {
+			continue
+		}
+		if result.S3User.Valid && result.S3User.String != "" {
+			activeUsers[result.S3User.String] = true
+		}
+		if result.Bucket.Valid && result.Bucket.String != "" {
+			buckets = append(buckets, result.Bucket.String)
+			quota := "-"
+			if result.Quota.Valid {
+				quota = result.Quota.String
+			}
+			quotas = append(quotas, quota)
+		}
+	}
+	if tenantInfo == nil {
+		return nil, ErrTenantNotFound
+	}
+	for _, cred := range creds {
+		if !activeUsers[cred.User] {
+			return nil, invalidRequest("User '%s' not found in tenant", cred.User)
+		}
+	}
+
+	recipient, err := pgp_delivery.FindRecipient(request.Email)
+	if err != nil {
+		return nil, invalidRequest("%v", err)
+	}
+
+	clusterMap := InventoryClusterMap(tenantInfo)
+	encrypted, err := pgp_delivery.EncryptCredentials(recipient, request.Tenant,
+		[]string{clusterMap["tls_endpoint"], clusterMap["mtls_endpoint"]}, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := pgp_delivery.FileName(request.Tenant)
+	emailText, err := email_template.PopulateEmailTemplate(map[string][]string{
+		"email":          {request.Email},
+		"request_id_sd":  {request.RequestIdSd},
+		"request_id_srt": {request.RequestIdSrt},
+		"segment":        {tenantInfo.NetSeg},
+		"env":            {tenantInfo.Env},
+		"tenant":         {request.Tenant},
+		"users":          pgp_delivery.Users(creds),
+		"bucketnames":    buckets,
+		"bucketquotas":   quotas,
+		"encrypted_file": {fileName},
+	}, clusterMap)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate email template: %v", err)
+	}
+
+	return map[string]interface{}{
+		"email":     emailText,
+		"recipient": pgp_delivery.Identity(recipient),
+		"users":     pgp_delivery.Users(creds),
+		"file_name": fileName,
+		"encrypted": string(encrypted),
+	}, nil
+}
@@ -0,0 +1,31 @@
+package request_processing
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidRequest is matched by the errors caused by the request itself
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ErrUserNotFound is returned for changes of users missing in the tenant
+var ErrUserNotFound = errors.New("user not found")
+
+// requestError keeps the message shown to the user while matching one of
+// the errors above
+type requestError struct {
+	kind    error
+	message string
+}
+
+func (e *requestError) Error() string { return e.message }
+
+func (e *requestError) Unwrap() error { return e.kind }
+
+func invalidRequest(format string, args ...interface{}) error {
+	return &requestError{kind: ErrInvalidRequest, message: fmt.Sprintf(format, args...)}
+}
+
+func userNotFound(user string) error {
+	return &requestError{kind: ErrUserNotFound, message: fmt.Sprintf("User '%s' not found in tenant", user)}
+}
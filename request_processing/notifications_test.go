@@ -0,0 +1,106 @@
+package request_processing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NarrativeBias/zayavki/email_template"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+)
+
+func loadEmailTemplates(t *testing.T) {
+	t.Helper()
+	if err := email_template.LoadTemplates("../email_templates"); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+}
+
+func TestQuotaResponse(t *testing.T) {
+	loadEmailTemplates(t)
+	result := &postgresql_operations.QuotaUpdateResult{
+		UpdatedBuckets: []postgresql_operations.QuotaUpdate{
+			{Name: "ift-cosd-bucket1", Size: "1T", OldSize: "500G", OldMaxObjects: "1000"},
+			{Name: "ift-cosd-bucket2", MaxObjects: "10", OldSize: "-"},
+		},
+		UpdatedUsers: []postgresql_operations.QuotaUpdate{{Name: "ift_cosd_user1", Size: "2T", OldSize: "1T"}},
+	}
+
+	tests := []struct {
+		name, action, lang string
+		want               []string
+		unwanted           []string
+	}{
+		{
+			name: "sizes",
+			lang: "ru",
+			want: []string{
+				"Изменение квот в тенанте ift-cosd-tenant", "в рамках обращения SRT-0000001",
+				"- ift-cosd-bucket1: 500G, objects=1000 → 1T, objects=1000",
+				"- ift-cosd-bucket2: - → -, objects=10",
+				"- ift_cosd_user1: 1T → 2T",
+			},
+			unwanted: []string{"Квоты включены", "Квоты выключены"},
+		},
+		{
+			name:     "enable keeps the values out",
+			action:   "enable",
+			lang:     "en",
+			want:     []string{"Quota change in tenant ift-cosd-tenant", "- ift-cosd-bucket1\n", "- ift_cosd_user1\n"},
+			unwanted: []string{"→", "1T"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := QuotaResponse("ift-cosd-tenant", "srt-0000001", tt.action, tt.lang, result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Email == nil {
+				t.Fatal("no email")
+			}
+			email := response.Email.Subject + "\n" + response.Email.Text
+			for _, want := range tt.want {
+				if !strings.Contains(email, want) {
+					t.Errorf("email lacks %q:\n%s", want, email)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(email, unwanted) {
+					t.Errorf("email contains %q:\n%s", unwanted, email)
+				}
+			}
+		})
+	}
+
+	response, err := QuotaResponse("ift-cosd-tenant", "", "", "ru", &postgresql_operations.QuotaUpdateResult{})
+	if err != nil || response.Email != nil {
+		t.Errorf("update without changes: email %+v, error %v", response.Email, err)
+	}
+}
+
+func TestDeletionEmail(t *testing.T) {
+	loadEmailTemplates(t)
+	request := DeactivationRequest{Tenant: "ift-cosd-tenant", RequestIdSrt: "srt-0000001", EmailLang: "en"}
+
+	email, err := deletionEmail(request, []string{"ift_cosd_user1"}, []string{"ift-cosd-bucket1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"SRT-0000001", "Users deleted:\n- ift_cosd_user1", "Buckets deleted:\n- ift-cosd-bucket1"} {
+		if !strings.Contains(email.Text, want) {
+			t.Errorf("email lacks %q:\n%s", want, email.Text)
+		}
+	}
+	if !strings.Contains(email.HTML, "<li>ift-cosd-bucket1</li>") {
+		t.Errorf("HTML lacks the bucket:\n%s", email.HTML)
+	}
+
+	request.EmailLang = ""
+	email, err = deletionEmail(request, nil, []string{"ift-cosd-bucket1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(email.Subject, "Удаление ресурсов") || strings.Contains(email.Text, "Пользователи удалены") {
+		t.Errorf("buckets only email in the default language:\n%s\n%s", email.Subject, email.Text)
+	}
+}
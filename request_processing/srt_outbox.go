@@ -0,0 +1,142 @@
+package request_processing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/NarrativeBias/zayavki/email_template"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/srt_connector"
+)
+
+// srtOutboxWake triggers an outbox run without waiting for the next tick
+var srtOutboxWake = make(chan struct{}, 1)
+
+// Updates are claimed in batches small enough to be delivered within the
+// lease, after which another run may claim them again
+const (
+	srtOutboxBatch = 10
+	srtOutboxLease = 10 * time.Minute
+)
+
+// The outbox operations, replaced in the tests
+var (
+	claimOutbox      = postgresql_operations.ClaimOutboxEntries
+	markOutboxSent   = postgresql_operations.MarkOutboxSent
+	markOutboxFailed = postgresql_operations.MarkOutboxFailed
+)
+
+var errNoSRTNumber = errors.New("no SRT number")
+
+// SRTWriteBack returns the outbox function queuing the closing updates of a
+// creation request with its push, or nil when there is nothing to queue
+func SRTWriteBack(processedVars map[string][]string, clusterMap map[string]string) postgresql_operations.OutboxFunc {
+	if !srt_connector.WriteBackEnabled() {
+		return nil
+	}
+	srtNum, err := writeBackNumber(processedVars)
+	if err != nil {
+		return nil
+	}
+	return func(dbResult string) (string, []postgresql_operations.OutboxEntry, error) {
+		comment, err := email_template.PopulateEmailTemplate(processedVars, clusterMap)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to generate email template: %v", err)
+		}
+		var entries []postgresql_operations.OutboxEntry
+		for _, update := range srt_connector.ClosingUpdates(srtNum, comment, dbResult) {
+			payload, err := json.Marshal(update)
+			if err != nil {
+				return "", nil, err
+			}
+			entries = append(entries, postgresql_operations.OutboxEntry{Kind: update.Kind, Payload: string(payload)})
+		}
+		return srtNum, entries, nil
+	}
+}
+
+func writeBackNumber(processedVars map[string][]string) (string, error) {
+	if len(processedVars["request_id_srt"]) == 0 || processedVars["request_id_srt"][0] == "" {
+		return "", errNoSRTNumber
+	}
+	return srt_connector.NormalizeNumber(processedVars["request_id_srt"][0])
+}
+
+// srtWriteBackStatus reports the write-back of a pushed request and sends its
+// queued updates
+func srtWriteBackStatus(processedVars map[string][]string) string {
+	srtNum, err := writeBackNumber(processedVars)
+	if errors.Is(err, errNoSRTNumber) {
+		return "Номер SRT не указан, задание не обновлено\n"
+	}
+	if err != nil {
+		return fmt.Sprintf("Задание не обновлено: %v\n", err)
+	}
+	WakeSRTOutbox()
+	return fmt.Sprintf("Комментарий, результат БД и статус для %s поставлены в очередь отправки\n", srtNum)
+}
+
+// WakeSRTOutbox makes RunSRTOutbox send the queued updates now
+func WakeSRTOutbox() {
+	select {
+	case srtOutboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunSRTOutbox sends the queued ticket updates, on every wake-up and
+// periodically for retries
+func RunSRTOutbox(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		processSRTOutbox(ctx)
+		select {
+		case <-srtOutboxWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processSRTOutbox sends the due updates until none is left. Only the oldest
+// unsent update of a ticket is due, so each pass moves every ticket one
+// update further.
+func processSRTOutbox(ctx context.Context) {
+	for {
+		entries, err := claimOutbox(srtOutboxBatch, srtOutboxLease)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to read srt outbox", "error", err)
+			return
+		}
+
+		sent := 0
+		for _, entry := range entries {
+			var update srt_connector.Update
+			err := json.Unmarshal([]byte(entry.Payload), &update)
+			if err == nil {
+				err = srt_connector.Deliver(entry.SrtNum, update)
+			}
+			if err == nil {
+				sent++
+				if err := markOutboxSent(entry.ID); err != nil {
+					slog.ErrorContext(ctx, "failed to update srt outbox", "srt_num", entry.SrtNum, "outbox_id", entry.ID, "error", err)
+				}
+				continue
+			}
+
+			retryAfter, final := srt_connector.RetryDelay(entry.Attempts + 1)
+			slog.WarnContext(ctx, "failed to send srt update", "srt_num", entry.SrtNum, "kind", entry.Kind,
+				"attempt", entry.Attempts+1, "final", final, "error", err)
+			if err := markOutboxFailed(entry.ID, err.Error(), retryAfter, final); err != nil {
+				slog.ErrorContext(ctx, "failed to update srt outbox", "srt_num", entry.SrtNum, "outbox_id", entry.ID, "error", err)
+			}
+		}
+		if sent == 0 {
+			return
+		}
+	}
+}
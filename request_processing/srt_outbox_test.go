@@ -0,0 +1,225 @@
+package request_processing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/srt_connector"
+)
+
+// fakeOutbox keeps the outbox in memory with the claim rules of
+// postgresql_operations.ClaimOutboxEntries
+type fakeOutbox struct {
+	now     time.Time
+	entries []*fakeEntry
+}
+
+type fakeEntry struct {
+	postgresql_operations.OutboxEntry
+	nextAttempt time.Time
+}
+
+func (o *fakeOutbox) add(srtNum string, updates ...srt_connector.Update) {
+	for _, update := range updates {
+		payload, _ := json.Marshal(update)
+		o.entries = append(o.entries, &fakeEntry{
+			OutboxEntry: postgresql_operations.OutboxEntry{
+				ID: len(o.entries) + 1, SrtNum: srtNum, Kind: update.Kind,
+				Payload: string(payload), Status: postgresql_operations.OutboxPending,
+			},
+			nextAttempt: o.now,
+		})
+	}
+}
+
+func (o *fakeOutbox) claim(limit int, lease time.Duration) ([]postgresql_operations.OutboxEntry, error) {
+	blocked := map[string]bool{}
+	var claimed []postgresql_operations.OutboxEntry
+	for _, entry := range o.entries {
+		if entry.Status == postgresql_operations.OutboxSent {
+			continue
+		}
+		due := entry.Status == postgresql_operations.OutboxPending && !entry.nextAttempt.After(o.now)
+		if due && !blocked[entry.SrtNum] && len(claimed) < limit {
+			entry.nextAttempt = o.now.Add(lease)
+			claimed = append(claimed, entry.OutboxEntry)
+		}
+		blocked[entry.SrtNum] = true
+	}
+	return claimed, nil
+}
+
+func (o *fakeOutbox) markSent(id int) error {
+	o.entries[id-1].Status = postgresql_operations.OutboxSent
+	o.entries[id-1].Attempts++
+	return nil
+}
+
+func (o *fakeOutbox) markFailed(id int, deliveryError string, retryAfter time.Duration, final bool) error {
+	entry := o.entries[id-1]
+	entry.Attempts++
+	entry.Error = deliveryError
+	entry.nextAttempt = o.now.Add(retryAfter)
+	if final {
+		entry.Status = postgresql_operations.OutboxFailed
+	}
+	return nil
+}
+
+func (o *fakeOutbox) statuses(srtNum string) string {
+	var statuses []string
+	for _, entry := range o.entries {
+		if entry.SrtNum == srtNum {
+			statuses = append(statuses, entry.Status)
+		}
+	}
+	return strings.Join(statuses, ",")
+}
+
+// ticketAPI accepts ticket updates, failing the first updates of the tickets
+// in failures
+type ticketAPI struct {
+	mu       sync.Mutex
+	failures map[string]int
+	received []string
+}
+
+func (a *ticketAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	number, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/entities/"), "/")
+	if a.failures[number] > 0 {
+		a.failures[number]--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	a.received = append(a.received, number+" "+resource)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func setupOutbox(t *testing.T, maxAttempts int, failures map[string]int) (*fakeOutbox, *ticketAPI) {
+	t.Helper()
+	api := &ticketAPI{failures: failures}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "srt.json")
+	cfg := fmt.Sprintf(`{"enabled": true, "base_url": %q, "write_back": true, "closing_status": "resolved",
+		"max_attempts": %d, "retry_seconds": 60}`, server.URL+"/entities", maxAttempts)
+	if err := os.WriteFile(path, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := srt_connector.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.WriteFile(path, []byte(`{"enabled": false}`), 0644)
+		srt_connector.LoadConfig(path)
+	})
+
+	outbox := &fakeOutbox{now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	claimOutbox, markOutboxSent, markOutboxFailed = outbox.claim, outbox.markSent, outbox.markFailed
+	t.Cleanup(func() {
+		claimOutbox = postgresql_operations.ClaimOutboxEntries
+		markOutboxSent = postgresql_operations.MarkOutboxSent
+		markOutboxFailed = postgresql_operations.MarkOutboxFailed
+	})
+	return outbox, api
+}
+
+func closingUpdates(srtNum string) []srt_connector.Update {
+	return srt_connector.ClosingUpdates(srtNum, "Ресурсы созданы", "INSERT 0 2")
+}
+
+func TestSRTOutboxKeepsTicketOrder(t *testing.T) {
+	outbox, api := setupOutbox(t, 3, nil)
+	outbox.add("SRT-1", closingUpdates("SRT-1")...)
+	outbox.add("SRT-2", closingUpdates("SRT-2")...)
+
+	processSRTOutbox(context.Background())
+
+	want := "SRT-1 comments,SRT-2 comments,SRT-1 attachments,SRT-2 attachments,SRT-1 status,SRT-2 status"
+	if got := strings.Join(api.received, ","); got != want {
+		t.Errorf("updates sent as\n%s\nwant\n%s", got, want)
+	}
+	for _, srtNum := range []string{"SRT-1", "SRT-2"} {
+		if got := outbox.statuses(srtNum); got != "sent,sent,sent" {
+			t.Errorf("%s: %s", srtNum, got)
+		}
+	}
+}
+
+func TestSRTOutboxRetriesFailedUpdate(t *testing.T) {
+	outbox, api := setupOutbox(t, 3, map[string]int{"SRT-1": 1})
+	outbox.add("SRT-1", closingUpdates("SRT-1")...)
+	outbox.add("SRT-2", closingUpdates("SRT-2")...)
+
+	processSRTOutbox(context.Background())
+
+	// The other ticket is not held up, the failed one waits for its retry
+	if got := strings.Join(api.received, ","); got != "SRT-2 comments,SRT-2 attachments,SRT-2 status" {
+		t.Errorf("updates sent: %s", got)
+	}
+	if got := outbox.statuses("SRT-1"); got != "pending,pending,pending" {
+		t.Errorf("SRT-1: %s", got)
+	}
+	if entry := outbox.entries[0]; entry.Attempts != 1 || !strings.Contains(entry.Error, "503") {
+		t.Errorf("failed attempt recorded as %+v", entry.OutboxEntry)
+	}
+
+	outbox.now = outbox.now.Add(59 * time.Second)
+	processSRTOutbox(context.Background())
+	if len(api.received) != 3 {
+		t.Fatalf("update retried before its delay: %v", api.received)
+	}
+
+	outbox.now = outbox.now.Add(time.Second)
+	processSRTOutbox(context.Background())
+	if got := strings.Join(api.received[3:], ","); got != "SRT-1 comments,SRT-1 attachments,SRT-1 status" {
+		t.Errorf("updates sent after the retry: %s", got)
+	}
+	if got := outbox.statuses("SRT-1"); got != "sent,sent,sent" {
+		t.Errorf("SRT-1: %s", got)
+	}
+}
+
+func TestSRTOutboxStopsAfterFailedUpdate(t *testing.T) {
+	outbox, api := setupOutbox(t, 2, map[string]int{"SRT-1": 2})
+	outbox.add("SRT-1", closingUpdates("SRT-1")...)
+
+	processSRTOutbox(context.Background())
+	outbox.now = outbox.now.Add(time.Minute)
+	processSRTOutbox(context.Background())
+
+	// The closing status must not be set after the comment was lost
+	if len(api.received) != 0 {
+		t.Errorf("updates sent after a failed one: %v", api.received)
+	}
+	if got := outbox.statuses("SRT-1"); got != "failed,pending,pending" {
+		t.Errorf("SRT-1: %s", got)
+	}
+
+	outbox.now = outbox.now.Add(time.Hour)
+	processSRTOutbox(context.Background())
+	if len(api.received) != 0 {
+		t.Errorf("updates sent after a failed one: %v", api.received)
+	}
+
+	// A retry requested by an operator resumes the ticket
+	outbox.entries[0].Status = postgresql_operations.OutboxPending
+	outbox.entries[0].Attempts = 0
+	processSRTOutbox(context.Background())
+	if got := strings.Join(api.received, ","); got != "SRT-1 comments,SRT-1 attachments,SRT-1 status" {
+		t.Errorf("updates sent after the retry: %s", got)
+	}
+}
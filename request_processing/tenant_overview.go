@@ -0,0 +1,151 @@
+package request_processing
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/NarrativeBias/zayavki/cluster_endpoint_parser"
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/NarrativeBias/zayavki/quota"
+)
+
+// TenantOverview is everything known about a tenant: its rows, active or
+// not, the capacity they allocate, its cluster and the requests and events
+// that touched it
+type TenantOverview struct {
+	Tenant       string                                `json:"tenant"`
+	Active       bool                                  `json:"active"`
+	Cluster      string                                `json:"cluster"`
+	Realm        string                                `json:"realm"`
+	Env          string                                `json:"environment"`
+	Segment      string                                `json:"segment"`
+	TLSEndpoint  string                                `json:"tls_endpoint"`
+	MTLSEndpoint string                                `json:"mtls_endpoint"`
+	RisCode      string                                `json:"ris_code"`
+	RisId        string                                `json:"ris_id"`
+	OwnerGroup   string                                `json:"owner_group"`
+	Owner        string                                `json:"owner"`
+	Users        []postgresql_operations.CheckResult   `json:"users"`
+	Buckets      []postgresql_operations.CheckResult   `json:"buckets"`
+	Capacity     TenantCapacity                        `json:"capacity"`
+	Requests     []postgresql_operations.Request       `json:"requests"`
+	Timeline     []postgresql_operations.TimelineEvent `json:"timeline"`
+}
+
+// TenantCapacity sums the quotas of the active users and buckets of a tenant
+type TenantCapacity struct {
+	ActiveUsers         int    `json:"active_users"`
+	InactiveUsers       int    `json:"inactive_users"`
+	ActiveBuckets       int    `json:"active_buckets"`
+	InactiveBuckets     int    `json:"inactive_buckets"`
+	BucketQuotaBytes    int64  `json:"bucket_quota_bytes"`
+	BucketQuota         string `json:"bucket_quota"`
+	UserQuotaBytes      int64  `json:"user_quota_bytes"`
+	UserQuota           string `json:"user_quota"`
+	BucketsWithoutQuota int    `json:"buckets_without_quota"`
+}
+
+// GetTenantOverview collects the overview of a tenant
+func GetTenantOverview(tenant string) (*TenantOverview, error) {
+	rows, err := postgresql_operations.CheckDBForExistingEntries("", "", "", "", tenant, "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrTenantNotFound
+	}
+
+	overview := &TenantOverview{
+		Tenant:  tenant,
+		Users:   []postgresql_operations.CheckResult{},
+		Buckets: []postgresql_operations.CheckResult{},
+	}
+	// Rows are newest first, so the tenant details come from the latest
+	// request, preferring the row of the tenant user
+	tenantRow := rows[0]
+	srtNums := map[string]bool{}
+	capacity := &overview.Capacity
+	for _, row := range rows {
+		if row.S3User.String == tenant {
+			tenantRow = row
+		}
+		if row.Active {
+			overview.Active = true
+		}
+		if row.SrtNum != "" && row.SrtNum != "-" {
+			srtNums[row.SrtNum] = true
+		}
+
+		switch {
+		case row.Bucket.Valid && row.Bucket.String != "-":
+			overview.Buckets = append(overview.Buckets, row)
+			if !row.Active {
+				capacity.InactiveBuckets++
+				continue
+			}
+			capacity.ActiveBuckets++
+			if row.QuotaBytes.Valid {
+				capacity.BucketQuotaBytes += row.QuotaBytes.Int64
+			} else {
+				capacity.BucketsWithoutQuota++
+			}
+		case row.S3User.Valid && row.S3User.String != "-":
+			overview.Users = append(overview.Users, row)
+			if !row.Active {
+				capacity.InactiveUsers++
+				continue
+			}
+			capacity.ActiveUsers++
+			if row.QuotaBytes.Valid {
+				capacity.UserQuotaBytes += row.QuotaBytes.Int64
+			}
+		}
+	}
+	capacity.BucketQuota = quota.Size(capacity.BucketQuotaBytes).String()
+	capacity.UserQuota = quota.Size(capacity.UserQuotaBytes).String()
+
+	// Active resources first, then by name
+	sortRows := func(list []postgresql_operations.CheckResult, name func(postgresql_operations.CheckResult) string) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Active != list[j].Active {
+				return list[i].Active
+			}
+			return name(list[i]) < name(list[j])
+		})
+	}
+	sortRows(overview.Users, func(r postgresql_operations.CheckResult) string { return r.S3User.String })
+	sortRows(overview.Buckets, func(r postgresql_operations.CheckResult) string { return r.Bucket.String })
+
+	overview.Cluster = tenantRow.ClsName
+	overview.Realm = tenantRow.Realm
+	overview.Env = tenantRow.Env
+	overview.Segment = tenantRow.NetSeg
+	overview.RisCode = tenantRow.RisCode
+	overview.RisId = tenantRow.RisId
+	overview.OwnerGroup = tenantRow.OwnerGroup
+	overview.Owner = tenantRow.OwnerPerson
+
+	// The cluster list is only informative here, a tenant on a cluster that
+	// was removed from it is still shown
+	if clusters, err := cluster_endpoint_parser.ListClusters("clusters.xlsx"); err == nil {
+		for _, cluster := range clusters {
+			if strings.EqualFold(cluster.Кластер, overview.Cluster) {
+				overview.TLSEndpoint = cluster.TLSEndpoint
+				overview.MTLSEndpoint = cluster.MTLSEndpoint
+				break
+			}
+		}
+	}
+
+	numbers := make([]string, 0, len(srtNums))
+	for number := range srtNums {
+		numbers = append(numbers, number)
+	}
+	if overview.Requests, err = postgresql_operations.TenantRequests(tenant, numbers); err != nil {
+		return nil, err
+	}
+	if overview.Timeline, err = postgresql_operations.TenantTimeline(tenant); err != nil {
+		return nil, err
+	}
+	return overview, nil
+}
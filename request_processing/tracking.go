@@ -0,0 +1,47 @@
+package request_processing
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+)
+
+// TrackRequest records the state of the request with the SD and SRT numbers
+// of variables, changed by actor. Tracking is best effort and never fails the work itself.
+func TrackRequest(ctx context.Context, actor string, variables map[string][]string, form map[string]string, state, note string) {
+	getFirst := func(key string) string {
+		if len(variables[key]) > 0 {
+			return variables[key][0]
+		}
+		return ""
+	}
+	if getFirst("request_id_sd") == "" && getFirst("request_id_srt") == "" {
+		return
+	}
+
+	_, err := postgresql_operations.RecordRequestState(postgresql_operations.RequestUpdate{
+		SdNum:  getFirst("request_id_sd"),
+		SrtNum: getFirst("request_id_srt"),
+		Tenant: getFirst("tenant"),
+		State:  state,
+		Actor:  actor,
+		Note:   note,
+		Form:   form,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to track request", "sd_num", getFirst("request_id_sd"), "srt_num", getFirst("request_id_srt"),
+			"tenant", getFirst("tenant"), "actor", actor, "state", state, "error", err)
+	}
+}
+
+// FormValues returns the first value of every form field
+func FormValues(values map[string][]string) map[string]string {
+	form := map[string]string{}
+	for key, value := range values {
+		if len(value) > 0 && value[0] != "" {
+			form[key] = value[0]
+		}
+	}
+	return form
+}
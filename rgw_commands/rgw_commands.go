@@ -2,54 +2,321 @@ package rgw_commands
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
+	"text/template"
+
+	"github.com/NarrativeBias/zayavki/bucket_features"
+	"github.com/NarrativeBias/zayavki/quota"
+)
+
+// TemplateSet holds the command templates used for one generation of clusters.
+// Every command is a text/template executed against CommandData. Params are
+// the defaults of .Params; a param with an empty default has to be set for
+// every cluster using the set in the cluster_params of the config.
+type TemplateSet struct {
+	BucketCreate string            `json:"bucket_create"`
+	UserCreate   string            `json:"user_create"`
+	ResultCheck  string            `json:"result_check"`
+	UserDelete   string            `json:"user_delete"`
+	BucketDelete string            `json:"bucket_delete"`
+	QuotaSet     string            `json:"quota_set"`
+	UserQuotaSet string            `json:"user_quota_set"`
+	QuotaEnable  string            `json:"quota_enable"`
+	QuotaDisable string            `json:"quota_disable"`
+	PolicyApply  string            `json:"policy_apply"`
+	PolicyDelete string            `json:"policy_delete"`
+	Versioning   string            `json:"bucket_versioning"`
+	ObjectLock   string            `json:"bucket_object_lock"`
+	Lifecycle    string            `json:"bucket_lifecycle"`
+	KeyCreate    string            `json:"key_create"`
+	KeyRemove    string            `json:"key_rm"`
+	Params       map[string]string `json:"params"`
+}
+
+// TemplateConfig maps clusters and realms to named template sets.
+// ClusterParams override the params of the set for single clusters.
+type TemplateConfig struct {
+	Default       string                       `json:"default"`
+	Clusters      map[string]string            `json:"clusters"`
+	Realms        map[string]string            `json:"realms"`
+	ClusterParams map[string]map[string]string `json:"cluster_params"`
+	Sets          map[string]TemplateSet       `json:"sets"`
+}
+
+// CommandData is the data passed to every command template
+type CommandData struct {
+	Realm       string
+	Tenant      string
+	Bucket      string
+	Size        string
+	DisplayName string
+	User        string
+	SRT         string
+	MaxObjects  string
+	Scope       string
+	Endpoint    string
+	Policy      string
+	ObjectLock  bool
+	LockMode    string
+	LockDays    string
+	Lifecycle   string
+	AccessKey   string
+	Params      map[string]string
+}
+
+const defaultSetName = "standard"
+
+// policyGuard runs a bucket policy command only when the current policy of
+// the bucket holds nothing but the statements zayavki generated, as
+// put-bucket-policy replaces the whole policy. A bucket without a policy
+// passes, a policy that cannot be read does not.
+const policyGuard = "if ! aws s3api get-bucket-policy --profile {{.Tenant}} --endpoint-url {{.Endpoint}} --bucket {{.Bucket}} --query Policy --output text > {{.Bucket}}-policy-current.json 2> {{.Bucket}}-policy-error.txt; then grep -q NoSuchBucketPolicy {{.Bucket}}-policy-error.txt && echo '{\"Statement\": []}' > {{.Bucket}}-policy-current.json; fi\n" +
+	"if test -s {{.Bucket}}-policy-current.json && jq -e '[.Statement[] | select((.Sid // \"\") | startswith(\"Zayavki\") | not)] == []' {{.Bucket}}-policy-current.json > /dev/null; then\n" +
+	"  %s\n" +
+	"else\n" +
+	"  echo \"{{.Bucket}}: текущая политика не прочитана или содержит правила, созданные не zayavki, объедините их вручную\" >&2\n" +
+	"fi"
+
+// builtinSet reproduces the commands used before templates became configurable
+var builtinSet = TemplateSet{
+	BucketCreate: `~/scripts/rgw-create-bucket.sh --config {{.Realm}} --tenant {{.Tenant}} --bucket {{.Bucket}} --size {{.Size}}{{if .ObjectLock}} --object-lock-enabled-for-bucket{{end}}{{if .DisplayName}} --display-name "{{.DisplayName}}"{{end}}`,
+	UserCreate:   `sudo radosgw-admin user create --rgw-realm {{.Realm}} --tenant {{.Tenant}} --uid {{.User}} --display-name {{.SRT}} --max-buckets -1 | grep -A2 '"user"'`,
+	ResultCheck:  `sudo radosgw-admin user list --rgw-realm {{.Realm}} | grep {{.Tenant}}; sudo radosgw-admin bucket list --rgw-realm {{.Realm}} | grep {{.Tenant}};`,
+	UserDelete:   `sudo radosgw-admin user rm --rgw-realm {{.Realm}} --tenant {{.Tenant}} --uid {{.User}}`,
+	BucketDelete: `sudo radosgw-admin bucket rm --rgw-realm {{.Realm}} --bucket "{{.Tenant}}/{{.Bucket}}"`,
+	QuotaSet:     `sudo radosgw-admin quota set --rgw-realm {{.Realm}} --quota-scope bucket --bucket "{{.Tenant}}/{{.Bucket}}"{{if .Size}} --max-size {{.Size}}{{end}}{{if .MaxObjects}} --max-objects {{.MaxObjects}}{{end}}`,
+	UserQuotaSet: `sudo radosgw-admin quota set --rgw-realm {{.Realm}} --quota-scope user --tenant {{.Tenant}} --uid {{.User}}{{if .Size}} --max-size {{.Size}}{{end}}{{if .MaxObjects}} --max-objects {{.MaxObjects}}{{end}}`,
+	QuotaEnable:  `sudo radosgw-admin quota enable --rgw-realm {{.Realm}} --quota-scope {{.Scope}}{{if eq .Scope "bucket"}} --bucket "{{.Tenant}}/{{.Bucket}}"{{else}} --tenant {{.Tenant}} --uid {{.User}}{{end}}`,
+	QuotaDisable: `sudo radosgw-admin quota disable --rgw-realm {{.Realm}} --quota-scope {{.Scope}}{{if eq .Scope "bucket"}} --bucket "{{.Tenant}}/{{.Bucket}}"{{else}} --tenant {{.Tenant}} --uid {{.User}}{{end}}`,
+	PolicyApply:  "cat > {{.Bucket}}-policy.json <<'EOF'\n{{.Policy}}\nEOF\n" + fmt.Sprintf(policyGuard, "aws s3api put-bucket-policy --profile {{.Tenant}} --endpoint-url {{.Endpoint}} --bucket {{.Bucket}} --policy file://{{.Bucket}}-policy.json"),
+	PolicyDelete: fmt.Sprintf(policyGuard, "aws s3api delete-bucket-policy --profile {{.Tenant}} --endpoint-url {{.Endpoint}} --bucket {{.Bucket}}"),
+	Versioning:   `aws s3api put-bucket-versioning --profile {{.Tenant}} --endpoint-url {{.Endpoint}} --bucket {{.Bucket}} --versioning-configuration Status=Enabled`,
+	ObjectLock:   `aws s3api put-object-lock-configuration --profile {{.Tenant}} --endpoint-url {{.Endpoint}} --bucket {{.Bucket}} --object-lock-configuration '{"ObjectLockEnabled": "Enabled", "Rule": {"DefaultRetention": {"Mode": "{{.LockMode}}", "Days": {{.LockDays}}}}}'`,
+	KeyCreate:    `sudo radosgw-admin key create --rgw-realm {{.Realm}} --tenant {{.Tenant}} --uid {{.User}} --key-type s3 --gen-access-key --gen-secret | grep -A2 '"user"'`,
+	KeyRemove:    `sudo radosgw-admin key rm --rgw-realm {{.Realm}} --tenant {{.Tenant}} --uid {{.User}} --key-type s3 --access-key {{.AccessKey}}`,
+	Lifecycle:    "cat > {{.Bucket}}-lifecycle.xml <<'EOF'\n{{.Lifecycle}}\nEOF\ns3cmd --config ~/.s3cfg-{{.Tenant}} setlifecycle {{.Bucket}}-lifecycle.xml s3://{{.Bucket}}",
+}
+
+var (
+	templateConfig = TemplateConfig{Default: defaultSetName}
+	templateSets   = map[string]*compiledSet{}
 )
 
-// convertGBToBytes converts gigabytes to bytes (1 GB = 1,000,000,000 bytes)
-// Only accepts plain integers, assumed to be in GB
-func convertGBToBytes(gbStr string) string {
-	// Remove any whitespace
-	gbStr = strings.TrimSpace(gbStr)
+type compiledSet struct {
+	tmpl   *template.Template
+	params map[string]string
+}
+
+func init() {
+	set, err := compileSet(defaultSetName, builtinSet)
+	if err != nil {
+		panic(fmt.Sprintf("invalid builtin command templates: %v", err))
+	}
+	templateSets[defaultSetName] = set
+}
 
-	// Parse the GB value as integer
-	gb, err := strconv.ParseInt(gbStr, 10, 64)
+// LoadTemplates reads command template sets from a JSON config file.
+// All templates are parsed and trial-executed so broken sets fail at startup.
+func LoadTemplates(configPath string) error {
+	file, err := os.ReadFile(configPath)
 	if err != nil {
-		return gbStr // Return original if conversion fails
+		return fmt.Errorf("failed to read command templates: %v", err)
+	}
+
+	var cfg TemplateConfig
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return fmt.Errorf("failed to parse command templates: %v", err)
+	}
+	if cfg.Default == "" {
+		cfg.Default = defaultSetName
+	}
+
+	sets := map[string]*compiledSet{defaultSetName: templateSets[defaultSetName]}
+	for name, set := range cfg.Sets {
+		compiled, err := compileSet(name, set)
+		if err != nil {
+			return err
+		}
+		sets[name] = compiled
+	}
+
+	if _, ok := sets[cfg.Default]; !ok {
+		return fmt.Errorf("default command template set '%s' is not defined", cfg.Default)
+	}
+	for cluster, name := range cfg.Clusters {
+		set, ok := sets[name]
+		if !ok {
+			return fmt.Errorf("cluster '%s' refers to unknown command template set '%s'", cluster, name)
+		}
+		if _, err := set.clusterParams(cluster, cfg.ClusterParams[cluster]); err != nil {
+			return err
+		}
+	}
+	for realm, name := range cfg.Realms {
+		if _, ok := sets[name]; !ok {
+			return fmt.Errorf("realm '%s' refers to unknown command template set '%s'", realm, name)
+		}
+	}
+
+	templateConfig = cfg
+	templateSets = sets
+	return nil
+}
+
+// commands maps the command names to their templates
+func (set TemplateSet) commands() map[string]string {
+	return map[string]string{
+		"bucket_create":      set.BucketCreate,
+		"user_create":        set.UserCreate,
+		"result_check":       set.ResultCheck,
+		"user_delete":        set.UserDelete,
+		"bucket_delete":      set.BucketDelete,
+		"quota_set":          set.QuotaSet,
+		"user_quota_set":     set.UserQuotaSet,
+		"quota_enable":       set.QuotaEnable,
+		"quota_disable":      set.QuotaDisable,
+		"policy_apply":       set.PolicyApply,
+		"policy_delete":      set.PolicyDelete,
+		"bucket_versioning":  set.Versioning,
+		"bucket_object_lock": set.ObjectLock,
+		"bucket_lifecycle":   set.Lifecycle,
+		"key_create":         set.KeyCreate,
+		"key_rm":             set.KeyRemove,
+	}
+}
+
+func compileSet(name string, set TemplateSet) (*compiledSet, error) {
+	commands := set.commands()
+	root := template.New(name).Option("missingkey=error")
+	for command, src := range commands {
+		if src == "" {
+			return nil, fmt.Errorf("command template set '%s' is missing '%s'", name, command)
+		}
+		if _, err := root.New(command).Parse(src); err != nil {
+			return nil, fmt.Errorf("command template set '%s', '%s': %v", name, command, err)
+		}
+	}
+
+	compiled := &compiledSet{tmpl: root, params: set.Params}
+	sample := CommandData{
+		Realm: "realm", Tenant: "tenant", Bucket: "bucket", Size: "1",
+		DisplayName: "group;owner;SRT-1", User: "user", SRT: "SRT-1",
+		MaxObjects: "1", Scope: "bucket", Endpoint: "https://endpoint", Policy: "{}",
+		ObjectLock: true, LockMode: "GOVERNANCE", LockDays: "1", Lifecycle: "<LifecycleConfiguration/>",
+		AccessKey: "ACCESSKEY",
+	}
+	for command := range commands {
+		if _, err := compiled.render(command, sample); err != nil {
+			return nil, fmt.Errorf("command template set '%s', '%s': %v", name, command, err)
+		}
+	}
+	return compiled, nil
+}
+
+// clusterParams merges the params of a cluster over the defaults of the set
+// and fails when a param without a default is not set for the cluster
+func (s *compiledSet) clusterParams(cluster string, overrides map[string]string) (map[string]string, error) {
+	params := s.merge(overrides)
+	for name, value := range params {
+		if value == "" {
+			return nil, fmt.Errorf("cluster '%s' has no '%s' param for its command template set", cluster, name)
+		}
+	}
+	return params, nil
+}
+
+func (s *compiledSet) merge(overrides map[string]string) map[string]string {
+	params := make(map[string]string, len(s.params)+len(overrides))
+	for name, value := range s.params {
+		params[name] = value
+	}
+	for name, value := range overrides {
+		params[name] = value
+	}
+	return params
+}
+
+// render executes a command with data.Params merged over the set defaults
+func (s *compiledSet) render(command string, data CommandData) (string, error) {
+	data.Params = s.merge(data.Params)
+	var buf bytes.Buffer
+	if err := s.tmpl.ExecuteTemplate(&buf, command, data); err != nil {
+		return "", err
 	}
+	return buf.String(), nil
+}
 
-	// Convert to bytes (1 GB = 1,000,000,000 bytes)
-	bytes := gb * 1000000000
+// SelectTemplateSet picks the template set for a cluster. The "Шаблон" column
+// of the cluster inventory wins, then the cluster and realm mappings of the
+// config, then the configured default.
+func SelectTemplateSet(clusters map[string]string) (string, error) {
+	name := clusters["Шаблон"]
+	if name == "" {
+		name = templateConfig.Clusters[clusters["Кластер"]]
+	}
+	if name == "" {
+		name = templateConfig.Realms[clusters["Реалм"]]
+	}
+	if name == "" {
+		name = templateConfig.Default
+	}
+	if _, ok := templateSets[name]; !ok {
+		return "", fmt.Errorf("unknown command template set '%s' for cluster '%s'", name, clusters["Кластер"])
+	}
+	return name, nil
+}
 
-	return strconv.FormatInt(bytes, 10)
+func renderCommand(clusters map[string]string, command string, data CommandData) (string, error) {
+	name, err := SelectTemplateSet(clusters)
+	if err != nil {
+		return "", err
+	}
+	set := templateSets[name]
+	params, err := set.clusterParams(clusters["Кластер"], templateConfig.ClusterParams[clusters["Кластер"]])
+	if err != nil {
+		return "", err
+	}
+	data.Realm = clusters["Реалм"]
+	data.Endpoint = clusters["tls_endpoint"]
+	data.Params = params
+	cmd, err := set.render(command, data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering %s with template set '%s': %v", command, name, err)
+	}
+	return cmd, nil
 }
 
-func BucketCreation(variables map[string][]string, clusters map[string]string) string {
+func BucketCreation(variables map[string][]string, clusters map[string]string) (string, error) {
 	var rows bytes.Buffer
 	for i, bucket := range variables["bucketnames"] {
 		if bucket != "" {
+			size, err := quota.Parse(variables["bucketquotas"][i])
+			if err != nil {
+				return "", fmt.Errorf("invalid quota for bucket %s: %v", bucket, err)
+			}
+			data := CommandData{
+				Tenant: variables["tenant"][0],
+				Bucket: variables["bucketnames"][i],
+				Size:   size.Bytes(),
+				// Object lock can only be enabled when the bucket is created
+				ObjectLock: i < len(variables["bucketlock"]) && variables["bucketlock"][i] != "-",
+			}
 			createTenant, ok := variables["create_tenant"]
 			if i == 0 && ok && len(createTenant) > 0 && createTenant[0] == "true" {
-				displayName := fmt.Sprintf("%s;%s;%s",
+				data.DisplayName = fmt.Sprintf("%s;%s;%s",
 					variables["resp_group"][0],
 					variables["owner"][0],
 					variables["request_id_srt"][0])
-				bucketcreate := fmt.Sprintf("~/scripts/rgw-create-bucket.sh --config %s --tenant %s --bucket %s --size %s --display-name \"%s\"",
-					clusters["Реалм"],
-					variables["tenant"][0],
-					variables["bucketnames"][i],
-					convertGBToBytes(variables["bucketquotas"][i]),
-					displayName)
-				rows.WriteString(bucketcreate)
-			} else {
-				bucketcreate := fmt.Sprintf("~/scripts/rgw-create-bucket.sh --config %s --tenant %s --bucket %s --size %s",
-					clusters["Реалм"],
-					variables["tenant"][0],
-					variables["bucketnames"][i],
-					convertGBToBytes(variables["bucketquotas"][i]))
-				rows.WriteString(bucketcreate)
 			}
+			bucketcreate, err := renderCommand(clusters, "bucket_create", data)
+			if err != nil {
+				return "", err
+			}
+			rows.WriteString(bucketcreate)
 			if i < len(variables["bucketnames"])-1 {
 				rows.WriteString(";\n")
 			} else {
@@ -57,10 +324,10 @@ func BucketCreation(variables map[string][]string, clusters map[string]string) s
 			}
 		}
 	}
-	return rows.String()
+	return rows.String(), nil
 }
 
-func UserCreation(variables map[string][]string, clusters map[string]string) string {
+func UserCreation(variables map[string][]string, clusters map[string]string) (string, error) {
 
 	// Iterate over the usernames to generate terminal commands for "radogw-admin user create"
 	var rows bytes.Buffer
@@ -71,8 +338,15 @@ func UserCreation(variables map[string][]string, clusters map[string]string) str
 		}
 		// Check if UID is empty
 		if user != "" {
-			usercreate := fmt.Sprintf("sudo radosgw-admin user create --rgw-realm %s --tenant %s --uid %s --display-name %s --max-buckets -1 | grep -A2 '\"user\"';", clusters["Реалм"], variables["tenant"][0], user, variables["request_id_srt"][0])
-			rows.WriteString(usercreate)
+			usercreate, err := renderCommand(clusters, "user_create", CommandData{
+				Tenant: variables["tenant"][0],
+				User:   user,
+				SRT:    variables["request_id_srt"][0],
+			})
+			if err != nil {
+				return "", err
+			}
+			rows.WriteString(usercreate + ";")
 			// Add newline character only if it's not the last row
 			if i < len(variables["users"])-1 {
 				rows.WriteString("\n")
@@ -80,54 +354,255 @@ func UserCreation(variables map[string][]string, clusters map[string]string) str
 		}
 
 	}
-	return rows.String()
+	return rows.String(), nil
 }
 
-func ResultCheck(variables map[string][]string, clusters map[string]string) string {
+func ResultCheck(variables map[string][]string, clusters map[string]string) (string, error) {
 	// Commands to check users and buckets in tenant
-	userListCommand := fmt.Sprintf("sudo radosgw-admin user list --rgw-realm %s | grep %s; sudo radosgw-admin bucket list --rgw-realm %s | grep %s;\n", clusters["Реалм"], variables["tenant"][0], clusters["Реалм"], variables["tenant"][0])
-	return userListCommand
+	userListCommand, err := renderCommand(clusters, "result_check", CommandData{Tenant: variables["tenant"][0]})
+	if err != nil {
+		return "", err
+	}
+	return userListCommand + "\n", nil
 }
 
-func GenerateDeletionCommands(tenant string, users []string, buckets []string, realm string) string {
+func GenerateDeletionCommands(tenant string, users []string, buckets []string, clusters map[string]string) (string, error) {
 	var commands bytes.Buffer
 
 	// Generate user deletion commands
 	for _, user := range users {
 		if user != "" && user != tenant { // Skip empty users and tenant user
-			cmd := fmt.Sprintf("sudo radosgw-admin user rm --rgw-realm %s --tenant %s --uid %s\n",
-				realm, tenant, user)
-			commands.WriteString(cmd)
+			cmd, err := renderCommand(clusters, "user_delete", CommandData{Tenant: tenant, User: user})
+			if err != nil {
+				return "", err
+			}
+			commands.WriteString(cmd + "\n")
 		}
 	}
 
 	// Generate bucket deletion commands
 	for _, bucket := range buckets {
 		if bucket != "" {
-			cmd := fmt.Sprintf("sudo radosgw-admin bucket rm --rgw-realm %s --bucket \"%s/%s\"\n",
-				realm, tenant, bucket)
-			commands.WriteString(cmd)
+			cmd, err := renderCommand(clusters, "bucket_delete", CommandData{Tenant: tenant, Bucket: bucket})
+			if err != nil {
+				return "", err
+			}
+			commands.WriteString(cmd + "\n")
 		}
 	}
 
-	return commands.String()
+	return commands.String(), nil
+}
+
+// splitQuotaLine splits a "name | size | objects=N" line and returns the size
+// in bytes. A size of "-" means that only the object limit is changed.
+func splitQuotaLine(line string) (name, size, maxObjects string, err error) {
+	parts := strings.Split(line, "|")
+	name = strings.TrimSpace(parts[0])
+	size = "0"
+	if len(parts) > 1 {
+		size = strings.TrimSpace(parts[1])
+	}
+	if size == "-" {
+		size = ""
+	} else if len(parts) > 1 {
+		parsed, err := quota.Parse(size)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid quota for %s: %v", name, err)
+		}
+		size = parsed.Bytes()
+	}
+	for _, option := range parts[min(len(parts), 2):] {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		if strings.TrimSpace(key) == "objects" {
+			maxObjects = strings.TrimSpace(value)
+		}
+	}
+	return name, size, maxObjects, nil
 }
 
-func GenerateQuotaCommands(tenant string, buckets []string, realm string) string {
+// GenerateQuotaCommands generates commands for the given quota action: "set"
+// (the default) applies sizes and object limits, "enable" and "disable" toggle
+// the quotas of the listed buckets and users.
+func GenerateQuotaCommands(tenant string, buckets []string, users []string, action string, clusters map[string]string) (string, error) {
 	var commands bytes.Buffer
+	write := func(command string, data CommandData) error {
+		data.Tenant = tenant
+		cmd, err := renderCommand(clusters, command, data)
+		if err != nil {
+			return err
+		}
+		commands.WriteString(cmd + "\n")
+		return nil
+	}
+
 	for _, bucket := range buckets {
-		if bucket != "" {
-			parts := strings.Split(bucket, "|")
-			name := strings.TrimSpace(parts[0])
-			size := "0"
-			if len(parts) > 1 {
-				size = strings.TrimSpace(parts[1])
+		if bucket == "" {
+			continue
+		}
+		name, size, maxObjects, err := splitQuotaLine(bucket)
+		if err != nil {
+			return "", err
+		}
+		switch action {
+		case "enable", "disable":
+			err = write("quota_"+action, CommandData{Bucket: name, Scope: "bucket"})
+		default:
+			err = write("quota_set", CommandData{Bucket: name, Size: size, MaxObjects: maxObjects})
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, user := range users {
+		if user == "" {
+			continue
+		}
+		name, size, maxObjects, err := splitQuotaLine(user)
+		if err != nil {
+			return "", err
+		}
+		switch action {
+		case "enable", "disable":
+			if err := write("quota_"+action, CommandData{User: name, Scope: "user"}); err != nil {
+				return "", err
+			}
+		default:
+			// User quotas are disabled until explicitly enabled
+			if err := write("user_quota_set", CommandData{User: name, Size: size, MaxObjects: maxObjects}); err != nil {
+				return "", err
 			}
+			if err := write("quota_enable", CommandData{User: name, Scope: "user"}); err != nil {
+				return "", err
+			}
+		}
+	}
+	return commands.String(), nil
+}
 
-			cmd := fmt.Sprintf("sudo radosgw-admin quota set --rgw-realm %s --quota-scope bucket --bucket \"%s/%s\" --max-size %s\n",
-				realm, tenant, name, convertGBToBytes(size))
-			commands.WriteString(cmd)
+// QuotaCreation generates the quota commands that follow resource creation:
+// object limits for new buckets and user-scope quotas for new users.
+func QuotaCreation(variables map[string][]string, clusters map[string]string) (string, error) {
+	var buckets, users []string
+	for i, bucket := range variables["bucketnames"] {
+		if i < len(variables["bucketobjects"]) && variables["bucketobjects"][i] != "-" {
+			buckets = append(buckets, fmt.Sprintf("%s | - | objects=%s", bucket, variables["bucketobjects"][i]))
+		}
+	}
+	for i, user := range variables["quotausers"] {
+		line := fmt.Sprintf("%s | %s", user, variables["quotausersizes"][i])
+		if variables["quotauserobjects"][i] != "-" {
+			line += " | objects=" + variables["quotauserobjects"][i]
+		}
+		users = append(users, line)
+	}
+	return GenerateQuotaCommands(variables["tenant"][0], buckets, users, "set", clusters)
+}
+
+// BucketPolicyCommands generates the commands that apply bucket policies. The
+// policies map holds the policy JSON per bucket; an empty policy removes the
+// policy of the bucket.
+func BucketPolicyCommands(tenant string, buckets []string, policies map[string]string, clusters map[string]string) (string, error) {
+	var commands bytes.Buffer
+	for _, bucket := range buckets {
+		command := "policy_apply"
+		if policies[bucket] == "" {
+			command = "policy_delete"
+		}
+		cmd, err := renderCommand(clusters, command, CommandData{Tenant: tenant, Bucket: bucket, Policy: policies[bucket]})
+		if err != nil {
+			return "", err
+		}
+		commands.WriteString(cmd + "\n")
+	}
+	return commands.String(), nil
+}
+
+// BucketFeatures generates the configuration steps for new buckets that
+// requested versioning, object lock or lifecycle expiration. The object lock
+// retention is set on buckets created with object lock enabled by
+// BucketCreation.
+func BucketFeatures(variables map[string][]string, clusters map[string]string) (string, error) {
+	var commands bytes.Buffer
+	tenant := variables["tenant"][0]
+	for i, bucket := range variables["bucketnames"] {
+		if bucket == "" || i >= len(variables["bucketversioning"]) {
+			continue
+		}
+		features := bucket_features.Features{Versioning: variables["bucketversioning"][i] == "true"}
+		for option, value := range map[string]string{"lock": variables["bucketlock"][i], "expire": variables["bucketexpire"][i]} {
+			if value == "-" {
+				continue
+			}
+			if _, err := features.ParseOption(option + "=" + value); err != nil {
+				return "", fmt.Errorf("invalid options for bucket %s: %v", bucket, err)
+			}
+		}
+
+		data := CommandData{Tenant: tenant, Bucket: bucket}
+		if features.Versioning {
+			cmd, err := renderCommand(clusters, "bucket_versioning", data)
+			if err != nil {
+				return "", err
+			}
+			commands.WriteString(cmd + "\n")
+		}
+		if features.LockMode != "" {
+			data.LockMode, data.LockDays = features.LockMode, strconv.Itoa(features.LockDays)
+			cmd, err := renderCommand(clusters, "bucket_object_lock", data)
+			if err != nil {
+				return "", err
+			}
+			commands.WriteString(cmd + "\n")
+		}
+		if features.ExpireDays > 0 {
+			lifecycle, err := features.LifecycleXML()
+			if err != nil {
+				return "", err
+			}
+			data.Lifecycle = strings.TrimSpace(lifecycle)
+			cmd, err := renderCommand(clusters, "bucket_lifecycle", data)
+			if err != nil {
+				return "", err
+			}
+			commands.WriteString(cmd + "\n")
+		}
+	}
+	return commands.String(), nil
+}
+
+// KeyCommands generates access key commands for a user: "issue" creates a new
+// key, "rotate" creates a new key and removes the given old keys, "revoke"
+// removes the given keys.
+func KeyCommands(tenant, user, action string, accessKeys []string, clusters map[string]string) (string, error) {
+	var commands bytes.Buffer
+	write := func(command string, data CommandData) error {
+		data.Tenant, data.User = tenant, user
+		cmd, err := renderCommand(clusters, command, data)
+		if err != nil {
+			return err
+		}
+		commands.WriteString(cmd + "\n")
+		return nil
+	}
+
+	switch action {
+	case "issue", "rotate", "revoke":
+	default:
+		return "", fmt.Errorf("unknown key action '%s'", action)
+	}
+	if action != "revoke" {
+		if err := write("key_create", CommandData{}); err != nil {
+			return "", err
+		}
+	}
+	if action != "issue" {
+		for _, key := range accessKeys {
+			if err := write("key_rm", CommandData{AccessKey: key}); err != nil {
+				return "", err
+			}
 		}
 	}
-	return commands.String()
+	return commands.String(), nil
 }
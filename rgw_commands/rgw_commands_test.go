@@ -0,0 +1,208 @@
+package rgw_commands
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// goldenData fills every field used by the templates
+var goldenData = CommandData{
+	Realm:       "k37-realm",
+	Tenant:      "ten-acme",
+	Bucket:      "ten-acme-logs",
+	Size:        "107374182400",
+	DisplayName: "grp-acme;Иванов И.И.;SRT-1234",
+	User:        "ten-acme-app",
+	SRT:         "SRT-1234",
+	MaxObjects:  "1000000",
+	Scope:       "bucket",
+	Endpoint:    "https://s3.k37.example",
+	Policy:      `{"Version": "2012-10-17"}`,
+	ObjectLock:  true,
+	LockMode:    "GOVERNANCE",
+	LockDays:    "30",
+	Lifecycle:   "<LifecycleConfiguration/>",
+	AccessKey:   "AKIAEXAMPLE",
+	Params:      map[string]string{"zonegroup": "dmz-k37"},
+}
+
+// loadRepoTemplates loads the shipped templates for one test and restores
+// the previous ones afterwards
+func loadRepoTemplates(t *testing.T) {
+	t.Helper()
+	savedConfig, savedSets := templateConfig, templateSets
+	t.Cleanup(func() {
+		templateConfig, templateSets = savedConfig, savedSets
+	})
+	if err := LoadTemplates("../command_templates.json"); err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+}
+
+func TestTemplateSetsGolden(t *testing.T) {
+	loadRepoTemplates(t)
+
+	var names []string
+	for name := range builtinSet.commands() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for setName, set := range templateSets {
+		t.Run(setName, func(t *testing.T) {
+			var out strings.Builder
+			for _, command := range names {
+				data := goldenData
+				if command == "quota_enable" || command == "quota_disable" {
+					// Both scopes take different arguments
+					for _, scope := range []string{"bucket", "user"} {
+						data.Scope = scope
+						rendered, err := set.render(command, data)
+						if err != nil {
+							t.Fatalf("%s: %v", command, err)
+						}
+						out.WriteString("## " + command + " " + scope + "\n" + rendered + "\n\n")
+					}
+					continue
+				}
+				rendered, err := set.render(command, data)
+				if err != nil {
+					t.Fatalf("%s: %v", command, err)
+				}
+				out.WriteString("## " + command + "\n" + rendered + "\n\n")
+			}
+
+			golden := filepath.Join("testdata", setName+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(out.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if out.String() != string(want) {
+				t.Errorf("commands of %s differ from %s:\n%s", setName, golden, out.String())
+			}
+		})
+	}
+}
+
+func TestSelectTemplateSet(t *testing.T) {
+	loadRepoTemplates(t)
+	// Restored with the rest of the config by loadRepoTemplates
+	templateConfig.Realms = map[string]string{"k37-realm": "s3-wrapper-v2"}
+
+	tests := []struct {
+		name     string
+		clusters map[string]string
+		want     string
+	}{
+		{"inventory column wins", map[string]string{"Шаблон": "standard", "Кластер": "PROD-DMZ-K37", "Реалм": "k37-realm"}, "standard"},
+		{"cluster before realm", map[string]string{"Кластер": "PROD-DMZ-K37", "Реалм": "k37-realm"}, "legacy-zonegroup"},
+		{"realm before default", map[string]string{"Кластер": "IFT-K12", "Реалм": "k37-realm"}, "s3-wrapper-v2"},
+		{"default", map[string]string{"Кластер": "IFT-K12", "Реалм": "k12-realm"}, "standard"},
+	}
+	for _, tt := range tests {
+		got, err := SelectTemplateSet(tt.clusters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := SelectTemplateSet(map[string]string{"Шаблон": "missing"}); err == nil {
+		t.Error("unknown set in the inventory was accepted")
+	}
+}
+
+func TestLoadTemplatesRejectsBrokenSets(t *testing.T) {
+	dir := t.TempDir()
+	for name, config := range map[string]string{
+		"missing command":       `{"sets": {"broken": {"bucket_create": "create"}}}`,
+		"parse error":           `{"sets": {"broken": {"bucket_create": "{{.Bucket"}}}`,
+		"unknown mapping":       `{"clusters": {"K1": "missing"}}`,
+		"unknown default":       `{"default": "missing"}`,
+		"missing cluster param": `{"clusters": {"K1": "zg"}, "sets": {"zg": ` + zonegroupSet(t) + `}}`,
+	} {
+		path := filepath.Join(dir, "templates.json")
+		if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadTemplates(path); err == nil {
+			t.Errorf("%s: LoadTemplates accepted %s", name, config)
+		}
+	}
+}
+
+// zonegroupSet returns the JSON of the standard set with a required
+// zonegroup param
+func zonegroupSet(t *testing.T) string {
+	t.Helper()
+	set := builtinSet
+	set.UserDelete += " --rgw-zonegroup {{.Params.zonegroup}}"
+	set.Params = map[string]string{"zonegroup": ""}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestClusterParams(t *testing.T) {
+	loadRepoTemplates(t)
+
+	render := func(clusters map[string]string) (string, error) {
+		return renderCommand(clusters, "user_delete", CommandData{Tenant: "ten-acme", User: "app"})
+	}
+	for cluster, zonegroup := range map[string]string{"PROD-DMZ-K37": "dmz-k37", "PROD-DMZ-PR1": "dmz-pr1"} {
+		cmd, err := render(map[string]string{"Кластер": cluster, "Реалм": "realm"})
+		if err != nil {
+			t.Fatalf("%s: %v", cluster, err)
+		}
+		if !strings.Contains(cmd, "--rgw-zonegroup "+zonegroup+" ") {
+			t.Errorf("%s: %q lacks zonegroup %s", cluster, cmd, zonegroup)
+		}
+	}
+
+	// A cluster moved to the set by the inventory has no zonegroup configured
+	if cmd, err := render(map[string]string{"Шаблон": "legacy-zonegroup", "Кластер": "PROD-DMZ-NEW"}); err == nil {
+		t.Errorf("cluster without a zonegroup rendered %q", cmd)
+	}
+}
+
+func TestBucketCreationEnablesObjectLock(t *testing.T) {
+	loadRepoTemplates(t)
+	variables := map[string][]string{
+		"tenant":           {"ten-acme"},
+		"bucketnames":      {"ten-acme-locked", "ten-acme-plain"},
+		"bucketquotas":     {"100G", "100G"},
+		"bucketversioning": {"true", "false"},
+		"bucketlock":       {"GOVERNANCE:30", "-"},
+		"bucketexpire":     {"-", "-"},
+	}
+	commands, err := BucketCreation(variables, map[string]string{"Кластер": "IFT-K12", "Реалм": "k12-realm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(commands, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 commands, got %q", commands)
+	}
+	if !strings.Contains(lines[0], "--object-lock-enabled-for-bucket") {
+		t.Errorf("locked bucket is created without object lock: %s", lines[0])
+	}
+	if strings.Contains(lines[1], "--object-lock-enabled-for-bucket") {
+		t.Errorf("plain bucket is created with object lock: %s", lines[1])
+	}
+}
@@ -0,0 +1,166 @@
+package srt_connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config holds the ticket API settings
+type Config struct {
+	// Enabled turns on fetching tickets, otherwise they are pasted as JSON
+	Enabled bool `json:"enabled"`
+	// BaseURL is the entities endpoint, tickets are read from
+	// <base_url>/<number> and their parameters from <base_url>/<number>/parameters
+	BaseURL  string `json:"base_url"`
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// TimeoutSeconds limits each request to the ticket API
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// WriteBack posts the closing comment, the DB result and the closing
+	// status to the ticket after a push to the DB
+	WriteBack     bool   `json:"write_back"`
+	ClosingStatus string `json:"closing_status"`
+	// MaxAttempts and RetrySeconds control the retries of failed updates,
+	// the delay grows with every attempt
+	MaxAttempts  int `json:"max_attempts"`
+	RetrySeconds int `json:"retry_seconds"`
+}
+
+var config = Config{
+	TimeoutSeconds: 15,
+	ClosingStatus:  "resolved",
+	MaxAttempts:    5,
+	RetrySeconds:   60,
+}
+
+var client = &http.Client{}
+
+// LoadConfig reads the ticket API settings from a JSON config file
+func LoadConfig(configPath string) error {
+	file, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read srt config: %v", err)
+	}
+
+	cfg := config
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return fmt.Errorf("failed to parse srt config: %v", err)
+	}
+	if !cfg.Enabled {
+		config = cfg
+		return nil
+	}
+
+	if cfg.BaseURL == "" {
+		return fmt.Errorf("base_url is required when the srt connector is enabled")
+	}
+	if cfg.TimeoutSeconds <= 0 {
+		return fmt.Errorf("timeout_seconds must be positive")
+	}
+	if cfg.WriteBack && (cfg.ClosingStatus == "" || cfg.MaxAttempts <= 0 || cfg.RetrySeconds <= 0) {
+		return fmt.Errorf("closing_status, max_attempts and retry_seconds are required for write_back")
+	}
+
+	config = cfg
+	client = &http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second}
+	return nil
+}
+
+// Enabled reports whether tickets can be fetched
+func Enabled() bool {
+	return config.Enabled
+}
+
+var ticketNumber = regexp.MustCompile(`^SRT-\d+$`)
+
+// NormalizeNumber accepts "SRT-123", "srt-123" or "123" and returns "SRT-123"
+func NormalizeNumber(number string) (string, error) {
+	number = strings.ToUpper(strings.TrimSpace(number))
+	if !strings.HasPrefix(number, "SRT-") {
+		number = "SRT-" + number
+	}
+	if !ticketNumber.MatchString(number) {
+		return "", fmt.Errorf("invalid SRT number: %s", number)
+	}
+	return number, nil
+}
+
+// FetchTicket returns the ticket JSON and the parameters JSON of an SRT
+// ticket
+func FetchTicket(number string) ([]byte, []byte, error) {
+	if !config.Enabled {
+		return nil, nil, fmt.Errorf("srt connector is not enabled")
+	}
+	number, err := NormalizeNumber(number)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base := strings.TrimRight(config.BaseURL, "/") + "/" + number
+	ticket, err := get(base, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	params, err := get(base+"/parameters", number)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ticket, params, nil
+}
+
+func get(url, number string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", number, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for %s: %v", number, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("ticket %s not found", number)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("access to ticket %s denied (status %d)", number, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("ticket API returned status %d for %s: %s", resp.StatusCode, number, snippet(body))
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("ticket API returned invalid JSON for %s: %s", number, snippet(body))
+	}
+	return body, nil
+}
+
+// do sends a request to the ticket API with the configured credentials
+func do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Accept", "application/json")
+	if config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Token)
+	} else if config.Username != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+	return client.Do(req)
+}
+
+func snippet(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if len(text) > 200 {
+		text = text[:200] + "..."
+	}
+	return text
+}
@@ -0,0 +1,247 @@
+package srt_connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ticketAPI is a stand-in for the ticket API. Tickets are read from
+// testdata/<number>.json and their parameters from
+// testdata/<number>.parameters.json, updates of recorded tickets are kept in
+// memory. Numbers in statuses are answered with the given status instead.
+type ticketAPI struct {
+	statuses map[string]int
+
+	mu      sync.Mutex
+	updates []receivedUpdate
+	auth    []string
+}
+
+type receivedUpdate struct {
+	Number string
+	Update Update
+}
+
+func (a *ticketAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	a.auth = append(a.auth, r.Header.Get("Authorization"))
+	a.mu.Unlock()
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/entities/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	number, resource, _ := strings.Cut(path, "/")
+	if status, ok := a.statuses[number]; ok {
+		http.Error(w, "ticket API error", status)
+		return
+	}
+
+	ticketFile := filepath.Join("testdata", number+".json")
+	switch {
+	case r.Method == http.MethodGet && resource == "":
+		serveFile(w, r, ticketFile)
+	case r.Method == http.MethodGet && resource == "parameters":
+		serveFile(w, r, filepath.Join("testdata", number+".parameters.json"))
+	case r.Method == http.MethodPost:
+		if _, err := os.Stat(ticketFile); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		update, err := readUpdate(r, resource)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.mu.Lock()
+		a.updates = append(a.updates, receivedUpdate{Number: number, Update: update})
+		a.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func readUpdate(r *http.Request, resource string) (Update, error) {
+	switch resource {
+	case "comments", "status":
+		var body struct {
+			Text   string `json:"text"`
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return Update{}, fmt.Errorf("invalid request body: %v", err)
+		}
+		if resource == "comments" {
+			return Update{Kind: UpdateComment, Text: body.Text}, nil
+		}
+		return Update{Kind: UpdateStatus, Status: body.Status}, nil
+	case "attachments":
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return Update{}, fmt.Errorf("invalid attachment: %v", err)
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return Update{}, fmt.Errorf("invalid attachment: %v", err)
+		}
+		return Update{Kind: UpdateAttachment, FileName: header.Filename, Text: string(data)}, nil
+	}
+	return Update{}, fmt.Errorf("unknown resource: %s", resource)
+}
+
+// startTicketAPI points the connector at a ticket API stand-in
+func startTicketAPI(t *testing.T, statuses map[string]int) *ticketAPI {
+	t.Helper()
+	api := &ticketAPI{statuses: statuses}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	savedConfig, savedClient := config, client
+	t.Cleanup(func() { config, client = savedConfig, savedClient })
+
+	path := filepath.Join(t.TempDir(), "srt.json")
+	cfg := fmt.Sprintf(`{"enabled": true, "base_url": %q, "token": "secret", "write_back": true, "closing_status": "resolved"}`, server.URL+"/entities/")
+	if err := os.WriteFile(path, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+func TestFetchTicket(t *testing.T) {
+	api := startTicketAPI(t, nil)
+
+	ticket, params, err := FetchTicket("srt-0000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string][]byte{"SRT-0000001.json": ticket, "SRT-0000001.parameters.json": params} {
+		want, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s was not returned as served:\n%s", name, got)
+		}
+	}
+	for _, auth := range api.auth {
+		if auth != "Bearer secret" {
+			t.Errorf("request sent with Authorization %q", auth)
+		}
+	}
+}
+
+func TestFetchTicketErrors(t *testing.T) {
+	startTicketAPI(t, map[string]int{
+		"SRT-0000401": http.StatusUnauthorized,
+		"SRT-0000403": http.StatusForbidden,
+		"SRT-0000500": http.StatusInternalServerError,
+	})
+
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"SRT-0000404", "ticket SRT-0000404 not found"},
+		{"SRT-0000401", "access to ticket SRT-0000401 denied (status 401)"},
+		{"SRT-0000403", "access to ticket SRT-0000403 denied (status 403)"},
+		{"SRT-0000500", "ticket API returned status 500 for SRT-0000500: ticket API error"},
+		{"SRT-0000002", "ticket API returned invalid JSON for SRT-0000002"},
+		{"INC-1", "invalid SRT number: SRT-INC-1"},
+	}
+	for _, tt := range tests {
+		_, _, err := FetchTicket(tt.number)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("FetchTicket(%s) error = %v, want %q", tt.number, err, tt.want)
+		}
+	}
+}
+
+func TestFetchTicketDisabled(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.Enabled = false
+	if _, _, err := FetchTicket("SRT-0000001"); err == nil {
+		t.Error("ticket fetched with the connector disabled")
+	}
+}
+
+func TestDeliverClosingUpdates(t *testing.T) {
+	api := startTicketAPI(t, nil)
+
+	updates := ClosingUpdates("SRT-0000001", "Ресурсы созданы", "INSERT 0 2")
+	for _, update := range updates {
+		if err := Deliver("0000001", update); err != nil {
+			t.Fatalf("%s: %v", update.Kind, err)
+		}
+	}
+
+	want := []Update{
+		{Kind: UpdateComment, Text: "Ресурсы созданы"},
+		{Kind: UpdateAttachment, FileName: "SRT-0000001_db_result.txt", Text: "INSERT 0 2"},
+		{Kind: UpdateStatus, Status: "resolved"},
+	}
+	if len(api.updates) != len(want) {
+		t.Fatalf("received %d updates, want %d", len(api.updates), len(want))
+	}
+	for i, received := range api.updates {
+		if received.Number != "SRT-0000001" || received.Update != want[i] {
+			t.Errorf("update %d = %+v, want %+v", i, received, want[i])
+		}
+	}
+}
+
+func TestDeliverErrors(t *testing.T) {
+	startTicketAPI(t, map[string]int{"SRT-0000500": http.StatusBadGateway})
+
+	err := Deliver("SRT-0000500", Update{Kind: UpdateComment, Text: "text"})
+	if err == nil || !strings.Contains(err.Error(), "ticket API returned status 502 for SRT-0000500 comment") {
+		t.Errorf("error = %v", err)
+	}
+	if err := Deliver("SRT-0000404", Update{Kind: UpdateStatus, Status: "resolved"}); err == nil {
+		t.Error("update of an unknown ticket succeeded")
+	}
+	if err := Deliver("SRT-0000001", Update{Kind: "reopen"}); err == nil {
+		t.Error("unknown update kind was sent")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.MaxAttempts = 3
+	config.RetrySeconds = 60
+
+	for attempts, want := range map[int]string{1: "1m0s", 2: "2m0s"} {
+		delay, final := RetryDelay(attempts)
+		if final || delay.String() != want {
+			t.Errorf("RetryDelay(%d) = %s, %v, want %s", attempts, delay, final, want)
+		}
+	}
+	if _, final := RetryDelay(3); !final {
+		t.Error("update is retried after max_attempts")
+	}
+}
@@ -0,0 +1,128 @@
+package srt_connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kinds of ticket updates
+const (
+	UpdateComment    = "comment"
+	UpdateAttachment = "attachment"
+	UpdateStatus     = "status"
+)
+
+// Update is a change written back to a ticket. Comments use Text,
+// attachments FileName and Text as the file content, status changes Status.
+type Update struct {
+	Kind     string `json:"kind"`
+	Text     string `json:"text,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	Status   string `json:"status,omitempty"`
+}
+
+// WriteBackEnabled reports whether results are written back to tickets
+func WriteBackEnabled() bool {
+	return config.Enabled && config.WriteBack
+}
+
+// ClosingUpdates returns the updates closing a ticket after a push to the DB:
+// the closing comment, the DB result as an attachment and the closing status
+func ClosingUpdates(number, comment, dbResult string) []Update {
+	return []Update{
+		{Kind: UpdateComment, Text: comment},
+		{Kind: UpdateAttachment, FileName: number + "_db_result.txt", Text: dbResult},
+		{Kind: UpdateStatus, Status: config.ClosingStatus},
+	}
+}
+
+// RetryDelay returns the delay before the next attempt after the given number
+// of failed attempts, and whether the update should be given up
+func RetryDelay(attempts int) (time.Duration, bool) {
+	if attempts >= config.MaxAttempts {
+		return 0, true
+	}
+	return time.Duration(config.RetrySeconds*attempts) * time.Second, false
+}
+
+// Deliver writes an update to a ticket. Comments are posted to
+// <base_url>/<number>/comments, attachments to <base_url>/<number>/attachments
+// and status changes to <base_url>/<number>/status.
+func Deliver(number string, update Update) error {
+	if !config.Enabled {
+		return fmt.Errorf("srt connector is not enabled")
+	}
+	number, err := NormalizeNumber(number)
+	if err != nil {
+		return err
+	}
+	base := strings.TrimRight(config.BaseURL, "/") + "/" + number
+
+	var req *http.Request
+	switch update.Kind {
+	case UpdateComment:
+		req, err = jsonRequest(base+"/comments", map[string]string{"text": update.Text})
+	case UpdateStatus:
+		req, err = jsonRequest(base+"/status", map[string]string{"status": update.Status})
+	case UpdateAttachment:
+		req, err = fileRequest(base+"/attachments", update.FileName, update.Text)
+	default:
+		return fmt.Errorf("unknown update kind: %s", update.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s to %s: %v", update.Kind, number, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return fmt.Errorf("ticket API returned status %d for %s %s: %s", resp.StatusCode, number, update.Kind, snippet(body))
+	}
+	return nil
+}
+
+func jsonRequest(url string, body interface{}) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func fileRequest(url, fileName, content string) (*http.Request, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write([]byte(content)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req, nil
+}
@@ -0,0 +1,228 @@
+package table_export
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/NarrativeBias/zayavki/postgresql_operations"
+	"github.com/xuri/excelize/v2"
+)
+
+// Export formats
+const (
+	CSV  = "csv"
+	XLSX = "xlsx"
+)
+
+// Table is a sheet of an export. Cells are written as they are, so numbers
+// stay numbers in XLSX.
+type Table struct {
+	Name    string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Options select the format of an export. Comma is the CSV separator;
+// Excel in the Russian locale expects semicolons.
+type Options struct {
+	Format string
+	Comma  rune
+}
+
+// ParseOptions reads the format and delimiter parameters of an export
+// request. The format defaults to XLSX and the delimiter to a comma.
+func ParseOptions(format, delimiter string) (Options, error) {
+	options := Options{Format: strings.ToLower(format), Comma: ','}
+	if options.Format == "" {
+		options.Format = XLSX
+	}
+	if options.Format != CSV && options.Format != XLSX {
+		return options, fmt.Errorf("unknown export format: %s (expected csv or xlsx)", format)
+	}
+	switch strings.ToLower(delimiter) {
+	case "", "comma", ",":
+	case "semicolon", ";":
+		options.Comma = ';'
+	default:
+		return options, fmt.Errorf("unknown delimiter: %s (expected comma or semicolon)", delimiter)
+	}
+	return options, nil
+}
+
+// ContentType returns the MIME type of the format
+func (o Options) ContentType() string {
+	if o.Format == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+
+// FileName returns a file name for an export of base made now
+func (o Options) FileName(base string) string {
+	return fmt.Sprintf("%s_%s.%s", base, time.Now().Format("20060102_150405"), o.Format)
+}
+
+// Write writes the tables in the format of the options. A CSV file holds
+// the tables one after another, each after its name when there are several.
+func Write(w io.Writer, options Options, tables ...Table) error {
+	if options.Format == CSV {
+		return writeCSV(w, options.Comma, tables)
+	}
+	return writeXLSX(w, tables)
+}
+
+func writeCSV(w io.Writer, comma rune, tables []Table) error {
+	// The BOM makes Excel read the file as UTF-8
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return fmt.Errorf("error writing CSV: %v", err)
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	writer.UseCRLF = true
+	for i, table := range tables {
+		if len(tables) > 1 {
+			if i > 0 {
+				writer.Write(nil)
+			}
+			writer.Write([]string{table.Name})
+		}
+		writer.Write(table.Columns)
+		for _, row := range table.Rows {
+			record := make([]string, len(row))
+			for j, cell := range row {
+				record[j] = formatCell(cell)
+			}
+			writer.Write(record)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing CSV: %v", err)
+	}
+	return nil
+}
+
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeXLSX writes each table on its own sheet, streaming the rows so large
+// exports do not keep every cell in memory twice
+func writeXLSX(w io.Writer, tables []Table) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return fmt.Errorf("error creating header style: %v", err)
+	}
+
+	for i, table := range tables {
+		sheet := sheetName(table.Name, i)
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+				return fmt.Errorf("error naming sheet: %v", err)
+			}
+		} else if _, err := f.NewSheet(sheet); err != nil {
+			return fmt.Errorf("error creating sheet: %v", err)
+		}
+
+		stream, err := f.NewStreamWriter(sheet)
+		if err != nil {
+			return fmt.Errorf("error creating sheet writer: %v", err)
+		}
+		if len(table.Columns) > 0 {
+			if err := stream.SetColWidth(1, len(table.Columns), 18); err != nil {
+				return fmt.Errorf("error setting column width: %v", err)
+			}
+		}
+		if err := stream.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+			return fmt.Errorf("error freezing header: %v", err)
+		}
+
+		header := make([]interface{}, len(table.Columns))
+		for j, column := range table.Columns {
+			header[j] = excelize.Cell{StyleID: headerStyle, Value: column}
+		}
+		if err := stream.SetRow("A1", header); err != nil {
+			return fmt.Errorf("error writing header: %v", err)
+		}
+		for j, row := range table.Rows {
+			cell, _ := excelize.CoordinatesToCellName(1, j+2)
+			if err := stream.SetRow(cell, row); err != nil {
+				return fmt.Errorf("error writing row %d: %v", j+2, err)
+			}
+		}
+		if err := stream.Flush(); err != nil {
+			return fmt.Errorf("error writing sheet: %v", err)
+		}
+	}
+
+	if err := f.Write(w); err != nil {
+		return fmt.Errorf("error writing XLSX: %v", err)
+	}
+	return nil
+}
+
+// sheetName makes a valid and unique sheet name of a table name
+func sheetName(name string, index int) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet"
+	}
+	if index > 0 {
+		name = fmt.Sprintf("%d %s", index+1, name)
+	}
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
+
+// RegistryColumns are the columns of every export of registry rows
+var RegistryColumns = []string{
+	"Active", "Cluster", "Segment", "Environment", "Realm", "Tenant", "User", "Bucket",
+	"Quota", "Quota bytes", "Max objects", "Quota enabled", "Versioning", "Object lock", "Lifecycle expire",
+	"SD", "SRT", "Date", "RIS Code", "RIS ID", "Owner Group", "Owner", "Applicant", "Email", "Comment",
+}
+
+// RegistryTable returns registry rows as a table with RegistryColumns
+func RegistryTable(name string, results []postgresql_operations.CheckResult) Table {
+	table := Table{Name: name, Columns: RegistryColumns, Rows: make([][]interface{}, 0, len(results))}
+	for _, r := range results {
+		var quotaBytes interface{}
+		if r.QuotaBytes.Valid {
+			quotaBytes = r.QuotaBytes.Int64
+		}
+		table.Rows = append(table.Rows, []interface{}{
+			r.Active, r.ClsName, r.NetSeg, r.Env, r.Realm, r.Tenant, nullString(r.S3User), nullString(r.Bucket),
+			nullString(r.Quota), quotaBytes, r.MaxObjects, r.QuotaEnabled, r.Versioning, r.ObjectLock, r.Expire,
+			r.SdNum, r.SrtNum, r.DoneDate, r.RisCode, r.RisId, r.OwnerGroup, r.OwnerPerson, r.Applicant,
+			nullString(r.Email), nullString(r.CsppComment),
+		})
+	}
+	return table
+}
+
+func nullString(value sql.NullString) string {
+	if !value.Valid {
+		return "-"
+	}
+	return value.String
+}
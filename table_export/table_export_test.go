@@ -0,0 +1,126 @@
+package table_export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		format, delimiter string
+		want              Options
+	}{
+		{"", "", Options{Format: XLSX, Comma: ','}},
+		{"CSV", "", Options{Format: CSV, Comma: ','}},
+		{"csv", "semicolon", Options{Format: CSV, Comma: ';'}},
+		{"csv", ";", Options{Format: CSV, Comma: ';'}},
+		{"csv", "Comma", Options{Format: CSV, Comma: ','}},
+	}
+	for _, tt := range tests {
+		got, err := ParseOptions(tt.format, tt.delimiter)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseOptions(%q, %q) = %+v, %v, want %+v", tt.format, tt.delimiter, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"pdf", ""}, {"csv", "tab"}, {"csv", "|"}} {
+		if _, err := ParseOptions(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseOptions(%q, %q) accepted", bad[0], bad[1])
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	table := Table{
+		Name:    "Бакеты",
+		Columns: []string{"Тенант", "Квота", "Комментарий"},
+		Rows: [][]interface{}{
+			{"tenant-a", 1.5, "a;b"},
+			{"tenant-b", nil, `say "hi"`},
+		},
+	}
+
+	for _, comma := range []rune{',', ';'} {
+		var buf bytes.Buffer
+		if err := Write(&buf, Options{Format: CSV, Comma: comma}, table); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, "\ufeff") {
+			t.Fatalf("comma %q: output does not start with a BOM: %q", comma, out)
+		}
+		if !strings.Contains(out, "\r\n") {
+			t.Errorf("comma %q: rows are not CRLF terminated: %q", comma, out)
+		}
+
+		reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, "\ufeff")))
+		reader.Comma = comma
+		records, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("comma %q: %v", comma, err)
+		}
+		want := [][]string{
+			{"Тенант", "Квота", "Комментарий"},
+			{"tenant-a", "1.5", "a;b"},
+			{"tenant-b", "", `say "hi"`},
+		}
+		if !reflect.DeepEqual(records, want) {
+			t.Errorf("comma %q: records = %q, want %q", comma, records, want)
+		}
+	}
+}
+
+func TestWriteCSVSeveralTables(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Options{Format: CSV, Comma: ';'},
+		Table{Name: "first", Columns: []string{"a"}, Rows: [][]interface{}{{1}}},
+		Table{Name: "second", Columns: []string{"b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\ufefffirst\r\na\r\n1\r\n\r\nsecond\r\nb\r\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Options{Format: XLSX},
+		Table{Name: "usage/by:ris", Columns: []string{"RIS", "Bytes"}, Rows: [][]interface{}{{"1234", 2048}}},
+		Table{Name: "second", Columns: []string{"b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if sheets := f.GetSheetList(); !reflect.DeepEqual(sheets, []string{"usage_by_ris", "2 second"}) {
+		t.Errorf("sheets = %q", sheets)
+	}
+	rows, err := f.GetRows("usage_by_ris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"RIS", "Bytes"}, {"1234", "2048"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	if got := sheetName("", 0); got != "Sheet" {
+		t.Errorf("sheetName(\"\", 0) = %q", got)
+	}
+	long := strings.Repeat("я", 40)
+	if got := sheetName(long, 2); len([]rune(got)) != 31 || !strings.HasPrefix(got, "3 ") {
+		t.Errorf("sheetName(long, 2) = %q", got)
+	}
+}
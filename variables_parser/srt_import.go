@@ -0,0 +1,291 @@
+package variables_parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SRTTicket is the part of an SRT ticket JSON used to fill in a request
+type SRTTicket struct {
+	Number             string           `json:"number"`
+	RequestDetails     string           `json:"requestDetails"`
+	CustomFieldsValues []SRTCustomField `json:"customFieldsValues"`
+}
+
+// SRTCustomField is a custom field of an SRT ticket
+type SRTCustomField struct {
+	Code  string          `json:"code"`
+	Value json.RawMessage `json:"value"`
+}
+
+// SRTParam is an entry of the SRT parameters JSON
+type SRTParam struct {
+	Label string          `json:"label"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Diagnostic statuses of imported fields
+const (
+	FieldOK      = "ok"
+	FieldMissing = "missing"
+	FieldInvalid = "invalid"
+)
+
+// FieldDiagnostic reports how a form field was filled from the ticket
+type FieldDiagnostic struct {
+	Field   string `json:"field"`
+	Label   string `json:"label"`
+	Status  string `json:"status"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// SRTImport is the result of importing an SRT ticket
+type SRTImport struct {
+	// Fields are the form values, as the new tenant form submits them
+	Fields map[string]string `json:"fields"`
+	// Variables are the processed variables, nil when the fields do not pass
+	// processing
+	Variables      map[string][]string `json:"variables,omitempty"`
+	Diagnostics    []FieldDiagnostic   `json:"diagnostics"`
+	RequestDetails string              `json:"request_details,omitempty"`
+}
+
+// importedFields lists the form fields filled from a ticket with their labels
+var importedFields = []struct{ id, label string }{
+	{"request_id_srt", "Номер SRT"},
+	{"request_id_sd", "Номер SD"},
+	{"requester", "Заявитель"},
+	{"segment", "Сегмент"},
+	{"env", "Среда"},
+	{"ris_number", "РИС номер"},
+	{"ris_name", "РИС имя"},
+	{"resp_group", "Группа сопровождения"},
+	{"owner", "Владелец"},
+	{"zam_owner", "Зам. владельца"},
+	{"email_for_credentials", "Email для учетных данных"},
+	{"buckets", "Бакеты"},
+	{"users", "Пользователи"},
+}
+
+// paramFields maps SRT parameter labels to form fields
+var paramFields = map[string]string{
+	"Электронная почта с поддержкой шифрования для отправки учетных данных": "email_for_credentials",
+	"Зона безопасности (выделенный кластер)":                                "segment",
+	"Зона безопасности (коммунальный кластер)":                              "segment",
+	"Рабочая группа сопровождения ИС: название":                             "resp_group",
+	"Основной владелец бакета: email":                                       "owner",
+	"Замещающий владелец бакета: email":                                     "zam_owner",
+	"Среда": "env",
+	"Номер РИС и идентификационный код": "ris",
+}
+
+var risPattern = regexp.MustCompile(`^(\d{2,5})\s+([A-Za-z]{3,5})$`)
+
+// ImportSRT fills in the new tenant form from the SRT ticket JSON and the SRT
+// parameters JSON. Either may be empty. Problems with single fields are
+// reported in the diagnostics, only malformed JSON is an error.
+func ImportSRT(ticketJSON, paramsJSON []byte) (*SRTImport, error) {
+	result := &SRTImport{Fields: map[string]string{}}
+	problems := map[string]string{}
+
+	if len(bytes.TrimSpace(ticketJSON)) > 0 {
+		var ticket SRTTicket
+		if err := json.Unmarshal(ticketJSON, &ticket); err != nil {
+			return nil, fmt.Errorf("failed to parse SRT JSON: %v", err)
+		}
+		importTicket(ticket, result.Fields)
+		result.RequestDetails = ticket.RequestDetails
+	}
+
+	if len(bytes.TrimSpace(paramsJSON)) > 0 {
+		params, err := parseParams(paramsJSON)
+		if err != nil {
+			return nil, err
+		}
+		importParams(params, result.Fields, problems)
+	}
+
+	if buckets := result.Fields["buckets"]; buckets != "" {
+		if _, err := ParseBucketLines(buckets, result.Fields["env"]); err != nil {
+			problems["buckets"] = err.Error()
+		}
+	}
+
+	for _, field := range importedFields {
+		diagnostic := FieldDiagnostic{Field: field.id, Label: field.label, Value: result.Fields[field.id]}
+		switch {
+		case problems[field.id] != "":
+			diagnostic.Status = FieldInvalid
+			diagnostic.Message = problems[field.id]
+		case diagnostic.Value == "":
+			diagnostic.Status = FieldMissing
+		default:
+			diagnostic.Status = FieldOK
+		}
+		result.Diagnostics = append(result.Diagnostics, diagnostic)
+	}
+
+	raw := map[string][]string{"create_tenant": {"true"}}
+	for field, value := range result.Fields {
+		raw[field] = []string{value}
+	}
+	if variables, err := ParseAndProcessVariables(raw); err == nil {
+		result.Variables = variables
+	}
+	return result, nil
+}
+
+func importTicket(ticket SRTTicket, fields map[string]string) {
+	custom := map[string]string{}
+	for _, field := range ticket.CustomFieldsValues {
+		custom[field.Code] = rawString(field.Value)
+	}
+
+	fields["request_id_srt"] = strings.ToUpper(strings.TrimSpace(ticket.Number))
+	fields["request_id_sd"] = strings.ToUpper(custom["appealNumber"])
+	fields["requester"] = custom["applicant"]
+
+	if ticket.RequestDetails == "" {
+		return
+	}
+	details := strings.ReplaceAll(ticket.RequestDetails, "\r\n", "\n")
+
+	// Buckets are "name | quota in GB" lines, newer tickets add an ID column
+	var buckets []string
+	for _, line := range detailsSection(details, "Список бакетов", "Имя бакета") {
+		parts := strings.Split(line, "|")
+		if len(parts) >= 2 {
+			line = strings.TrimSpace(parts[0]) + " | " + strings.TrimSpace(parts[1])
+		}
+		buckets = append(buckets, line)
+	}
+	fields["buckets"] = strings.Join(buckets, "\n")
+
+	// Users are names, newer tickets add an ID column
+	var users []string
+	for _, line := range detailsSection(details, "Список дополнительных учетных записей", "Имя дополнительной учетной записи") {
+		name, _, _ := strings.Cut(line, "|")
+		users = append(users, strings.TrimSpace(name))
+	}
+	fields["users"] = strings.Join(users, "\n")
+}
+
+// detailsSection returns the lines of a requestDetails table. The table
+// starts after the title and header lines and ends at an empty line or the
+// next list.
+func detailsSection(details, title, header string) []string {
+	lines := strings.Split(details, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.Contains(strings.ToLower(line), strings.ToLower(title)) {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	var rows []string
+	inTable := false
+	for _, line := range lines[start:] {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.Contains(line, header):
+			inTable = true
+		case !inTable:
+			continue
+		case line == "" || strings.HasPrefix(line, "Список"):
+			return rows
+		default:
+			rows = append(rows, line)
+		}
+	}
+	return rows
+}
+
+// parseParams accepts the parameters as an array or wrapped in an object
+func parseParams(paramsJSON []byte) ([]SRTParam, error) {
+	var params []SRTParam
+	if err := json.Unmarshal(paramsJSON, &params); err == nil {
+		return params, nil
+	}
+
+	var wrapped struct {
+		Params     []SRTParam `json:"params"`
+		Parameters []SRTParam `json:"parameters"`
+	}
+	if err := json.Unmarshal(paramsJSON, &wrapped); err != nil {
+		return nil, fmt.Errorf("failed to parse SRT parameters JSON: %v", err)
+	}
+	if wrapped.Params != nil {
+		return wrapped.Params, nil
+	}
+	return wrapped.Parameters, nil
+}
+
+func importParams(params []SRTParam, fields map[string]string, problems map[string]string) {
+	for _, param := range params {
+		field, ok := paramFields[strings.TrimSpace(param.Label)]
+		if !ok {
+			continue
+		}
+		value := rawString(param.Value)
+
+		switch field {
+		case "env":
+			env, ok := envFromLabel(value)
+			if !ok {
+				problems["env"] = fmt.Sprintf("неизвестная среда '%s'", value)
+				continue
+			}
+			fields["env"] = env
+		case "ris":
+			parts := risPattern.FindStringSubmatch(value)
+			if parts == nil {
+				problems["ris_number"] = fmt.Sprintf("не удалось разобрать '%s' (ожидается: номер код)", value)
+				problems["ris_name"] = problems["ris_number"]
+				continue
+			}
+			fields["ris_number"] = parts[1]
+			fields["ris_name"] = strings.ToLower(parts[2])
+		default:
+			fields[field] = value
+		}
+	}
+}
+
+// envFromLabel maps the environment label of a ticket to an environment.
+// Preprod is checked before prod as its label contains the prod one.
+func envFromLabel(label string) (string, bool) {
+	value := strings.ToUpper(label)
+	switch {
+	case strings.Contains(value, "ХОТФИКС") || strings.Contains(value, "HF"):
+		return "HOTFIX", true
+	case strings.Contains(value, "ПРЕПРОД") || strings.Contains(value, "PP"):
+		return "PREPROD", true
+	case strings.Contains(value, "ИФТ"):
+		return "IFT", true
+	case value == "НТ" || value == "LT" || strings.Contains(value, "НАГРУЗ"):
+		return "LT", true
+	case strings.Contains(value, "ПРОД") || strings.Contains(value, "PROD"):
+		return "PROD", true
+	}
+	return "", false
+}
+
+// rawString returns a JSON string value, or the raw JSON of other values
+func rawString(raw json.RawMessage) string {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return strings.TrimSpace(value)
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return strings.TrimSpace(string(raw))
+}
@@ -0,0 +1,110 @@
+package variables_parser
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected results")
+
+// Every directory in testdata holds a ticket.json and a params.json export and
+// the expected import in want.json
+func TestImportSRTFixtures(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no fixtures in testdata")
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			ticket, err := os.ReadFile(filepath.Join(dir, "ticket.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			params, err := os.ReadFile(filepath.Join(dir, "params.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := ImportSRT(ticket, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(result, "", "    ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join(dir, "want.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("import differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestImportSRTMalformedJSON(t *testing.T) {
+	if _, err := ImportSRT([]byte(`{"number": `), nil); err == nil {
+		t.Error("malformed ticket JSON was accepted")
+	}
+	if _, err := ImportSRT(nil, []byte(`"Среда"`)); err == nil {
+		t.Error("malformed parameters JSON was accepted")
+	}
+}
+
+func TestImportSRTEmpty(t *testing.T) {
+	result, err := ImportSRT(nil, []byte("  "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Variables != nil {
+		t.Errorf("variables without an environment: %v", result.Variables)
+	}
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Status != FieldMissing {
+			t.Errorf("%s: status %s, want %s", diagnostic.Field, diagnostic.Status, FieldMissing)
+		}
+	}
+}
+
+func TestEnvFromLabel(t *testing.T) {
+	tests := map[string]string{
+		"ПРОД":            "PROD",
+		"Продуктивная":    "PROD",
+		"PROD":            "PROD",
+		"ПРЕПРОД":         "PREPROD",
+		"Препродуктивная": "PREPROD",
+		"PP":              "PREPROD",
+		"ИФТ":             "IFT",
+		"Хотфикс":         "HOTFIX",
+		"HF":              "HOTFIX",
+		"НТ":              "LT",
+		"lt":              "LT",
+		"Нагрузочное тестирование": "LT",
+	}
+	for label, want := range tests {
+		got, ok := envFromLabel(label)
+		if !ok || got != want {
+			t.Errorf("envFromLabel(%q) = %q, %v, want %q", label, got, ok, want)
+		}
+	}
+	for _, label := range []string{"", "Разработка", "НТ-2"} {
+		if env, ok := envFromLabel(label); ok {
+			t.Errorf("envFromLabel(%q) = %q, want no match", label, env)
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package variables_parser
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/NarrativeBias/zayavki/bucket_features"
+	"github.com/NarrativeBias/zayavki/quota"
 )
 
 func ParseAndProcessVariables(rawVariables map[string][]string) (map[string][]string, error) {
@@ -21,7 +22,7 @@ func ParseAndProcessVariables(rawVariables map[string][]string) (map[string][]st
 	variablesToProcess := []string{
 		"request_id_sd", "request_id_srt", "segment", "env", "ris_number", "ris_name",
 		"resp_group", "owner", "create_tenant", "tenant_override", "requester",
-		"email_for_credentials", "zam_owner",
+		"email_for_credentials", "zam_owner", "send_email", "email_lang",
 	}
 
 	for _, varName := range variablesToProcess {
@@ -30,7 +31,7 @@ func ParseAndProcessVariables(rawVariables map[string][]string) (map[string][]st
 		switch varName {
 		case "request_id_sd", "request_id_srt", "segment", "env":
 			processedValue = strings.ToUpper(rawValue)
-		case "ris_name", "create_tenant", "tenant_override":
+		case "ris_name", "create_tenant", "tenant_override", "send_email", "email_lang":
 			processedValue = strings.ToLower(rawValue)
 		case "email_for_credentials":
 			processedValue = strings.ToLower(rawValue)
@@ -51,12 +52,28 @@ func ParseAndProcessVariables(rawVariables map[string][]string) (map[string][]st
 	// Process buckets
 	bucketInput := getFirst(rawVariables["buckets"])
 	if bucketInput != "" {
-		bucketNames, bucketQuotas, err := parseBuckets(bucketInput)
+		bucketVars, err := ParseBucketLines(bucketInput, processedVars["env"][0])
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse buckets: %v", err)
 		}
-		processedVars["bucketnames"] = bucketNames
-		processedVars["bucketquotas"] = bucketQuotas
+		for key, values := range bucketVars {
+			processedVars[key] = values
+		}
+	}
+
+	// Process user-scope quotas
+	userQuotaInput := getFirst(rawVariables["user_quotas"])
+	if userQuotaInput != "" {
+		quotaUsers, quotaSizes, quotaObjects, err := ParseQuotaLines(userQuotaInput, processedVars["env"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse user quotas: %v", err)
+		}
+		for i := range quotaUsers {
+			quotaUsers[i] = strings.ToLower(quotaUsers[i])
+		}
+		processedVars["quotausers"] = quotaUsers
+		processedVars["quotausersizes"] = quotaSizes
+		processedVars["quotauserobjects"] = quotaObjects
 	}
 
 	// Process environment code
@@ -83,44 +100,66 @@ func ParseAndProcessVariables(rawVariables map[string][]string) (map[string][]st
 	return processedVars, nil
 }
 
-type SRTData struct {
-	RequestID string `json:"request_id"`
-	Segment   string `json:"segment"`
-	Env       string `json:"env"`
-	RisNumber string `json:"ris_number"`
-	RisName   string `json:"ris_name"`
-	// Add other fields as needed
-}
-
-func LoadFromJSON(url string) (map[string]interface{}, error) {
-	// Make HTTP GET request
-	resp, err := http.Get(url)
+// ParseQuotaLines parses lines in the "name | quota | objects=N" format used
+// for user quotas. Quotas are checked against the limits of env and returned
+// in canonical form. The objects option is optional and is returned as "-"
+// when absent.
+func ParseQuotaLines(input, env string) ([]string, []string, []string, error) {
+	var names, quotas, objects []string
+	err := parseQuotaLines(input, env, func(name, quota, maxObjects string, options []string) error {
+		if len(options) > 0 {
+			return fmt.Errorf("unknown option for %s: %s", name, options[0])
+		}
+		names = append(names, name)
+		quotas = append(quotas, quota)
+		objects = append(objects, maxObjects)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch JSON: %v", err)
+		return nil, nil, nil, err
 	}
-	defer resp.Body.Close()
+	return names, quotas, objects, nil
+}
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+// ParseBucketLines parses bucket lines in the "name | quota | options" format.
+// Besides objects=N, buckets accept the versioning, lock=MODE:days and
+// expire=days options, which are validated against the rules of env.
+func ParseBucketLines(input, env string) (map[string][]string, error) {
+	vars := map[string][]string{}
+	err := parseQuotaLines(input, env, func(name, quota, maxObjects string, options []string) error {
+		var features bucket_features.Features
+		for _, option := range options {
+			known, err := features.ParseOption(option)
+			if err != nil {
+				return fmt.Errorf("invalid option for %s: %v", name, err)
+			}
+			if !known {
+				return fmt.Errorf("unknown option for %s: %s", name, option)
+			}
+		}
+		if err := features.Validate(env); err != nil {
+			return fmt.Errorf("invalid options for %s: %v", name, err)
+		}
+		vars["bucketnames"] = append(vars["bucketnames"], name)
+		vars["bucketquotas"] = append(vars["bucketquotas"], quota)
+		vars["bucketobjects"] = append(vars["bucketobjects"], maxObjects)
+		vars["bucketversioning"] = append(vars["bucketversioning"], strconv.FormatBool(features.Versioning))
+		vars["bucketlock"] = append(vars["bucketlock"], features.Lock())
+		vars["bucketexpire"] = append(vars["bucketexpire"], features.Expire())
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %v", err)
-	}
-
-	// Parse JSON into a generic map
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %v", err)
+		return nil, err
 	}
-
-	return data, nil
+	return vars, nil
 }
 
-func parseBuckets(bucketStr string) ([]string, []string, error) {
-	var bucketNames []string
-	var bucketQuotas []string
-
+// parseQuotaLines splits "name | quota | options" lines and passes the
+// canonical quota, the object limit and the remaining options of each line
+// to handle.
+func parseQuotaLines(input, env string, handle func(name, quota, maxObjects string, options []string) error) error {
 	// Split into lines and process each line
-	lines := strings.Split(bucketStr, "\n")
+	lines := strings.Split(input, "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" {
@@ -129,21 +168,45 @@ func parseBuckets(bucketStr string) ([]string, []string, error) {
 
 		// Split by pipe and trim spaces
 		parts := strings.Split(line, "|")
-		if len(parts) != 2 {
-			return nil, nil, fmt.Errorf("invalid bucket format: %s (expected: name | quota)", line)
+		if len(parts) < 2 {
+			return fmt.Errorf("invalid format: %s (expected: name | quota [| objects=N])", line)
 		}
 
-		bucketName := strings.TrimSpace(parts[0])
-		quota := strings.TrimSpace(parts[1])
+		name := strings.TrimSpace(parts[0])
+		size := strings.TrimSpace(parts[1])
 
-		// Validate bucket name and quota
-		if bucketName == "" || quota == "" {
-			return nil, nil, fmt.Errorf("bucket name and quota cannot be empty")
+		// Validate name and quota
+		if name == "" || size == "" {
+			return fmt.Errorf("name and quota cannot be empty")
+		}
+		parsed, err := quota.ParseForEnv(size, env)
+		if err != nil {
+			return fmt.Errorf("invalid quota for %s: %v", name, err)
 		}
 
-		bucketNames = append(bucketNames, bucketName)
-		bucketQuotas = append(bucketQuotas, quota)
+		maxObjects := "-"
+		var options []string
+		for _, option := range parts[2:] {
+			option = strings.TrimSpace(option)
+			key, value, _ := strings.Cut(option, "=")
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "objects":
+				value = strings.TrimSpace(value)
+				if n, err := strconv.ParseInt(value, 10, 64); err != nil || n <= 0 {
+					return fmt.Errorf("invalid object limit for %s: %s", name, value)
+				}
+				maxObjects = value
+			case "":
+				continue
+			default:
+				options = append(options, option)
+			}
+		}
+
+		if err := handle(name, parsed.String(), maxObjects, options); err != nil {
+			return err
+		}
 	}
 
-	return bucketNames, bucketQuotas, nil
+	return nil
 }
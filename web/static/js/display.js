@@ -33,12 +33,22 @@ function displayCheckResults(data) {
         )
     ));
 
+    if (data.tenant.name) {
+        const exportButton = document.createElement('button');
+        exportButton.type = 'button';
+        exportButton.className = 'copy-button';
+        exportButton.textContent = 'Экспорт XLSX';
+        exportButton.onclick = () => downloadExport(`/zayavki/export/tenant?${new URLSearchParams({ tenant: data.tenant.name })}`)
+            .catch(error => displayResult(`Ошибка экспорта: ${error.message}`));
+        container.appendChild(exportButton);
+    }
+
     // Users section
     if (data.users && data.users.length > 0) {
         container.appendChild(createSection('Пользователи',
             createTable(
-                ['Пользователь', 'Статус'],
-                data.users.map(user => [user.name, user.status])
+                ['Пользователь', 'Квота', 'Лимит объектов', 'Квота включена', 'Статус'],
+                data.users.map(user => [user.name, user.quota, user.max_objects, user.quota_enabled, user.status])
             )
         ));
     }
@@ -47,8 +57,8 @@ function displayCheckResults(data) {
     if (data.buckets && data.buckets.length > 0) {
         container.appendChild(createSection('Бакеты',
             createTable(
-                ['Бакет', 'Размер', 'Статус'],
-                data.buckets.map(bucket => [bucket.name, bucket.size, bucket.status])
+                ['Бакет', 'Размер', 'Лимит объектов', 'Квота включена', 'Функции', 'Статус'],
+                data.buckets.map(bucket => [bucket.name, bucket.size, bucket.max_objects, bucket.quota_enabled, bucket.features, bucket.status])
             )
         ));
     }
@@ -59,76 +69,7 @@ function displayCheckResults(data) {
                             data.deletion_commands ? 'Команды для удаления' :
                             'Команды';
         const commands = data.creation_commands || data.deletion_commands || data.commands;
-        
-        // Create commands section container
-        const commandsSection = document.createElement('div');
-        commandsSection.className = 'commands-section';
-                // Create header with title and copy button
-                const commandsHeader = document.createElement('div');
-                commandsHeader.className = 'commands-header';
-                
-                const titleElement = document.createElement('h3');
-                titleElement.className = 'commands-title';
-                titleElement.textContent = commandsTitle;
-                
-        const pre = document.createElement('pre');
-        pre.className = 'command-block';
-        pre.textContent = commands;
-        
-        // Add copy button for tenant-mod and bucket-mod tabs
-        const activeTab = document.querySelector('.tab-pane.active');
-        if (activeTab && (activeTab.id === 'tenant-mod' || activeTab.id === 'bucket-mod')) {
-            const copyButton = document.createElement('button');
-            copyButton.className = 'copy-button';
-            copyButton.textContent = 'Копировать';
-            copyButton.onclick = () => {
-                navigator.clipboard.writeText(commands).then(() => {
-                    // Temporarily change button text to show success
-                    const originalText = copyButton.textContent;
-                    copyButton.textContent = 'Скопировано!';
-                    copyButton.style.backgroundColor = '#28a745';
-                    copyButton.disabled = true;
-                    
-                    setTimeout(() => {
-                        copyButton.textContent = originalText;
-                        copyButton.style.backgroundColor = '';
-                        copyButton.disabled = false;
-                    }, 2000);
-                }).catch(err => {
-                    console.error('Failed to copy: ', err);
-                    // Fallback for older browsers
-                    const textArea = document.createElement('textarea');
-                    textArea.value = commands;
-                    document.body.appendChild(textArea);
-                    textArea.select();
-                    document.execCommand('copy');
-                    document.body.removeChild(textArea);
-                    
-                    // Show success message
-                    const originalText = copyButton.textContent;
-                    copyButton.textContent = 'Скопировано!';
-                    copyButton.style.backgroundColor = '#28a745';
-                    copyButton.disabled = true;
-                    
-                    setTimeout(() => {
-                        copyButton.textContent = originalText;
-                        copyButton.style.backgroundColor = '';
-                        copyButton.disabled = false;
-                    }, 2000);
-                });
-            };
-            
-            commandsHeader.appendChild(titleElement);
-            commandsHeader.appendChild(copyButton);
-        } else {
-            // No copy button for other tabs
-            commandsHeader.appendChild(titleElement);
-        }
-        
-        // Assemble the section
-        commandsSection.appendChild(commandsHeader);
-        commandsSection.appendChild(pre);
-        container.appendChild(createSection(commandsTitle, commandsSection));
+        container.appendChild(createCommandsSection(commandsTitle, commands));
     }
 
     const resultDiv = document.getElementById('result');
@@ -136,6 +77,79 @@ function displayCheckResults(data) {
     resultDiv.appendChild(container);
 }
 
+function createCommandsSection(commandsTitle, commands) {
+    // Create commands section container
+    const commandsSection = document.createElement('div');
+    commandsSection.className = 'commands-section';
+
+    // Create header with title and copy button
+    const commandsHeader = document.createElement('div');
+    commandsHeader.className = 'commands-header';
+
+    const titleElement = document.createElement('h3');
+    titleElement.className = 'commands-title';
+    titleElement.textContent = commandsTitle;
+
+    const pre = document.createElement('pre');
+    pre.className = 'command-block';
+    pre.textContent = commands;
+    
+    // Add copy button for tabs that prepare commands to run
+    const activeTab = document.querySelector('.tab-pane.active');
+    if (activeTab && ['tenant-mod', 'bucket-mod', 'bucket-policy', 'access-keys', 'batch'].includes(activeTab.id)) {
+        const copyButton = document.createElement('button');
+        copyButton.className = 'copy-button';
+        copyButton.textContent = 'Копировать';
+        copyButton.onclick = () => {
+            navigator.clipboard.writeText(commands).then(() => {
+                // Temporarily change button text to show success
+                const originalText = copyButton.textContent;
+                copyButton.textContent = 'Скопировано!';
+                copyButton.style.backgroundColor = '#28a745';
+                copyButton.disabled = true;
+                
+                setTimeout(() => {
+                    copyButton.textContent = originalText;
+                    copyButton.style.backgroundColor = '';
+                    copyButton.disabled = false;
+                }, 2000);
+            }).catch(err => {
+                console.error('Failed to copy: ', err);
+                // Fallback for older browsers
+                const textArea = document.createElement('textarea');
+                textArea.value = commands;
+                document.body.appendChild(textArea);
+                textArea.select();
+                document.execCommand('copy');
+                document.body.removeChild(textArea);
+                
+                // Show success message
+                const originalText = copyButton.textContent;
+                copyButton.textContent = 'Скопировано!';
+                copyButton.style.backgroundColor = '#28a745';
+                copyButton.disabled = true;
+                
+                setTimeout(() => {
+                    copyButton.textContent = originalText;
+                    copyButton.style.backgroundColor = '';
+                    copyButton.disabled = false;
+                }, 2000);
+            });
+        };
+        
+        commandsHeader.appendChild(titleElement);
+        commandsHeader.appendChild(copyButton);
+    } else {
+        // No copy button for other tabs
+        commandsHeader.appendChild(titleElement);
+    }
+    
+    // Assemble the section
+    commandsSection.appendChild(commandsHeader);
+    commandsSection.appendChild(pre);
+    return createSection(commandsTitle, commandsSection);
+}
+
 function displayDeactivationResults(result) {
     const container = document.createElement('div');
     container.className = 'table-container';
@@ -268,65 +282,78 @@ function displayDeactivationResults(result) {
         container.appendChild(createSection('Ошибки', errorList));
     }
 
+    if (result.email) {
+        container.appendChild(createEmailSection(result.email));
+    }
+
     const resultDiv = document.getElementById('result');
     resultDiv.innerHTML = '';
     resultDiv.appendChild(container);
 }
 
-function convertTableToCSV(table) {
-    const rows = table.querySelectorAll('tr');
-    const csvRows = [];
-    
-    rows.forEach(row => {
-        const cells = row.querySelectorAll('td, th');
-        const cellTexts = Array.from(cells).map(cell => {
-            // Escape quotes and wrap in quotes if contains comma, quote, or newline
-            let text = cell.textContent.trim();
-            if (text.includes(',') || text.includes('"') || text.includes('\n')) {
-                text = '"' + text.replace(/"/g, '""') + '"';
-            }
-            return text;
-        });
-        csvRows.push(cellTexts.join(','));
-    });
-    
-    return csvRows.join('\n');
+// createEmailSection shows the subject and text of a customer notification
+function createEmailSection(email) {
+    const pre = document.createElement('pre');
+    pre.className = 'command-block';
+    pre.textContent = `Тема: ${email.subject}\n${email.text}`;
+    return createSection('Шаблон письма для заявителя', pre);
 }
 
-function displaySearchResults(results) {
+// Columns of the search results with the fields they show and sort by
+const SEARCH_COLUMNS = [
+    ['Active', 'active'], ['Cluster', 'cluster'], ['Segment', 'segment'], ['Environment', 'environment'],
+    ['Realm', 'realm'], ['Tenant', 'tenant'], ['User', 'user'], ['Bucket', 'bucket'], ['Quota', 'quota'],
+    ['SD', 'sd_num'], ['SRT', 'srt_num'], ['Date', 'done_date'], ['RIS Code', 'ris_code'],
+    ['RIS ID', 'ris_id'], ['Owner Group', 'owner_group'], ['Owner', 'owner'], ['Applicant', 'applicant']
+];
+
+function displaySearchResults(data, actions) {
     const resultDiv = document.getElementById('result');
     if (!resultDiv) return;
 
+    const results = data.results;
     if (!results || results.length === 0) {
-        resultDiv.textContent = 'No results found';
+        resultDiv.textContent = data.total > 0 ? 'Страница пуста' : 'No results found';
         return;
     }
 
     const table = createTable(
-        ['Active', 'Cluster', 'Segment', 'Environment', 'Realm', 'Tenant', 
-         'User', 'Bucket', 'Quota', 'SD', 'SRT', 'Date',
-         'RIS Code', 'RIS ID', 'Owner Group', 'Owner', 'Applicant'],
-        results.map(result => [
-            result.active ? '✓' : '✗',
-            result.cluster,
-            result.segment,
-            result.environment,
-            result.realm,
-            result.tenant,
-            result.user,
-            result.bucket,
-            result.quota,
-            result.sd_num,
-            result.srt_num,
-            result.done_date,
-            result.ris_code,
-            result.ris_id,
-            result.owner_group,
-            result.owner,
-            result.applicant
-        ])
+        SEARCH_COLUMNS.map(([label]) => label),
+        results.map(result => SEARCH_COLUMNS.map(([, field]) =>
+            field === 'active' ? (result.active ? '✓' : '✗') : result[field]))
     );
 
+    // Sort by a column on a click on its header, a second click reverses it
+    table.querySelectorAll('th').forEach((th, i) => {
+        const field = SEARCH_COLUMNS[i][1];
+        th.classList.add('sortable');
+        if (field === data.sort) {
+            th.classList.add(data.order === 'asc' ? 'sorted-asc' : 'sorted-desc');
+        }
+        th.onclick = () => actions.sort(field);
+    });
+
+    const pages = Math.max(1, Math.ceil(data.total / data.page_size));
+    const pagination = document.createElement('div');
+    pagination.className = 'pagination';
+    const addPageButton = (label, page, enabled) => {
+        const button = document.createElement('button');
+        button.type = 'button';
+        button.className = 'copy-button';
+        button.textContent = label;
+        button.disabled = !enabled;
+        button.onclick = () => actions.page(page);
+        pagination.appendChild(button);
+    };
+    addPageButton('«', 1, data.page > 1);
+    addPageButton('‹', data.page - 1, data.page > 1);
+    const pageInfo = document.createElement('span');
+    const first = (data.page - 1) * data.page_size + 1;
+    pageInfo.textContent = `Страница ${data.page} из ${pages} (строки ${first}–${first + results.length - 1} из ${data.total})`;
+    pagination.appendChild(pageInfo);
+    addPageButton('›', data.page + 1, data.page < pages);
+    addPageButton('»', pages, data.page < pages);
+
     // Create container for results with copy button
     const resultsContainer = document.createElement('div');
     resultsContainer.className = 'search-results-container';
@@ -339,41 +366,223 @@ function displaySearchResults(results) {
     titleElement.className = 'results-title';
     titleElement.textContent = 'Результаты поиска';
     
-    // Create export button with CSV functionality
-    const exportButton = document.createElement('button');
-    exportButton.className = 'copy-button';
-    exportButton.textContent = 'Экспорт';
-    exportButton.onclick = () => {
-        // Convert table data to CSV format
-        const csvContent = convertTableToCSV(table);
-        
-        // Add BOM for proper UTF-8 encoding of Russian characters
-        const BOM = '\uFEFF';
-        const csvWithBOM = BOM + csvContent;
-        
-        // Create and download CSV file
-        const blob = new Blob([csvWithBOM], { type: 'text/csv;charset=utf-8;' });
-        const link = document.createElement('a');
-        const url = URL.createObjectURL(blob);
-        link.setAttribute('href', url);
-        link.setAttribute('download', 'search_results.csv');
-        link.style.visibility = 'hidden';
-        document.body.appendChild(link);
-        link.click();
-        document.body.removeChild(link);
-    };
+    // The server exports every page of the search
+    const exportButtons = document.createElement('div');
+    [['XLSX', 'xlsx', 'comma'], ['CSV', 'csv', 'comma'], ['CSV (;)', 'csv', 'semicolon']].forEach(([label, format, delimiter]) => {
+        const exportButton = document.createElement('button');
+        exportButton.type = 'button';
+        exportButton.className = 'copy-button';
+        exportButton.textContent = `Экспорт ${label}`;
+        exportButton.onclick = () => actions.export(format, delimiter);
+        exportButtons.appendChild(exportButton);
+    });
     
     resultsHeader.appendChild(titleElement);
-    resultsHeader.appendChild(exportButton);
+    resultsHeader.appendChild(exportButtons);
     
     // Assemble the container
     resultsContainer.appendChild(resultsHeader);
+    resultsContainer.appendChild(pagination);
     resultsContainer.appendChild(table);
 
     resultDiv.textContent = '';
     resultDiv.appendChild(resultsContainer);
 }
 
+function displayTextSearchResults(data) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+
+    const groups = data.groups || [];
+    const summary = groups.length === 0
+        ? `По запросу «${data.query}» ничего не найдено`
+        : `Найдено строк: ${data.rows}, тенантов: ${groups.length}` +
+          (data.truncated ? ' (показаны лучшие совпадения, уточните запрос)' : '');
+    container.appendChild(Object.assign(document.createElement('p'), { textContent: summary }));
+
+    groups.forEach(group => {
+        const overviewButton = document.createElement('button');
+        overviewButton.type = 'button';
+        overviewButton.className = 'copy-button';
+        overviewButton.textContent = 'Обзор тенанта';
+        overviewButton.onclick = () => showTenantOverview(group.tenant);
+
+        container.appendChild(createSection(`${group.tenant} (${group.cluster}, ${group.environment}, ${group.segment})`, [
+            createTable(
+                ['Active', 'User', 'Bucket', 'Quota', 'SD', 'SRT', 'Date', 'Owner', 'Applicant', 'Email', 'Comment'],
+                group.rows.map(row => [
+                    row.active ? '✓' : '✗', row.user, row.bucket, row.quota, row.sd_num, row.srt_num,
+                    row.done_date, row.owner, row.applicant, row.email, row.cspp_comment
+                ])
+            ),
+            overviewButton
+        ]));
+    });
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+function displayTenantOverview(data) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+    const status = active => active ? 'Активен' : 'Неактивен';
+
+    const exportButton = document.createElement('button');
+    exportButton.type = 'button';
+    exportButton.className = 'copy-button';
+    exportButton.textContent = 'Экспорт XLSX';
+    exportButton.onclick = () => downloadExport(`/zayavki/export/tenant?${new URLSearchParams({ tenant: data.tenant })}`)
+        .catch(error => displayResult(`Ошибка экспорта: ${error.message}`));
+
+    container.appendChild(createSection(`Тенант ${data.tenant}`, [
+        createTable(['Параметр', 'Значение'], [
+            ['Статус', status(data.active)],
+            ['Кластер', data.cluster],
+            ['Реалм', data.realm],
+            ['Среда', data.environment],
+            ['Зона безопасности', data.segment],
+            ['TLS endpoint', data.tls_endpoint],
+            ['mTLS endpoint', data.mtls_endpoint],
+            ['РИС код', data.ris_code],
+            ['РИС номер', data.ris_id],
+            ['Группа владельцев', data.owner_group],
+            ['Владелец', data.owner]
+        ]),
+        exportButton
+    ]));
+
+    const capacity = data.capacity;
+    container.appendChild(createSection('Выделенный объем', createTable(
+        ['Квота бакетов', 'Квота пользователей', 'Бакеты (акт./неакт.)', 'Пользователи (акт./неакт.)', 'Бакеты без квоты'],
+        [[
+            capacity.bucket_quota, capacity.user_quota,
+            `${capacity.active_buckets} / ${capacity.inactive_buckets}`,
+            `${capacity.active_users} / ${capacity.inactive_users}`,
+            String(capacity.buckets_without_quota)
+        ]]
+    )));
+
+    container.appendChild(createSection('Пользователи', createTable(
+        ['Пользователь', 'Квота', 'Лимит объектов', 'Квота включена', 'Статус', 'SD', 'SRT', 'Дата'],
+        data.users.map(user => [
+            user.user, user.quota, user.max_objects, user.quota_enabled ? 'да' : 'нет', status(user.active),
+            user.sd_num, user.srt_num, user.done_date
+        ])
+    )));
+
+    container.appendChild(createSection('Бакеты', createTable(
+        ['Бакет', 'Квота', 'Лимит объектов', 'Квота включена', 'Версионирование', 'Object lock', 'Expire', 'Статус', 'SD', 'SRT', 'Дата'],
+        data.buckets.map(bucket => [
+            bucket.bucket, bucket.quota, bucket.max_objects, bucket.quota_enabled ? 'да' : 'нет',
+            bucket.versioning ? 'да' : 'нет', bucket.object_lock, bucket.lifecycle_expire, status(bucket.active),
+            bucket.sd_num, bucket.srt_num, bucket.done_date
+        ])
+    )));
+
+    const states = data.states || {};
+    container.appendChild(createSection('Заявки', createTable(
+        ['SD', 'SRT', 'Статус', 'Комментарий', 'Создал', 'Создана', 'Изменена'],
+        data.requests.map(request => [
+            request.sd_num, request.srt_num, states[request.state] || request.state, request.note,
+            request.created_by, request.created_at, `${request.updated_at} (${request.updated_by})`
+        ])
+    )));
+
+    container.appendChild(createSection('История', createTable(
+        ['Время', 'Событие', 'Кто', 'SRT'],
+        data.timeline.map(event => [event.at, event.event, event.actor, event.srt_num])
+    )));
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+// formatGigabytes shows bytes as the decimal gigabytes quotas are given in
+function formatGigabytes(bytes) {
+    return (bytes / 1e9).toLocaleString('ru-RU', { maximumFractionDigits: 2 });
+}
+
+function displayReports(data) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+
+    const allocationTable = (column, rows) => createTable(
+        [column, 'Тенанты', 'Бакеты', 'Пользователи', 'Квота бакетов, ГБ', 'Квота пользователей, ГБ', 'Бакеты без квоты'],
+        rows.map(row => [
+            row.key, String(row.tenants), String(row.buckets), String(row.users),
+            formatGigabytes(row.bucket_quota_bytes), formatGigabytes(row.user_quota_bytes),
+            String(row.buckets_without_quota)
+        ])
+    );
+
+    container.appendChild(createSection('Всего', allocationTable('', [data.total])));
+    container.appendChild(createSection('По средам', allocationTable('Среда', data.by_env)));
+    container.appendChild(createSection('По зонам безопасности', allocationTable('Зона безопасности', data.by_segment)));
+    container.appendChild(createSection('По кластерам', allocationTable('Кластер', data.by_cluster)));
+    container.appendChild(createSection(`Топ ${data.top_ris.length} РИС`, allocationTable('РИС', data.top_ris)));
+
+    const topTenants = allocationTable('Тенант', data.top_tenants);
+    topTenants.querySelectorAll('tbody tr').forEach((row, i) => {
+        const overviewButton = document.createElement('button');
+        overviewButton.type = 'button';
+        overviewButton.className = 'copy-button';
+        overviewButton.textContent = data.top_tenants[i].key;
+        overviewButton.onclick = () => showTenantOverview(data.top_tenants[i].key);
+        row.cells[0].replaceChildren(overviewButton);
+    });
+    container.appendChild(createSection(`Топ ${data.top_tenants.length} тенантов`, topTenants));
+
+    // Growth is shown as bars of the quota added in each period
+    const growth = createTable(
+        ['Период', 'Бакеты', 'Добавлено, ГБ', 'Всего, ГБ', ''],
+        data.growth.map(row => [
+            row.period, String(row.buckets), formatGigabytes(row.added_bytes), formatGigabytes(row.cumulative_bytes), ''
+        ])
+    );
+    const maxAdded = Math.max(1, ...data.growth.map(row => row.added_bytes));
+    growth.querySelectorAll('tbody tr').forEach((row, i) => {
+        const bar = document.createElement('div');
+        bar.className = 'report-bar';
+        bar.style.width = `${Math.round(data.growth[i].added_bytes / maxAdded * 100)}%`;
+        row.cells[4].replaceChildren(bar);
+    });
+    container.appendChild(createSection('Рост выделенного объема бакетов', growth));
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+function displayUsageReport(data) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+
+    const usageTable = rows => createTable(
+        ['Тенант', 'Бакет', 'Кластер', 'Среда', 'РИС', 'Квота', 'Занято, ГБ', 'Занято, %', 'Объекты', 'Собрано', 'Последнее изменение'],
+        rows.map(row => [
+            row.tenant, row.bucket, row.cluster, row.environment, `${row.ris_id} ${row.ris_code}`, row.quota,
+            formatGigabytes(row.size_actual), row.quota_bytes > 0 ? row.used_percent.toFixed(1) : '-',
+            String(row.num_objects), row.collected_at, row.last_change
+        ])
+    );
+
+    container.appendChild(createSection(
+        `Заполнены на ${data.threshold_percent}% квоты и больше (${data.over_utilized.length})`,
+        usageTable(data.over_utilized)
+    ));
+    container.appendChild(createSection(
+        `Без изменений ${data.unused_days} дней и больше (${data.unused.length})`,
+        usageTable(data.unused)
+    ));
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
 function displayFormResult(data) {
     const resultDiv = document.getElementById('result');
     if (resultDiv) {
@@ -402,20 +611,37 @@ function displayBucketModUpdateResults(result) {
     const container = document.createElement('div');
     container.className = 'table-container';
 
+    const formatQuota = entry => [
+        entry.name,
+        entry.old_size || '-',
+        entry.size || '-',
+        entry.max_objects || '-'
+    ];
+
     // Show updated buckets
     if (result.updated_buckets && result.updated_buckets.length > 0) {
         container.appendChild(createSection('Успешно обновленные квоты бакетов',
             createTable(
-                ['Бакет', 'Новая квота'],
-                result.updated_buckets.map(bucket => [
-                    bucket.name,
-                    bucket.size + 'G'
-                ])
+                ['Бакет', 'Старая квота', 'Новая квота', 'Лимит объектов'],
+                result.updated_buckets.map(formatQuota)
             )
         ));
-    } else {
+    }
+
+    // Show updated users
+    if (result.updated_users && result.updated_users.length > 0) {
+        container.appendChild(createSection('Успешно обновленные квоты пользователей',
+            createTable(
+                ['Пользователь', 'Старая квота', 'Новая квота', 'Лимит объектов'],
+                result.updated_users.map(formatQuota)
+            )
+        ));
+    }
+
+    if (!(result.updated_buckets && result.updated_buckets.length) &&
+        !(result.updated_users && result.updated_users.length)) {
         const noUpdatesMsg = document.createElement('p');
-        noUpdatesMsg.textContent = 'Ни один бакет не был обновлен';
+        noUpdatesMsg.textContent = 'Ни одна квота не была обновлена';
         container.appendChild(createSection('Результат', noUpdatesMsg));
     }
 
@@ -432,16 +658,329 @@ function displayBucketModUpdateResults(result) {
         container.appendChild(createSection('Ошибки', errorList));
     }
 
+    if (result.email) {
+        container.appendChild(createEmailSection(result.email));
+    }
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+function displayPolicyResults(data) {
+    displayCheckResults({ tenant: data.tenant, commands: data.commands });
+    const container = document.querySelector('#result .table-container');
+
+    const grantRows = grants => grants.map(grant => [grant.user, grant.bucket, grant.access, grant.srt_num, grant.granted_at]);
+    const sections = [];
+
+    sections.push(createSection(data.saved ? 'Доступы сохранены в БД' : 'Текущие доступы',
+        data.grants && data.grants.length > 0 ?
+            createTable(['Пользователь', 'Бакет', 'Доступ', 'SRT', 'Выдан'], grantRows(data.grants)) :
+            Object.assign(document.createElement('p'), { textContent: 'Доступы не выданы' })
+    ));
+
+    if (data.pending_grants && !data.saved) {
+        sections.push(createSection('Доступы после применения',
+            createTable(['Пользователь', 'Бакет', 'Доступ', 'SRT', 'Выдан'], grantRows(data.pending_grants))
+        ));
+    }
+
+    if (data.policies) {
+        Object.keys(data.policies).sort().forEach(bucket => {
+            const pre = document.createElement('pre');
+            pre.className = 'command-block';
+            pre.textContent = data.policies[bucket] || 'Политика будет удалена';
+            sections.push(createSection(`Политика бакета ${bucket}`, pre));
+        });
+    }
+
+    // Show grants and policies between the tenant info and the commands
+    sections.reverse().forEach(section => container.insertBefore(section, container.children[1] || null));
+}
+
+function displayKeyResults(data) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+
+    if (data.recorded || data.revoked) {
+        container.appendChild(createSection('Изменения в БД',
+            createTable(
+                ['Записано ключей', 'Отозвано ключей'],
+                [[(data.recorded || []).join(', '), (data.revoked || []).join(', ')]]
+            )
+        ));
+    }
+
+    const title = data.min_age_days ?
+        `Ключи старше ${data.min_age_days} дней` : 'Активные ключи пользователя';
+    container.appendChild(createSection(title,
+        data.keys && data.keys.length > 0 ?
+            createTable(
+                ['Тенант', 'Пользователь', 'Access key', 'SRT', 'Создан', 'Истекает', 'Возраст (дней)'],
+                data.keys.map(key => [key.tenant, key.user, key.access_key, key.srt_num, key.created_at, key.expires_at, String(key.age_days)])
+            ) :
+            Object.assign(document.createElement('p'), { textContent: 'Ключи не найдены' })
+    ));
+
+    if (data.commands) {
+        container.appendChild(createCommandsSection('Команды', data.commands));
+    }
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+function displayCredentialResults(data) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+
+    const fileInfo = document.createElement('div');
+    fileInfo.appendChild(createTable(
+        ['Получатель', 'Пользователи', 'Файл'],
+        [[data.recipient, (data.users || []).join(', '), data.file_name]]
+    ));
+    const downloadButton = document.createElement('button');
+    downloadButton.className = 'copy-button';
+    downloadButton.textContent = 'Скачать файл';
+    downloadButton.onclick = () => {
+        const blob = new Blob([data.encrypted], { type: 'application/pgp-encrypted' });
+        const link = document.createElement('a');
+        const url = URL.createObjectURL(blob);
+        link.setAttribute('href', url);
+        link.setAttribute('download', data.file_name);
+        link.style.visibility = 'hidden';
+        document.body.appendChild(link);
+        link.click();
+        document.body.removeChild(link);
+        URL.revokeObjectURL(url);
+    };
+    fileInfo.appendChild(downloadButton);
+    container.appendChild(createSection('Зашифрованный файл с ключами', fileInfo));
+
+    const email = document.createElement('pre');
+    email.className = 'command-block';
+    email.textContent = data.email;
+    container.appendChild(createSection('Шаблон письма с данными УЗ', email));
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+function displayRequests(data, actions) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+    const states = data.states || {};
+    const requests = data.requests || [];
+
+    if (requests.length === 0) {
+        container.appendChild(createSection(`Заявки (${data.actor})`,
+            Object.assign(document.createElement('p'), { textContent: 'Заявки не найдены' })));
+    } else {
+        const table = createTable(
+            ['SD', 'SRT', 'Тенант', 'Статус', 'Комментарий', 'Создал', 'Изменена', 'Действия'],
+            requests.map(request => [
+                request.sd_num, request.srt_num, request.tenant, states[request.state] || request.state,
+                request.note, request.created_by, `${request.updated_at} (${request.updated_by})`, ''
+            ])
+        );
+
+        table.querySelectorAll('tbody tr').forEach((row, index) => {
+            const request = requests[index];
+            const cell = row.lastElementChild;
+            cell.textContent = '';
+
+            const addButton = (label, onclick) => {
+                const button = document.createElement('button');
+                button.className = 'copy-button';
+                button.textContent = label;
+                button.onclick = onclick;
+                cell.appendChild(button);
+            };
+
+            addButton('Продолжить', () => actions.resume(request));
+            if (request.state === 'commands_generated') {
+                addButton('Выполнено', () => actions.setState(request, 'executed'));
+            }
+            if (request.state !== 'closed') {
+                addButton('Закрыть', () => actions.setState(request, 'closed'));
+            }
+            addButton('История', async () => {
+                try {
+                    const response = await fetch(`/zayavki/request?id=${request.id}`);
+                    if (!response.ok) {
+                        throw new Error(await response.text());
+                    }
+                    const details = await response.json();
+                    const history = createSection(`История заявки ${details.srt_num}`, createTable(
+                        ['Время', 'Из статуса', 'В статус', 'Кто', 'Комментарий'],
+                        (details.transitions || []).map(t => [
+                            t.created_at, states[t.from] || t.from, states[t.to] || t.to, t.actor, t.note
+                        ])
+                    ));
+                    container.querySelectorAll('.request-history').forEach(el => el.remove());
+                    history.classList.add('request-history');
+                    container.appendChild(history);
+                } catch (error) {
+                    displayResult(`Ошибка: ${error.message}`);
+                }
+            });
+        });
+
+        container.appendChild(createSection(`Заявки (${data.actor})`, table));
+    }
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+function displayApprovalPending(approval) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+
+    const message = document.createElement('p');
+    message.textContent = `Изменение требует согласования. Заявка #${approval.id} сохранена и будет выполнена после одобрения другим пользователем на вкладке "Согласование".`;
+    container.appendChild(createSection('Ожидает согласования', message));
+
+    const plan = document.createElement('pre');
+    plan.className = 'command-block';
+    plan.textContent = approval.plan;
+    container.appendChild(createSection('План изменений', plan));
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+function displayApprovals(data, actions) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+    const labels = data.labels || {};
+    const list = data.approvals || [];
+
+    if (list.length === 0) {
+        container.appendChild(createSection('Согласование',
+            Object.assign(document.createElement('p'), { textContent: 'Заявки не найдены' })));
+    }
+
+    list.forEach(approval => {
+        const details = createTable(
+            ['Операция', 'Тенант', 'SRT', 'Статус', 'Автор', 'Создана', 'Решение', 'Выполнена'],
+            [[
+                labels[approval.operation] || approval.operation, approval.tenant, approval.srt_num,
+                labels[approval.status] || approval.status,
+                approval.requested_by, approval.requested_at,
+                approval.reviewed_by ? `${approval.reviewed_by}: ${approval.review_comment || ''}` : '',
+                approval.executed_by ? `${approval.executed_by} ${approval.executed_at}` : ''
+            ]]
+        );
+
+        const plan = document.createElement('pre');
+        plan.className = 'command-block';
+        plan.textContent = approval.plan;
+
+        const elements = [details, plan];
+        if (approval.error) {
+            elements.push(Object.assign(document.createElement('p'), { textContent: `Ошибка выполнения: ${approval.error}` }));
+        }
+
+        const addButton = (label, onclick) => {
+            const button = document.createElement('button');
+            button.className = 'copy-button';
+            button.textContent = label;
+            button.onclick = onclick;
+            elements.push(button);
+        };
+        // Authors cannot approve their own requests, the server checks it too
+        if (approval.status === 'pending' && approval.requested_by !== data.actor) {
+            addButton('Одобрить', () => actions.review(approval, true));
+            addButton('Отклонить', () => actions.review(approval, false));
+        }
+        if (approval.status === 'approved') {
+            addButton('Выполнить', () => actions.execute(approval));
+        }
+
+        container.appendChild(createSection(`Заявка #${approval.id}`, elements));
+    });
+
+    const resultDiv = document.getElementById('result');
+    resultDiv.innerHTML = '';
+    resultDiv.appendChild(container);
+}
+
+const BATCH_STATUS_LABELS = {
+    invalid: 'Ошибка',
+    valid: 'Проверена',
+    pushed: 'Внесена в БД',
+    not_pushed: 'Не внесена',
+    pending_approval: 'Ожидает согласования'
+};
+
+function displayBatchResults(data) {
+    const container = document.createElement('div');
+    container.className = 'table-container';
+    const rows = data.rows || [];
+
+    let summary;
+    if (data.error) {
+        summary = `Данные не внесены в БД: ${data.error}`;
+    } else if (data.valid === false) {
+        summary = `Файл не прошел проверку: ошибки в ${rows.filter(row => row.status === 'invalid').length} из ${rows.length} строк. Исправьте их и загрузите файл снова.`;
+    } else if (data.approval) {
+        summary = `Пакетное создание требует согласования. Заявка #${data.approval.id} сохранена, все ${rows.length} тенантов будут внесены в БД после одобрения на вкладке "Согласование".`;
+    } else if (rows.some(row => row.status === 'pushed')) {
+        summary = `Все ${rows.length} тенантов внесены в БД`;
+    } else {
+        summary = `Все ${rows.length} строк прошли проверку`;
+    }
+    container.appendChild(createSection('Результат',
+        Object.assign(document.createElement('p'), { textContent: summary })));
+
+    container.appendChild(createSection('Строки файла',
+        createTable(
+            ['Строка', 'SD', 'SRT', 'Тенант', 'Среда', 'Кластер', 'Статус', 'Ошибки'],
+            rows.map(row => [
+                row.line, row.sd_num, row.srt_num, row.tenant, row.env, row.cluster,
+                BATCH_STATUS_LABELS[row.status] || row.status, (row.errors || []).join('; ')
+            ])
+        )
+    ));
+
+    if (data.script) {
+        container.appendChild(createCommandsSection('Команды для всех тенантов', data.script));
+    }
+
+    rows.filter(row => row.result).forEach(row => {
+        const pre = document.createElement('pre');
+        pre.className = 'command-block';
+        pre.textContent = row.result;
+        container.appendChild(createSection(`Строка ${row.line}: ${row.tenant}`, pre));
+    });
+
     const resultDiv = document.getElementById('result');
     resultDiv.innerHTML = '';
     resultDiv.appendChild(container);
 }
 
 // Export functions
+window.displayBatchResults = displayBatchResults;
+window.displayApprovalPending = displayApprovalPending;
+window.displayApprovals = displayApprovals;
+window.displayRequests = displayRequests;
 window.displayResult = displayResult;
+window.displayCredentialResults = displayCredentialResults;
+window.displayKeyResults = displayKeyResults;
+window.displayPolicyResults = displayPolicyResults;
 window.displayCheckResults = displayCheckResults;
 window.displayDeactivationResults = displayDeactivationResults;
 window.displaySearchResults = displaySearchResults;
+window.displayTextSearchResults = displayTextSearchResults;
+window.displayTenantOverview = displayTenantOverview;
+window.displayReports = displayReports;
+window.displayUsageReport = displayUsageReport;
 window.displayFormResult = displayFormResult;
 window.displayCombinedResult = displayCombinedResult;
 window.displayBucketModUpdateResults = displayBucketModUpdateResults; 
\ No newline at end of file
@@ -35,6 +35,12 @@ const ALL_BUTTONS = {
 const TAB_CONFIGS = {
     'search': {
         fields: [
+            {
+                id: 'search_text',
+                label: 'Поиск по всем полям',
+                type: 'text',
+                placeholder: 'Email, часть имени бакета, пользователь, SD-XXXXXXX'
+            },
             { id: 'segment', label: 'Зона безопасности', type: 'text', placeholder: 'INET-DEVTEST-SYNT' },
             { 
                 id: 'env', 
@@ -53,13 +59,131 @@ const TAB_CONFIGS = {
             { id: 'cluster', label: 'Кластер', type: 'text' },
             { id: 'tenant', label: 'Тенант', type: 'text' },
             { id: 'bucket', label: 'Бакет', type: 'text' },
-            { id: 'user', label: 'Пользователь', type: 'text' }
+            { id: 'user', label: 'Пользователь', type: 'text' },
+            { id: 'owner', label: 'Владелец', type: 'text', placeholder: 'owner@vtb.ru' },
+            { id: 'request_id_sd', label: 'Номер обращения SD', type: 'text', placeholder: 'SD-XXXXXXX' },
+            { id: 'request_id_srt', label: 'Номер задания SRT', type: 'text', placeholder: 'SRT-XXXXXXX' },
+            {
+                id: 'match',
+                label: 'Совпадение',
+                type: 'select',
+                options: [
+                    { value: 'contains', label: 'Содержит' },
+                    { value: 'prefix', label: 'Начинается с' },
+                    { value: 'exact', label: 'Точное' }
+                ]
+            },
+            {
+                id: 'active',
+                label: 'Статус',
+                type: 'select',
+                options: [
+                    { value: '', label: 'Все' },
+                    { value: 'true', label: 'Активные' },
+                    { value: 'false', label: 'Неактивные' }
+                ]
+            },
+            { id: 'date_from', label: 'Дата с', type: 'date' },
+            { id: 'date_to', label: 'Дата по', type: 'date' },
+            {
+                id: 'page_size',
+                label: 'Строк на странице',
+                type: 'select',
+                options: ['50', '100', '200', '500']
+            }
         ],
         buttons: [
             { id: 'search', label: 'Поиск' },
+            { id: 'text-search', label: 'Найти везде' },
             { id: 'clear', label: 'Очистить' }
         ],
-        required_fields: ['segment', 'env']
+        required_fields: []
+    },
+    'tenant-overview': {
+        fields: [
+            { id: 'tenant', label: 'Имя тенанта', type: 'text', required: true, placeholder: 'Имя существующего тенанта' }
+        ],
+        buttons: [
+            { id: 'check-tenant', label: 'Показать', className: 'primary-button' }
+        ],
+        required_fields: ['tenant']
+    },
+    'reports': {
+        fields: [
+            {
+                id: 'env',
+                label: 'Среда',
+                type: 'select',
+                options: [
+                    { value: '', label: 'Все среды' },
+                    { value: 'PROD', label: 'PROD' },
+                    { value: 'PREPROD', label: 'PREPROD' },
+                    { value: 'IFT', label: 'IFT' },
+                    { value: 'HOTFIX', label: 'HOTFIX' }
+                ]
+            },
+            { id: 'segment', label: 'Зона безопасности', type: 'text', placeholder: 'INET-DEVTEST-SYNT' },
+            { id: 'cluster', label: 'Кластер', type: 'text' },
+            { id: 'ris_number', label: 'РИС номер', type: 'text', placeholder: '1763' },
+            {
+                id: 'report_interval',
+                label: 'Рост по',
+                type: 'select',
+                options: [
+                    { value: 'month', label: 'Месяцам' },
+                    { value: 'week', label: 'Неделям' },
+                    { value: 'quarter', label: 'Кварталам' },
+                    { value: 'year', label: 'Годам' },
+                    { value: 'day', label: 'Дням' }
+                ]
+            },
+            {
+                id: 'report_top',
+                label: 'Топ потребителей',
+                type: 'select',
+                options: ['10', '20', '50']
+            }
+        ],
+        buttons: [
+            { id: 'check-tenant', label: 'Показать', className: 'primary-button' },
+            { id: 'export-report', label: 'Экспорт XLSX' }
+        ],
+        required_fields: []
+    },
+    'usage': {
+        fields: [
+            {
+                id: 'env',
+                label: 'Среда',
+                type: 'select',
+                options: [
+                    { value: '', label: 'Все среды' },
+                    { value: 'PROD', label: 'PROD' },
+                    { value: 'PREPROD', label: 'PREPROD' },
+                    { value: 'IFT', label: 'IFT' },
+                    { value: 'HOTFIX', label: 'HOTFIX' }
+                ]
+            },
+            { id: 'segment', label: 'Зона безопасности', type: 'text', placeholder: 'INET-DEVTEST-SYNT' },
+            { id: 'cluster', label: 'Кластер', type: 'text' },
+            { id: 'ris_number', label: 'РИС номер', type: 'text', placeholder: '1763' },
+            { id: 'usage_threshold', label: 'Порог заполнения, %', type: 'number', placeholder: 'Из настроек' },
+            { id: 'unused_days', label: 'Без изменений, дней', type: 'number', placeholder: 'Из настроек' },
+            {
+                id: 'usage_file',
+                label: 'Вывод radosgw-admin bucket stats (JSON)',
+                type: 'file',
+                accept: '.json,.txt'
+            },
+            { id: 'usage_cluster', label: 'Кластер статистики', type: 'text', placeholder: 'Для загрузки и сбора, пусто — все кластеры' }
+        ],
+        buttons: [
+            { id: 'check-tenant', label: 'Показать', className: 'primary-button' },
+            { id: 'export-usage', label: 'Экспорт XLSX' },
+            { id: 'usage-import', label: 'Загрузить статистику', className: 'import-json-button' },
+            { id: 'usage-collect', label: 'Собрать через Admin Ops', className: 'import-json-button' }
+        ],
+        required_fields: []
     },
     'new-tenant': {
         fields: [
@@ -147,6 +271,26 @@ const TAB_CONFIGS = {
                 required: true,
                 placeholder: 'email@vtb.ru'
             },
+            {
+                id: 'send_email',
+                label: 'Отправить письмо с данными УЗ (копия владельцам)',
+                type: 'select',
+                required: false,
+                options: [
+                    { value: 'false', label: 'Нет, только шаблон письма' },
+                    { value: 'true', label: 'Да, отправить через SMTP' }
+                ]
+            },
+            {
+                id: 'email_lang',
+                label: 'Язык письма',
+                type: 'select',
+                required: false,
+                options: [
+                    { value: 'ru', label: 'Русский' },
+                    { value: 'en', label: 'English' }
+                ]
+            },
             {
                 id: 'tenant_override',
                 label: 'Имя тенанта (override)',
@@ -163,10 +307,17 @@ const TAB_CONFIGS = {
             },
             {
                 id: 'buckets',
-                label: 'Бакеты с указанием квоты (формат: имя-бакета | размер)',
+                label: 'Бакеты с указанием квоты (формат: имя-бакета | размер | параметры)',
+                type: 'textarea',
+                required: false,
+                placeholder: 'Примеры:\nif-cosd-bucket1 | 100G\nif-cosd-bucket2 | 1.5T | versioning | expire=90\nif-cosd-bucket3 | 500G | lock=GOVERNANCE:30\n\nПримечание:\n- Имя бакета должно начинаться с {среда}-{рис_имя}-\n- Размер: 500G, 1.5T, 200GiB (число без единиц — GB)\n- Параметры: objects=N, versioning, lock=GOVERNANCE:дни или lock=COMPLIANCE:дни (только PROD), expire=дни\n- Разрешены только буквы, цифры и дефисы'
+            },
+            {
+                id: 'user_quotas',
+                label: 'Квоты пользователей (формат: пользователь | размер | objects=N)',
                 type: 'textarea',
                 required: false,
-                placeholder: 'Примеры:\nif-cosd-bucket1 | 100\nif-cosd-bucket2 | 200\n\nПримечание:\n- Имя бакета должно начинаться с {среда}-{рис_имя}-\n- Размер указывается в GB, только целые числа\n- Разрешены только буквы, цифры и дефисы'
+                placeholder: 'Примеры:\nif_cosd_user1 | 500G\nif_cosd_user2 | 200GiB | objects=100000\n\nПримечание:\n- Размер: 500G, 1.5T, 200GiB (число без единиц — GB)\n- objects=N задает лимит количества объектов (необязательно)'
             }
         ],
         buttons: [
@@ -210,10 +361,17 @@ const TAB_CONFIGS = {
             },
             {
                 id: 'buckets',
-                label: 'Бакеты с указанием квоты (формат: имя-бакета | размер)',
+                label: 'Бакеты с указанием квоты (формат: имя-бакета | размер | параметры)',
+                type: 'textarea',
+                required: false,
+                placeholder: 'Примеры:\nif-cosd-bucket1 | 100G\nif-cosd-bucket2 | 1.5T | versioning | expire=90\nif-cosd-bucket3 | 500G | lock=GOVERNANCE:30\n\nПримечание:\n- Имя бакета должно начинаться с {env_code}-{ris_code}-\n- Код среды и РИС извлекается из имени тенанта\n- Размер: 500G, 1.5T, 200GiB (число без единиц — GB)\n- Параметры: objects=N, versioning, lock=GOVERNANCE:дни или lock=COMPLIANCE:дни (только PROD), expire=дни\n- Разрешены только буквы, цифры и дефисы'
+            },
+            {
+                id: 'user_quotas',
+                label: 'Квоты пользователей (формат: пользователь | размер | objects=N)',
                 type: 'textarea',
                 required: false,
-                placeholder: 'Примеры:\nif-cosd-bucket1 | 100\nif-cosd-bucket2 | 200\n\nПримечание:\n- Имя бакета должно начинаться с {env_code}-{ris_code}-\n- Код среды и РИС извлекается из имени тенанта\n- Размер указывается в GB, только целые числа\n- Разрешены только буквы, цифры и дефисы'
+                placeholder: 'Примеры:\nif_cosd_user1 | 500G\nif_cosd_user2 | 200GiB | objects=100000\n\nПримечание:\n- Размер: 500G, 1.5T, 200GiB (число без единиц — GB)\n- objects=N задает лимит количества объектов (необязательно)'
             }
         ],
         buttons: [
@@ -232,6 +390,13 @@ const TAB_CONFIGS = {
                 required: true,
                 placeholder: 'Имя существующего тенанта'
             },
+            {
+                id: 'request_id_srt',
+                label: 'Номер задания SRT',
+                type: 'text',
+                required: false,
+                placeholder: 'SRT-XXXXXXX'
+            },
             {
                 id: 'users',
                 label: 'Пользователи (один в строке)',
@@ -263,12 +428,37 @@ const TAB_CONFIGS = {
                 required: true,
                 placeholder: 'Имя существующего тенанта'
             },
+            {
+                id: 'request_id_srt',
+                label: 'Номер задания SRT',
+                type: 'text',
+                required: false,
+                placeholder: 'SRT-XXXXXXX'
+            },
+            {
+                id: 'quota_action',
+                label: 'Действие',
+                type: 'select',
+                required: true,
+                options: [
+                    { value: 'set', label: 'Установить квоты' },
+                    { value: 'enable', label: 'Включить квоты' },
+                    { value: 'disable', label: 'Выключить квоты' }
+                ]
+            },
             {
                 id: 'buckets',
-                label: 'Бакеты с указанием квоты (формат: имя-бакета | размер)',
+                label: 'Бакеты с указанием квоты (формат: имя-бакета | размер | objects=N)',
                 type: 'textarea',
-                required: true,
-                placeholder: 'Примеры:\nif-cosd-bucket1 | 100\nif-cosd-bucket2 | 200\n\nПримечание:\n- Имя бакета должно начинаться с {env_code}-{ris_code}-\n- Код среды и РИС извлекается из имени тенанта\n- Размер указывается в GB, только целые числа\n- Разрешены только буквы, цифры и дефисы'
+                required: false,
+                placeholder: 'Примеры:\nif-cosd-bucket1 | 100G\nif-cosd-bucket2 | 1.5T | objects=100000\n\nПримечание:\n- Имя бакета должно начинаться с {env_code}-{ris_code}-\n- Код среды и РИС извлекается из имени тенанта\n- Размер: 500G, 1.5T, 200GiB (число без единиц — GB)\n- Для включения/выключения размер можно указать как -\n- Разрешены только буквы, цифры и дефисы'
+            },
+            {
+                id: 'user_quotas',
+                label: 'Квоты пользователей (формат: пользователь | размер | objects=N)',
+                type: 'textarea',
+                required: false,
+                placeholder: 'Примеры:\nif_cosd_user1 | 500G\nif_cosd_user2 | 200GiB | objects=100000\n\nПримечание:\n- Размер: 500G, 1.5T, 200GiB (число без единиц — GB)\n- objects=N задает лимит количества объектов (необязательно)'
             }
         ],
         buttons: [
@@ -276,7 +466,228 @@ const TAB_CONFIGS = {
             { id: 'submit-form', label: 'Обновить квоты', className: 'danger-button' },
             { id: 'clear', label: 'Очистить', className: 'clear-search-button' }
         ],
-        required_fields: ['tenant', 'buckets']
+        required_fields: ['tenant']
+    },
+    'bucket-policy': {
+        fields: [
+            {
+                id: 'tenant',
+                label: 'Имя тенанта',
+                type: 'text',
+                required: true,
+                placeholder: 'Имя существующего тенанта'
+            },
+            {
+                id: 'request_id_srt',
+                label: 'Номер задания SRT',
+                type: 'text',
+                required: false,
+                placeholder: 'SRT-XXXXXXX'
+            },
+            {
+                id: 'policy_action',
+                label: 'Действие',
+                type: 'select',
+                required: true,
+                options: [
+                    { value: 'grant', label: 'Выдать доступ' },
+                    { value: 'revoke', label: 'Отозвать доступ' }
+                ]
+            },
+            {
+                id: 'policy_grants',
+                label: 'Доступы (формат: пользователь | бакет | уровень)',
+                type: 'textarea',
+                required: false,
+                placeholder: 'Примеры:\nif_cosd_user1 | if-cosd-bucket1 | read-only\nif_cosd_user2 | if-cosd-bucket1 | read-write\n\nПримечание:\n- Уровни доступа: read-only, read-write, admin\n- Для отзыва уровень можно не указывать\n- Без строк доступа показываются текущие доступы тенанта'
+            }
+        ],
+        buttons: [
+            { id: 'check-tenant', label: 'Проверить', className: 'primary-button' },
+            { id: 'submit-form', label: 'Отправить в БД', className: 'danger-button' },
+            { id: 'clear', label: 'Очистить', className: 'clear-search-button' }
+        ],
+        required_fields: ['tenant']
+    },
+    'access-keys': {
+        fields: [
+            {
+                id: 'key_action',
+                label: 'Действие',
+                type: 'select',
+                required: true,
+                options: [
+                    { value: 'issue', label: 'Выпустить ключ' },
+                    { value: 'rotate', label: 'Ротировать ключи' },
+                    { value: 'revoke', label: 'Отозвать указанные ключи' },
+                    { value: 'revoke_all', label: 'Отозвать все ключи' },
+                    { value: 'list', label: 'Список старых ключей' }
+                ]
+            },
+            {
+                id: 'tenant',
+                label: 'Имя тенанта',
+                type: 'text',
+                required: false,
+                placeholder: 'Имя существующего тенанта'
+            },
+            {
+                id: 'user',
+                label: 'Пользователь',
+                type: 'text',
+                required: false,
+                placeholder: 'if_cosd_user1'
+            },
+            {
+                id: 'request_id_srt',
+                label: 'Номер задания SRT',
+                type: 'text',
+                required: false,
+                placeholder: 'SRT-XXXXXXX'
+            },
+            {
+                id: 'access_keys',
+                label: 'Текущие ключи пользователя / ключи для отзыва (access key ID, по одному в строке)',
+                type: 'textarea',
+                required: false,
+                placeholder: 'Для отзыва обязательно, если не выбран отзыв всех ключей.\nДля пользователей, чьи ключи не записаны в БД, укажите ключи до выпуска нового: при ротации они будут отозваны'
+            },
+            {
+                id: 'key_output',
+                label: 'Вывод команды создания ключа',
+                type: 'textarea',
+                required: false,
+                placeholder: 'Вставьте вывод radosgw-admin key create.\nВ БД сохраняются только access key ID, секретные ключи не сохраняются'
+            },
+            {
+                id: 'min_age_days',
+                label: 'Возраст ключей (дней)',
+                type: 'text',
+                required: false,
+                placeholder: 'По умолчанию из access_keys.json'
+            }
+        ],
+        buttons: [
+            { id: 'check-tenant', label: 'Проверить', className: 'primary-button' },
+            { id: 'submit-form', label: 'Отправить в БД', className: 'danger-button' },
+            { id: 'clear', label: 'Очистить', className: 'clear-search-button' }
+        ],
+        required_fields: ['key_action']
+    },
+    'credentials': {
+        fields: [
+            {
+                id: 'tenant',
+                label: 'Имя тенанта',
+                type: 'text',
+                required: true,
+                placeholder: 'Имя существующего тенанта'
+            },
+            {
+                id: 'email_for_credentials',
+                label: 'Email для отправки данных УЗ',
+                type: 'email',
+                required: true,
+                placeholder: 'email@vtb.ru'
+            },
+            {
+                id: 'request_id_sd',
+                label: 'Номер обращения SD',
+                type: 'text',
+                required: false,
+                placeholder: 'SD-XXXXXXX'
+            },
+            {
+                id: 'request_id_srt',
+                label: 'Номер задания SRT',
+                type: 'text',
+                required: false,
+                placeholder: 'SRT-XXXXXXX'
+            },
+            {
+                id: 'credentials_output',
+                label: 'Вывод команд создания пользователей',
+                type: 'textarea',
+                required: true,
+                placeholder: 'Вставьте вывод radosgw-admin user create или строки в формате:\nпользователь | access key | secret key\nКлючи шифруются PGP-ключом получателя и не сохраняются'
+            }
+        ],
+        buttons: [
+            { id: 'submit-form', label: 'Зашифровать', className: 'primary-button' },
+            { id: 'clear', label: 'Очистить', className: 'clear-search-button' }
+        ],
+        required_fields: ['tenant', 'email_for_credentials', 'credentials_output']
+    },
+    'requests': {
+        fields: [
+            {
+                id: 'requests_scope',
+                label: 'Заявки',
+                type: 'select',
+                required: false,
+                options: [
+                    { value: 'mine', label: 'Мои заявки' },
+                    { value: 'all', label: 'Все заявки' }
+                ]
+            },
+            {
+                id: 'requests_closed',
+                label: 'Закрытые заявки',
+                type: 'select',
+                required: false,
+                options: [
+                    { value: 'false', label: 'Не показывать' },
+                    { value: 'true', label: 'Показывать' }
+                ]
+            }
+        ],
+        buttons: [
+            { id: 'check-tenant', label: 'Показать', className: 'primary-button' }
+        ],
+        required_fields: []
+    },
+    'batch': {
+        fields: [
+            {
+                id: 'batch_file',
+                label: 'Файл CSV или XLSX (одна строка — один тенант)',
+                type: 'file',
+                accept: '.csv,.xlsx',
+                required: true
+            }
+        ],
+        buttons: [
+            { id: 'batch-template', label: 'Скачать шаблон', className: 'import-json-button' },
+            { id: 'check-tenant', label: 'Проверить', className: 'primary-button' },
+            { id: 'submit-form', label: 'Отправить в БД', className: 'danger-button' }
+        ],
+        required_fields: ['batch_file']
+    },
+    'approvals': {
+        fields: [
+            {
+                id: 'approval_status',
+                label: 'Статус',
+                type: 'select',
+                required: false,
+                options: [
+                    { value: 'pending', label: 'Ожидают согласования' },
+                    { value: 'approved', label: 'Согласованы, не выполнены' },
+                    { value: 'all', label: 'Все' }
+                ]
+            },
+            {
+                id: 'approval_comment',
+                label: 'Комментарий к решению',
+                type: 'textarea',
+                required: false,
+                placeholder: 'Обязателен при отклонении'
+            }
+        ],
+        buttons: [
+            { id: 'check-tenant', label: 'Показать', className: 'primary-button' }
+        ],
+        required_fields: []
     }
 };
 
@@ -287,6 +698,15 @@ const NON_SHARED_FIELDS = [
     'tenant_override',  // Tenant override name
     'users',           // Users list
     'buckets',         // Buckets list
+    'user_quotas',     // User quotas list
+    'quota_action',    // Quota action
+    'policy_grants',   // Bucket access grants
+    'policy_action',   // Bucket access action
+    'key_action',      // Access key action
+    'access_keys',     // Access key IDs
+    'key_output',      // Key creation output
+    'min_age_days',    // Access key age
+    'credentials_output', // User creation output
     'user',            // Single user
     'bucket',          // Single bucket
     'requester',       // Applicant
@@ -307,5 +727,12 @@ const NO_MEMORY_FIELDS = [
     'email_for_credentials', // Email
     'tenant_override',  // Tenant name
     'users',            // Users list
-    'buckets'          // Buckets list
+    'buckets',          // Buckets list
+    'user_quotas',      // User quotas list
+    'policy_grants',    // Bucket access grants
+    'access_keys',      // Access key IDs
+    'key_output',       // Key creation output
+    'credentials_output', // User creation output
+    'approval_comment', // Review comment
+    'batch_file'        // Batch file
 ]; 
\ No newline at end of file
@@ -239,6 +239,10 @@ function initializeFormSubmission() {
     initializeFormSubmissionHandler();
 }
 
+// The last search, so the pages and the sort order can be changed
+// without reading the filters again
+let lastSearch = null;
+
 async function handleSearch() {
     try {
         // Check for validation errors before proceeding
@@ -254,42 +258,69 @@ async function handleSearch() {
             return;
         }
 
-        const formData = new FormData();
-        const inputs = searchTab.querySelectorAll('input, select, textarea');
-        inputs.forEach(input => {
-            if (input.id && input.value) {
-                formData.append(input.id, input.value);
-            }
-        });
-
-        const searchData = {
-            segment: formData.get('segment'),
-            env: formData.get('env'),
-            ris_number: formData.get('ris_number'),
-            ris_name: formData.get('ris_name')?.toLowerCase(),
-            tenant: formData.get('tenant'),
-            bucket: formData.get('bucket'),
-            user: formData.get('user'),
-            cluster: formData.get('cluster')
+        const formData = collectFormFields(searchTab);
+        lastSearch = {
+            segment: formData.get('segment') || '',
+            env: formData.get('env') || '',
+            ris_number: formData.get('ris_number') || '',
+            ris_name: (formData.get('ris_name') || '').toLowerCase(),
+            tenant: formData.get('tenant') || '',
+            bucket: formData.get('bucket') || '',
+            user: formData.get('user') || '',
+            cluster: formData.get('cluster') || '',
+            owner: formData.get('owner') || '',
+            sd_num: formData.get('request_id_sd') || '',
+            srt_num: formData.get('request_id_srt') || '',
+            match: formData.get('match') || 'contains',
+            active: formData.get('active') || '',
+            date_from: formData.get('date_from') || '',
+            date_to: formData.get('date_to') || '',
+            page_size: parseInt(formData.get('page_size'), 10) || 50,
+            sort: lastSearch ? lastSearch.sort : 'done_date',
+            order: lastSearch ? lastSearch.order : 'desc',
+            page: 1
         };
-        
-        fetch('/zayavki/check', {
-            method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify(searchData)
-        })
-        .then(response => response.json())
-        .then(data => {
-            displaySearchResults(data.results);
-        })
-        .catch(error => {
-            displayResult(`Error performing search: ${error.message}`);
-        });
+        await loadSearchPage();
     } catch (error) {
         displayResult(`Error in handleSearch: ${error.message}`);
     }
 }
 
+async function loadSearchPage(changes = {}) {
+    if (!lastSearch) return;
+    Object.assign(lastSearch, changes);
+    try {
+        const response = await fetchJson('/zayavki/search', lastSearch);
+        displaySearchResults(await response.json(), {
+            page: page => loadSearchPage({ page }),
+            export: (format, delimiter) => downloadExport('/zayavki/export/search', { ...lastSearch, format, delimiter })
+                .catch(error => displayResult(`Ошибка экспорта: ${error.message}`)),
+            sort: column => loadSearchPage({
+                sort: column,
+                order: lastSearch.sort === column && lastSearch.order === 'asc' ? 'desc' : 'asc',
+                page: 1
+            })
+        });
+    } catch (error) {
+        displayResult(`Error performing search: ${error.message}`);
+    }
+}
+
+async function handleTextSearch() {
+    const input = document.querySelector('#search #search_text');
+    const query = input ? input.value.trim() : '';
+    if (!query) {
+        displayResult('Ошибка: Введите текст для поиска');
+        return;
+    }
+    try {
+        const response = await fetchJson('/zayavki/search/text', { query });
+        displayTextSearchResults(await response.json());
+    } catch (error) {
+        displayResult(`Error performing search: ${error.message}`);
+    }
+}
+
 async function handleClusterSelection(selectedCluster, formData, pushToDb) {
     try {
         // Check for validation errors before proceeding
@@ -342,12 +373,15 @@ async function handleTenantModCheck() {
     const tenantInput = tabPane.querySelector('#tenant');
     const usersInput = tabPane.querySelector('#users');
     const bucketsInput = tabPane.querySelector('#buckets');
+    const userQuotasInput = tabPane.querySelector('#user_quotas');
     
     const tenant = tenantInput ? tenantInput.value.trim() : '';
     const users = usersInput && usersInput.value ? 
         usersInput.value.trim().split('\n').filter(Boolean).map(u => u.trim()) : [];
     const buckets = bucketsInput && bucketsInput.value ? 
         bucketsInput.value.trim().split('\n').filter(Boolean).map(b => b.trim()) : [];
+    const userQuotas = userQuotasInput && userQuotasInput.value ? 
+        userQuotasInput.value.trim().split('\n').filter(Boolean).map(q => q.trim()) : [];
 
     if (!tenant) {
         displayResult('Ошибка: Необходимо указать имя тенанта');
@@ -363,6 +397,7 @@ async function handleTenantModCheck() {
             tenant,
             users,
             buckets,
+            user_quotas: userQuotas,
             mode: "create",  // Specify create mode
             request_id_srt: requestIdSrt
         });
@@ -414,9 +449,18 @@ function initializeForm() {
         initializeFieldSync();
 
         // Initialize specific tab handlers
+        initializeTenantOverview();
+        initializeReports();
+        initializeUsage();
         initializeUserBucketDel();
         initializeTenantMod();
         initializeBucketMod();
+        initializeBucketPolicy();
+        initializeAccessKeys();
+        initializeCredentials();
+        initializeRequests();
+        initializeBatch();
+        initializeApprovals();
     }
 
     // Initialize with the first tab (search)
@@ -483,7 +527,11 @@ function initializeUserBucketDel() {
             try {
                 const response = await fetchJson('/zayavki/deactivate-resources', resourceData);
                 const data = await response.json();
-                displayDeactivationResults(data);
+                if (data.approval) {
+                    displayApprovalPending(data.approval);
+                } else {
+                    displayDeactivationResults(data);
+                }
             } catch (error) {
                 displayResult(`Ошибка: ${error.message}`);
             }
@@ -545,6 +593,7 @@ function initializeTenantMod() {
             const srtInput = tabPane.querySelector('#request_id_srt');
             const usersInput = tabPane.querySelector('#users');
             const bucketsInput = tabPane.querySelector('#buckets');
+            const userQuotasInput = tabPane.querySelector('#user_quotas');
             const emailInput = tabPane.querySelector('#email_for_credentials');
 
             // Create form data
@@ -563,6 +612,7 @@ function initializeTenantMod() {
             if (srtInput) submitData.append('request_id_srt', srtInput.value);
             if (usersInput) submitData.append('users', usersInput.value);
             if (bucketsInput) submitData.append('buckets', bucketsInput.value);
+            if (userQuotasInput) submitData.append('user_quotas', userQuotasInput.value);
             if (emailInput) submitData.append('email_for_credentials', emailInput.value);
 
             try {
@@ -581,7 +631,8 @@ function initializeTenantMod() {
                             "Среда": clusterInfo.Среда,
                             "ЗБ": clusterInfo.ЗБ,
                             "tls_endpoint": clusterInfo.tls_endpoint,
-                            "mtls_endpoint": clusterInfo.mtls_endpoint
+                            "mtls_endpoint": clusterInfo.mtls_endpoint,
+                            "Шаблон": clusterInfo.Шаблон
                         },
                         pushToDb: true
                     })
@@ -624,10 +675,15 @@ function initializeBucketMod() {
 
                 const tenantInput = tabPane.querySelector('#tenant');
                 const bucketsInput = tabPane.querySelector('#buckets');
+                const userQuotasInput = tabPane.querySelector('#user_quotas');
+                const actionInput = tabPane.querySelector('#quota_action');
             
             const tenant = tenantInput ? tenantInput.value.trim() : '';
             const buckets = bucketsInput && bucketsInput.value ? 
                 bucketsInput.value.trim().split('\n').filter(Boolean).map(b => b.trim()) : [];
+            const users = userQuotasInput && userQuotasInput.value ? 
+                userQuotasInput.value.trim().split('\n').filter(Boolean).map(u => u.trim()) : [];
+            const quotaAction = actionInput ? actionInput.value : 'set';
 
             if (!tenant) {
                 displayResult('Ошибка: Необходимо указать имя тенанта');
@@ -638,7 +694,9 @@ function initializeBucketMod() {
                 const response = await fetchJson('/zayavki/check-tenant-resources', {
                     tenant,
                     buckets,
-                    mode: "quota"
+                    users,
+                    mode: "quota",
+                    quota_action: quotaAction
                 });
                 
                 const data = await response.json();
@@ -681,39 +739,600 @@ function initializeBucketMod() {
             }
 
             const bucketsInput = tabPane.querySelector('#buckets');
+            const userQuotasInput = tabPane.querySelector('#user_quotas');
+            const actionInput = tabPane.querySelector('#quota_action');
             const bucketsText = bucketsInput ? bucketsInput.value.trim() : '';
+            const userQuotasText = userQuotasInput ? userQuotasInput.value.trim() : '';
 
-            if (!bucketsText) {
-                displayResult('Ошибка: Необходимо указать бакеты');
+            if (!bucketsText && !userQuotasText) {
+                displayResult('Ошибка: Необходимо указать бакеты или пользователей');
                 return;
             }
 
+            // Parse "name | size | objects=N" lines into quota updates
+            const parseQuotaUpdates = text => text.split('\n')
+                .filter(Boolean)
+                .map(line => {
+                    const [name, size, ...options] = line.split('|').map(s => s.trim());
+                    const objects = options.find(o => o.toLowerCase().startsWith('objects='));
+                    return { name, size, max_objects: objects ? objects.split('=')[1].trim() : '' };
+                });
+
             try {
-                const bucketUpdates = bucketsText.split('\n')
-                    .filter(Boolean)
-                    .map(line => {
-                        const [name, size] = line.split('|').map(s => s.trim());
-                        return { name, size };
-                    });
-
-                const response = await fetchJson('/zayavki/update-bucket-quotas', {
+                const response = await fetchJson('/zayavki/update-quotas', {
                     tenant: lastCheckedTenantInfo.tenant,
-                    buckets: bucketUpdates
+                    buckets: bucketsText ? parseQuotaUpdates(bucketsText) : [],
+                    users: userQuotasText ? parseQuotaUpdates(userQuotasText) : [],
+                    action: actionInput ? actionInput.value : 'set',
+                    request_id_srt: (tabPane.querySelector('#request_id_srt')?.value || '').trim()
                 });
 
                 const result = await response.json();
-                displayBucketModUpdateResults(result);
+                if (result.approval) {
+                    displayApprovalPending(result.approval);
+                } else {
+                    displayBucketModUpdateResults(result);
+                }
+            } catch (error) {
+                displayResult(`Ошибка: ${error.message}`);
+            }
+        };
+    }
+}
+
+
+function initializeBucketPolicy() {
+    const tabPane = document.querySelector('#bucket-policy');
+    if (!tabPane) {
+        console.error('Could not find bucket policy tab');
+        return;
+    }
+
+    const checkButton = tabPane.querySelector('#check-tenant');
+    const submitButton = tabPane.querySelector('#submit-form');
+
+    // Collect the policy request from the tab fields
+    const collectPolicyRequest = pushToDb => {
+        const tenantInput = tabPane.querySelector('#tenant');
+        const srtInput = tabPane.querySelector('#request_id_srt');
+        const actionInput = tabPane.querySelector('#policy_action');
+        const grantsInput = tabPane.querySelector('#policy_grants');
+
+        return {
+            tenant: tenantInput ? tenantInput.value.trim() : '',
+            request_id_srt: srtInput ? srtInput.value.trim() : '',
+            action: actionInput ? actionInput.value : 'grant',
+            grants: grantsInput && grantsInput.value ?
+                grantsInput.value.trim().split('\n').filter(Boolean).map(g => g.trim()) : [],
+            push_to_db: pushToDb
+        };
+    };
+
+    const sendPolicyRequest = async (e, pushToDb) => {
+        e.preventDefault();
+        e.stopPropagation();
+
+        const request = collectPolicyRequest(pushToDb);
+        if (!request.tenant) {
+            displayResult('Ошибка: Необходимо указать имя тенанта');
+            return;
+        }
+        if (pushToDb && request.grants.length === 0) {
+            displayResult('Ошибка: Необходимо указать доступы');
+            return;
+        }
+
+        try {
+            const response = await fetchJson('/zayavki/bucket-policies', request);
+            const data = await response.json();
+            displayPolicyResults(data);
+        } catch (error) {
+            displayResult(`Ошибка: ${error.message}`);
+        }
+    };
+
+    if (checkButton) {
+        checkButton.onclick = e => sendPolicyRequest(e, false);
+    }
+    if (submitButton) {
+        submitButton.onclick = e => sendPolicyRequest(e, true);
+    }
+}
+function initializeAccessKeys() {
+    const tabPane = document.querySelector('#access-keys');
+    if (!tabPane) {
+        console.error('Could not find access keys tab');
+        return;
+    }
+
+    const checkButton = tabPane.querySelector('#check-tenant');
+    const submitButton = tabPane.querySelector('#submit-form');
+    const value = id => {
+        const input = tabPane.querySelector(`#${id}`);
+        return input ? input.value.trim() : '';
+    };
+
+    const sendKeyRequest = async (e, pushToDb) => {
+        e.preventDefault();
+        e.stopPropagation();
+
+        const action = value('key_action') || 'issue';
+        const request = {
+            action: action === 'revoke_all' ? 'revoke' : action,
+            revoke_all: action === 'revoke_all',
+            tenant: value('tenant'),
+            user: value('user'),
+            request_id_srt: value('request_id_srt'),
+            access_keys: value('access_keys'),
+            key_output: value('key_output'),
+            min_age_days: parseInt(value('min_age_days'), 10) || 0,
+            push_to_db: pushToDb
+        };
+
+        if (request.action === 'list' && pushToDb) {
+            displayResult('Ошибка: Для списка ключей используйте кнопку "Проверить"');
+            return;
+        }
+        if (request.action !== 'list' && (!request.tenant || !request.user)) {
+            displayResult('Ошибка: Необходимо указать тенант и пользователя');
+            return;
+        }
+        if (action === 'revoke' && !request.access_keys) {
+            displayResult('Ошибка: Укажите ключи для отзыва или выберите отзыв всех ключей');
+            return;
+        }
+        if (pushToDb && request.action !== 'revoke' && !request.key_output) {
+            displayResult('Ошибка: Вставьте вывод команды создания ключа');
+            return;
+        }
+
+        try {
+            const response = await fetchJson('/zayavki/access-keys', request);
+            const data = await response.json();
+            displayKeyResults(data);
+        } catch (error) {
+            displayResult(`Ошибка: ${error.message}`);
+        }
+    };
+
+    if (checkButton) {
+        checkButton.onclick = e => sendKeyRequest(e, false);
+    }
+    if (submitButton) {
+        submitButton.onclick = e => sendKeyRequest(e, true);
+    }
+}
+function initializeCredentials() {
+    const tabPane = document.querySelector('#credentials');
+    if (!tabPane) {
+        console.error('Could not find credentials tab');
+        return;
+    }
+
+    const submitButton = tabPane.querySelector('#submit-form');
+    const value = id => {
+        const input = tabPane.querySelector(`#${id}`);
+        return input ? input.value.trim() : '';
+    };
+
+    if (submitButton) {
+        submitButton.onclick = async (e) => {
+            e.preventDefault();
+            e.stopPropagation();
+
+            const request = {
+                tenant: value('tenant'),
+                email: value('email_for_credentials'),
+                request_id_sd: value('request_id_sd'),
+                request_id_srt: value('request_id_srt'),
+                key_output: value('credentials_output')
+            };
+
+            if (!request.tenant || !request.email || !request.key_output) {
+                displayResult('Ошибка: Необходимо указать тенант, email получателя и вывод команд');
+                return;
+            }
+
+            try {
+                const response = await fetchJson('/zayavki/encrypt-credentials', request);
+                const data = await response.json();
+                // Drop the plaintext keys from the form once they are encrypted
+                tabPane.querySelector('#credentials_output').value = '';
+                displayCredentialResults(data);
+            } catch (error) {
+                displayResult(`Ошибка: ${error.message}`);
+            }
+        };
+    }
+}
+
+function initializeTenantOverview() {
+    const tabPane = document.querySelector('#tenant-overview');
+    if (!tabPane) {
+        console.error('Could not find tenant overview tab');
+        return;
+    }
+
+    const showButton = tabPane.querySelector('#check-tenant');
+    if (showButton) {
+        showButton.onclick = e => {
+            e.preventDefault();
+            e.stopPropagation();
+            const input = tabPane.querySelector('#tenant');
+            loadTenantOverview(input ? input.value.trim() : '');
+        };
+    }
+}
+
+async function loadTenantOverview(tenant) {
+    if (!tenant) {
+        displayResult('Ошибка: Укажите имя тенанта');
+        return;
+    }
+    try {
+        const response = await fetch(`/zayavki/tenant?${new URLSearchParams({ tenant })}`);
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        displayTenantOverview(await response.json());
+    } catch (error) {
+        displayResult(`Ошибка: ${error.message}`);
+    }
+}
+
+// showTenantOverview opens the overview tab of a tenant from other tabs
+function showTenantOverview(tenant) {
+    const tabButton = document.querySelector('.tab-button[data-tab="tenant-overview"]');
+    if (tabButton) {
+        tabButton.click();
+    }
+    const input = document.querySelector('#tenant-overview #tenant');
+    if (input) {
+        input.value = tenant;
+    }
+    loadTenantOverview(tenant);
+}
+
+function initializeReports() {
+    const tabPane = document.querySelector('#reports');
+    if (!tabPane) {
+        console.error('Could not find reports tab');
+        return;
+    }
+
+    // reportQuery reads the filter of the tab as query parameters
+    const reportQuery = () => {
+        const fields = collectFormFields(tabPane);
+        return new URLSearchParams({
+            env: fields.get('env') || '',
+            segment: fields.get('segment') || '',
+            cluster: fields.get('cluster') || '',
+            ris_number: fields.get('ris_number') || '',
+            interval: fields.get('report_interval') || 'month',
+            top: fields.get('report_top') || '10'
+        });
+    };
+
+    const showButton = tabPane.querySelector('#check-tenant');
+    if (showButton) {
+        showButton.onclick = async e => {
+            e.preventDefault();
+            e.stopPropagation();
+            try {
+                const response = await fetch(`/zayavki/reports?${reportQuery()}`);
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                displayReports(await response.json());
             } catch (error) {
                 displayResult(`Ошибка: ${error.message}`);
             }
         };
     }
+
+    const exportButton = tabPane.querySelector('#export-report');
+    if (exportButton) {
+        exportButton.onclick = e => {
+            e.preventDefault();
+            e.stopPropagation();
+            downloadExport(`/zayavki/export/report?${reportQuery()}`)
+                .catch(error => displayResult(`Ошибка экспорта: ${error.message}`));
+        };
+    }
+}
+
+function initializeUsage() {
+    const tabPane = document.querySelector('#usage');
+    if (!tabPane) {
+        console.error('Could not find usage tab');
+        return;
+    }
+
+    const usageQuery = () => {
+        const fields = collectFormFields(tabPane);
+        return new URLSearchParams({
+            env: fields.get('env') || '',
+            segment: fields.get('segment') || '',
+            cluster: fields.get('cluster') || '',
+            ris_number: fields.get('ris_number') || '',
+            threshold: fields.get('usage_threshold') || '',
+            unused_days: fields.get('unused_days') || ''
+        });
+    };
+
+    const showReport = async () => {
+        const response = await fetch(`/zayavki/usage/report?${usageQuery()}`);
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        displayUsageReport(await response.json());
+    };
+
+    const bind = (id, handler) => {
+        const button = tabPane.querySelector(`#${id}`);
+        if (button) {
+            button.onclick = e => {
+                e.preventDefault();
+                e.stopPropagation();
+                handler().catch(error => displayResult(`Ошибка: ${error.message}`));
+            };
+        }
+    };
+
+    bind('check-tenant', showReport);
+    bind('export-usage', () => downloadExport(`/zayavki/export/usage?${usageQuery()}`));
+
+    bind('usage-import', async () => {
+        const fileInput = tabPane.querySelector('#usage_file');
+        if (!fileInput || fileInput.files.length === 0) {
+            throw new Error('Выберите файл с выводом radosgw-admin bucket stats');
+        }
+        const clusterInput = tabPane.querySelector('#usage_cluster');
+        const formData = new FormData();
+        formData.append('usage_file', fileInput.files[0]);
+        formData.append('cluster', clusterInput ? clusterInput.value.trim() : '');
+        const response = await fetch('/zayavki/usage/import', { method: 'POST', body: formData });
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        const result = await response.json();
+        displayResult(`Сохранена статистика ${result.saved} бакетов`);
+    });
+
+    bind('usage-collect', async () => {
+        const clusterInput = tabPane.querySelector('#usage_cluster');
+        const response = await fetchJson('/zayavki/usage/collect', { cluster: clusterInput ? clusterInput.value.trim() : '' });
+        const result = await response.json();
+        displayResult(result.clusters
+            .map(c => c.error ? `${c.cluster}: ошибка — ${c.error}` : `${c.cluster}: сохранена статистика ${c.saved} бакетов`)
+            .join('\n'));
+    });
+}
+
+function initializeRequests() {
+    const tabPane = document.querySelector('#requests');
+    if (!tabPane) {
+        console.error('Could not find requests tab');
+        return;
+    }
+
+    const showButton = tabPane.querySelector('#check-tenant');
+    const value = id => {
+        const input = tabPane.querySelector(`#${id}`);
+        return input ? input.value : '';
+    };
+
+    if (showButton) {
+        showButton.onclick = e => {
+            e.preventDefault();
+            e.stopPropagation();
+            loadRequests();
+        };
+    }
+
+    // Reload the list every time the tab is opened
+    const tabButton = document.querySelector('.tab-button[data-tab="requests"]');
+    if (tabButton) {
+        tabButton.addEventListener('click', () => loadRequests());
+    }
+
+    async function loadRequests() {
+        const params = new URLSearchParams({
+            all: String(value('requests_scope') === 'all'),
+            closed: value('requests_closed') || 'false'
+        });
+        try {
+            const response = await fetch(`/zayavki/requests?${params}`);
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            displayRequests(await response.json(), { resume: resumeRequest, setState: setRequestState });
+        } catch (error) {
+            displayResult(`Ошибка: ${error.message}`);
+        }
+    }
+
+    async function setRequestState(request, state) {
+        try {
+            await fetchJson('/zayavki/requests/state', { id: request.id, state });
+            await loadRequests();
+        } catch (error) {
+            displayResult(`Ошибка: ${error.message}`);
+        }
+    }
+}
+
+function initializeBatch() {
+    const tabPane = document.querySelector('#batch');
+    if (!tabPane) {
+        console.error('Could not find batch tab');
+        return;
+    }
+
+    const checkButton = tabPane.querySelector('#check-tenant');
+    const submitButton = tabPane.querySelector('#submit-form');
+    const templateButton = tabPane.querySelector('#batch-template');
+
+    if (checkButton) {
+        checkButton.onclick = e => {
+            e.preventDefault();
+            e.stopPropagation();
+            submitBatch(false);
+        };
+    }
+    if (submitButton) {
+        submitButton.onclick = e => {
+            e.preventDefault();
+            e.stopPropagation();
+            if (confirm('Внести все тенанты из файла в БД? Если хотя бы одна строка не пройдет проверку, ничего не будет сохранено.')) {
+                submitBatch(true);
+            }
+        };
+    }
+    if (templateButton) {
+        templateButton.onclick = e => {
+            e.preventDefault();
+            window.location.href = '/zayavki/batch-template';
+        };
+    }
+
+    async function submitBatch(pushToDb) {
+        const fileInput = tabPane.querySelector('#batch_file');
+        if (!fileInput || fileInput.files.length === 0) {
+            displayResult('Ошибка: Выберите файл CSV или XLSX');
+            return;
+        }
+
+        const formData = new FormData();
+        formData.append('batch_file', fileInput.files[0]);
+        formData.append('push_to_db', String(pushToDb));
+        try {
+            const response = await fetch('/zayavki/batch', { method: 'POST', body: formData });
+            if (!response.ok) {
+                const text = await response.text();
+                let message = text;
+                try {
+                    message = JSON.parse(text).error || text;
+                } catch (e) {
+                    // Plain text error
+                }
+                throw new Error(message);
+            }
+            displayBatchResults(await response.json());
+        } catch (error) {
+            displayResult(`Ошибка: ${error.message}`);
+        }
+    }
 }
 
+function initializeApprovals() {
+    const tabPane = document.querySelector('#approvals');
+    if (!tabPane) {
+        console.error('Could not find approvals tab');
+        return;
+    }
+
+    const showButton = tabPane.querySelector('#check-tenant');
+    const value = id => {
+        const input = tabPane.querySelector(`#${id}`);
+        return input ? input.value.trim() : '';
+    };
+
+    if (showButton) {
+        showButton.onclick = e => {
+            e.preventDefault();
+            e.stopPropagation();
+            loadApprovals();
+        };
+    }
 
+    const tabButton = document.querySelector('.tab-button[data-tab="approvals"]');
+    if (tabButton) {
+        tabButton.addEventListener('click', () => loadApprovals());
+    }
+
+    async function loadApprovals() {
+        const params = new URLSearchParams({ status: value('approval_status') || 'pending' });
+        try {
+            const response = await fetch(`/zayavki/approvals?${params}`);
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            displayApprovals(await response.json(), { review, execute });
+        } catch (error) {
+            displayResult(`Ошибка: ${error.message}`);
+        }
+    }
+
+    async function review(approval, approve) {
+        const comment = value('approval_comment');
+        if (!approve && !comment) {
+            displayResult('Ошибка: Укажите комментарий с причиной отклонения');
+            return;
+        }
+        try {
+            await fetchJson('/zayavki/approvals/review', { id: approval.id, approve, comment });
+            const commentInput = tabPane.querySelector('#approval_comment');
+            if (commentInput) commentInput.value = '';
+            await loadApprovals();
+        } catch (error) {
+            displayResult(`Ошибка: ${error.message}`);
+        }
+    }
+
+    async function execute(approval) {
+        if (!confirm(`Выполнить согласованную заявку #${approval.id}?`)) {
+            return;
+        }
+        try {
+            const response = await fetchJson('/zayavki/approvals/execute', { id: approval.id });
+            const data = await response.json();
+            switch (data.operation) {
+                case 'deactivate':
+                    displayDeactivationResults(data.result);
+                    break;
+                case 'quota_decrease':
+                case 'quota_toggle':
+                    displayBucketModUpdateResults(data.result);
+                    break;
+                case 'batch_create':
+                    displayBatchResults(data.result);
+                    break;
+                default:
+                    displayResult(data.result);
+            }
+        } catch (error) {
+            displayResult(`Ошибка: ${error.message}`);
+        }
+    }
+}
+
+// Fills the tab the request was started in with its saved form
+function resumeRequest(request) {
+    const form = request.form || {};
+    const tabId = form.tenant && form.create_tenant !== 'true' ? 'tenant-mod' : 'new-tenant';
+
+    const tabButton = document.querySelector(`.tab-button[data-tab="${tabId}"]`);
+    if (tabButton) {
+        tabButton.click();
+    }
+
+    const tabPane = document.getElementById(tabId);
+    if (!tabPane) return;
+    Object.entries(form).forEach(([id, value]) => {
+        const input = tabPane.querySelector(`#${id}`);
+        if (!input) return;
+        input.value = value;
+        fieldValues[tabId][id] = value;
+        input.dispatchEvent(new Event('change', { bubbles: true }));
+    });
+
+    displayResult(`Заявка ${request.srt_num !== '-' ? request.srt_num : request.sd_num} загружена в форму`);
+}
 
 // Export functions for use in other files
 window.handleClusterSelection = handleClusterSelection;
+window.showTenantOverview = showTenantOverview;
 window.initializeForm = initializeForm;
 window.initializeUserBucketDel = initializeUserBucketDel;
 window.initializeTenantMod = initializeTenantMod;
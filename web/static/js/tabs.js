@@ -1,10 +1,19 @@
 // Store field values for each tab separately
 const fieldValues = {
     'search': {},
+    'tenant-overview': {},
+    'reports': {},
+    'usage': {},
     'new-tenant': {},
     'tenant-mod': {},
     'user-bucket-del': {},
-    'bucket-mod': {}
+    'bucket-mod': {},
+    'bucket-policy': {},
+    'access-keys': {},
+    'credentials': {},
+    'requests': {},
+    'batch': {},
+    'approvals': {}
 };
 
 function initializeTabs() {
@@ -38,6 +38,9 @@ function createFormField(fieldConfig, required = false) {
     if (fieldConfig.placeholder) {
         input.placeholder = fieldConfig.placeholder;
     }
+    if (fieldConfig.accept) {
+        input.accept = fieldConfig.accept;
+    }
 
     fieldWrapper.appendChild(label);
     fieldWrapper.appendChild(input);
@@ -132,7 +135,11 @@ function createButton(buttonConfig) {
                     try {
                         const response = await fetchJson('/zayavki/deactivate-resources', resourceData);
                         const data = await response.json();
-                        displayDeactivationResults(data);
+                        if (data.approval) {
+                            displayApprovalPending(data.approval);
+                        } else {
+                            displayDeactivationResults(data);
+                        }
                     } catch (error) {
                         displayResult(`Ошибка: ${error.message}`);
                     }
@@ -148,6 +155,10 @@ function createButton(buttonConfig) {
             button.className = 'search-button';
             button.onclick = handleSearch;
             break;
+        case 'text-search':
+            button.className = 'search-button';
+            button.onclick = handleTextSearch;
+            break;
         case 'clear':
             button.className = 'clear-search-button';
             button.onclick = clearAllFields;
@@ -219,10 +230,19 @@ function createTabContent(tabId) {
 function getTabTitle(tabId) {
     const titles = {
         'search': 'Поиск',
+        'tenant-overview': 'Обзор тенанта: ресурсы, заявки и история',
+        'reports': 'Выделенный объем по РИС, тенантам, кластерам и зонам',
+        'usage': 'Фактическое использование бакетов',
         'new-tenant': 'Создание нового тенанта',
         'tenant-mod': 'Создание пользователя/бакета в существующем тенанте',
         'user-bucket-del': 'Удаление пользователя/бакета из существующего тенанта',
-        'bucket-mod': 'Изменение квоты бакета'
+        'bucket-mod': 'Изменение квот',
+        'bucket-policy': 'Доступ к бакетам',
+        'access-keys': 'Ключи доступа',
+        'credentials': 'Передача ключей в зашифрованном виде',
+        'requests': 'Заявки в работе',
+        'batch': 'Пакетное создание тенантов из CSV/XLSX',
+        'approvals': 'Согласование изменений в PROD и удалений'
     };
     return titles[tabId] || '';
 }
@@ -76,13 +76,35 @@ async function fetchJson(url, data) {
     return response;
 }
 
+// downloadExport saves a file exported by the server, POSTing data when it
+// is given
+async function downloadExport(url, data) {
+    const response = data ? await fetchJson(url, data) : await fetch(url);
+    if (!response.ok) {
+        throw new Error(await response.text());
+    }
+
+    const disposition = response.headers.get('Content-Disposition') || '';
+    const match = disposition.match(/filename="?([^"]+)"?/);
+    const link = document.createElement('a');
+    link.href = URL.createObjectURL(await response.blob());
+    link.download = match ? match[1] : 'export';
+    link.style.visibility = 'hidden';
+    document.body.appendChild(link);
+    link.click();
+    document.body.removeChild(link);
+    URL.revokeObjectURL(link.href);
+}
+
 function collectTenantResourcesData(tabPane) {
     const tenantInput = tabPane.querySelector('#tenant');
     const usersInput = tabPane.querySelector('#users');
     const bucketsInput = tabPane.querySelector('#buckets');
+    const srtInput = tabPane.querySelector('#request_id_srt');
 
     return {
         tenant: tenantInput ? tenantInput.value.trim() : '',
+        request_id_srt: srtInput ? srtInput.value.trim() : '',
         users: usersInput && usersInput.value ? 
             usersInput.value.trim().split('\n').filter(Boolean).map(u => u.trim()) : [],
         buckets: bucketsInput && bucketsInput.value ? 
@@ -152,6 +174,7 @@ window.createTable = createTable;
 window.createSection = createSection;
 window.collectFormFields = collectFormFields;
 window.fetchJson = fetchJson;
+window.downloadExport = downloadExport;
 window.collectTenantResourcesData = collectTenantResourcesData;
 window.trimFormData = trimFormData;
 window.processFormData = processFormData;
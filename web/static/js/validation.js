@@ -54,7 +54,7 @@ function validateBucketQuotaFields() {
     // Find all bucket quota textarea fields in the currently active tab
     const activeTab = document.querySelector('.tab-pane.active');
     if (activeTab) {
-        const bucketFields = activeTab.querySelectorAll('textarea[id="buckets"]');
+        const bucketFields = activeTab.querySelectorAll('textarea[id="buckets"], textarea[id="user_quotas"]');
         
         bucketFields.forEach(field => {
             // Remove existing event listeners to prevent duplicates
@@ -232,6 +232,15 @@ function validateTenantNameFormat(input) {
 }
 
 function validateBucketQuotaFormat(textarea) {
+    validateQuotaLines(textarea, 'имя-бакета', 'Имя бакета', validateBucketName);
+}
+
+function validateUserQuotaFormat(textarea) {
+    validateQuotaLines(textarea, 'пользователь', 'Имя пользователя', validateUsername);
+}
+
+// Validates "name | size | objects=N" lines; the objects option is optional
+function validateQuotaLines(textarea, nameHint, nameLabel, validateName) {
     const value = textarea.value.trim();
     if (!value) {
         clearValidationMessage(getOrCreateValidationDiv(textarea));
@@ -246,27 +255,52 @@ function validateBucketQuotaFormat(textarea) {
         if (!line) continue; // Skip empty lines
         
         const parts = line.split('|');
-        if (parts.length !== 2) {
-            errors.push(`Строка ${i + 1}: Неверный формат. Используйте "имя-бакета | размер"`);
+        if (parts.length < 2) {
+            errors.push(`Строка ${i + 1}: Неверный формат. Используйте "${nameHint} | размер | objects=N"`);
             continue;
         }
         
-        const bucketName = parts[0].trim();
+        const name = parts[0].trim();
         const quota = parts[1].trim();
         
-        if (!bucketName) {
-            errors.push(`Строка ${i + 1}: Имя бакета не может быть пустым`);
+        if (!name) {
+            errors.push(`Строка ${i + 1}: ${nameLabel} не может быть пустым`);
         } else {
-            // Validate bucket name format and characters
-            const bucketNameErrors = validateBucketName(bucketName, i + 1);
-            errors.push(...bucketNameErrors);
+            // Validate name format and characters
+            errors.push(...validateName(name, i + 1));
         }
         
+        // Quota toggling on the quota tab does not need a size
+        const activeTab = document.querySelector('.tab-pane.active');
+        const sizeOptional = activeTab && activeTab.id === 'bucket-mod' && quota === '-';
+        
         if (!quota) {
             errors.push(`Строка ${i + 1}: Размер квоты не может быть пустым`);
-        } else if (!isValidQuota(quota)) {
-            errors.push(`Строка ${i + 1}: Размер квоты "${quota}" должен быть положительным целым числом (в GB)`);
+        } else if (!sizeOptional && !isValidQuota(quota)) {
+            errors.push(`Строка ${i + 1}: Размер квоты "${quota}" должен быть положительным числом с единицей измерения (например, 500G, 1.5T, 200GiB)`);
         }
+        
+        parts.slice(2).map(p => p.trim()).filter(Boolean).forEach(option => {
+            const [key, value] = option.split('=').map(p => p.trim());
+            const name = key.toLowerCase();
+            if (name === 'objects') {
+                if (!/^[1-9]\d*$/.test(value || '')) {
+                    errors.push(`Строка ${i + 1}: Лимит объектов "${value || ''}" должен быть положительным целым числом`);
+                }
+            } else if (textarea.id === 'buckets' && name === 'versioning') {
+                // No value needed
+            } else if (textarea.id === 'buckets' && name === 'lock') {
+                if (!/^(GOVERNANCE|COMPLIANCE):[1-9]\d*$/i.test(value || '')) {
+                    errors.push(`Строка ${i + 1}: Блокировка "${value || ''}" должна быть в формате lock=GOVERNANCE:дни или lock=COMPLIANCE:дни`);
+                }
+            } else if (textarea.id === 'buckets' && name === 'expire') {
+                if (!/^[1-9]\d*$/.test(value || '')) {
+                    errors.push(`Строка ${i + 1}: Срок хранения "${value || ''}" должен быть положительным целым числом дней`);
+                }
+            } else {
+                errors.push(`Строка ${i + 1}: Неизвестный параметр "${option}"`);
+            }
+        });
     }
     
     if (errors.length > 0) {
@@ -555,9 +589,10 @@ function validateUsername(username, lineNumber) {
 }
 
 function isValidQuota(quota) {
-    // Must be a positive integer only (no units, no decimals)
-    const pattern = /^[1-9]\d*$/;
-    return pattern.test(quota.trim());
+    // A positive number with an optional unit: 500G, 1.5T, 200GiB (plain numbers are GB)
+    const pattern = /^(\d+(\.\d+)?)\s*([KMGTP](B|iB)?|B)?$/i;
+    const match = quota.trim().match(pattern);
+    return match !== null && parseFloat(match[1]) > 0;
 }
 
 // Check if there are any validation errors on the page
@@ -659,6 +694,8 @@ function triggerFieldValidation(input) {
             console.log('Using validateBucketQuotaFormat for other tabs');
             validateBucketQuotaFormat(input);
         }
+    } else if (fieldId === 'user_quotas') {
+        validateUserQuotaFormat(input);
     } else if (fieldId === 'users') {
         validateUsernameFormat(input);
     } else if (fieldId === 'tenant') {
@@ -672,6 +709,7 @@ window.initializeFieldValidation = initializeFieldValidation;
 window.hasValidationErrors = hasValidationErrors;
 window.hasValidationErrorsInCurrentTab = hasValidationErrorsInCurrentTab;
 window.validateBucketQuotaFormat = validateBucketQuotaFormat;
+window.validateUserQuotaFormat = validateUserQuotaFormat;
 window.validateBucketNamesOnly = validateBucketNamesOnly;
 window.isValidQuota = isValidQuota;
 window.reinitializeValidation = reinitializeValidation;
@@ -725,6 +763,11 @@ function reinitializeValidationAfterImport() {
                 validateUsernameFormat(usersInput);
             }
             
+            const userQuotasInput = activeTab.querySelector('textarea[id="user_quotas"]');
+            if (userQuotasInput && userQuotasInput.value) {
+                validateUserQuotaFormat(userQuotasInput);
+            }
+            
             // Validate tenant field if it exists
             const tenantInput = activeTab.querySelector('#tenant');
             if (tenantInput && tenantInput.value) {